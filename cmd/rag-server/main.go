@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -10,18 +11,10 @@ import (
 
 	_ "github.com/Guru2308/rag-code/docs"
 	"github.com/Guru2308/rag-code/internal/api"
+	"github.com/Guru2308/rag-code/internal/app"
 	"github.com/Guru2308/rag-code/internal/config"
-	"github.com/Guru2308/rag-code/internal/embeddings"
-	"github.com/Guru2308/rag-code/internal/graph"
-	"github.com/Guru2308/rag-code/internal/hierarchy"
 	"github.com/Guru2308/rag-code/internal/indexing"
-	"github.com/Guru2308/rag-code/internal/llm"
 	"github.com/Guru2308/rag-code/internal/logger"
-	"github.com/Guru2308/rag-code/internal/prompt"
-	"github.com/Guru2308/rag-code/internal/reranker"
-	"github.com/Guru2308/rag-code/internal/retrieval"
-	"github.com/Guru2308/rag-code/internal/vectorstore"
-	"github.com/redis/go-redis/v9"
 )
 
 // @title           RAG Code API
@@ -40,6 +33,10 @@ import (
 // @BasePath  /api
 
 func main() {
+	verifyPath := flag.String("verify", "", "verify the index for this directory against the stores, print a JSON report and exit")
+	repair := flag.Bool("repair", false, "with -verify, remove orphans and re-index missing or stale files")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -67,84 +64,23 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Initialize services
+	// Initialize services (steps 1-7a: embeddings, LLM, stores, retrieval, indexing, prompts)
 	logger.Info("Initializing services")
-
-	// 1. Ollama Embedding Service (configurable parallelism)
-	embedder := embeddings.NewOllamaEmbedderWithConfig(
-		cfg.OllamaURL,
-		cfg.EmbeddingModel,
-		cfg.EmbeddingWorkers,
-		cfg.MaxConcurrentEmbeddings,
-	)
-
-	// 2. Ollama LLM Service
-	llmClient := llm.NewOllamaLLM(cfg.OllamaURL, cfg.LLMModel)
-
-	// 3. Qdrant Vector Store
-	qStore, err := vectorstore.NewQdrantStore(cfg.VectorStoreURL, cfg.CollectionName)
+	services, err := app.New(ctx, cfg)
 	if err != nil {
-		logger.Error("Failed to initialize Qdrant store", "error", err)
+		logger.Error("Failed to initialize services", "error", err)
 		os.Exit(1)
 	}
-
-	// 4. Redis Inverted Index (for BM25)
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisURL,
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
-	})
-	redisIndex := retrieval.NewRedisIndex(redisClient, "rag:")
-
-	// 5. Hybrid Retrieval Components
-	preprocessor := retrieval.NewQueryPreprocessor()
-	bm25Scorer := retrieval.NewBM25Scorer(cfg.BM25K1, cfg.BM25B, redisIndex)
-
-	fusionConfig := retrieval.FusionConfig{
-		Strategy:     retrieval.FusionRRF, // Defaulting to RRF for now
-		VectorWeight: cfg.HybridVectorWeight,
-		RRFConstant:  60,
-	}
-
-	// 5a. Phase 4: Dependency Graph and Expander
-	depGraph := graph.NewGraph()
-	expander := retrieval.NewContextExpander(depGraph, qStore)
-
-	// 5b. Phase 5 & 6: Reranker and Hierarchy
-	baseReranker := reranker.NewHeuristicReranker()
-	var reRanker reranker.Reranker = baseReranker
-	if cfg.UseMMR {
-		reRanker = reranker.NewMMRReranker(baseReranker, float32(cfg.MMRLambda))
-		logger.Info("MMR reranking enabled", "lambda", cfg.MMRLambda)
-	}
-	hierFilter := hierarchy.NewHierarchicalFilter(3)
-
-	// 6. Retrieval Engine
-	retr := retrieval.NewRetriever(embedder, qStore, redisIndex, bm25Scorer, preprocessor, expander, reRanker, hierFilter, fusionConfig)
-
-	// 7. Indexing Pipeline
-	parser := indexing.NewMultiParser()
-	chunker := indexing.NewSemanticChunker(cfg.MaxChunkSize, cfg.ChunkOverlap)
-	indexer := indexing.NewIndexer(parser, chunker, embedder, qStore, retr, depGraph, cfg.NumWorkers)
-
-	// Initialize Collection in Qdrant
-	// all-minilm has 384 dimensions
-	initCtx, initCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer initCancel()
-	if err := qStore.InitCollection(initCtx, 384); err != nil {
-		logger.Error("Failed to initialize Qdrant collection", "error", err)
-		os.Exit(1)
-	}
-
-	// 7a. Prompt Generator (professional code assistant + reviewer by default)
-	prompter, err := prompt.NewTemplateGenerator(
-		prompt.TemplateByName(cfg.PromptTemplate),
-		prompt.WithMaxTokens(4096),
-		prompt.WithModel(cfg.LLMModel),
-	)
-	if err != nil {
-		logger.Error("Failed to initialize prompt generator", "error", err)
-		os.Exit(1)
+	defer services.Close()
+	indexer := services.Indexer
+
+	// One-off verification (fsck) instead of serving
+	if *verifyPath != "" {
+		code := runVerify(ctx, indexer, *verifyPath, *repair)
+		// os.Exit skips deferred calls, so release the services first
+		services.Close()
+		stop()
+		os.Exit(code)
 	}
 
 	// 7b. File Watcher — auto re-index on file changes
@@ -158,6 +94,9 @@ func main() {
 		case indexing.FileEventDelete:
 			logger.Info("File deleted — removing from index", "path", path)
 			return indexer.DeleteFile(watchCtx, path)
+		case indexing.FileEventRescan:
+			logger.Info("Watch root needs rescan — re-indexing", "path", path)
+			return indexer.IndexDirectory(watchCtx, path)
 		default: // create or modify
 			logger.Info("File changed — re-indexing", "path", path, "event", event)
 			return indexer.IndexFile(watchCtx, path)
@@ -167,11 +106,21 @@ func main() {
 		logger.Error("Failed to create file watcher", "error", err)
 		os.Exit(1)
 	}
+	watcher.SetFileFilter(services.FileFilter)
+	if err := watcher.SetWatchMode(indexing.WatchMode(cfg.WatchMode), cfg.WatchPollInterval); err != nil {
+		logger.Warn("Invalid watch mode, using auto", "mode", cfg.WatchMode, "error", err)
+		_ = watcher.SetWatchMode(indexing.WatchModeAuto, cfg.WatchPollInterval)
+	}
+	// Bursts (git checkout, pull, rebase) become one tracked batch job
+	watcher.SetBatchHandler(func(watchCtx context.Context, root string, changes []indexing.FileChange) error {
+		_, err := indexer.IndexBatch(watchCtx, root, changes)
+		return err
+	}, cfg.WatchBurstThreshold)
 
 	if err := watcher.AddPath(watchPath); err != nil {
 		logger.Warn("Failed to add watch path — auto-indexing disabled", "path", watchPath, "error", err)
 	} else {
-		logger.Info("File watcher started", "path", watchPath, "debounce", "500ms")
+		logger.Info("File watcher started", "path", watchPath, "debounce", "500ms", "mode", cfg.WatchMode)
 		go func() {
 			if err := watcher.Start(ctx); err != nil {
 				logger.Error("File watcher stopped with error", "error", err)
@@ -180,7 +129,20 @@ func main() {
 	}
 
 	// 8. API Server
-	srv := api.NewServer(cfg.ServerPort, indexer, retr, llmClient, prompter)
+	srv := api.NewServer(cfg.ServerPort, indexer, services.Retriever, services.LLM, services.Prompter)
+	if services.Feedback != nil {
+		srv.SetFeedbackStore(services.Feedback)
+	}
+	if services.Chat != nil {
+		srv.SetChat(services.Chat)
+	}
+	srv.SetAgent(services.Agent)
+	if services.QueryLog != nil {
+		srv.SetQueryLog(services.QueryLog)
+	}
+	if services.Trigram != nil {
+		srv.SetGrep(services.Trigram)
+	}
 
 	logger.Info("All services initialized successfully")
 
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/Guru2308/rag-code/internal/indexing"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// runVerify verifies the index for path, prints the report as JSON and
+// returns the process exit code: 0 when the index is consistent (or was fully
+// repaired), 1 when issues remain and 2 when verification failed. The graph
+// lives in the server process, so it is not checked here.
+func runVerify(ctx context.Context, indexer *indexing.Indexer, path string, repair bool) int {
+	report, err := indexer.Verify(ctx, path, indexing.VerifyOptions{Repair: repair, SkipGraph: true})
+	if err != nil {
+		logger.Error("Verify failed", "path", path, "error", err)
+		return 2
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		logger.Error("Failed to write verify report", "error", err)
+		return 2
+	}
+
+	if len(report.Issues) == 0 || (report.Repaired && len(report.RepairErrors) == 0) {
+		return 0
+	}
+	return 1
+}
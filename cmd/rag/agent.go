@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+// runAgent asks the agent a question, printing each tool call as it
+// completes and then the answer with the chunks the tools returned
+func runAgent(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	steps := flags.Int("steps", 0, "tool calls allowed (default and maximum set by the server)")
+	tokens := flags.Int("tokens", 0, "model tokens allowed (default and maximum set by the server)")
+	asJSON := flags.Bool("json", false, "print the answer and tool trace as JSON once complete")
+	if !parseCommand("agent", flags, args, 1, 1) {
+		return exitError
+	}
+
+	onStep := func(step domain.AgentStep) {
+		if *asJSON {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "[%d] %s %s", step.Step, step.Tool, step.Args)
+		if step.Error != "" {
+			fmt.Fprintf(os.Stderr, ": error: %s\n", step.Error)
+			return
+		}
+		lines := strings.Count(strings.TrimRight(step.Observation, "\n"), "\n") + 1
+		fmt.Fprintf(os.Stderr, ": %d lines, %d chunks (%.0fms)\n", lines, len(step.ChunkIDs), step.DurationMS)
+	}
+
+	req := domain.AgentRequest{Question: flags.Arg(0), MaxSteps: *steps, MaxTokens: *tokens}
+	resp, err := b.Agent(ctx, req, onStep)
+	if err != nil {
+		return fail(err)
+	}
+	if *asJSON {
+		return printJSON(resp)
+	}
+
+	if resp.StoppedBy != domain.AgentStopAnswer {
+		fmt.Fprintf(os.Stderr, "Stopped by the %s budget after %d tool calls.\n", strings.ReplaceAll(resp.StoppedBy, "max_", ""), len(resp.Steps))
+	}
+	fmt.Println()
+	fmt.Println(resp.Answer)
+	printCitations(os.Stdout, resp.Results)
+	printQueryID(os.Stdout, resp.QueryID)
+	return exitOK
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/app"
+	"github.com/Guru2308/rag-code/internal/client"
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/eval"
+	"github.com/Guru2308/rag-code/internal/feedback"
+	"github.com/Guru2308/rag-code/internal/indexing"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/querylog"
+)
+
+// backend is what the subcommands run against: a server over HTTP or the
+// services wired in-process
+type backend interface {
+	// Index indexes path and returns a line describing what happened
+	Index(ctx context.Context, path string) (string, error)
+	Query(ctx context.Context, query domain.SearchQuery, onResults func(queryID string, results []*domain.SearchResult), onToken func(string) error) error
+	Search(ctx context.Context, req domain.SearchRequest) (*domain.SearchPage, error)
+	Grep(ctx context.Context, req domain.GrepRequest) (*domain.GrepResponse, error)
+	Chat(ctx context.Context, req domain.ChatRequest, onResults func(*domain.ChatResponse), onToken func(string) error) error
+	Agent(ctx context.Context, req domain.AgentRequest, onStep func(domain.AgentStep)) (*domain.AgentResponse, error)
+	// Retrieve returns the ranked results for a query, as eval.Retriever
+	Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error)
+	Status(ctx context.Context) (*client.Status, error)
+	Jobs(ctx context.Context) ([]*domain.IndexingJob, error)
+	Job(ctx context.Context, id string) (*domain.IndexingJob, error)
+	Explain(ctx context.Context, chunkID string) (*domain.ChunkExplanation, error)
+	Verify(ctx context.Context, path string, repair bool) (*domain.VerifyReport, error)
+	Feedback(ctx context.Context, req domain.FeedbackRequest) (*domain.Feedback, error)
+	ExportFeedback(ctx context.Context, name string) (*eval.GoldenSet, error)
+	Analytics(ctx context.Context, since time.Duration, limit int) (*querylog.Summary, error)
+	Close()
+}
+
+// remoteBackend forwards every call to a running rag-server
+type remoteBackend struct {
+	*client.Client
+	url string
+}
+
+func newRemoteBackend(url string) *remoteBackend {
+	return &remoteBackend{Client: client.New(url), url: url}
+}
+
+func (b *remoteBackend) Index(ctx context.Context, path string) (string, error) {
+	if err := b.Client.Index(ctx, path); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Indexing of %s started on %s (follow progress with `rag status`)", path, b.url), nil
+}
+
+func (b *remoteBackend) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
+	page, err := b.Client.Search(ctx, domain.SearchRequest{SearchQuery: query})
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+func (b *remoteBackend) Close() {}
+
+// localBackend runs the indexer and retriever in this process, for one-off
+// use in scripts and CI. Jobs and the dependency graph only cover what this
+// process did.
+type localBackend struct {
+	app *app.App
+}
+
+// errNoRedis is returned for chat and feedback, which are kept in Redis,
+// when Redis is not configured
+var errNoRedis = errors.ValidationError("chat and feedback need Redis (set REDIS_URL)")
+
+func (b *localBackend) Index(ctx context.Context, path string) (string, error) {
+	if err := b.app.Indexer.Index(ctx, path); err != nil {
+		return "", err
+	}
+	st := b.app.Indexer.Status()
+	return fmt.Sprintf("Indexed %s: %d files indexed, %d skipped, %d failed, %d chunks in %dms",
+		path, st.FilesIndexed, st.FilesSkipped, st.FilesErrored, st.ChunksCreated, st.DurationMS), nil
+}
+
+func (b *localBackend) Query(ctx context.Context, query domain.SearchQuery, onResults func(queryID string, results []*domain.SearchResult), onToken func(string) error) error {
+	results, err := b.app.Retriever.Retrieve(ctx, query)
+	if err != nil {
+		return err
+	}
+	if onResults != nil {
+		onResults(b.recordTrace(ctx, "query", query, results), results)
+	}
+
+	promptStr, err := b.app.Prompter.Generate(ctx, query.Query, results)
+	if err != nil {
+		return err
+	}
+	return b.app.LLM.StreamGenerate(ctx, []llm.ChatMessage{{Role: "user", Content: promptStr}}, onToken)
+}
+
+// Chat keeps the session in Redis like the server, so a chat started
+// standalone can be continued against a server and the other way round
+func (b *localBackend) Chat(ctx context.Context, req domain.ChatRequest, onResults func(*domain.ChatResponse), onToken func(string) error) error {
+	if b.app.Chat == nil {
+		return errNoRedis
+	}
+	turn, err := b.app.Chat.Prepare(ctx, req)
+	if err != nil {
+		return err
+	}
+	queryID := b.recordTrace(ctx, "chat", turn.Query, turn.Results)
+	if onResults != nil {
+		onResults(&domain.ChatResponse{
+			SessionID: turn.Session.ID,
+			QueryID:   queryID,
+			Query:     turn.Query.Query,
+			Results:   turn.Results,
+			Reused:    turn.Reused,
+		})
+	}
+
+	var answer strings.Builder
+	err = b.app.LLM.StreamGenerate(ctx, turn.Messages, func(token string) error {
+		answer.WriteString(token)
+		return onToken(token)
+	})
+	if err != nil {
+		return err
+	}
+	return b.app.Chat.Finish(ctx, turn, queryID, answer.String())
+}
+
+// Agent runs the agent in-process. The dependency graph of a one-off
+// process is empty, so callers and callees find nothing.
+func (b *localBackend) Agent(ctx context.Context, req domain.AgentRequest, onStep func(domain.AgentStep)) (*domain.AgentResponse, error) {
+	resp, err := b.app.Agent.Run(ctx, req, onStep)
+	if err != nil {
+		return nil, err
+	}
+	resp.QueryID = b.recordTrace(ctx, "agent", domain.SearchQuery{Query: req.Question}, resp.Results)
+	return resp, nil
+}
+
+func (b *localBackend) Search(ctx context.Context, req domain.SearchRequest) (*domain.SearchPage, error) {
+	page, err := b.app.Retriever.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	results := page.Results
+	for _, g := range page.Groups {
+		results = append(results, g.Results...)
+	}
+	page.QueryID = b.recordTrace(ctx, "search", req.SearchQuery, results)
+	return page, nil
+}
+
+func (b *localBackend) Grep(ctx context.Context, req domain.GrepRequest) (*domain.GrepResponse, error) {
+	if b.app.Trigram == nil {
+		return nil, errors.ValidationError("the trigram index is off (TRIGRAM_INDEX=false)")
+	}
+	return b.app.Trigram.Grep(ctx, req)
+}
+
+// recordTrace assigns the query an ID and stores its trace in Redis like the
+// server does, so feedback can be given on standalone queries too. Standalone
+// queries are not written to the query log.
+func (b *localBackend) recordTrace(ctx context.Context, kind string, query domain.SearchQuery, results []*domain.SearchResult) string {
+	id := querylog.NewID()
+	if b.app.Feedback == nil {
+		return id
+	}
+	if err := b.app.Feedback.SaveTrace(ctx, feedback.NewTrace(id, kind, query, results)); err != nil {
+		logger.Warn("Failed to record query trace", "query_id", id, "error", err)
+	}
+	return id
+}
+
+func (b *localBackend) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
+	return b.app.Retriever.Retrieve(ctx, query)
+}
+
+func (b *localBackend) Status(ctx context.Context) (*client.Status, error) {
+	st := b.app.Indexer.Status()
+	return &client.Status{Status: "standalone", Index: &st}, nil
+}
+
+func (b *localBackend) Jobs(ctx context.Context) ([]*domain.IndexingJob, error) {
+	return b.app.Indexer.ListJobs(), nil
+}
+
+func (b *localBackend) Job(ctx context.Context, id string) (*domain.IndexingJob, error) {
+	return b.app.Indexer.GetJob(id)
+}
+
+func (b *localBackend) Explain(ctx context.Context, chunkID string) (*domain.ChunkExplanation, error) {
+	return b.app.Retriever.Explain(ctx, chunkID)
+}
+
+func (b *localBackend) Verify(ctx context.Context, path string, repair bool) (*domain.VerifyReport, error) {
+	// The graph of a one-off process is empty, so it is not compared
+	return b.app.Indexer.Verify(ctx, path, indexing.VerifyOptions{Repair: repair, SkipGraph: true})
+}
+
+func (b *localBackend) Feedback(ctx context.Context, req domain.FeedbackRequest) (*domain.Feedback, error) {
+	if b.app.Feedback == nil {
+		return nil, errNoRedis
+	}
+	return b.app.Feedback.Submit(ctx, req)
+}
+
+func (b *localBackend) ExportFeedback(ctx context.Context, name string) (*eval.GoldenSet, error) {
+	if b.app.Feedback == nil {
+		return nil, errNoRedis
+	}
+	entries, err := b.app.Feedback.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return feedback.GoldenSet(name, entries), nil
+}
+
+func (b *localBackend) Analytics(ctx context.Context, since time.Duration, limit int) (*querylog.Summary, error) {
+	if b.app.QueryLog == nil {
+		return nil, errors.ValidationError("query logging is off (QUERY_LOG=off)")
+	}
+	return b.app.QueryLog.Summarize(ctx, querylog.SummaryOptions{Since: time.Now().Add(-since), Limit: limit})
+}
+
+func (b *localBackend) Close() {
+	b.app.Close()
+}
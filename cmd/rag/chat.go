@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+// runChat sends one message, or with no message reads one per line from
+// stdin, keeping the conversation in a server-side session
+func runChat(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("chat", flag.ContinueOnError)
+	session := flags.String("session", "", "continue this chat session")
+	k := flags.Int("k", 5, "number of chunks to retrieve as context")
+	sources := flags.Bool("sources", false, "list the sources of each answer")
+	if !parseCommand("chat", flags, args, 0, 1) {
+		return exitError
+	}
+
+	send := func(message string) error {
+		var resp *domain.ChatResponse
+		printed := false
+		err := b.Chat(ctx, domain.ChatRequest{SessionID: *session, Message: message, MaxResults: *k},
+			func(r *domain.ChatResponse) { resp = r },
+			func(token string) error {
+				printed = true
+				fmt.Print(token)
+				return nil
+			})
+		if printed {
+			fmt.Println()
+		}
+		if err != nil {
+			return err
+		}
+		*session = resp.SessionID
+		if *sources {
+			printCitations(os.Stdout, resp.Results)
+		}
+		return nil
+	}
+
+	if flags.NArg() == 1 {
+		if err := send(flags.Arg(0)); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("\nSession: %s (continue with `rag chat -session %s`)\n", *session, *session)
+		return exitOK
+	}
+
+	fmt.Fprintln(os.Stderr, "Ask about the codebase; an empty line or Ctrl-D ends the chat.")
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "> ")
+		if !in.Scan() {
+			break
+		}
+		message := strings.TrimSpace(in.Text())
+		if message == "" {
+			break
+		}
+		if err := send(message); err != nil {
+			return fail(err)
+		}
+		fmt.Println()
+	}
+	if *session != "" {
+		fmt.Fprintf(os.Stderr, "Session: %s\n", *session)
+	}
+	return exitOK
+}
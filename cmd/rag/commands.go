@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/retrieval"
+)
+
+// parseCommand parses a subcommand's flags and checks it got between minArgs
+// and maxArgs positional arguments. It returns false after printing usage on
+// error.
+func parseCommand(name string, flags *flag.FlagSet, args []string, minArgs, maxArgs int) bool {
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rag %s\n", usages[name])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+	if flags.NArg() < minArgs || flags.NArg() > maxArgs {
+		flags.Usage()
+		return false
+	}
+	return true
+}
+
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "rag: %v\n", err)
+	return exitError
+}
+
+func runIndex(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("index", flag.ContinueOnError)
+	if !parseCommand("index", flags, args, 1, 1) {
+		return exitError
+	}
+
+	msg, err := b.Index(ctx, flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Println(msg)
+	return exitOK
+}
+
+func runQuery(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	k := flags.Int("k", 5, "number of chunks to retrieve as context")
+	hyde := flags.Bool("hyde", false, "also search with code the LLM sketches as an answer")
+	multi := flags.Int("multi", 0, "also search with this many LLM paraphrases of the question")
+	asJSON := flags.Bool("json", false, "print the answer and results as JSON once complete")
+	if !parseCommand("query", flags, args, 1, 1) {
+		return exitError
+	}
+
+	var queryID string
+	var results []*domain.SearchResult
+	var answer strings.Builder
+	onToken := func(token string) error {
+		answer.WriteString(token)
+		if !*asJSON {
+			fmt.Print(token)
+		}
+		return nil
+	}
+
+	query := domain.SearchQuery{Query: flags.Arg(0), MaxResults: *k, HyDE: *hyde, MultiQuery: *multi}
+	err := b.Query(ctx, query, func(id string, r []*domain.SearchResult) { queryID, results = id, r }, onToken)
+	if err != nil {
+		if !*asJSON && answer.Len() > 0 {
+			fmt.Println()
+		}
+		return fail(err)
+	}
+
+	if *asJSON {
+		return printJSON(map[string]any{"query_id": queryID, "response": answer.String(), "results": results})
+	}
+	fmt.Println()
+	printCitations(os.Stdout, results)
+	printQueryID(os.Stdout, queryID)
+	return exitOK
+}
+
+func runSearch(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	k := flags.Int("k", 10, "results (or files with -group) per page")
+	language := flags.String("lang", "", "only return chunks in this language")
+	filePath := flags.String("file", "", "only return chunks from this file")
+	cursor := flags.String("cursor", "", "continue from a previous page")
+	group := flags.Bool("group", false, "group results by file")
+	noRerank := flags.Bool("no-rerank", false, "skip reranking")
+	noExpand := flags.Bool("no-expand", false, "skip dependency graph expansion")
+	noHierarchy := flags.Bool("no-hierarchy", false, "skip the per-file result cap")
+	hyde := flags.Bool("hyde", false, "also search with code the LLM sketches as an answer")
+	multi := flags.Int("multi", 0, "also search with this many LLM paraphrases of the query")
+	debug := flags.Bool("debug", false, "explain each result's ranking (implies -json)")
+	asJSON := flags.Bool("json", false, "print the page as JSON")
+	if !parseCommand("search", flags, args, 1, 1) {
+		return exitError
+	}
+
+	page, err := b.Search(ctx, domain.SearchRequest{
+		SearchQuery: domain.SearchQuery{
+			Query:         flags.Arg(0),
+			MaxResults:    *k,
+			Language:      *language,
+			FilePath:      *filePath,
+			SkipRerank:    *noRerank,
+			SkipExpansion: *noExpand,
+			SkipHierarchy: *noHierarchy,
+			HyDE:          *hyde,
+			MultiQuery:    *multi,
+			Debug:         *debug,
+		},
+		Cursor:      *cursor,
+		GroupByFile: *group,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	if *asJSON || *debug {
+		return printJSON(page)
+	}
+	printSearchPage(os.Stdout, page)
+	return exitOK
+}
+
+func runGrep(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("grep", flag.ContinueOnError)
+	regex := flags.Bool("E", false, "treat the pattern as a regular expression")
+	caseSensitive := flags.Bool("case", false, "match case")
+	paths := flags.String("path", "", "comma-separated globs of paths to search; prefix with ! to exclude")
+	k := flags.Int("k", retrieval.DefaultGrepResults, "maximum matching lines")
+	asJSON := flags.Bool("json", false, "print matches as JSON")
+	if !parseCommand("grep", flags, args, 1, 1) {
+		return exitError
+	}
+
+	resp, err := b.Grep(ctx, domain.GrepRequest{
+		Pattern:       flags.Arg(0),
+		Regex:         *regex,
+		CaseSensitive: *caseSensitive,
+		Paths:         splitList(*paths),
+		MaxResults:    *k,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	if *asJSON {
+		return printJSON(resp)
+	}
+	printGrep(os.Stdout, resp)
+	return exitOK
+}
+
+func runStatus(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print status as JSON")
+	if !parseCommand("status", flags, args, 0, 0) {
+		return exitError
+	}
+
+	status, err := b.Status(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *asJSON {
+		return printJSON(status)
+	}
+	printStatus(os.Stdout, status)
+	return exitOK
+}
+
+func runJobs(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print jobs as JSON")
+	if !parseCommand("jobs", flags, args, 0, 1) {
+		return exitError
+	}
+
+	var jobs []*domain.IndexingJob
+	if flags.NArg() == 1 {
+		job, err := b.Job(ctx, flags.Arg(0))
+		if err != nil {
+			return fail(err)
+		}
+		if *asJSON {
+			return printJSON(job)
+		}
+		jobs = []*domain.IndexingJob{job}
+	} else {
+		var err error
+		if jobs, err = b.Jobs(ctx); err != nil {
+			return fail(err)
+		}
+		if *asJSON {
+			return printJSON(map[string]any{"jobs": jobs})
+		}
+	}
+	printJobs(os.Stdout, jobs)
+	return exitOK
+}
+
+func runExplain(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the chunk as JSON")
+	if !parseCommand("explain", flags, args, 1, 1) {
+		return exitError
+	}
+
+	explanation, err := b.Explain(ctx, flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+
+	if *asJSON {
+		return printJSON(explanation)
+	}
+	printExplanation(os.Stdout, explanation)
+	return exitOK
+}
+
+func runVerify(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "remove orphans and re-index missing or stale files")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if !parseCommand("verify", flags, args, 1, 1) {
+		return exitError
+	}
+
+	report, err := b.Verify(ctx, flags.Arg(0), *repair)
+	if err != nil {
+		return fail(err)
+	}
+
+	code := exitIssues
+	if len(report.Issues) == 0 || (report.Repaired && len(report.RepairErrors) == 0) {
+		code = exitOK
+	}
+	if *asJSON {
+		if c := printJSON(report); c != exitOK {
+			return c
+		}
+		return code
+	}
+	printVerifyReport(os.Stdout, report)
+	return code
+}
+
+func runAnalytics(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("analytics", flag.ContinueOnError)
+	since := flags.Duration("since", 24*time.Hour, "summarize queries logged within this window")
+	n := flags.Int("n", 10, "entries per list")
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+	if !parseCommand("analytics", flags, args, 0, 0) {
+		return exitError
+	}
+
+	summary, err := b.Analytics(ctx, *since, *n)
+	if err != nil {
+		return fail(err)
+	}
+	if *asJSON {
+		return printJSON(summary)
+	}
+	printAnalytics(os.Stdout, summary)
+	return exitOK
+}
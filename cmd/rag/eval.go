@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/eval"
+	"github.com/Guru2308/rag-code/internal/reranker"
+)
+
+func runEval(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	kList := flags.String("k", "1,5,10", "comma-separated cutoffs for recall and nDCG")
+	configList := flags.String("config", "full", "comma-separated pipeline configs: "+strings.Join(eval.PresetNames(), ", "))
+	out := flags.String("o", "", "write the JSON report to this file")
+	baseline := flags.String("baseline", "", "report to compare against; exits 1 on regressions")
+	tolerance := flags.Float64("tolerance", 0.01, "largest metric drop against the baseline that is not a regression")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if !parseCommand("eval", flags, args, 1, 1) {
+		return exitError
+	}
+
+	ks, err := parseInts(*kList)
+	if err != nil {
+		return fail(err)
+	}
+	var configs []eval.PipelineConfig
+	for _, name := range strings.Split(*configList, ",") {
+		cfg, err := eval.Preset(strings.TrimSpace(name))
+		if err != nil {
+			return fail(err)
+		}
+		configs = append(configs, cfg)
+	}
+
+	set, err := eval.LoadGoldenSet(flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	var base *eval.Report
+	if *baseline != "" {
+		if base, err = eval.LoadReport(*baseline); err != nil {
+			return fail(err)
+		}
+	}
+
+	report, err := eval.Run(ctx, b, set, eval.Options{K: ks, Configs: configs})
+	if err != nil {
+		return fail(err)
+	}
+	if *out != "" {
+		if err := report.Save(*out); err != nil {
+			return fail(err)
+		}
+	}
+
+	if *asJSON {
+		if code := printJSON(report); code != exitOK {
+			return code
+		}
+	} else {
+		printEvalReport(os.Stdout, report)
+	}
+
+	if base == nil {
+		return exitOK
+	}
+	regressions := eval.Compare(base, report, *tolerance)
+	if len(regressions) == 0 {
+		fmt.Fprintf(os.Stderr, "No regressions against %s\n", *baseline)
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "%d regressions against %s:\n", len(regressions), *baseline)
+	for _, r := range regressions {
+		fmt.Fprintf(os.Stderr, "  %s\n", r)
+	}
+	return exitIssues
+}
+
+func parseInts(list string) ([]int, error) {
+	var out []int
+	for _, field := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid cutoff %q", field)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func runEvalGen(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("eval-gen", flag.ContinueOnError)
+	count := flags.Int("n", 200, "number of queries to generate")
+	perChunk := flags.Int("per-chunk", 2, "questions kept per sampled chunk")
+	langs := flags.String("lang", "", "comma-separated languages to sample (default all)")
+	types := flags.String("types", "function,method,class", "comma-separated chunk types to sample")
+	root := flags.String("root", "", "write target paths relative to this directory")
+	seed := flags.Int64("seed", 1, "sampling seed")
+	name := flags.String("name", "", "golden set name (default the output file name)")
+	if !parseCommand("eval-gen", flags, args, 1, 1) {
+		return exitError
+	}
+
+	// Generation reads the vector store directly, which the HTTP API does not expose
+	local, ok := b.(*localBackend)
+	if !ok {
+		return fail(fmt.Errorf("eval-gen needs -standalone"))
+	}
+
+	out := flags.Arg(0)
+	opts := eval.GenerateOptions{
+		Name:              *name,
+		Count:             *count,
+		QuestionsPerChunk: *perChunk,
+		Languages:         splitList(*langs),
+		Root:              *root,
+		Seed:              *seed,
+	}
+	if opts.Name == "" {
+		opts.Name = strings.TrimSuffix(filepath.Base(out), filepath.Ext(out))
+	}
+	for _, t := range splitList(*types) {
+		opts.ChunkTypes = append(opts.ChunkTypes, domain.ChunkType(t))
+	}
+
+	set, stats, err := eval.Generate(ctx, local.app.Store, local.app.LLM, opts)
+	if stats != nil {
+		fmt.Fprintf(os.Stderr, "Sampled %d of %d eligible chunks (%d indexed); %d LLM errors\n",
+			stats.ChunksUsed, stats.ChunksEligible, stats.ChunksSeen, stats.LLMErrors)
+		printCounts(os.Stderr, "Rejected", stats.Rejected)
+		printCounts(os.Stderr, "Generated", stats.Strata)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err := set.Save(out); err != nil {
+		return fail(err)
+	}
+	fmt.Printf("Wrote %d queries to %s\n", len(set.Queries), out)
+	return exitOK
+}
+
+func splitList(list string) []string {
+	var out []string
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+func runTune(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	k := flags.Int("k", 10, "nDCG cutoff to maximise")
+	candidates := flags.Int("candidates", 50, "results retrieved per query for reranking")
+	rounds := flags.Int("rounds", 10, "coordinate ascent rounds at most")
+	holdout := flags.Float64("holdout", 0.2, "fraction of queries held out to check for overfitting")
+	initial := flags.String("init", "", "reranker config to start from (default the built-in weights)")
+	out := flags.String("o", "reranker.json", "write the tuned reranker config to this file")
+	asJSON := flags.Bool("json", false, "print the tuning result as JSON")
+	if !parseCommand("tune", flags, args, 1, 1) {
+		return exitError
+	}
+
+	set, err := eval.LoadGoldenSet(flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	opts := eval.TuneOptions{K: *k, Candidates: *candidates, Rounds: *rounds, Holdout: *holdout}
+	if *initial != "" {
+		if opts.Initial, err = reranker.LoadHeuristicConfig(*initial); err != nil {
+			return fail(err)
+		}
+	}
+
+	result, err := eval.Tune(ctx, b, set, opts)
+	if err != nil {
+		return fail(err)
+	}
+	if err := result.Config.Save(*out); err != nil {
+		return fail(err)
+	}
+
+	if *asJSON {
+		return printJSON(result)
+	}
+	for _, s := range result.Steps {
+		fmt.Printf("round %d: %-22s %.3f -> %.3f  nDCG %.4f\n", s.Round, s.Param, s.From, s.To, s.NDCG)
+	}
+	fmt.Println(result)
+	if result.Errors > 0 {
+		fmt.Printf("%d queries failed and were left out\n", result.Errors)
+	}
+	fmt.Printf("Wrote %s; set RERANKER_CONFIG=%s and restart the server to use it\n", *out, *out)
+	return exitOK
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+func runFeedback(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("feedback", flag.ContinueOnError)
+	up := flags.String("up", "", "comma-separated chunk IDs that were useful")
+	down := flags.String("down", "", "comma-separated chunk IDs that were not")
+	answer := flags.String("answer", "", "comma-separated chunk IDs that held the answer")
+	helpful := flags.Bool("helpful", false, "the answer was helpful")
+	unhelpful := flags.Bool("unhelpful", false, "the answer was not helpful")
+	note := flags.String("note", "", "free-text note")
+	if !parseCommand("feedback", flags, args, 1, 1) {
+		return exitError
+	}
+	if *helpful && *unhelpful {
+		return fail(errors.ValidationError("-helpful and -unhelpful are mutually exclusive"))
+	}
+
+	req := domain.FeedbackRequest{QueryID: flags.Arg(0), Note: *note}
+	if *helpful || *unhelpful {
+		req.Helpful = helpful
+	}
+	for _, r := range []struct {
+		rating domain.FeedbackRating
+		ids    string
+	}{{domain.RatingAnswer, *answer}, {domain.RatingUp, *up}, {domain.RatingDown, *down}} {
+		for _, id := range splitList(r.ids) {
+			req.Results = append(req.Results, domain.ResultFeedback{ChunkID: id, Rating: r.rating})
+		}
+	}
+
+	fb, err := b.Feedback(ctx, req)
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("Recorded feedback on %s (%d results rated)\n", fb.QueryID, len(fb.Results))
+	return exitOK
+}
+
+func runFeedbackExport(ctx context.Context, b backend, args []string) int {
+	flags := flag.NewFlagSet("feedback-export", flag.ContinueOnError)
+	name := flags.String("name", "feedback", "golden set name")
+	out := flags.String("o", "", "write the golden set to this file instead of stdout")
+	if !parseCommand("feedback-export", flags, args, 0, 0) {
+		return exitError
+	}
+
+	set, err := b.ExportFeedback(ctx, *name)
+	if err != nil {
+		return fail(err)
+	}
+	if *out == "" {
+		return printJSON(set)
+	}
+	if err := set.Save(*out); err != nil {
+		return fail(err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d queries to %s\n", len(set.Queries), *out)
+	return exitOK
+}
@@ -0,0 +1,166 @@
+// Command rag is a command-line client for the RAG code server. It talks to a
+// running rag-server over HTTP, or with -standalone wires the indexer and
+// retriever in-process for one-off use in scripts and CI.
+//
+// Usage:
+//
+//	rag [-server URL | -standalone] [-v] <command> [flags] [args]
+//
+// Commands:
+//
+//	index <path>          index a file or directory
+//	query "<text>"        answer a question, streaming the response with citations
+//	chat ["<text>"]       multi-turn conversation; reads messages from stdin without text
+//	agent "<question>"    answer by letting the LLM call search, call graph and file tools
+//	search "<text>"       retrieval only; prints a table or JSON
+//	grep <pattern>        find matching lines of indexed code, literally or by regex
+//	status                server and indexing status
+//	jobs [id]             list indexing jobs or show one
+//	explain <chunk-id>    show a chunk and its dependency graph neighbours
+//	verify <path>         compare the index with the file system
+//	eval <golden.json>    measure retrieval quality against a golden set
+//	eval-gen <out.json>   generate a golden set from the index with the LLM (-standalone)
+//	tune <golden.json>    fit the heuristic reranker weights to a golden set
+//	feedback <query-id>   rate the results of a query or search
+//	feedback-export       export feedback as a golden set
+//	analytics             summarize the query log
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Guru2308/rag-code/internal/app"
+	"github.com/Guru2308/rag-code/internal/config"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// Exit codes
+const (
+	exitOK     = 0
+	exitIssues = 1 // verify found issues that remain, or eval found regressions
+	exitError  = 2
+)
+
+// command runs a subcommand and returns the process exit code
+type command func(ctx context.Context, b backend, args []string) int
+
+var commands = map[string]command{
+	"index":    runIndex,
+	"query":    runQuery,
+	"chat":     runChat,
+	"agent":    runAgent,
+	"search":   runSearch,
+	"grep":     runGrep,
+	"status":   runStatus,
+	"jobs":     runJobs,
+	"explain":  runExplain,
+	"verify":   runVerify,
+	"eval":     runEval,
+	"eval-gen": runEvalGen,
+	"tune":     runTune,
+
+	"feedback":        runFeedback,
+	"feedback-export": runFeedbackExport,
+	"analytics":       runAnalytics,
+}
+
+var commandOrder = []string{"index", "query", "chat", "agent", "search", "grep", "status", "jobs", "explain", "verify", "eval", "eval-gen", "tune", "feedback", "feedback-export", "analytics"}
+
+var usages = map[string]string{
+	"index":    "index <path>",
+	"query":    `query [-k N] [-hyde] [-multi N] [-json] "<text>"`,
+	"chat":     `chat [-session ID] [-k N] [-sources] ["<text>"]`,
+	"agent":    `agent [-steps N] [-tokens N] [-json] "<question>"`,
+	"search":   `search [-k N] [-lang L] [-file PATH] [-group] [-cursor C] [-no-rerank] [-no-expand] [-no-hierarchy] [-hyde] [-multi N] [-debug] [-json] "<text>"`,
+	"grep":     "grep [-E] [-case] [-path GLOBS] [-k N] [-json] <pattern>",
+	"status":   "status [-json]",
+	"jobs":     "jobs [-json] [id]",
+	"explain":  "explain [-json] <chunk-id>",
+	"verify":   "verify [-repair] [-json] <path>",
+	"eval":     "eval [-k 1,5,10] [-config full,no-rerank] [-o FILE] [-baseline FILE] [-tolerance T] [-json] <golden.json>",
+	"eval-gen": "eval-gen [-n 200] [-per-chunk 2] [-lang L,...] [-types function,method,class] [-root DIR] [-seed N] [-name NAME] <out.json>",
+	"tune":     "tune [-k 10] [-candidates 50] [-rounds 10] [-holdout 0.2] [-init FILE] [-o reranker.json] [-json] <golden.json>",
+
+	"feedback":        "feedback [-up IDS] [-down IDS] [-answer IDS] [-helpful | -unhelpful] [-note TEXT] <query-id>",
+	"feedback-export": "feedback-export [-name NAME] [-o FILE]",
+	"analytics":       "analytics [-since 24h] [-n 10] [-json]",
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rag", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	serverURL := flags.String("server", envOrDefault("RAG_SERVER", "http://localhost:8080"), "rag-server base URL (env RAG_SERVER)")
+	standalone := flags.Bool("standalone", false, "run in-process against Qdrant, Redis and Ollama instead of a server")
+	verbose := flags.Bool("v", false, "log to stderr")
+	flags.Usage = func() { printUsage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		printUsage(flags, stderr)
+		return exitError
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "rag: unknown command %q\n\n", flags.Arg(0))
+		printUsage(flags, stderr)
+		return exitError
+	}
+
+	level := logger.LevelError
+	if *verbose {
+		level = logger.LevelInfo
+	}
+	_ = logger.Init(logger.Config{Level: level, Format: "text", Output: stderr})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var b backend
+	if *standalone {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(stderr, "rag: %v\n", err)
+			return exitError
+		}
+		services, err := app.New(ctx, cfg)
+		if err != nil {
+			fmt.Fprintf(stderr, "rag: %v\n", err)
+			return exitError
+		}
+		b = &localBackend{app: services}
+	} else {
+		b = newRemoteBackend(*serverURL)
+	}
+	defer b.Close()
+
+	return cmd(ctx, b, flags.Args()[1:])
+}
+
+func printUsage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: rag [-server URL | -standalone] [-v] <command> [flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  %s\n", usages[name])
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	flags.PrintDefaults()
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
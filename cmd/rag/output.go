@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/client"
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/eval"
+	"github.com/Guru2308/rag-code/internal/querylog"
+)
+
+func printJSON(v any) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// location formats a chunk as path:start-end
+func location(c *domain.CodeChunk) string {
+	if c.StartLine == 0 && c.EndLine == 0 {
+		return c.FilePath
+	}
+	return fmt.Sprintf("%s:%d-%d", c.FilePath, c.StartLine, c.EndLine)
+}
+
+// printCitations lists the chunks an answer was generated from
+func printCitations(w io.Writer, results []*domain.SearchResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nSources:")
+	for i, r := range results {
+		if r.Chunk == nil {
+			continue
+		}
+		fmt.Fprintf(w, "  [%d] %s (%s, relevance %.2f)\n", i+1, location(r.Chunk), r.Chunk.ChunkType, r.RelevanceScore)
+	}
+}
+
+func printResults(w io.Writer, results []*domain.SearchResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No results.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tRELEVANCE\tSOURCE\tTYPE\tLOCATION\tID")
+	for _, r := range results {
+		if r.Chunk == nil {
+			continue
+		}
+		rank := "-" // expanded context
+		if r.Rank > 0 {
+			rank = strconv.Itoa(r.Rank)
+		}
+		fmt.Fprintf(tw, "%s\t%.3f\t%s\t%s\t%s\t%s\n", rank, r.RelevanceScore, r.Source, r.Chunk.ChunkType, location(r.Chunk), r.Chunk.ID)
+	}
+	tw.Flush()
+}
+
+func printSearchPage(w io.Writer, page *domain.SearchPage) {
+	if page.Groups != nil {
+		for _, g := range page.Groups {
+			fmt.Fprintf(w, "%s (%.3f)\n", g.FilePath, g.Score)
+			printResults(w, g.Results)
+			fmt.Fprintln(w)
+		}
+	} else {
+		printResults(w, page.Results)
+	}
+	if page.NextCursor != "" {
+		fmt.Fprintf(w, "\n%d total; next page: -cursor %s\n", page.Total, page.NextCursor)
+	}
+	if page.Partial {
+		fmt.Fprintln(w, "\nPartial results: a search stage ran out of time")
+	}
+	printQueryID(w, page.QueryID)
+}
+
+func printGrep(w io.Writer, resp *domain.GrepResponse) {
+	for _, m := range resp.Matches {
+		fmt.Fprintf(w, "%s:%d: %s\n", m.FilePath, m.Line, m.Text)
+	}
+	if resp.Truncated {
+		fmt.Fprintf(w, "\nShowing the first %d matches; raise -k for more\n", len(resp.Matches))
+	}
+}
+
+// printQueryID tells the user how to rate what they were shown
+func printQueryID(w io.Writer, queryID string) {
+	if queryID != "" {
+		fmt.Fprintf(w, "\nQuery ID: %s (rate results with `rag feedback`)\n", queryID)
+	}
+}
+
+func printStatus(w io.Writer, status *client.Status) {
+	fmt.Fprintf(w, "Status: %s\n", status.Status)
+	if st := status.Index; st != nil {
+		fmt.Fprintf(w, "Files tracked:  %d\n", st.FilesTracked)
+		fmt.Fprintf(w, "Jobs running:   %d\n", st.JobsRunning)
+		fmt.Fprintf(w, "Last run:       %d indexed, %d skipped, %d failed, %d chunks in %s\n",
+			st.FilesIndexed, st.FilesSkipped, st.FilesErrored, st.ChunksCreated, time.Duration(st.DurationMS)*time.Millisecond)
+	}
+}
+
+func printJobs(w io.Writer, jobs []*domain.IndexingJob) {
+	if len(jobs) == 0 {
+		fmt.Fprintln(w, "No jobs.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tSTATUS\tFILES\tFAILED\tSTARTED\tDURATION\tPATH")
+	for _, j := range jobs {
+		duration := "-"
+		if !j.FinishedAt.IsZero() {
+			duration = j.FinishedAt.Sub(j.StartedAt).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%d\t%s\t%s\t%s\n",
+			j.ID, j.Kind, j.Status, j.FilesDone, j.FilesTotal, j.FilesFailed,
+			j.StartedAt.Local().Format(time.DateTime), duration, j.Path)
+	}
+	tw.Flush()
+}
+
+func printExplanation(w io.Writer, e *domain.ChunkExplanation) {
+	c := e.Chunk
+	fmt.Fprintf(w, "%s\n", location(c))
+	fmt.Fprintf(w, "ID:         %s\n", c.ID)
+	fmt.Fprintf(w, "Type:       %s\n", c.ChunkType)
+	fmt.Fprintf(w, "Language:   %s\n", c.Language)
+	if name := c.Metadata["name"]; name != "" {
+		fmt.Fprintf(w, "Name:       %s\n", name)
+	}
+	if c.Generation != 0 {
+		fmt.Fprintf(w, "Indexed at: %s\n", time.Unix(0, c.Generation).Local().Format(time.DateTime))
+	}
+
+	sections := []struct {
+		title   string
+		related []domain.RelatedChunk
+	}{
+		{"Calls", e.Calls},
+		{"Called by", e.CalledBy},
+		{"Imports", e.Imports},
+		{"Imported by", e.ImportedBy},
+		{"Defined in", e.DefinedIn},
+		{"Defines", e.Defines},
+	}
+	for _, s := range sections {
+		if len(s.related) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", s.title)
+		for _, r := range s.related {
+			fmt.Fprintf(w, "  %s %s (%s) %s\n", r.Type, r.Name, r.FilePath, r.ID)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%s\n", strings.TrimRight(c.Content, "\n"))
+}
+
+func printVerifyReport(w io.Writer, r *domain.VerifyReport) {
+	fmt.Fprintf(w, "Verified %s in %s\n", r.Root, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Files on disk: %d, indexed: %d\n", r.FilesOnDisk, r.FilesIndexed)
+	for _, store := range []string{"vector", "keyword", "graph"} {
+		if n, ok := r.Chunks[store]; ok {
+			fmt.Fprintf(w, "  %s chunks: %d\n", store, n)
+		}
+	}
+	fmt.Fprintf(w, "Orphans: %d, missing: %d, stale: %d\n",
+		r.Count(domain.VerifyOrphan), r.Count(domain.VerifyMissing), r.Count(domain.VerifyStale))
+
+	if len(r.Issues) > 0 {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tSTORE\tFILE\tCHUNKS\tREASON")
+		for _, issue := range r.Issues {
+			file := issue.FilePath
+			if file == "" {
+				file = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", issue.Kind, issue.Store, file, len(issue.ChunkIDs), issue.Reason)
+		}
+		tw.Flush()
+	}
+
+	if r.Repaired {
+		fmt.Fprintln(w)
+		if len(r.RepairErrors) == 0 {
+			fmt.Fprintln(w, "Repaired all issues.")
+		} else {
+			fmt.Fprintf(w, "Repair finished with %d errors:\n", len(r.RepairErrors))
+			for _, e := range r.RepairErrors {
+				fmt.Fprintf(w, "  %s\n", e)
+			}
+		}
+	}
+}
+
+func printEvalReport(w io.Writer, r *eval.Report) {
+	fmt.Fprintf(w, "Golden set %s: %d queries\n\n", r.GoldenSet, r.Queries)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "CONFIG\tMRR"
+	for _, k := range r.K {
+		header += fmt.Sprintf("\tR@%d\tNDCG@%d", k, k)
+	}
+	fmt.Fprintln(tw, header+"\tP50 MS\tP95 MS\tERRORS")
+	for _, cr := range r.Configs {
+		row := fmt.Sprintf("%s\t%.3f", cr.Config.Name, cr.MRR)
+		for _, c := range cr.Cutoffs {
+			row += fmt.Sprintf("\t%.3f\t%.3f", c.Recall, c.NDCG)
+		}
+		fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t%d\n", row, cr.Latency.P50MS, cr.Latency.P95MS, cr.Errors)
+	}
+	tw.Flush()
+}
+
+// printCounts prints a map of counts on one line, sorted by key
+func printCounts(w io.Writer, label string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	fmt.Fprintf(w, "%s: %s\n", label, strings.Join(parts, " "))
+}
+
+func printAnalytics(w io.Writer, s *querylog.Summary) {
+	fmt.Fprintf(w, "Since %s: %d queries, %d errors, %d with no results\n",
+		s.Since.Local().Format(time.DateTime), s.Queries, s.Errors, s.ZeroResults)
+	fmt.Fprintf(w, "Latency: p50 %.0fms, p95 %.0fms; mean answer %.0f chars\n", s.Latency.P50MS, s.Latency.P95MS, s.MeanAnswerLength)
+	printCounts(w, "Models", s.Models)
+
+	printQueryCounts(w, "Top queries", s.TopQueries)
+	printQueryCounts(w, "Queries with no results", s.ZeroResultQueries)
+
+	if len(s.SlowestStages) > 0 {
+		fmt.Fprintln(w, "\nSlowest stages:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  STAGE\tCOUNT\tMEAN MS\tP50 MS\tP95 MS\tMAX MS")
+		for _, st := range s.SlowestStages {
+			fmt.Fprintf(tw, "  %s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\n", st.Stage, st.Count, st.MeanMS, st.P50MS, st.P95MS, st.MaxMS)
+		}
+		tw.Flush()
+	}
+
+	if len(s.TopFiles) > 0 {
+		fmt.Fprintln(w, "\nMost retrieved files:")
+		for _, f := range s.TopFiles {
+			fmt.Fprintf(w, "  %5d  %s\n", f.Count, f.FilePath)
+		}
+	}
+}
+
+func printQueryCounts(w io.Writer, label string, queries []querylog.QueryCount) {
+	if len(queries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", label)
+	for _, q := range queries {
+		fmt.Fprintf(w, "  %5d  %s\n", q.Count, q.Query)
+	}
+}
@@ -0,0 +1,273 @@
+// Package agent answers questions that span several parts of a codebase by
+// letting the LLM look code up through tools (search, chunk lookup, the
+// call graph and file reads) until it can answer or runs out of budget.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/graph"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/retrieval"
+)
+
+// Stages timed by the agent in addition to the retrieval stages its
+// searches record
+const (
+	StageModel = "agent_model"
+	StageTools = "agent_tools"
+)
+
+// Retriever backs the search tool
+type Retriever interface {
+	Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error)
+}
+
+// ChunkStore backs get_chunk, and tells read_file which files are indexed
+type ChunkStore interface {
+	Get(ctx context.Context, id string) (*domain.CodeChunk, error)
+	ListChunks(ctx context.Context) ([]domain.ChunkRef, error)
+}
+
+// Model is the chat model that plans the tool calls and answers
+type Model interface {
+	Generate(ctx context.Context, messages []llm.ChatMessage) (string, error)
+}
+
+// Config bounds an agent run
+type Config struct {
+	MaxSteps            int // tool calls per run (default 6)
+	MaxTokens           int // model tokens per run, estimated at 4 chars per token (default 32000)
+	MaxObservationChars int // tool output sent back to the model per step (default 4000)
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{MaxSteps: 6, MaxTokens: 32000, MaxObservationChars: 4000}
+}
+
+const charsPerToken = 4
+
+// Agent runs the tool loop
+type Agent struct {
+	retriever Retriever
+	chunks    ChunkStore
+	graph     *graph.Graph
+	model     Model
+	cfg       Config
+}
+
+// New creates an agent. g may be nil, in which case callers and callees
+// report that the graph is unavailable.
+func New(retriever Retriever, chunks ChunkStore, g *graph.Graph, model Model, cfg Config) *Agent {
+	def := DefaultConfig()
+	if cfg.MaxSteps <= 0 {
+		cfg.MaxSteps = def.MaxSteps
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = def.MaxTokens
+	}
+	if cfg.MaxObservationChars <= 0 {
+		cfg.MaxObservationChars = def.MaxObservationChars
+	}
+	return &Agent{retriever: retriever, chunks: chunks, graph: g, model: model, cfg: cfg}
+}
+
+// run is the state of one agent run
+type run struct {
+	agent    *Agent
+	messages []llm.ChatMessage
+	tokens   int
+	results  []*domain.SearchResult
+	seen     map[string]bool
+	files    map[string]bool // indexed files, loaded by the first read_file
+}
+
+func (r *run) addResult(res *domain.SearchResult) {
+	if res.Chunk == nil || r.seen[res.Chunk.ID] {
+		return
+	}
+	r.seen[res.Chunk.ID] = true
+	r.results = append(r.results, res)
+}
+
+const systemPrompt = `You answer questions about a codebase. You cannot see the code; look it up with these tools, one call at a time:
+
+%s
+Search first, then follow the call graph or read the surrounding code as needed. When you have enough to answer, answer concisely and cite file paths and line numbers.
+
+Reply with exactly one JSON object and nothing else. To call a tool:
+{"tool": "<name>", "args": {...}}
+To answer:
+{"answer": "<your answer>"}`
+
+const finalPrompt = `You have no tool calls left. Answer the question now with what you have found, saying what you could not confirm. Reply with {"answer": "<your answer>"}.`
+
+// Run answers req.Question, calling onStep (if set) after each tool call.
+// A failing tool call is reported to the model, which may try something
+// else; a failing model call ends the run with an error. When the step or
+// token budget runs out the model is asked once more to answer with what
+// it has.
+func (a *Agent) Run(ctx context.Context, req domain.AgentRequest, onStep func(domain.AgentStep)) (*domain.AgentResponse, error) {
+	if strings.TrimSpace(req.Question) == "" {
+		return nil, errors.ValidationError("question is required")
+	}
+	maxSteps, maxTokens := a.cfg.MaxSteps, a.cfg.MaxTokens
+	if req.MaxSteps > 0 && req.MaxSteps < maxSteps {
+		maxSteps = req.MaxSteps
+	}
+	if req.MaxTokens > 0 && req.MaxTokens < maxTokens {
+		maxTokens = req.MaxTokens
+	}
+
+	var toolList strings.Builder
+	for _, t := range tools {
+		fmt.Fprintf(&toolList, "- %s %s: %s\n", t.name, t.args, t.description)
+	}
+	r := &run{
+		agent: a,
+		seen:  make(map[string]bool),
+		messages: []llm.ChatMessage{
+			{Role: "system", Content: fmt.Sprintf(systemPrompt, toolList.String())},
+			{Role: "user", Content: "Question: " + req.Question},
+		},
+	}
+	resp := &domain.AgentResponse{Steps: []domain.AgentStep{}}
+
+	for {
+		if len(resp.Steps) >= maxSteps {
+			resp.StoppedBy = domain.AgentStopMaxSteps
+			break
+		}
+		if r.tokens >= maxTokens {
+			resp.StoppedBy = domain.AgentStopMaxTokens
+			break
+		}
+
+		reply, err := a.generate(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		act := parseAction(reply)
+		if act.Tool == "" {
+			resp.Answer, resp.StoppedBy = act.Answer, domain.AgentStopAnswer
+			break
+		}
+
+		step := a.callTool(ctx, r, len(resp.Steps)+1, act)
+		resp.Steps = append(resp.Steps, step)
+		if onStep != nil {
+			onStep(step)
+		}
+
+		observation := fmt.Sprintf("Result of %s:\n%s", step.Tool, step.Observation)
+		if step.Error != "" {
+			observation = fmt.Sprintf("Error from %s: %s", step.Tool, step.Error)
+		}
+		if left := maxSteps - len(resp.Steps); left > 0 {
+			observation += fmt.Sprintf("\n\n(%d tool calls left)", left)
+		}
+		r.messages = append(r.messages,
+			llm.ChatMessage{Role: "assistant", Content: reply},
+			llm.ChatMessage{Role: "user", Content: observation},
+		)
+	}
+
+	if resp.StoppedBy != domain.AgentStopAnswer {
+		logger.Info("Agent budget exhausted, asking for an answer", "reason", resp.StoppedBy, "steps", len(resp.Steps), "tokens", r.tokens)
+		r.messages = append(r.messages, llm.ChatMessage{Role: "user", Content: finalPrompt})
+		reply, err := a.generate(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		act := parseAction(reply)
+		resp.Answer = act.Answer
+		if act.Tool != "" {
+			// A tool call after being told to stop; keep its text rather than nothing
+			resp.Answer = strings.TrimSpace(reply)
+		}
+	}
+
+	resp.Results = r.results
+	resp.TokensUsed = r.tokens
+	logger.Info("Agent run complete", "steps", len(resp.Steps), "tokens", r.tokens, "stopped_by", resp.StoppedBy, "results", len(r.results))
+	return resp, nil
+}
+
+// generate sends the conversation so far to the model and counts the
+// tokens both ways
+func (a *Agent) generate(ctx context.Context, r *run) (string, error) {
+	sent := 0
+	for _, m := range r.messages {
+		sent += len(m.Content)
+	}
+	start := time.Now()
+	reply, err := a.model.Generate(ctx, r.messages)
+	retrieval.StageTimingsFrom(ctx).Record(StageModel, time.Since(start))
+	if err != nil {
+		return "", err
+	}
+	r.tokens += (sent + len(reply)) / charsPerToken
+	return reply, nil
+}
+
+func (a *Agent) callTool(ctx context.Context, r *run, n int, act action) domain.AgentStep {
+	step := domain.AgentStep{Step: n, Tool: act.Tool, Args: act.Args}
+	before := len(r.results)
+	start := time.Now()
+
+	t, ok := findTool(act.Tool)
+	var observation string
+	var err error
+	if !ok {
+		err = errors.ValidationError(fmt.Sprintf("unknown tool %q; the tools are %s", act.Tool, toolNames()))
+	} else {
+		observation, err = t.run(ctx, r, act.Args)
+	}
+
+	d := time.Since(start)
+	retrieval.StageTimingsFrom(ctx).Record(StageTools, d)
+	step.DurationMS = float64(d.Microseconds()) / 1000
+	if err != nil {
+		step.Error = err.Error()
+		logger.Debug("Agent tool call failed", "step", n, "tool", act.Tool, "error", err)
+		return step
+	}
+	if len(observation) > a.cfg.MaxObservationChars {
+		observation = observation[:a.cfg.MaxObservationChars] + "\n... (truncated)"
+	}
+	step.Observation = observation
+	for _, res := range r.results[before:] {
+		step.ChunkIDs = append(step.ChunkIDs, res.Chunk.ID)
+	}
+	logger.Debug("Agent tool call", "step", n, "tool", act.Tool, "chunks", len(step.ChunkIDs), "duration_ms", step.DurationMS)
+	return step
+}
+
+// action is what the model asked for: a tool call, or an answer
+type action struct {
+	Tool   string          `json:"tool"`
+	Args   json.RawMessage `json:"args"`
+	Answer string          `json:"answer"`
+}
+
+// parseAction reads the JSON object the prompt asks for, tolerating text
+// around it. A reply without one is taken as the answer, since small models
+// often answer in prose.
+func parseAction(reply string) action {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start >= 0 && end > start {
+		var act action
+		if json.Unmarshal([]byte(reply[start:end+1]), &act) == nil && (act.Tool != "" || act.Answer != "") {
+			return act
+		}
+	}
+	return action{Answer: strings.TrimSpace(reply)}
+}
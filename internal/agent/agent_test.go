@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/graph"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+func init() {
+	logger.Init(logger.Config{Level: logger.LevelDebug})
+}
+
+// scriptedModel replies with each of its replies in turn, repeating the last
+type scriptedModel struct {
+	replies []string
+	calls   [][]llm.ChatMessage
+	err     error
+}
+
+func (m *scriptedModel) Generate(_ context.Context, messages []llm.ChatMessage) (string, error) {
+	m.calls = append(m.calls, append([]llm.ChatMessage(nil), messages...))
+	if m.err != nil {
+		return "", m.err
+	}
+	return m.replies[min(len(m.calls), len(m.replies))-1], nil
+}
+
+type fakeRetriever struct{ results []*domain.SearchResult }
+
+func (f *fakeRetriever) Retrieve(context.Context, domain.SearchQuery) ([]*domain.SearchResult, error) {
+	return f.results, nil
+}
+
+type fakeStore map[string]*domain.CodeChunk
+
+func (f fakeStore) Get(_ context.Context, id string) (*domain.CodeChunk, error) {
+	if c, ok := f[id]; ok {
+		return c, nil
+	}
+	return nil, errors.NotFoundError("chunk not found")
+}
+
+func (f fakeStore) ListChunks(context.Context) ([]domain.ChunkRef, error) {
+	var refs []domain.ChunkRef
+	for _, c := range f {
+		refs = append(refs, domain.ChunkRef{ID: c.ID, FilePath: c.FilePath})
+	}
+	return refs, nil
+}
+
+func setupAgent(t *testing.T, model Model, cfg Config) (*Agent, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth", "token.go")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte("package auth\n\nfunc ValidateToken(t string) error {\n\treturn verify(t)\n}\n"), 0o644)
+
+	def := &domain.CodeChunk{ID: "def", FilePath: path, StartLine: 3, EndLine: 5, ChunkType: domain.ChunkTypeFunction,
+		Content: "func ValidateToken(t string) error {\n\treturn verify(t)\n}", Metadata: map[string]string{"name": "ValidateToken", "calls": "verify"}}
+	verify := &domain.CodeChunk{ID: "verify", FilePath: filepath.Join(dir, "auth", "verify.go"), ChunkType: domain.ChunkTypeFunction,
+		Metadata: map[string]string{"name": "verify"}}
+	caller := &domain.CodeChunk{ID: "caller", FilePath: filepath.Join(dir, "api", "middleware.go"), ChunkType: domain.ChunkTypeFunction,
+		Metadata: map[string]string{"name": "RequireAuth", "calls": "ValidateToken"}}
+
+	g := graph.NewBuilder().Build(context.Background(), []*domain.CodeChunk{def, verify, caller})
+	store := fakeStore{"def": def, "verify": verify, "caller": caller}
+	retriever := &fakeRetriever{results: []*domain.SearchResult{{Chunk: def, Source: "hybrid", Rank: 1}}}
+	return New(retriever, store, g, model, cfg), path
+}
+
+func TestAgent_Run(t *testing.T) {
+	model := &scriptedModel{replies: []string{
+		`{"tool": "search", "args": {"query": "token validation"}}`,
+		`Let me check who calls it. {"tool": "callers", "args": {"symbol": "ValidateToken"}}`,
+		`{"tool": "get_chunk", "args": {"id": "caller"}}`,
+		`{"tool": "read_file", "args": {"path": "auth/token.go", "start_line": 3, "end_line": 4}}`,
+		`{"tool": "explode", "args": {}}`,
+		`{"answer": "RequireAuth calls ValidateToken (auth/token.go:3-5)."}`,
+	}}
+	a, path := setupAgent(t, model, Config{MaxSteps: 10})
+
+	var streamed []domain.AgentStep
+	resp, err := a.Run(context.Background(), domain.AgentRequest{Question: "Who validates tokens?"}, func(s domain.AgentStep) { streamed = append(streamed, s) })
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if resp.StoppedBy != domain.AgentStopAnswer || !strings.Contains(resp.Answer, "RequireAuth") {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(resp.Steps) != 5 || len(streamed) != 5 {
+		t.Fatalf("got %d steps (%d streamed), want 5", len(resp.Steps), len(streamed))
+	}
+
+	steps := resp.Steps
+	if steps[0].Tool != "search" || len(steps[0].ChunkIDs) != 1 || !strings.Contains(steps[0].Observation, "ValidateToken") {
+		t.Errorf("unexpected search step %+v", steps[0])
+	}
+	if !strings.Contains(steps[1].Observation, "RequireAuth") || strings.Contains(steps[1].Observation, "verify") {
+		t.Errorf("callers should list RequireAuth only: %q", steps[1].Observation)
+	}
+	if steps[2].ChunkIDs[0] != "caller" {
+		t.Errorf("get_chunk should add the chunk: %+v", steps[2])
+	}
+	if !strings.HasPrefix(steps[3].Observation, path) || !strings.Contains(steps[3].Observation, "3: func ValidateToken") || strings.Contains(steps[3].Observation, "5: }") {
+		t.Errorf("read_file should resolve the path and return lines 3-4: %q", steps[3].Observation)
+	}
+	if steps[4].Error == "" || !strings.Contains(steps[4].Error, "unknown tool") {
+		t.Errorf("unknown tool should be reported as an error: %+v", steps[4])
+	}
+
+	if len(resp.Results) != 2 || resp.Results[0].Chunk.ID != "def" || resp.Results[1].Source != "agent:get_chunk" {
+		t.Errorf("unexpected results %+v", resp.Results)
+	}
+	if resp.TokensUsed == 0 {
+		t.Error("tokens should be counted")
+	}
+
+	// The error is sent back to the model so it can recover
+	last := model.calls[len(model.calls)-1]
+	if got := last[len(last)-1].Content; !strings.HasPrefix(got, "Error from explode") {
+		t.Errorf("last observation = %q", got)
+	}
+}
+
+func TestAgent_Budgets(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       Config
+		req       domain.AgentRequest
+		wantStop  string
+		wantSteps int
+	}{
+		{"step budget", Config{MaxSteps: 5}, domain.AgentRequest{Question: "q", MaxSteps: 2}, domain.AgentStopMaxSteps, 2},
+		{"request cannot raise the budget", Config{MaxSteps: 1}, domain.AgentRequest{Question: "q", MaxSteps: 50}, domain.AgentStopMaxSteps, 1},
+		{"token budget", Config{MaxSteps: 50, MaxTokens: 1}, domain.AgentRequest{Question: "q"}, domain.AgentStopMaxTokens, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedModel{replies: []string{`{"tool": "search", "args": {"query": "x"}}`}}
+			a, _ := setupAgent(t, model, tt.cfg)
+			resp, err := a.Run(context.Background(), tt.req, nil)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if resp.StoppedBy != tt.wantStop || len(resp.Steps) != tt.wantSteps {
+				t.Errorf("stopped by %s after %d steps, want %s after %d", resp.StoppedBy, len(resp.Steps), tt.wantStop, tt.wantSteps)
+			}
+			// The model is asked for a final answer
+			last := model.calls[len(model.calls)-1]
+			if last[len(last)-1].Content != finalPrompt {
+				t.Errorf("last message = %q, want the final prompt", last[len(last)-1].Content)
+			}
+		})
+	}
+}
+
+func TestAgent_Errors(t *testing.T) {
+	a, _ := setupAgent(t, &scriptedModel{err: errors.ExternalError("model down", nil)}, Config{})
+	if _, err := a.Run(context.Background(), domain.AgentRequest{Question: "q"}, nil); !errors.Is(err, errors.ErrorTypeExternal) {
+		t.Errorf("Run() error = %v, want the model error", err)
+	}
+	if _, err := a.Run(context.Background(), domain.AgentRequest{Question: " "}, nil); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("Run() error = %v, want validation error", err)
+	}
+}
+
+func TestReadFile_OnlyIndexedFiles(t *testing.T) {
+	a, _ := setupAgent(t, &scriptedModel{}, Config{})
+	r := &run{agent: a, seen: make(map[string]bool)}
+	for _, path := range []string{"/etc/passwd", "../../etc/passwd", "token.go.bak"} {
+		args, _ := json.Marshal(map[string]string{"path": path})
+		if _, err := runReadFile(context.Background(), r, args); !errors.Is(err, errors.ErrorTypeNotFound) {
+			t.Errorf("read_file(%q) error = %v, want not found", path, err)
+		}
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		reply      string
+		wantTool   string
+		wantAnswer string
+	}{
+		{`{"tool": "search", "args": {"query": "x"}}`, "search", ""},
+		{"```json\n{\"answer\": \"done\"}\n```", "", "done"},
+		{"It is in auth.go.", "", "It is in auth.go."},
+		{"Use `if x { y }` here.", "", "Use `if x { y }` here."},
+	}
+	for _, tt := range tests {
+		act := parseAction(tt.reply)
+		if act.Tool != tt.wantTool || act.Answer != tt.wantAnswer {
+			t.Errorf("parseAction(%q) = %+v", tt.reply, act)
+		}
+	}
+}
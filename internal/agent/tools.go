@@ -0,0 +1,315 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/graph"
+)
+
+const (
+	maxSearchResults  = 10
+	searchPreviewLine = 12  // lines of each search result shown to the model
+	maxRelated        = 20  // callers or callees listed
+	maxReadLines      = 200 // lines read_file returns at most
+	defaultReadLines  = 100
+)
+
+// tool is one action the model can take. run returns the observation sent
+// back to the model.
+type tool struct {
+	name        string
+	args        string // example argument object shown in the prompt
+	description string
+	run         func(ctx context.Context, r *run, args json.RawMessage) (string, error)
+}
+
+var tools = []tool{
+	{
+		name:        "search",
+		args:        `{"query": "...", "language": "", "file_path": "", "max_results": 5}`,
+		description: "hybrid search over the indexed code; language and file_path are optional filters",
+		run:         runSearch,
+	},
+	{
+		name:        "get_chunk",
+		args:        `{"id": "..."}`,
+		description: "the full content of a chunk by its ID",
+		run:         runGetChunk,
+	},
+	{
+		name:        "callers",
+		args:        `{"symbol": "..."}`,
+		description: "the functions and methods that call a symbol (use Type.Method for methods)",
+		run:         func(ctx context.Context, r *run, args json.RawMessage) (string, error) { return runRelated(r, args, true) },
+	},
+	{
+		name:        "callees",
+		args:        `{"symbol": "..."}`,
+		description: "the functions and methods a symbol calls",
+		run:         func(ctx context.Context, r *run, args json.RawMessage) (string, error) { return runRelated(r, args, false) },
+	},
+	{
+		name:        "read_file",
+		args:        `{"path": "...", "start_line": 1, "end_line": 100}`,
+		description: fmt.Sprintf("lines of an indexed file, at most %d at a time", maxReadLines),
+		run:         runReadFile,
+	},
+}
+
+func findTool(name string) (tool, bool) {
+	for _, t := range tools {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return tool{}, false
+}
+
+func toolNames() string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func decodeArgs(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		raw = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeValidation, "invalid arguments")
+	}
+	return nil
+}
+
+func runSearch(ctx context.Context, r *run, raw json.RawMessage) (string, error) {
+	var args struct {
+		Query      string `json:"query"`
+		Language   string `json:"language"`
+		FilePath   string `json:"file_path"`
+		MaxResults int    `json:"max_results"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "", errors.ValidationError("query is required")
+	}
+	if args.MaxResults <= 0 {
+		args.MaxResults = 5
+	}
+	args.MaxResults = min(args.MaxResults, maxSearchResults)
+
+	results, err := r.agent.retriever.Retrieve(ctx, domain.SearchQuery{
+		Query:      args.Query,
+		Language:   args.Language,
+		FilePath:   args.FilePath,
+		MaxResults: args.MaxResults,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "No results.", nil
+	}
+
+	var b strings.Builder
+	for i, res := range results {
+		if res.Chunk == nil {
+			continue
+		}
+		r.addResult(res)
+		fmt.Fprintf(&b, "[%d] %s\n%s\n", i+1, describe(res.Chunk), preview(res.Chunk.Content, searchPreviewLine))
+	}
+	return b.String(), nil
+}
+
+func runGetChunk(ctx context.Context, r *run, raw json.RawMessage) (string, error) {
+	var args struct {
+		ID string `json:"id"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	if args.ID == "" {
+		return "", errors.ValidationError("id is required")
+	}
+	chunk, err := r.agent.chunks.Get(ctx, args.ID)
+	if err != nil {
+		return "", err
+	}
+	if chunk == nil {
+		return "", errors.NotFoundError("chunk not found")
+	}
+	r.addResult(&domain.SearchResult{Chunk: chunk, Source: "agent:get_chunk"})
+	return describe(chunk) + "\n" + chunk.Content, nil
+}
+
+// runRelated lists the callers (or callees) of every definition of a symbol
+func runRelated(r *run, raw json.RawMessage, callers bool) (string, error) {
+	var args struct {
+		Symbol string `json:"symbol"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	if args.Symbol == "" {
+		return "", errors.ValidationError("symbol is required")
+	}
+	g := r.agent.graph
+	if g == nil {
+		return "", errors.ValidationError("the dependency graph is not available")
+	}
+
+	name, receiver := args.Symbol, ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		receiver, name = name[:i], name[i+1:]
+	}
+	var defs []*graph.Node
+	for _, n := range g.GetNodesByName(name) {
+		if receiver == "" || n.Metadata["receiver"] == receiver || strings.TrimPrefix(n.Metadata["receiver"], "*") == receiver {
+			defs = append(defs, n)
+		}
+	}
+	if len(defs) == 0 {
+		return fmt.Sprintf("No symbol named %s in the dependency graph.", args.Symbol), nil
+	}
+
+	seen := make(map[string]bool)
+	var b strings.Builder
+	listed := 0
+	for _, def := range defs {
+		related := g.GetRelated(def.ID, graph.RelationCall)
+		if callers {
+			related = g.GetIncoming(def.ID, graph.RelationCall)
+		}
+		for _, n := range related {
+			if seen[n.ID] || listed == maxRelated {
+				continue
+			}
+			seen[n.ID] = true
+			listed++
+			fmt.Fprintf(&b, "- %s (%s) in %s, id %s\n", n.Name, n.Type, n.FilePath, n.ID)
+		}
+	}
+	if listed == 0 {
+		relation := "calls"
+		if callers {
+			relation = "callers"
+		}
+		return fmt.Sprintf("No %s of %s found in the dependency graph.", relation, args.Symbol), nil
+	}
+	return b.String(), nil
+}
+
+func runReadFile(ctx context.Context, r *run, raw json.RawMessage) (string, error) {
+	var args struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return "", err
+	}
+	if args.Path == "" {
+		return "", errors.ValidationError("path is required")
+	}
+	path, err := r.resolveFile(ctx, args.Path)
+	if err != nil {
+		return "", err
+	}
+	if args.StartLine <= 0 {
+		args.StartLine = 1
+	}
+	if args.EndLine < args.StartLine {
+		args.EndLine = args.StartLine + defaultReadLines - 1
+	}
+	args.EndLine = min(args.EndLine, args.StartLine+maxReadLines-1)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeNotFound, "failed to open file")
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan() && line <= args.EndLine; line++ {
+		if line >= args.StartLine {
+			fmt.Fprintf(&b, "%d: %s\n", line, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeInternal, "failed to read file")
+	}
+	if b.Len() == 0 {
+		return fmt.Sprintf("%s has fewer than %d lines.", path, args.StartLine), nil
+	}
+	return path + "\n" + b.String(), nil
+}
+
+// resolveFile maps path to an indexed file. Only indexed files can be read,
+// so the model cannot reach outside the codebase. A path that is not indexed
+// as given matches the one indexed file it is a suffix of.
+func (r *run) resolveFile(ctx context.Context, path string) (string, error) {
+	if r.files == nil {
+		refs, err := r.agent.chunks.ListChunks(ctx)
+		if err != nil {
+			return "", err
+		}
+		r.files = make(map[string]bool)
+		for _, ref := range refs {
+			r.files[ref.FilePath] = true
+		}
+	}
+
+	clean := filepath.Clean(path)
+	if r.files[clean] {
+		return clean, nil
+	}
+	var matches []string
+	suffix := string(filepath.Separator) + strings.TrimPrefix(clean, string(filepath.Separator))
+	for f := range r.files {
+		if strings.HasSuffix(f, suffix) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", errors.NotFoundError(fmt.Sprintf("%s is not an indexed file", path))
+	case 1:
+		return matches[0], nil
+	default:
+		return "", errors.ValidationError(fmt.Sprintf("%s matches %d indexed files; give more of the path", path, len(matches)))
+	}
+}
+
+// describe is the one-line header of a chunk shown to the model
+func describe(c *domain.CodeChunk) string {
+	s := fmt.Sprintf("%s:%d-%d %s", c.FilePath, c.StartLine, c.EndLine, c.ChunkType)
+	if name := c.Metadata["name"]; name != "" {
+		if receiver := c.Metadata["receiver"]; receiver != "" {
+			name = receiver + "." + name
+		}
+		s += " " + name
+	}
+	return s + " (id " + c.ID + ")"
+}
+
+func preview(content string, lines int) string {
+	parts := strings.SplitN(content, "\n", lines+1)
+	if len(parts) > lines {
+		return strings.Join(parts[:lines], "\n") + "\n..."
+	}
+	return content
+}
@@ -3,13 +3,19 @@ package api
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Guru2308/rag-code/internal/chat"
 	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/feedback"
 	"github.com/Guru2308/rag-code/internal/indexing"
 	"github.com/Guru2308/rag-code/internal/llm"
 	"github.com/Guru2308/rag-code/internal/logger"
 	"github.com/Guru2308/rag-code/internal/prompt"
+	"github.com/Guru2308/rag-code/internal/querylog"
 	"github.com/Guru2308/rag-code/internal/retrieval"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -23,9 +29,52 @@ type Server struct {
 	retriever *retrieval.Retriever
 	llm       *llm.OllamaLLM
 	prompter  prompt.Generator
+	feedback  FeedbackStore
+	queryLog  QueryLog
+	chat      ChatService
+	agent     Agent
+	grep      Grepper
 	port      string
 }
 
+// Stages timed by the server in addition to the retrieval stages
+const (
+	stagePrompt   = "prompt"
+	stageGenerate = "generate"
+)
+
+// FeedbackStore records query traces and the feedback given on them
+type FeedbackStore interface {
+	SaveTrace(ctx context.Context, trace *domain.QueryTrace) error
+	Submit(ctx context.Context, req domain.FeedbackRequest) (*domain.Feedback, error)
+	List(ctx context.Context) ([]*domain.Feedback, error)
+}
+
+// QueryLog records queries and summarizes them
+type QueryLog interface {
+	Log(ctx context.Context, entry *querylog.Entry)
+	Summarize(ctx context.Context, opts querylog.SummaryOptions) (*querylog.Summary, error)
+}
+
+// ChatService prepares chat messages with the history of their session and
+// records the answers
+type ChatService interface {
+	Prepare(ctx context.Context, req domain.ChatRequest) (*chat.Turn, error)
+	Finish(ctx context.Context, turn *chat.Turn, queryID, answer string) error
+	Session(ctx context.Context, id string) (*domain.ChatSession, error)
+	DeleteSession(ctx context.Context, id string) error
+}
+
+// Agent answers a question by calling tools until it can answer
+type Agent interface {
+	Run(ctx context.Context, req domain.AgentRequest, onStep func(domain.AgentStep)) (*domain.AgentResponse, error)
+}
+
+// Grepper finds the lines of indexed code matching a literal or regex
+type Grepper interface {
+	Grep(ctx context.Context, req domain.GrepRequest) (*domain.GrepResponse, error)
+}
+
 // NewServer creates a new API server
 func NewServer(port string, indexer *indexing.Indexer, retriever *retrieval.Retriever, llmClient *llm.OllamaLLM, prompter prompt.Generator) *Server {
 	gin.SetMode(gin.ReleaseMode)
@@ -57,6 +106,32 @@ func NewServer(port string, indexer *indexing.Indexer, retriever *retrieval.Retr
 	return s
 }
 
+// SetFeedbackStore enables the feedback endpoints. Without a store,
+// feedback is refused.
+func (s *Server) SetFeedbackStore(store FeedbackStore) {
+	s.feedback = store
+}
+
+// SetQueryLog enables query logging and the analytics endpoint
+func (s *Server) SetQueryLog(log QueryLog) {
+	s.queryLog = log
+}
+
+// SetChat enables the chat endpoints
+func (s *Server) SetChat(svc ChatService) {
+	s.chat = svc
+}
+
+// SetAgent enables the agent endpoint
+func (s *Server) SetAgent(a Agent) {
+	s.agent = a
+}
+
+// SetGrep enables the grep endpoint
+func (s *Server) SetGrep(g Grepper) {
+	s.grep = g
+}
+
 func (s *Server) setupRoutes() {
 	// Swagger documentation
 	s.Router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -65,7 +140,20 @@ func (s *Server) setupRoutes() {
 	{
 		api.POST("/index", s.handleIndex)
 		api.POST("/query", s.handleQuery)
+		api.POST("/search", s.handleSearch)
+		api.POST("/grep", s.handleGrep)
+		api.POST("/chat", s.handleChat)
+		api.GET("/chat/:id", s.handleGetChat)
+		api.DELETE("/chat/:id", s.handleDeleteChat)
+		api.POST("/agent", s.handleAgent)
+		api.GET("/chunks/:id", s.handleExplainChunk)
 		api.GET("/status", s.handleStatus)
+		api.GET("/jobs", s.handleListJobs)
+		api.GET("/jobs/:id", s.handleGetJob)
+		api.POST("/verify", s.handleVerify)
+		api.POST("/feedback", s.handleFeedback)
+		api.GET("/feedback/export", s.handleExportFeedback)
+		api.GET("/analytics", s.handleAnalytics)
 	}
 }
 
@@ -137,14 +225,15 @@ func (s *Server) handleIndex(c *gin.Context) {
 
 // handleQuery handles codebase queries
 // @Summary      Query the codebase
-// @Description  Search and answer questions about the codebase using hybrid retrieval and LLM
+// @Description  Search and answer questions about the codebase using hybrid retrieval and LLM. With stream=true the answer is sent as server-sent events: one "results" event, then "token" events, then "done" (or "error"). The query_id identifies the query in the query log and when giving feedback.
 // @Tags         query
 // @Accept       json
 // @Produce      json
-// @Param        query  body      domain.SearchQuery  true  "Search query"
-// @Success      200    {object}  map[string]interface{}
-// @Failure      400    {object}  map[string]string
-// @Failure      500    {object}  map[string]string
+// @Param        query   body      domain.SearchQuery  true   "Search query"
+// @Param        stream  query     bool                false  "Stream the answer as server-sent events"
+// @Success      200     {object}  map[string]interface{}
+// @Failure      400     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
 // @Router       /query [post]
 func (s *Server) handleQuery(c *gin.Context) {
 	var req domain.SearchQuery
@@ -157,18 +246,26 @@ func (s *Server) handleQuery(c *gin.Context) {
 		req.MaxResults = 5
 	}
 
+	ctx, timings := retrieval.WithStageTimings(c.Request.Context())
+	entry := querylog.NewEntry(querylog.NewID(), "query", req, time.Now())
+
 	// 1. Retrieve relevant chunks
-	results, err := s.retriever.Retrieve(c.Request.Context(), req)
+	results, err := s.retriever.Retrieve(ctx, req)
 	if err != nil {
 		logger.Error("Retrieval failed", "error", err)
+		s.logQuery(ctx, entry, timings, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve context"})
 		return
 	}
+	entry.SetResults(results)
 
 	// 2. Prepare LLM prompt using the prompter
-	promptStr, err := s.prompter.Generate(c.Request.Context(), req.Query, results)
+	start := time.Now()
+	promptStr, err := s.prompter.Generate(ctx, req.Query, results)
+	timings.Record(stagePrompt, time.Since(start))
 	if err != nil {
 		logger.Error("Prompt generation failed", "error", err)
+		s.logQuery(ctx, entry, timings, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate prompt"})
 		return
 	}
@@ -180,29 +277,602 @@ func (s *Server) handleQuery(c *gin.Context) {
 		},
 	}
 
+	entry.Model = s.llm.Model()
+	s.recordTrace(ctx, entry.ID, "query", req, results)
+	body := gin.H{"query_id": entry.ID, "results": results}
+	if len(timings.PartialStages()) > 0 {
+		body["partial"] = true
+	}
+	if c.Query("stream") == "true" {
+		s.streamAnswer(ctx, c, entry, timings, body, messages, nil)
+		return
+	}
+
 	// 3. Generate response
-	response, err := s.llm.Generate(c.Request.Context(), messages)
+	start = time.Now()
+	response, err := s.llm.Generate(ctx, messages)
+	timings.Record(stageGenerate, time.Since(start))
 	if err != nil {
 		logger.Error("LLM generation failed", "error", err)
+		s.logQuery(ctx, entry, timings, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate response"})
 		return
 	}
 
 	logger.Info("Generated LLM response", "query", req.Query, "response_length", len(response))
+	entry.AnswerLength = len(response)
+	s.logQuery(ctx, entry, timings, nil)
+
+	body["response"] = response
+	c.JSON(http.StatusOK, body)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"response": response,
-		"results":  results,
+// streamAnswer sends the results event and then the LLM answer token by
+// token as server-sent events. Event data is always JSON so tokens keep their
+// leading whitespace. finish, if set, receives the complete answer before
+// "done" is sent; its error is sent as an "error" event instead.
+func (s *Server) streamAnswer(ctx context.Context, c *gin.Context, entry *querylog.Entry, timings *retrieval.StageTimings, results any, messages []llm.ChatMessage, finish func(answer string) error) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	c.SSEvent("results", results)
+	c.Writer.Flush()
+
+	var answer strings.Builder
+	start := time.Now()
+	err := s.llm.StreamGenerate(ctx, messages, func(token string) error {
+		answer.WriteString(token)
+		c.SSEvent("token", gin.H{"content": token})
+		c.Writer.Flush()
+		return nil
 	})
+	timings.Record(stageGenerate, time.Since(start))
+	entry.AnswerLength = answer.Len()
+	if err != nil {
+		logger.Error("LLM streaming failed", "error", err)
+		s.logQuery(ctx, entry, timings, err)
+		c.SSEvent("error", gin.H{"error": "failed to generate response"})
+		c.Writer.Flush()
+		return
+	}
+	if finish != nil {
+		if err := finish(answer.String()); err != nil {
+			logger.Error("Finishing streamed answer failed", "query_id", entry.ID, "error", err)
+			s.logQuery(ctx, entry, timings, err)
+			c.SSEvent("error", gin.H{"error": "failed to store answer"})
+			c.Writer.Flush()
+			return
+		}
+	}
+
+	logger.Info("Streamed LLM response", "query", entry.Query, "response_length", entry.AnswerLength)
+	s.logQuery(ctx, entry, timings, nil)
+	c.SSEvent("done", gin.H{})
+	c.Writer.Flush()
+}
+
+// handleChat answers a message in a multi-turn conversation
+// @Summary      Chat about the codebase
+// @Description  Answer a message with the history of its session. A follow-up is condensed into a standalone retrieval query using the conversation, and chunks from the previous answer that are still relevant are kept in context. Omit session_id to start a new session. With stream=true the answer is sent as server-sent events like /query, and the "results" event carries the session_id.
+// @Tags         query
+// @Accept       json
+// @Produce      json
+// @Param        request  body      domain.ChatRequest  true   "Chat message"
+// @Param        stream   query     bool                false  "Stream the answer as server-sent events"
+// @Success      200      {object}  domain.ChatResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /chat [post]
+func (s *Server) handleChat(c *gin.Context) {
+	if s.chat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "chat is not enabled"})
+		return
+	}
+	var req domain.ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, timings := retrieval.WithStageTimings(c.Request.Context())
+	entry := querylog.NewEntry(querylog.NewID(), "chat", domain.SearchQuery{
+		Query: req.Message, Language: req.Language, FilePath: req.FilePath, MaxResults: req.MaxResults,
+	}, time.Now())
+
+	turn, err := s.chat.Prepare(ctx, req)
+	if err != nil {
+		s.logQuery(ctx, entry, timings, err)
+		switch {
+		case errors.Is(err, errors.ErrorTypeValidation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errors.ErrorTypeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logger.Error("Preparing chat message failed", "session_id", req.SessionID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve context"})
+		}
+		return
+	}
+
+	entry.Query = turn.Query.Query
+	entry.MaxResults = turn.Query.MaxResults
+	entry.SetResults(turn.Results)
+	entry.Model = s.llm.Model()
+	s.recordTrace(ctx, entry.ID, "chat", turn.Query, turn.Results)
+
+	resp := &domain.ChatResponse{
+		SessionID: turn.Session.ID,
+		QueryID:   entry.ID,
+		Query:     turn.Query.Query,
+		Results:   turn.Results,
+		Reused:    turn.Reused,
+		Partial:   len(timings.PartialStages()) > 0,
+	}
+	if c.Query("stream") == "true" {
+		s.streamAnswer(ctx, c, entry, timings, resp, turn.Messages, func(answer string) error {
+			return s.chat.Finish(ctx, turn, entry.ID, answer)
+		})
+		return
+	}
+
+	start := time.Now()
+	response, err := s.llm.Generate(ctx, turn.Messages)
+	timings.Record(stageGenerate, time.Since(start))
+	if err == nil {
+		entry.AnswerLength = len(response)
+		err = s.chat.Finish(ctx, turn, entry.ID, response)
+	}
+	s.logQuery(ctx, entry, timings, err)
+	if err != nil {
+		logger.Error("Answering chat message failed", "session_id", turn.Session.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate response"})
+		return
+	}
+
+	resp.Response = response
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleGetChat returns a chat session with its history
+// @Summary      Get a chat session
+// @Description  Get the messages of a chat session, with the standalone query and retrieved chunks of each turn
+// @Tags         query
+// @Produce      json
+// @Param        id   path      string  true  "Session ID"
+// @Success      200  {object}  domain.ChatSession
+// @Failure      404  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /chat/{id} [get]
+func (s *Server) handleGetChat(c *gin.Context) {
+	if s.chat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "chat is not enabled"})
+		return
+	}
+	session, err := s.chat.Session(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.chatSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// handleDeleteChat ends a chat session
+// @Summary      Delete a chat session
+// @Tags         query
+// @Param        id   path  string  true  "Session ID"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /chat/{id} [delete]
+func (s *Server) handleDeleteChat(c *gin.Context) {
+	if s.chat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "chat is not enabled"})
+		return
+	}
+	if err := s.chat.DeleteSession(c.Request.Context(), c.Param("id")); err != nil {
+		s.chatSessionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) chatSessionError(c *gin.Context, err error) {
+	if errors.Is(err, errors.ErrorTypeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	logger.Error("Chat session request failed", "id", c.Param("id"), "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load chat session"})
+}
+
+// handleAgent answers a question with the agent
+// @Summary      Ask the agent
+// @Description  Answer a question by letting the LLM call tools (search, get_chunk, callers, callees, read_file) until it can answer or its step or token budget runs out. The response includes every tool call. With stream=true each tool call is sent as a "step" server-sent event as it completes, followed by a "result" event with the full response and "done".
+// @Tags         query
+// @Accept       json
+// @Produce      json
+// @Param        request  body      domain.AgentRequest  true   "Question"
+// @Param        stream   query     bool                 false  "Stream the tool calls as server-sent events"
+// @Success      200      {object}  domain.AgentResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /agent [post]
+func (s *Server) handleAgent(c *gin.Context) {
+	if s.agent == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the agent is not enabled"})
+		return
+	}
+	var req domain.AgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, timings := retrieval.WithStageTimings(c.Request.Context())
+	entry := querylog.NewEntry(querylog.NewID(), "agent", domain.SearchQuery{Query: req.Question}, time.Now())
+	entry.Model = s.llm.Model()
+
+	stream := c.Query("stream") == "true"
+	var onStep func(domain.AgentStep)
+	if stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		onStep = func(step domain.AgentStep) {
+			c.SSEvent("step", step)
+			c.Writer.Flush()
+		}
+	}
+
+	resp, err := s.agent.Run(ctx, req, onStep)
+	if err == nil {
+		resp.QueryID = entry.ID
+		entry.SetResults(resp.Results)
+		entry.AnswerLength = len(resp.Answer)
+	}
+	s.logQuery(ctx, entry, timings, err)
+	if err != nil {
+		status, msg := http.StatusInternalServerError, "failed to answer the question"
+		if errors.Is(err, errors.ErrorTypeValidation) {
+			status, msg = http.StatusBadRequest, err.Error()
+		} else {
+			logger.Error("Agent run failed", "error", err)
+		}
+		if stream {
+			c.SSEvent("error", gin.H{"error": msg})
+			c.Writer.Flush()
+			return
+		}
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	s.recordTrace(ctx, entry.ID, "agent", domain.SearchQuery{Query: req.Question}, resp.Results)
+
+	if stream {
+		c.SSEvent("result", resp)
+		c.SSEvent("done", gin.H{})
+		c.Writer.Flush()
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleSearch runs retrieval without generating an answer
+// @Summary      Search the codebase
+// @Description  Run hybrid retrieval only and return one page of ranked chunks with per-stage scores. Pass next_cursor back as cursor for the next page.
+// @Tags         query
+// @Accept       json
+// @Produce      json
+// @Param        query  body      domain.SearchRequest  true  "Search request"
+// @Success      200    {object}  domain.SearchPage
+// @Failure      400    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /search [post]
+func (s *Server) handleSearch(c *gin.Context) {
+	var req domain.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, timings := retrieval.WithStageTimings(c.Request.Context())
+	entry := querylog.NewEntry(querylog.NewID(), "search", req.SearchQuery, time.Now())
+
+	page, err := s.retriever.Search(ctx, req)
+	if err != nil {
+		s.logQuery(ctx, entry, timings, err)
+		if errors.Is(err, errors.ErrorTypeValidation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Retrieval failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve results"})
+		return
+	}
+
+	results := page.Results
+	for _, g := range page.Groups {
+		results = append(results, g.Results...)
+	}
+	entry.SetResults(results)
+	s.logQuery(ctx, entry, timings, nil)
+	s.recordTrace(ctx, entry.ID, "search", req.SearchQuery, results)
+
+	page.QueryID = entry.ID
+	c.JSON(http.StatusOK, page)
+}
+
+// recordTrace stores what a query returned so feedback can refer to it.
+// Failing to store the trace does not fail the query; it just cannot
+// receive feedback.
+func (s *Server) recordTrace(ctx context.Context, id, kind string, query domain.SearchQuery, results []*domain.SearchResult) {
+	if s.feedback == nil {
+		return
+	}
+	if err := s.feedback.SaveTrace(ctx, feedback.NewTrace(id, kind, query, results)); err != nil {
+		logger.Warn("Failed to record query trace", "query_id", id, "error", err)
+	}
+}
+
+// logQuery completes entry with its timings and outcome and writes it to
+// the query log, if one is configured
+func (s *Server) logQuery(ctx context.Context, entry *querylog.Entry, timings *retrieval.StageTimings, err error) {
+	if s.queryLog == nil {
+		return
+	}
+	entry.StagesMS = timings.Milliseconds()
+	entry.Partial = timings.PartialStages()
+	entry.TotalMS = float64(time.Since(entry.Time).Microseconds()) / 1000
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.queryLog.Log(ctx, entry)
+}
+
+// handleGrep finds the lines of indexed code matching a pattern
+// @Summary      Grep the codebase
+// @Description  Find the lines of indexed code matching a literal or regular expression, case-insensitive unless case_sensitive is set, optionally restricted to paths matching globs ("!" excludes). Candidates are found through a trigram index.
+// @Tags         query
+// @Accept       json
+// @Produce      json
+// @Param        request  body      domain.GrepRequest  true  "Grep request"
+// @Success      200      {object}  domain.GrepResponse
+// @Failure      400      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /grep [post]
+func (s *Server) handleGrep(c *gin.Context) {
+	if s.grep == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the trigram index is not enabled"})
+		return
+	}
+	var req domain.GrepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := s.grep.Grep(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, errors.ErrorTypeValidation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Grep failed", "pattern", req.Pattern, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search the codebase"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleExplainChunk returns a chunk with its dependency graph neighbours
+// @Summary      Explain a chunk
+// @Description  Get a stored chunk with its callers, callees, imports and parent/child definitions
+// @Tags         query
+// @Produce      json
+// @Param        id   path      string  true  "Chunk ID"
+// @Success      200  {object}  domain.ChunkExplanation
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /chunks/{id} [get]
+func (s *Server) handleExplainChunk(c *gin.Context) {
+	explanation, err := s.retriever.Explain(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, errors.ErrorTypeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "chunk not found"})
+			return
+		}
+		logger.Error("Explain failed", "chunk_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load chunk"})
+		return
+	}
+	c.JSON(http.StatusOK, explanation)
 }
 
 // handleStatus returns the server status
 // @Summary      Health check
-// @Description  Check if the API server is alive
+// @Description  Check if the API server is alive and report indexing statistics
 // @Tags         system
 // @Produce      json
-// @Success      200  {object}  map[string]string
+// @Success      200  {object}  map[string]interface{}
 // @Router       /status [get]
 func (s *Server) handleStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+	status := gin.H{"status": "alive"}
+	if s.indexer != nil {
+		status["index"] = s.indexer.Status()
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// handleListJobs lists tracked indexing jobs
+// @Summary      List indexing jobs
+// @Description  List tracked indexing jobs (such as coalesced watcher batches), newest first
+// @Tags         indexing
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Router       /jobs [get]
+func (s *Server) handleListJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": s.indexer.ListJobs()})
+}
+
+// handleGetJob returns a single indexing job
+// @Summary      Get an indexing job
+// @Description  Get the status and progress of an indexing job
+// @Tags         indexing
+// @Produce      json
+// @Param        id   path      string  true  "Job ID"
+// @Success      200  {object}  domain.IndexingJob
+// @Failure      404  {object}  map[string]string
+// @Router       /jobs/{id} [get]
+func (s *Server) handleGetJob(c *gin.Context) {
+	job, err := s.indexer.GetJob(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, errors.ErrorTypeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+type verifyRequest struct {
+	Path   string `json:"path" binding:"required"`
+	Repair bool   `json:"repair"`
+}
+
+// handleVerify checks the index against the file system
+// @Summary      Verify the index
+// @Description  Compare the files under a path with the vector store, keyword index and dependency graph, reporting orphaned, missing and stale entries. With repair set, orphans are removed and missing or stale files are re-indexed.
+// @Tags         indexing
+// @Accept       json
+// @Produce      json
+// @Param        request  body      verifyRequest  true  "Path to verify"
+// @Success      200      {object}  domain.VerifyReport
+// @Failure      400      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /verify [post]
+func (s *Server) handleVerify(c *gin.Context) {
+	var req verifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := s.indexer.Verify(c.Request.Context(), req.Path, indexing.VerifyOptions{Repair: req.Repair})
+	if err != nil {
+		if errors.Is(err, errors.ErrorTypeValidation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Verify failed", "path", req.Path, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// handleFeedback records feedback on the results of a query
+// @Summary      Give feedback on a query
+// @Description  Rate the results of a query or search by its query_id: each result can be rated up, down or marked as the answer, the answer as a whole can be marked helpful or not, and a note can be attached. Feedback is stored with the retrieval trace of the query; giving it again replaces it.
+// @Tags         feedback
+// @Accept       json
+// @Produce      json
+// @Param        request  body      domain.FeedbackRequest  true  "Feedback"
+// @Success      201      {object}  domain.Feedback
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      503      {object}  map[string]string
+// @Router       /feedback [post]
+func (s *Server) handleFeedback(c *gin.Context) {
+	if s.feedback == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "feedback is not enabled"})
+		return
+	}
+	var req domain.FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fb, err := s.feedback.Submit(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrorTypeValidation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errors.ErrorTypeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logger.Error("Storing feedback failed", "query_id", req.QueryID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store feedback"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, fb)
+}
+
+// handleExportFeedback exports feedback as an eval golden set
+// @Summary      Export feedback as a golden set
+// @Description  Turn the results rated up or marked as the answer into a golden set for rag eval. Repeated queries are merged; results rated down are left out.
+// @Tags         feedback
+// @Produce      json
+// @Param        name  query     string  false  "Golden set name (default feedback)"
+// @Success      200   {object}  eval.GoldenSet
+// @Failure      500   {object}  map[string]string
+// @Failure      503   {object}  map[string]string
+// @Router       /feedback/export [get]
+func (s *Server) handleExportFeedback(c *gin.Context) {
+	if s.feedback == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "feedback is not enabled"})
+		return
+	}
+	entries, err := s.feedback.List(c.Request.Context())
+	if err != nil {
+		logger.Error("Listing feedback failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load feedback"})
+		return
+	}
+	c.JSON(http.StatusOK, feedback.GoldenSet(c.DefaultQuery("name", "feedback"), entries))
+}
+
+// handleAnalytics summarizes the query log
+// @Summary      Query analytics
+// @Description  Summarize the queries logged within a time window: top queries, zero-result queries, the slowest stages by p95 latency and the most retrieved files
+// @Tags         system
+// @Produce      json
+// @Param        since  query     string  false  "Window to summarize, as a duration (default 24h)"
+// @Param        limit  query     int     false  "Entries per list (default 10)"
+// @Success      200    {object}  querylog.Summary
+// @Failure      400    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Failure      503    {object}  map[string]string
+// @Router       /analytics [get]
+func (s *Server) handleAnalytics(c *gin.Context) {
+	if s.queryLog == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "query logging is not enabled"})
+		return
+	}
+	window, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+	if err != nil || window <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a positive duration such as 24h"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	summary, err := s.queryLog.Summarize(c.Request.Context(), querylog.SummaryOptions{Since: time.Now().Add(-window), Limit: limit})
+	if err != nil {
+		logger.Error("Summarizing query log failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read query log"})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
 }
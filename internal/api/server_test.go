@@ -5,18 +5,28 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/Guru2308/rag-code/internal/agent"
+	"github.com/Guru2308/rag-code/internal/chat"
 	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/eval"
+	"github.com/Guru2308/rag-code/internal/feedback"
 	"github.com/Guru2308/rag-code/internal/indexing"
 	"github.com/Guru2308/rag-code/internal/llm"
 	"github.com/Guru2308/rag-code/internal/logger"
 	"github.com/Guru2308/rag-code/internal/mocks"
 	"github.com/Guru2308/rag-code/internal/prompt"
+	"github.com/Guru2308/rag-code/internal/querylog"
 	"github.com/Guru2308/rag-code/internal/retrieval"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 func init() {
@@ -258,7 +268,8 @@ func TestServer_HandleQuery_DefaultMaxResults(t *testing.T) {
 	defer llmServer.Close()
 
 	llmClient := llm.NewOllamaLLM(llmServer.URL, "model")
-	server := NewServer("8080", nil, retriever, llmClient, nil)
+	prompter, _ := prompt.NewTemplateGenerator("")
+	server := NewServer("8080", nil, retriever, llmClient, prompter)
 
 	// Query without MaxResults
 	query := domain.SearchQuery{Query: "test"}
@@ -272,3 +283,561 @@ func TestServer_HandleQuery_DefaultMaxResults(t *testing.T) {
 		t.Errorf("Expected 200, got %d", w.Code)
 	}
 }
+
+func TestServer_HandleJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	indexer := indexing.NewIndexer(&mocks.MockParser{}, &mocks.MockChunker{}, &mocks.MockEmbedder{}, &mocks.MockChunkStore{}, nil, nil, 1)
+	job, err := indexer.IndexBatch(context.Background(), "/repo", nil)
+	if err != nil {
+		t.Fatalf("IndexBatch() error = %v", err)
+	}
+	server := NewServer("8080", indexer, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/jobs", nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var list struct {
+		Jobs []domain.IndexingJob `json:"jobs"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Jobs) != 1 || list.Jobs[0].ID != job.ID {
+		t.Errorf("Unexpected jobs: %+v", list.Jobs)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/jobs/"+job.ID, nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/jobs/missing", nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("Expected 404 for unknown job, got %d", w.Code)
+	}
+}
+
+func TestServer_HandleVerify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	indexer := indexing.NewIndexer(&mocks.MockParser{}, &mocks.MockChunker{}, &mocks.MockEmbedder{}, &mocks.MockChunkStore{}, nil, nil, 1)
+	server := NewServer("8080", indexer, nil, nil, nil)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing path", `{}`, 400},
+		{"nonexistent path", `{"path": "/does/not/exist"}`, 400},
+		// The mock store cannot be listed
+		{"store without inventory", `{"path": "` + t.TempDir() + `"}`, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/verify", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			server.Router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_HandleSearch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotLimit int
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			gotLimit = limit
+			return []*domain.SearchResult{
+				{Chunk: &domain.CodeChunk{ID: "1", Content: "code"}},
+			}, nil
+		},
+	}
+
+	retriever := retrieval.NewRetriever(mockEmbedder, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+	server := NewServer("8080", nil, retriever, nil, nil)
+
+	body, _ := json.Marshal(domain.SearchQuery{Query: "test"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/search", bytes.NewBuffer(body))
+	server.Router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Query   string                 `json:"query"`
+		Results []*domain.SearchResult `json:"results"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Query != "test" || len(resp.Results) != 1 {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+	if gotLimit == 0 {
+		t.Error("Expected default MaxResults to be applied")
+	}
+}
+
+func TestServer_HandleExplainChunk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockStore := &mocks.MockChunkStore{
+		GetFunc: func(ctx context.Context, id string) (*domain.CodeChunk, error) {
+			if id == "known" {
+				return &domain.CodeChunk{ID: id, Content: "code", Embedding: []float32{0.1}}, nil
+			}
+			return nil, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(nil, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+	server := NewServer("8080", nil, retriever, nil, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/chunks/known", nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var explanation domain.ChunkExplanation
+	json.Unmarshal(w.Body.Bytes(), &explanation)
+	if explanation.Chunk == nil || explanation.Chunk.ID != "known" || explanation.Chunk.Embedding != nil {
+		t.Errorf("Unexpected explanation: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/chunks/unknown", nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestServer_HandleQuery_Stream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{
+				{Chunk: &domain.CodeChunk{ID: "1", Content: "code"}},
+			}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(mockEmbedder, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]any{"message": map[string]string{"content": "Hello"}, "done": false})
+		enc.Encode(map[string]any{"message": map[string]string{"content": " world"}, "done": true})
+	}))
+	defer llmServer.Close()
+
+	prompter, _ := prompt.NewTemplateGenerator("")
+	server := NewServer("8080", nil, retriever, llm.NewOllamaLLM(llmServer.URL, "model"), prompter)
+
+	body, _ := json.Marshal(domain.SearchQuery{Query: "test"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/query?stream=true", bytes.NewBuffer(body))
+	server.Router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	got := w.Body.String()
+	for _, want := range []string{"event:results", `event:token`, `" world"`, "event:done"} {
+		if !bytes.Contains([]byte(got), []byte(want)) {
+			t.Errorf("Expected %q in stream, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestServer_HandleSearch_InvalidCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	retriever := retrieval.NewRetriever(&mocks.MockEmbedder{}, &mocks.MockChunkStore{}, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+	server := NewServer("8080", nil, retriever, nil, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/search", bytes.NewBufferString(`{"query":"test","cursor":"bogus"}`))
+	server.Router.ServeHTTP(w, req)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for invalid cursor, got %d", w.Code)
+	}
+}
+
+func TestServer_Feedback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{
+				{Chunk: &domain.CodeChunk{ID: "1", FilePath: "/repo/auth.go", Content: "code", Metadata: map[string]string{"name": "Login"}}},
+			}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(mockEmbedder, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+	server := NewServer("8080", nil, retriever, nil, nil)
+	server.SetFeedbackStore(feedback.NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test:", 0))
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		server.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/search", `{"query":"how do users log in"}`)
+	var page domain.SearchPage
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if w.Code != 200 || page.QueryID == "" {
+		t.Fatalf("Expected a query ID, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing query id", `{"note":"x"}`, 400},
+		{"unknown query", `{"query_id":"q-missing","note":"x"}`, 404},
+		{"unshown chunk", `{"query_id":"` + page.QueryID + `","results":[{"chunk_id":"2","rating":"up"}]}`, 400},
+		{"valid", `{"query_id":"` + page.QueryID + `","results":[{"chunk_id":"1","rating":"answer"}],"note":"spot on"}`, 201},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := post("/api/feedback", tt.body); w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/feedback/export?name=labels", nil)
+	server.Router.ServeHTTP(w, req)
+	var set eval.GoldenSet
+	json.Unmarshal(w.Body.Bytes(), &set)
+	if w.Code != 200 || set.Name != "labels" || len(set.Queries) != 1 || set.Queries[0].Expected[0].Symbol != "Login" {
+		t.Errorf("Unexpected export %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServer_Feedback_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer("8080", nil, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/feedback", bytes.NewBufferString(`{"query_id":"q-1","note":"x"}`))
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("Expected 503 without a feedback store, got %d", w.Code)
+	}
+}
+
+func TestServer_QueryLogAndAnalytics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			if text == "fail" {
+				return nil, errors.New("embedding failed")
+			}
+			return []float32{0.1}, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{{Chunk: &domain.CodeChunk{ID: "1", FilePath: "/repo/auth.go", Content: "code"}}}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(mockEmbedder, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]string{"content": "four"}, "done": true})
+	}))
+	defer llmServer.Close()
+	prompter, _ := prompt.NewTemplateGenerator("")
+	server := NewServer("8080", nil, retriever, llm.NewOllamaLLM(llmServer.URL, "test-model"), prompter)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/analytics", nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("Expected 503 without a query log, got %d", w.Code)
+	}
+
+	server.SetQueryLog(querylog.New(querylog.NewFileSink(filepath.Join(t.TempDir(), "queries.jsonl"), 0), nil))
+	for _, q := range []string{"token refresh", "Token refresh", "fail"} {
+		body, _ := json.Marshal(domain.SearchQuery{Query: q})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/query", bytes.NewBuffer(body))
+		server.Router.ServeHTTP(w, req)
+		var resp map[string]any
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code == 200 && resp["query_id"] == "" {
+			t.Errorf("Expected a query ID: %s", w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/analytics?since=1h&limit=5", nil)
+	server.Router.ServeHTTP(w, req)
+	var summary querylog.Summary
+	json.Unmarshal(w.Body.Bytes(), &summary)
+	if w.Code != 200 || summary.Queries != 3 || summary.Errors != 1 {
+		t.Fatalf("Unexpected summary %d: %s", w.Code, w.Body.String())
+	}
+	if len(summary.TopQueries) != 2 || summary.TopQueries[0].Count != 2 || summary.Models["test-model"] != 2 {
+		t.Errorf("Unexpected top queries %+v, models %v", summary.TopQueries, summary.Models)
+	}
+	if len(summary.TopFiles) != 1 || summary.TopFiles[0].FilePath != "/repo/auth.go" || summary.MeanAnswerLength != 4 {
+		t.Errorf("Unexpected files %+v, answer length %v", summary.TopFiles, summary.MeanAnswerLength)
+	}
+	stages := make(map[string]bool)
+	for _, st := range summary.SlowestStages {
+		stages[st.Stage] = true
+	}
+	for _, stage := range []string{retrieval.StageEmbed, retrieval.StageVectorSearch, stagePrompt, stageGenerate} {
+		if !stages[stage] {
+			t.Errorf("Stage %q missing from %+v", stage, summary.SlowestStages)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/analytics?since=yesterday", nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for invalid since, got %d", w.Code)
+	}
+}
+
+func TestServer_Chat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	var searched []string
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			searched = append(searched, text)
+			return []float32{0.1}, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{{Chunk: &domain.CodeChunk{ID: "1", FilePath: "/repo/auth.go", Content: "code"}}}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(mockEmbedder, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reply := "Login checks the password."
+		if strings.Contains(string(body), "standalone search query") {
+			reply = "where is Login called"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]string{"content": reply}, "done": true})
+	}))
+	defer llmServer.Close()
+	llmClient := llm.NewOllamaLLM(llmServer.URL, "model")
+	prompter, _ := prompt.NewTemplateGenerator("")
+	server := NewServer("8080", nil, retriever, llmClient, prompter)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		server.Router.ServeHTTP(w, req)
+		return w
+	}
+	if w := post("/api/chat", `{"message":"how do users log in"}`); w.Code != 503 {
+		t.Errorf("Expected 503 without chat, got %d", w.Code)
+	}
+
+	store := chat.NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test:", 0)
+	server.SetChat(chat.NewService(store, retriever, mockStore, llmClient, prompter, chat.Config{}))
+
+	w := post("/api/chat", `{"message":"how do users log in"}`)
+	var first domain.ChatResponse
+	json.Unmarshal(w.Body.Bytes(), &first)
+	if w.Code != 200 || first.SessionID == "" || first.QueryID == "" || first.Response != "Login checks the password." {
+		t.Fatalf("Unexpected first answer %d: %s", w.Code, w.Body.String())
+	}
+
+	w = post("/api/chat?stream=true", `{"session_id":"`+first.SessionID+`","message":"and where is it called?"}`)
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"query":"where is Login called"`) || !strings.Contains(w.Body.String(), "event:done") {
+		t.Fatalf("Unexpected follow-up %d: %s", w.Code, w.Body.String())
+	}
+	if got := searched[len(searched)-1]; got != "where is Login called" {
+		t.Errorf("Expected retrieval with the condensed query, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/chat/"+first.SessionID, nil)
+	server.Router.ServeHTTP(w, req)
+	var session domain.ChatSession
+	json.Unmarshal(w.Body.Bytes(), &session)
+	if w.Code != 200 || len(session.Turns) != 4 || session.Turns[3].Content != "Login checks the password." {
+		t.Errorf("Unexpected session %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := post("/api/chat", `{"session_id":"s-missing","message":"hi"}`); w.Code != 404 {
+		t.Errorf("Expected 404 for an unknown session, got %d", w.Code)
+	}
+	if w := post("/api/chat", `{"session_id":"x"}`); w.Code != 400 {
+		t.Errorf("Expected 400 without a message, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/chat/"+first.SessionID, nil)
+	server.Router.ServeHTTP(w, req)
+	if w.Code != 204 {
+		t.Errorf("Expected 204 on delete, got %d", w.Code)
+	}
+}
+
+func TestServer_Agent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{{Chunk: &domain.CodeChunk{ID: "1", FilePath: "/repo/auth.go", Content: "func Login() {}"}}}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(&mocks.MockEmbedder{}, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+
+	replies := []string{`{"tool": "search", "args": {"query": "login"}}`, `{"answer": "Login is in auth.go."}`}
+	calls := 0
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := replies[min(calls, len(replies)-1)]
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]string{"content": reply}, "done": true})
+	}))
+	defer llmServer.Close()
+	llmClient := llm.NewOllamaLLM(llmServer.URL, "model")
+	prompter, _ := prompt.NewTemplateGenerator("")
+	server := NewServer("8080", nil, retriever, llmClient, prompter)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		server.Router.ServeHTTP(w, req)
+		return w
+	}
+	if w := post("/api/agent", `{"question":"where is login"}`); w.Code != 503 {
+		t.Errorf("Expected 503 without the agent, got %d", w.Code)
+	}
+
+	log := &fakeQueryLog{}
+	server.SetQueryLog(log)
+	server.SetAgent(agent.New(retriever, mockStore, nil, llmClient, agent.Config{}))
+
+	w := post("/api/agent", `{"question":"where is login"}`)
+	var resp domain.AgentResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 200 || resp.Answer != "Login is in auth.go." || resp.StoppedBy != domain.AgentStopAnswer || resp.QueryID == "" {
+		t.Fatalf("Unexpected answer %d: %s", w.Code, w.Body.String())
+	}
+	if len(resp.Steps) != 1 || resp.Steps[0].Tool != "search" || len(resp.Results) != 1 {
+		t.Errorf("Unexpected trace %+v", resp)
+	}
+	if len(log.entries) != 1 || log.entries[0].Kind != "agent" || !hasStage(log.entries[0], agent.StageModel) {
+		t.Errorf("Unexpected query log %+v", log.entries)
+	}
+
+	calls = 0
+	w = post("/api/agent?stream=true", `{"question":"where is login"}`)
+	body := w.Body.String()
+	step, result := strings.Index(body, "event:step"), strings.Index(body, "event:result")
+	if step < 0 || result < step || !strings.Contains(body, "event:done") {
+		t.Errorf("Expected step, result and done events, got %s", body)
+	}
+
+	if w := post("/api/agent", `{}`); w.Code != 400 {
+		t.Errorf("Expected 400 without a question, got %d", w.Code)
+	}
+}
+
+func TestServer_Grep(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer("8080", nil, nil, nil, nil)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/grep", bytes.NewBufferString(body))
+		server.Router.ServeHTTP(w, req)
+		return w
+	}
+	if w := post(`{"pattern":"ctx.Done()"}`); w.Code != 503 {
+		t.Errorf("Expected 503 without the trigram index, got %d", w.Code)
+	}
+
+	index := retrieval.NewTrigramIndex()
+	index.AddToInvertedIndex(context.Background(), []*domain.CodeChunk{
+		{ID: "1", FilePath: "watch.go", StartLine: 5, Content: "select {\ncase <-ctx.Done():\n}"},
+	})
+	server.SetGrep(index)
+
+	w := post(`{"pattern":"ctx.Done()","case_sensitive":true}`)
+	var resp domain.GrepResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 200 || len(resp.Matches) != 1 || resp.Matches[0].FilePath != "watch.go" || resp.Matches[0].Line != 6 {
+		t.Fatalf("Unexpected grep response %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := post(`{}`); w.Code != 400 {
+		t.Errorf("Expected 400 without a pattern, got %d", w.Code)
+	}
+	if w := post(`{"pattern":"Err[A-Z","regex":true}`); w.Code != 400 {
+		t.Errorf("Expected 400 for an invalid regex, got %d", w.Code)
+	}
+}
+
+type fakeQueryLog struct{ entries []*querylog.Entry }
+
+func (f *fakeQueryLog) Log(_ context.Context, entry *querylog.Entry) {
+	f.entries = append(f.entries, entry)
+}
+
+func (f *fakeQueryLog) Summarize(context.Context, querylog.SummaryOptions) (*querylog.Summary, error) {
+	return &querylog.Summary{}, nil
+}
+
+func hasStage(entry *querylog.Entry, stage string) bool {
+	_, ok := entry.StagesMS[stage]
+	return ok
+}
@@ -0,0 +1,288 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/agent"
+	"github.com/Guru2308/rag-code/internal/chat"
+	"github.com/Guru2308/rag-code/internal/config"
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/embeddings"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/feedback"
+	"github.com/Guru2308/rag-code/internal/graph"
+	"github.com/Guru2308/rag-code/internal/hierarchy"
+	"github.com/Guru2308/rag-code/internal/indexing"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/prompt"
+	"github.com/Guru2308/rag-code/internal/querylog"
+	"github.com/Guru2308/rag-code/internal/reranker"
+	"github.com/Guru2308/rag-code/internal/retrieval"
+	"github.com/Guru2308/rag-code/internal/vectorstore"
+	"github.com/redis/go-redis/v9"
+)
+
+// embeddingDimensions is the vector size of the default all-minilm model
+const embeddingDimensions = 384
+
+// App holds the wired services shared by the server and the standalone CLI
+type App struct {
+	Config     *config.Config
+	Embedder   *embeddings.OllamaEmbedder
+	LLM        *llm.OllamaLLM
+	Store      vectorstore.Store
+	Redis      *redis.Client // nil when Redis is not configured
+	Keyword    retrieval.KeywordIndex
+	Trigram    *retrieval.TrigramIndex // nil when TRIGRAM_INDEX=false
+	Graph      *graph.Graph
+	Retriever  *retrieval.Retriever
+	Indexer    *indexing.Indexer
+	FileFilter *indexing.FileFilter
+	Prompter   prompt.Generator
+	Feedback   *feedback.Store  // nil without Redis
+	QueryLog   *querylog.Logger // nil when QUERY_LOG=off
+	Chat       *chat.Service    // nil without Redis
+	Agent      *agent.Agent
+}
+
+// New wires every service from cfg and makes sure the vector collection exists
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	a := &App{Config: cfg}
+
+	// 1. Ollama Embedding Service (configurable parallelism)
+	a.Embedder = embeddings.NewOllamaEmbedderWithConfig(
+		cfg.OllamaURL,
+		cfg.EmbeddingModel,
+		cfg.EmbeddingWorkers,
+		cfg.MaxConcurrentEmbeddings,
+	)
+
+	// 2. Ollama LLM Service
+	a.LLM = llm.NewOllamaLLM(cfg.OllamaURL, cfg.LLMModel)
+
+	// 3. Vector Store, in Qdrant or embedded
+	switch cfg.VectorStore {
+	case "local":
+		localCfg := vectorstore.DefaultLocalConfig()
+		localCfg.Index = cfg.VectorIndex
+		localCfg.Quantization = cfg.VectorQuantization
+		store, err := vectorstore.NewLocalStore(cfg.VectorStorePath, localCfg)
+		if err != nil {
+			return nil, err
+		}
+		a.Store = store
+	default:
+		store, err := vectorstore.NewQdrantStore(cfg.VectorStoreURL, cfg.CollectionName)
+		if err != nil {
+			return nil, err
+		}
+		a.Store = store
+	}
+
+	// 4. Keyword Index (for BM25), in Redis or in-process. Redis also holds
+	// chat sessions and feedback, which are disabled when it is not configured.
+	if cfg.RedisEnabled {
+		a.Redis = redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisURL,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+	} else {
+		logger.Info("Redis not configured; chat sessions and feedback are disabled")
+	}
+	switch cfg.KeywordIndex {
+	case "local":
+		local, err := retrieval.NewLocalIndex(cfg.KeywordIndexPath)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.Keyword = local
+	default:
+		redisIndex := retrieval.NewRedisIndex(a.Redis, cfg.KeywordIndexPrefix)
+		redisIndex.SetMaxPostings(cfg.KeywordMaxPostings)
+		if migrated, err := redisIndex.Migrate(ctx); err != nil {
+			logger.Warn("Could not migrate keyword index", "error", err)
+		} else if migrated > 0 {
+			logger.Info("Migrated keyword index to the current layout", "keys", migrated)
+		}
+		a.Keyword = redisIndex
+	}
+	a.Keyword.SetBM25Params(cfg.BM25K1, cfg.BM25B)
+	if a.Redis != nil {
+		a.Feedback = feedback.NewStore(a.Redis, "rag:", cfg.FeedbackTraceTTL)
+	}
+
+	// 4a. Query Log
+	redactor, err := querylog.NewRedactor(cfg.QueryLogRedact, cfg.QueryLogRedactPatterns)
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	switch cfg.QueryLog {
+	case "redis":
+		a.QueryLog = querylog.New(querylog.NewRedisSink(a.Redis, "rag:querylog", int64(cfg.QueryLogMaxEntries)), redactor)
+	case "file":
+		a.QueryLog = querylog.New(querylog.NewFileSink(cfg.QueryLogPath, cfg.QueryLogMaxBytes), redactor)
+	}
+
+	// 5. Hybrid Retrieval Components
+	preprocessor := retrieval.NewQueryPreprocessor()
+	bm25Scorer := retrieval.NewBM25Scorer(cfg.BM25K1, cfg.BM25B, a.Keyword)
+
+	fusionConfig := retrieval.FusionConfig{
+		Strategy:     retrieval.FusionRRF, // Defaulting to RRF for now
+		VectorWeight: cfg.HybridVectorWeight,
+		RRFConstant:  60,
+	}
+
+	// 5a. Phase 4: Dependency Graph and Expander
+	a.Graph = graph.NewGraph()
+	expander := retrieval.NewContextExpander(a.Graph, a.Store)
+
+	// 5b. Phase 5 & 6: Reranker and Hierarchy
+	heuristicCfg := reranker.DefaultHeuristicConfig()
+	if cfg.RerankerConfig != "" {
+		if heuristicCfg, err = reranker.LoadHeuristicConfig(cfg.RerankerConfig); err != nil {
+			a.Close()
+			return nil, err
+		}
+		logger.Info("Loaded reranker weights", "path", cfg.RerankerConfig)
+	}
+	var reRanker reranker.Reranker = reranker.NewHeuristicRerankerWithConfig(heuristicCfg)
+	if cfg.Reranker == "llm" {
+		rerankModel := a.LLM
+		if cfg.LLMRerankModel != cfg.LLMModel {
+			rerankModel = llm.NewOllamaLLM(cfg.OllamaURL, cfg.LLMRerankModel)
+		}
+		reRanker = reranker.NewLLMReranker(reRanker, rerankModel, reranker.LLMConfig{
+			TopN:      cfg.LLMRerankTopN,
+			Budget:    cfg.LLMRerankBudget,
+			CacheSize: cfg.LLMRerankCacheSize,
+		})
+		logger.Info("LLM reranking enabled", "model", cfg.LLMRerankModel, "top_n", cfg.LLMRerankTopN, "budget", cfg.LLMRerankBudget)
+	}
+	if cfg.UseMMR {
+		reRanker = reranker.NewMMRReranker(reRanker, float32(cfg.MMRLambda))
+		logger.Info("MMR reranking enabled", "lambda", cfg.MMRLambda)
+	}
+	hierFilter := hierarchy.NewHierarchicalFilter(3)
+
+	// 6. Retrieval Engine
+	a.Retriever = retrieval.NewRetriever(a.Embedder, a.Store, a.Keyword, bm25Scorer, preprocessor, expander, reRanker, hierFilter, fusionConfig)
+	transformModel := a.LLM
+	if cfg.QueryTransformModel != cfg.LLMModel {
+		transformModel = llm.NewOllamaLLM(cfg.OllamaURL, cfg.QueryTransformModel)
+	}
+	a.Retriever.SetTransformer(retrieval.NewQueryTransformer(transformModel, retrieval.TransformConfig{
+		Budget:    cfg.QueryTransformBudget,
+		CacheSize: cfg.QueryTransformCacheSize,
+	}))
+	a.Retriever.SetDeadlines(retrieval.StageDeadlines{
+		Search:    cfg.SearchDeadline,
+		Rerank:    cfg.RerankDeadline,
+		Expansion: cfg.ExpansionDeadline,
+	})
+
+	// 7. Indexing Pipeline
+	parser := indexing.NewMultiParser()
+	chunker := indexing.NewSemanticChunker(cfg.MaxChunkSize, cfg.ChunkOverlap)
+	a.FileFilter = indexing.NewFileFilter(indexing.FilterConfig{
+		IncludeGlobs:     cfg.IndexIncludeGlobs,
+		ExcludeGlobs:     cfg.IndexExcludeGlobs,
+		IgnoreFiles:      []string{".gitignore", ".ragignore"},
+		RespectGitignore: cfg.RespectGitignore,
+		MaxFileSize:      cfg.MaxFileSize,
+		SkipBinary:       cfg.SkipBinaryFiles,
+		SkipGenerated:    cfg.SkipGenerated,
+	})
+	indexerCfg := indexing.DefaultConfig()
+	indexerCfg.NumWorkers = cfg.NumWorkers
+	indexerCfg.Filter = a.FileFilter
+	a.Indexer = indexing.NewIndexerWithConfig(parser, chunker, a.Embedder, a.Store, a.Retriever, a.Graph, indexerCfg)
+
+	// Initialize the vector collection
+	initCtx, initCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer initCancel()
+	if err := a.Store.InitCollection(initCtx, embeddingDimensions); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	// Files indexed by earlier runs, so deletes and pruning can find them
+	if n, err := a.Indexer.LoadIndexedFiles(ctx); err != nil {
+		logger.Warn("Could not load indexed files from the vector store", "error", err)
+	} else {
+		logger.Info("Indexed files loaded", "files", n)
+	}
+
+	// 7a. Trigram Index, rebuilt in memory from the stored chunks
+	if cfg.TrigramIndex {
+		a.Trigram = retrieval.NewTrigramIndex()
+		if err := loadTrigramIndex(ctx, a.Store, a.Trigram); err != nil {
+			logger.Warn("Could not load trigram index from the vector store", "error", err)
+		} else {
+			logger.Info("Trigram index loaded", "chunks", a.Trigram.Len())
+		}
+		a.Retriever.SetTrigramIndex(a.Trigram)
+	}
+
+	// 7b. Prompt Generator (professional code assistant + reviewer by default)
+	a.Prompter, err = prompt.NewTemplateGenerator(
+		prompt.TemplateByName(cfg.PromptTemplate),
+		prompt.WithMaxTokens(4096),
+		prompt.WithModel(cfg.LLMModel),
+	)
+	if err != nil {
+		a.Close()
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to initialize prompt generator")
+	}
+
+	// 8. Chat Sessions
+	if a.Redis != nil {
+		a.Chat = chat.NewService(chat.NewStore(a.Redis, "rag:", cfg.ChatSessionTTL), a.Retriever, a.Store, a.LLM, a.Prompter, chat.Config{
+			HistoryTokens: cfg.ChatHistoryTokens,
+			MaxTurns:      cfg.ChatMaxTurns,
+			ReuseChunks:   cfg.ChatReuseChunks,
+		})
+	}
+
+	// 9. Agent
+	a.Agent = agent.New(a.Retriever, a.Store, a.Graph, a.LLM, agent.Config{
+		MaxSteps:  cfg.AgentMaxSteps,
+		MaxTokens: cfg.AgentMaxTokens,
+	})
+
+	return a, nil
+}
+
+// trigramLoadBatch is how many stored chunks are added to the trigram index
+// at a time on startup
+const trigramLoadBatch = 256
+
+// loadTrigramIndex adds every chunk in store to index
+func loadTrigramIndex(ctx context.Context, store vectorstore.Store, index *retrieval.TrigramIndex) error {
+	batch := make([]*domain.CodeChunk, 0, trigramLoadBatch)
+	err := store.ScrollChunks(ctx, func(chunk *domain.CodeChunk) error {
+		batch = append(batch, chunk)
+		if len(batch) < trigramLoadBatch {
+			return nil
+		}
+		err := index.AddToInvertedIndex(ctx, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return index.AddToInvertedIndex(ctx, batch)
+}
+
+// Close releases connections held by the services
+func (a *App) Close() {
+	if a.Redis != nil {
+		_ = a.Redis.Close()
+	}
+}
@@ -0,0 +1,392 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/prompt"
+	"github.com/Guru2308/rag-code/internal/retrieval"
+)
+
+// StageCondense is the stage timing of rewriting a follow-up into a
+// standalone query
+const StageCondense = "condense"
+
+// Roles of chat turns
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Retriever finds the context for a message
+type Retriever interface {
+	Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error)
+}
+
+// ChunkGetter loads the chunks an earlier answer was given
+type ChunkGetter interface {
+	Get(ctx context.Context, id string) (*domain.CodeChunk, error)
+}
+
+// Model condenses follow-up questions
+type Model interface {
+	Generate(ctx context.Context, messages []llm.ChatMessage) (string, error)
+}
+
+// Config controls conversation memory
+type Config struct {
+	HistoryTokens   int     // history sent with each message, estimated at 4 chars per token (default 1024)
+	MaxTurns        int     // turns kept per session; older ones are dropped (default 20)
+	CondenseTurns   int     // recent turns the model sees when condensing (default 6)
+	ReuseChunks     int     // chunks of the previous answer that may be carried over (default 3)
+	ReuseMinOverlap float64 // share of query terms a carried-over chunk must contain (default 0.3)
+}
+
+// DefaultConfig returns sensible defaults
+func DefaultConfig() Config {
+	return Config{
+		HistoryTokens:   1024,
+		MaxTurns:        20,
+		CondenseTurns:   6,
+		ReuseChunks:     3,
+		ReuseMinOverlap: 0.3,
+	}
+}
+
+const (
+	charsPerToken = 4
+	// maxCondenseTurnChars truncates each turn shown to the condensing model
+	maxCondenseTurnChars = 600
+	// maxQueryChars rejects condensed queries that are really answers
+	maxQueryChars = 500
+)
+
+// Turn is a chat message prepared for answering: its standalone query, the
+// context retrieved for it and the messages to send to the model
+type Turn struct {
+	Session  *domain.ChatSession
+	Message  string
+	Query    domain.SearchQuery     // the standalone query used for retrieval
+	Results  []*domain.SearchResult // retrieved results, then chunks carried over
+	Reused   []string               // IDs of the chunks carried over
+	Messages []llm.ChatMessage      // trimmed history followed by the prompt
+}
+
+// Service answers chat messages with the history of their session
+type Service struct {
+	sessions     *Store
+	retriever    Retriever
+	chunks       ChunkGetter
+	model        Model
+	prompter     prompt.Generator
+	preprocessor *retrieval.QueryPreprocessor
+	cfg          Config
+}
+
+// NewService creates a chat service
+func NewService(sessions *Store, retriever Retriever, chunks ChunkGetter, model Model, prompter prompt.Generator, cfg Config) *Service {
+	def := DefaultConfig()
+	if cfg.HistoryTokens <= 0 {
+		cfg.HistoryTokens = def.HistoryTokens
+	}
+	if cfg.MaxTurns <= 0 {
+		cfg.MaxTurns = def.MaxTurns
+	}
+	if cfg.CondenseTurns <= 0 {
+		cfg.CondenseTurns = def.CondenseTurns
+	}
+	if cfg.ReuseChunks <= 0 {
+		cfg.ReuseChunks = def.ReuseChunks
+	}
+	if cfg.ReuseMinOverlap <= 0 {
+		cfg.ReuseMinOverlap = def.ReuseMinOverlap
+	}
+	return &Service{
+		sessions:     sessions,
+		retriever:    retriever,
+		chunks:       chunks,
+		model:        model,
+		prompter:     prompter,
+		preprocessor: retrieval.NewQueryPreprocessor(),
+		cfg:          cfg,
+	}
+}
+
+// Prepare loads or starts the session of req, condenses the message into a
+// standalone query, retrieves its context and builds the model messages.
+// The session is not changed until Finish.
+func (s *Service) Prepare(ctx context.Context, req domain.ChatRequest) (*Turn, error) {
+	if strings.TrimSpace(req.Message) == "" {
+		return nil, errors.ValidationError("message is required")
+	}
+
+	session := &domain.ChatSession{ID: NewSessionID(), CreatedAt: time.Now()}
+	if req.SessionID != "" {
+		var err error
+		if session, err = s.sessions.Get(ctx, req.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	standalone := req.Message
+	if len(session.Turns) > 0 {
+		start := time.Now()
+		condensed, err := s.condense(ctx, session.Turns, req.Message)
+		retrieval.StageTimingsFrom(ctx).Record(StageCondense, time.Since(start))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.Wrap(ctx.Err(), errors.ErrorTypeInternal, "chat cancelled")
+			}
+			logger.Warn("Condensing follow-up failed, retrieving with the message as given", "session_id", session.ID, "error", err)
+		} else {
+			standalone = condensed
+		}
+	}
+
+	turn := &Turn{
+		Session: session,
+		Message: req.Message,
+		Query: domain.SearchQuery{
+			Query:      standalone,
+			MaxResults: req.MaxResults,
+			Language:   req.Language,
+			FilePath:   req.FilePath,
+		},
+	}
+	if turn.Query.MaxResults <= 0 {
+		turn.Query.MaxResults = 5
+	}
+
+	results, err := s.retriever.Retrieve(ctx, turn.Query)
+	if err != nil {
+		return nil, err
+	}
+	carried := s.carryOver(ctx, session, standalone, results)
+	for _, res := range carried {
+		turn.Reused = append(turn.Reused, res.Chunk.ID)
+	}
+	turn.Results = append(results, carried...)
+
+	promptStr, err := s.prompter.Generate(ctx, req.Message, turn.Results)
+	if err != nil {
+		return nil, err
+	}
+	turn.Messages = append(s.history(session.Turns), llm.ChatMessage{Role: RoleUser, Content: promptStr})
+
+	logger.Debug("Prepared chat turn", "session_id", session.ID, "query", standalone,
+		"results", len(results), "reused", len(carried), "history", len(turn.Messages)-1)
+	return turn, nil
+}
+
+// Finish appends the message and its answer to the session and stores it
+func (s *Service) Finish(ctx context.Context, turn *Turn, queryID, answer string) error {
+	now := time.Now()
+	chunkIDs := make([]string, 0, len(turn.Results))
+	seen := make(map[string]bool, len(turn.Results))
+	for _, res := range turn.Results {
+		if res.Chunk != nil && !seen[res.Chunk.ID] {
+			seen[res.Chunk.ID] = true
+			chunkIDs = append(chunkIDs, res.Chunk.ID)
+		}
+	}
+
+	session := turn.Session
+	session.Turns = append(session.Turns,
+		domain.ChatTurn{Role: RoleUser, Content: turn.Message, Query: turn.Query.Query, Time: now},
+		domain.ChatTurn{Role: RoleAssistant, Content: answer, QueryID: queryID, ChunkIDs: chunkIDs, Time: now},
+	)
+	if extra := len(session.Turns) - s.cfg.MaxTurns; extra > 0 {
+		// Drop whole exchanges so the history still starts with a question
+		extra += extra % 2
+		session.Turns = append([]domain.ChatTurn(nil), session.Turns[min(extra, len(session.Turns)):]...)
+	}
+	session.UpdatedAt = now
+	return s.sessions.Save(ctx, session)
+}
+
+// Session returns a stored session
+func (s *Service) Session(ctx context.Context, id string) (*domain.ChatSession, error) {
+	return s.sessions.Get(ctx, id)
+}
+
+// DeleteSession removes a session
+func (s *Service) DeleteSession(ctx context.Context, id string) error {
+	return s.sessions.Delete(ctx, id)
+}
+
+const condensePrompt = `Rewrite the developer's follow-up question as a standalone search query for the codebase. Use the conversation to replace words such as "it", "that" or "there" with what they refer to, and keep function, type and file names exactly. If the question already stands alone, repeat it.
+
+Conversation:
+%s
+Follow-up question: %s
+
+Reply with the standalone query only.`
+
+// condense asks the model to rewrite message so it can be retrieved without
+// the conversation
+func (s *Service) condense(ctx context.Context, turns []domain.ChatTurn, message string) (string, error) {
+	if len(turns) > s.cfg.CondenseTurns {
+		turns = turns[len(turns)-s.cfg.CondenseTurns:]
+	}
+	var conversation strings.Builder
+	for _, t := range turns {
+		content := t.Content
+		if len(content) > maxCondenseTurnChars {
+			content = content[:maxCondenseTurnChars] + "..."
+		}
+		role := "Developer"
+		if t.Role == RoleAssistant {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&conversation, "%s: %s\n", role, content)
+	}
+
+	reply, err := s.model.Generate(ctx, []llm.ChatMessage{
+		{Role: RoleUser, Content: fmt.Sprintf(condensePrompt, conversation.String(), message)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return parseCondensed(reply)
+}
+
+// parseCondensed takes the first non-empty line of reply, without a label or
+// quotes the model may have added
+func parseCondensed(reply string) (string, error) {
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, ":"); i >= 0 && strings.Contains(strings.ToLower(line[:i]), "query") {
+			line = strings.TrimSpace(line[i+1:])
+		}
+		line = strings.Trim(line, "\"'` ")
+		if line == "" {
+			continue
+		}
+		if len(line) > maxQueryChars {
+			return "", errors.ValidationError("condensed query is too long")
+		}
+		return line, nil
+	}
+	return "", errors.ValidationError("empty condensed query")
+}
+
+// carryOver returns the chunks of the previous answer that are still
+// relevant to query and not among results. A chunk is relevant when the
+// query names its symbol or contains enough of its terms; the follow-up
+// "and where is that called?" condensed to "where is ValidateToken called"
+// keeps the definition of ValidateToken in context.
+func (s *Service) carryOver(ctx context.Context, session *domain.ChatSession, query string, results []*domain.SearchResult) []*domain.SearchResult {
+	var previous []string
+	for i := len(session.Turns) - 1; i >= 0; i-- {
+		if session.Turns[i].Role == RoleAssistant {
+			previous = session.Turns[i].ChunkIDs
+			break
+		}
+	}
+	if len(previous) == 0 {
+		return nil
+	}
+
+	have := make(map[string]bool, len(results))
+	for _, res := range results {
+		if res.Chunk != nil {
+			have[res.Chunk.ID] = true
+		}
+	}
+	terms := uniqueTerms(s.preprocessor.Preprocess(query).Filtered)
+	lowerQuery := strings.ToLower(query)
+
+	var carried []*domain.SearchResult
+	for _, id := range previous {
+		if len(carried) == s.cfg.ReuseChunks {
+			break
+		}
+		if have[id] {
+			continue
+		}
+		chunk, err := s.chunks.Get(ctx, id)
+		if err != nil {
+			// Chunks of edited or deleted files are gone from the index
+			if !errors.Is(err, errors.ErrorTypeNotFound) {
+				logger.Warn("Failed to load earlier chat context", "chunk_id", id, "error", err)
+			}
+			continue
+		}
+		if chunk == nil {
+			continue
+		}
+		score := s.overlap(terms, chunk)
+		if name := chunk.Metadata["name"]; len(name) >= 4 && strings.Contains(lowerQuery, strings.ToLower(name)) {
+			score = 1
+		}
+		if score < s.cfg.ReuseMinOverlap {
+			continue
+		}
+		carried = append(carried, &domain.SearchResult{
+			Chunk:          chunk,
+			Score:          float32(score),
+			Source:         "chat:reused",
+			RelevanceScore: float32(score),
+		})
+	}
+	return carried
+}
+
+// overlap is the share of terms found in the chunk's content, path or name
+func (s *Service) overlap(terms []string, chunk *domain.CodeChunk) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	tokens := s.preprocessor.Preprocess(chunk.Content + " " + chunk.FilePath + " " + chunk.Metadata["name"]).Tokens
+	present := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		present[t] = true
+	}
+	found := 0
+	for _, t := range terms {
+		if present[t] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(terms))
+}
+
+// history returns the most recent exchanges that fit the history budget,
+// oldest first. Exchanges are kept or dropped whole.
+func (s *Service) history(turns []domain.ChatTurn) []llm.ChatMessage {
+	budget := s.cfg.HistoryTokens * charsPerToken
+	start := len(turns)
+	for i := len(turns) - 1; i >= 0; i-- {
+		budget -= len(turns[i].Content)
+		if budget < 0 {
+			break
+		}
+		if turns[i].Role == RoleUser {
+			start = i
+		}
+	}
+
+	messages := make([]llm.ChatMessage, 0, len(turns)-start)
+	for _, t := range turns[start:] {
+		messages = append(messages, llm.ChatMessage{Role: t.Role, Content: t.Content})
+	}
+	return messages
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	out := terms[:0:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/prompt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	logger.Init(logger.Config{Level: logger.LevelDebug})
+}
+
+type fakeRetriever struct {
+	queries []domain.SearchQuery
+	results map[string][]*domain.SearchResult
+}
+
+func (f *fakeRetriever) Retrieve(_ context.Context, q domain.SearchQuery) ([]*domain.SearchResult, error) {
+	f.queries = append(f.queries, q)
+	return f.results[q.Query], nil
+}
+
+type fakeChunks map[string]*domain.CodeChunk
+
+func (f fakeChunks) Get(_ context.Context, id string) (*domain.CodeChunk, error) {
+	if c, ok := f[id]; ok {
+		return c, nil
+	}
+	return nil, errors.NotFoundError("chunk not found")
+}
+
+type fakeModel struct {
+	reply    string
+	err      error
+	messages [][]llm.ChatMessage
+}
+
+func (f *fakeModel) Generate(_ context.Context, messages []llm.ChatMessage) (string, error) {
+	f.messages = append(f.messages, messages)
+	return f.reply, f.err
+}
+
+var testChunks = fakeChunks{
+	"def": {ID: "def", FilePath: "/repo/auth/token.go", Content: "func ValidateToken(t string) error { return verify(t) }",
+		Metadata: map[string]string{"name": "ValidateToken"}},
+	"misc": {ID: "misc", FilePath: "/repo/util/strings.go", Content: "func Reverse(s string) string { return s }",
+		Metadata: map[string]string{"name": "Reverse"}},
+	"caller": {ID: "caller", FilePath: "/repo/api/middleware.go", Content: "if err := ValidateToken(h); err != nil {}"},
+}
+
+func setupTestService(t *testing.T, model *fakeModel, cfg Config) (*Service, *fakeRetriever, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	prompter, err := prompt.NewTemplateGenerator(prompt.DefaultPromptTemplate)
+	if err != nil {
+		t.Fatalf("NewTemplateGenerator() error = %v", err)
+	}
+	retriever := &fakeRetriever{results: map[string][]*domain.SearchResult{
+		"How are tokens validated?":     {{Chunk: testChunks["def"]}, {Chunk: testChunks["misc"]}},
+		"where is ValidateToken called": {{Chunk: testChunks["caller"]}},
+	}}
+	store := NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test:", time.Hour)
+	return NewService(store, retriever, testChunks, model, prompter, cfg), retriever, mr
+}
+
+func TestService_FollowUp(t *testing.T) {
+	ctx := context.Background()
+	model := &fakeModel{reply: "Standalone query: \"where is ValidateToken called\""}
+	svc, retriever, mr := setupTestService(t, model, Config{})
+
+	first, err := svc.Prepare(ctx, domain.ChatRequest{Message: "How are tokens validated?"})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if len(model.messages) != 0 {
+		t.Error("the first message should not be condensed")
+	}
+	if len(first.Messages) != 1 || first.Query.Query != "How are tokens validated?" || first.Query.MaxResults != 5 {
+		t.Fatalf("unexpected first turn %+v", first)
+	}
+	if err := svc.Finish(ctx, first, "q-1", "ValidateToken checks the signature."); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if ttl := mr.TTL("test:chat:" + first.Session.ID); ttl != time.Hour {
+		t.Errorf("session TTL = %v, want 1h", ttl)
+	}
+
+	next, err := svc.Prepare(ctx, domain.ChatRequest{SessionID: first.Session.ID, Message: "and where is that called?"})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if got := retriever.queries[1].Query; got != "where is ValidateToken called" {
+		t.Errorf("retrieved with %q, want the condensed query", got)
+	}
+	if len(model.messages) != 1 || !strings.Contains(model.messages[0][0].Content, "ValidateToken checks the signature.") {
+		t.Error("the condensing prompt should include the conversation")
+	}
+
+	// The definition is named by the query and carried over; the unrelated chunk is not
+	if len(next.Reused) != 1 || next.Reused[0] != "def" {
+		t.Errorf("Reused = %v, want [def]", next.Reused)
+	}
+	if len(next.Results) != 2 || next.Results[1].Source != "chat:reused" {
+		t.Errorf("unexpected results %+v", next.Results)
+	}
+
+	// History precedes the prompt
+	if len(next.Messages) != 3 || next.Messages[0].Content != "How are tokens validated?" || next.Messages[1].Role != RoleAssistant {
+		t.Errorf("unexpected messages %+v", next.Messages)
+	}
+
+	if err := svc.Finish(ctx, next, "q-2", "In the middleware."); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	session, err := svc.Session(ctx, first.Session.ID)
+	if err != nil {
+		t.Fatalf("Session() error = %v", err)
+	}
+	if len(session.Turns) != 4 || session.Turns[2].Query != "where is ValidateToken called" || session.Turns[3].QueryID != "q-2" {
+		t.Errorf("unexpected session %+v", session.Turns)
+	}
+	if ids := session.Turns[3].ChunkIDs; len(ids) != 2 || ids[0] != "caller" || ids[1] != "def" {
+		t.Errorf("ChunkIDs = %v, want [caller def]", ids)
+	}
+}
+
+func TestService_CondenseFailureFallsBack(t *testing.T) {
+	ctx := context.Background()
+	model := &fakeModel{err: errors.ExternalError("model unavailable", nil)}
+	svc, retriever, _ := setupTestService(t, model, Config{})
+
+	first, err := svc.Prepare(ctx, domain.ChatRequest{Message: "How are tokens validated?"})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if err := svc.Finish(ctx, first, "q-1", "answer"); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if _, err := svc.Prepare(ctx, domain.ChatRequest{SessionID: first.Session.ID, Message: "and the tests?"}); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if got := retriever.queries[1].Query; got != "and the tests?" {
+		t.Errorf("retrieved with %q, want the message as given", got)
+	}
+}
+
+func TestService_UnknownSession(t *testing.T) {
+	svc, _, _ := setupTestService(t, &fakeModel{}, Config{})
+	_, err := svc.Prepare(context.Background(), domain.ChatRequest{SessionID: "s-missing", Message: "hi"})
+	if !errors.Is(err, errors.ErrorTypeNotFound) {
+		t.Errorf("Prepare() error = %v, want not found", err)
+	}
+	if err := svc.DeleteSession(context.Background(), "s-missing"); !errors.Is(err, errors.ErrorTypeNotFound) {
+		t.Errorf("DeleteSession() error = %v, want not found", err)
+	}
+}
+
+func TestService_HistoryTrimming(t *testing.T) {
+	svc, _, _ := setupTestService(t, &fakeModel{}, Config{HistoryTokens: 10, MaxTurns: 4})
+
+	turns := []domain.ChatTurn{
+		{Role: RoleUser, Content: strings.Repeat("a", 20)},
+		{Role: RoleAssistant, Content: strings.Repeat("b", 20)},
+		{Role: RoleUser, Content: "short"},
+		{Role: RoleAssistant, Content: "reply"},
+	}
+	// 40 chars fit: the last exchange (10 chars) and not the first (40 more)
+	history := svc.history(turns)
+	if len(history) != 2 || history[0].Content != "short" {
+		t.Errorf("history = %+v, want the last exchange only", history)
+	}
+
+	ctx := context.Background()
+	turn, err := svc.Prepare(ctx, domain.ChatRequest{Message: "How are tokens validated?"})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	turn.Session.Turns = turns
+	if err := svc.Finish(ctx, turn, "q-3", "answer"); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if n := len(turn.Session.Turns); n != 4 || turn.Session.Turns[0].Content != "short" {
+		t.Errorf("stored %d turns starting %q, want the 4 newest", n, turn.Session.Turns[0].Content)
+	}
+}
+
+func TestParseCondensed(t *testing.T) {
+	tests := []struct {
+		reply, want string
+		wantErr     bool
+	}{
+		{reply: "where is ValidateToken called", want: "where is ValidateToken called"},
+		{reply: "\n  Standalone query: `token refresh flow`\n\nExplanation...", want: "token refresh flow"},
+		{reply: "   \n", wantErr: true},
+		{reply: strings.Repeat("x", maxQueryChars+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCondensed(tt.reply)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseCondensed(%q) = %q, %v", tt.reply, got, err)
+		}
+	}
+}
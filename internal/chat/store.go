@@ -0,0 +1,82 @@
+// Package chat holds multi-turn conversations: sessions with their message
+// history, follow-up questions condensed into standalone retrieval queries,
+// and the context carried from one answer to the next.
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps chat sessions in Redis. A session expires after its TTL
+// without a new message.
+type Store struct {
+	client    *redis.Client
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewStore creates a session store (default TTL 24h)
+func NewStore(client *redis.Client, keyPrefix string, ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Store{client: client, keyPrefix: keyPrefix, ttl: ttl}
+}
+
+// NewSessionID returns a random session ID
+func NewSessionID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "s-" + hex.EncodeToString(b)
+}
+
+// Get returns a stored session
+func (s *Store) Get(ctx context.Context, id string) (*domain.ChatSession, error) {
+	data, err := s.client.Get(ctx, s.key(id)).Bytes()
+	if err == redis.Nil {
+		return nil, errors.NotFoundError(fmt.Sprintf("chat session %s not found or expired", id))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to load chat session")
+	}
+	var session domain.ChatSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to parse chat session")
+	}
+	return &session, nil
+}
+
+// Save stores a session and restarts its TTL
+func (s *Store) Save(ctx context.Context, session *domain.ChatSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal chat session")
+	}
+	if err := s.client.Set(ctx, s.key(session.ID), data, s.ttl).Err(); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to store chat session")
+	}
+	return nil
+}
+
+// Delete removes a session
+func (s *Store) Delete(ctx context.Context, id string) error {
+	n, err := s.client.Del(ctx, s.key(id)).Result()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to delete chat session")
+	}
+	if n == 0 {
+		return errors.NotFoundError(fmt.Sprintf("chat session %s not found or expired", id))
+	}
+	return nil
+}
+
+func (s *Store) key(id string) string { return s.keyPrefix + "chat:" + id }
@@ -0,0 +1,356 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/eval"
+	"github.com/Guru2308/rag-code/internal/querylog"
+)
+
+// Client talks to a running rag-server over its HTTP API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Status is the server status as reported by GET /api/status
+type Status struct {
+	Status string              `json:"status"`
+	Index  *domain.IndexStatus `json:"index,omitempty"`
+}
+
+// New creates a client for the server at baseURL (e.g. http://localhost:8080).
+// Requests are bounded by their context; streamed answers can take a while.
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{},
+	}
+}
+
+// Index asks the server to index path in the background
+func (c *Client) Index(ctx context.Context, path string) error {
+	return c.do(ctx, http.MethodPost, "/api/index", map[string]string{"path": path}, nil)
+}
+
+// Search runs retrieval only and returns one page of results
+func (c *Client) Search(ctx context.Context, req domain.SearchRequest) (*domain.SearchPage, error) {
+	var page domain.SearchPage
+	if err := c.do(ctx, http.MethodPost, "/api/search", req, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Grep returns the lines of indexed code matching a literal or regex
+func (c *Client) Grep(ctx context.Context, req domain.GrepRequest) (*domain.GrepResponse, error) {
+	var resp domain.GrepResponse
+	if err := c.do(ctx, http.MethodPost, "/api/grep", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Query retrieves context and streams the generated answer. onResults is
+// called once with the query ID and the retrieved chunks before the first
+// token.
+func (c *Client) Query(ctx context.Context, query domain.SearchQuery, onResults func(queryID string, results []*domain.SearchResult), onToken func(string) error) error {
+	return c.stream(ctx, "/api/query?stream=true", query, func(data []byte) error {
+		var payload struct {
+			QueryID string                 `json:"query_id"`
+			Results []*domain.SearchResult `json:"results"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return err
+		}
+		if onResults != nil {
+			onResults(payload.QueryID, payload.Results)
+		}
+		return nil
+	}, onToken)
+}
+
+// Chat sends a chat message and streams the answer. onResults receives the
+// session and query IDs, the standalone query and the retrieved context
+// before the first token.
+func (c *Client) Chat(ctx context.Context, req domain.ChatRequest, onResults func(*domain.ChatResponse), onToken func(string) error) error {
+	return c.stream(ctx, "/api/chat?stream=true", req, func(data []byte) error {
+		var resp domain.ChatResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		if onResults != nil {
+			onResults(&resp)
+		}
+		return nil
+	}, onToken)
+}
+
+// Agent asks the agent a question, calling onStep (if set) as each tool
+// call completes
+func (c *Client) Agent(ctx context.Context, req domain.AgentRequest, onStep func(domain.AgentStep)) (*domain.AgentResponse, error) {
+	resp, err := c.send(ctx, http.MethodPost, "/api/agent?stream=true", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result *domain.AgentResponse
+	err = readEvents(resp.Body, func(event string, data []byte) error {
+		switch event {
+		case "step":
+			var step domain.AgentStep
+			if err := json.Unmarshal(data, &step); err != nil {
+				return errors.Wrap(err, errors.ErrorTypeInternal, "failed to decode step event")
+			}
+			if onStep != nil {
+				onStep(step)
+			}
+		case "result":
+			result = &domain.AgentResponse{}
+			if err := json.Unmarshal(data, result); err != nil {
+				return errors.Wrap(err, errors.ErrorTypeInternal, "failed to decode result event")
+			}
+		case "error":
+			var payload struct {
+				Error string `json:"error"`
+			}
+			_ = json.Unmarshal(data, &payload)
+			return errors.New(errors.ErrorTypeExternal, "server: "+payload.Error)
+		case "done":
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New(errors.ErrorTypeExternal, "server sent no result")
+	}
+	return result, nil
+}
+
+// stream posts body to an answering endpoint and reads its server-sent
+// events, passing the results event to onResults and each token to onToken
+func (c *Client) stream(ctx context.Context, path string, body any, onResults func(data []byte) error, onToken func(string) error) error {
+	resp, err := c.send(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readEvents(resp.Body, func(event string, data []byte) error {
+		switch event {
+		case "results":
+			if err := onResults(data); err != nil {
+				return errors.Wrap(err, errors.ErrorTypeInternal, "failed to decode results event")
+			}
+		case "token":
+			var payload struct {
+				Content string `json:"content"`
+			}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return errors.Wrap(err, errors.ErrorTypeInternal, "failed to decode token event")
+			}
+			return onToken(payload.Content)
+		case "error":
+			var payload struct {
+				Error string `json:"error"`
+			}
+			_ = json.Unmarshal(data, &payload)
+			return errors.New(errors.ErrorTypeExternal, "server: "+payload.Error)
+		case "done":
+			return io.EOF
+		}
+		return nil
+	})
+}
+
+// Status returns the server status
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/api/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Jobs lists tracked indexing jobs, newest first
+func (c *Client) Jobs(ctx context.Context) ([]*domain.IndexingJob, error) {
+	var resp struct {
+		Jobs []*domain.IndexingJob `json:"jobs"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/jobs", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// Job returns a single indexing job
+func (c *Client) Job(ctx context.Context, id string) (*domain.IndexingJob, error) {
+	var job domain.IndexingJob
+	if err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Explain returns a chunk with its dependency graph neighbours
+func (c *Client) Explain(ctx context.Context, chunkID string) (*domain.ChunkExplanation, error) {
+	var explanation domain.ChunkExplanation
+	if err := c.do(ctx, http.MethodGet, "/api/chunks/"+url.PathEscape(chunkID), nil, &explanation); err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// Verify compares the index for path with the file system, optionally
+// repairing what it finds
+func (c *Client) Verify(ctx context.Context, path string, repair bool) (*domain.VerifyReport, error) {
+	var report domain.VerifyReport
+	body := map[string]any{"path": path, "repair": repair}
+	if err := c.do(ctx, http.MethodPost, "/api/verify", body, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Feedback rates the results of an earlier query or search
+func (c *Client) Feedback(ctx context.Context, req domain.FeedbackRequest) (*domain.Feedback, error) {
+	var fb domain.Feedback
+	if err := c.do(ctx, http.MethodPost, "/api/feedback", req, &fb); err != nil {
+		return nil, err
+	}
+	return &fb, nil
+}
+
+// ExportFeedback returns the feedback given so far as a golden set
+func (c *Client) ExportFeedback(ctx context.Context, name string) (*eval.GoldenSet, error) {
+	var set eval.GoldenSet
+	if err := c.do(ctx, http.MethodGet, "/api/feedback/export?name="+url.QueryEscape(name), nil, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Analytics summarizes the queries logged within the last since
+func (c *Client) Analytics(ctx context.Context, since time.Duration, limit int) (*querylog.Summary, error) {
+	var summary querylog.Summary
+	path := fmt.Sprintf("/api/analytics?since=%s&limit=%d", url.QueryEscape(since.String()), limit)
+	if err := c.do(ctx, http.MethodGet, path, nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// do sends a JSON request and decodes a JSON response into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to decode response")
+	}
+	return nil
+}
+
+// send issues the request and turns non-2xx responses into errors
+func (c *Client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal request")
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to create request")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to reach server")
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+	msg := apiErr.Error
+	if msg == "" {
+		msg = resp.Status
+	}
+
+	errType := errors.ErrorTypeExternal
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		errType = errors.ErrorTypeValidation
+	case http.StatusNotFound:
+		errType = errors.ErrorTypeNotFound
+	}
+	return nil, errors.New(errType, fmt.Sprintf("%s %s: %s", method, path, msg))
+}
+
+// readEvents parses a server-sent event stream, calling fn for each event.
+// fn returning io.EOF ends the stream without error.
+func readEvents(r io.Reader, fn func(event string, data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "" && len(data) == 0 {
+				continue
+			}
+			err := fn(event, []byte(strings.Join(data, "\n")))
+			event, data = "", nil
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			value := strings.TrimPrefix(line, "data:")
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to read event stream")
+	}
+	return errors.New(errors.ErrorTypeExternal, "event stream ended before the answer was complete")
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+func TestClient_Search(t *testing.T) {
+	var got domain.SearchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/search" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(domain.SearchPage{
+			Query: got.Query,
+			Results: []*domain.SearchResult{
+				{Chunk: &domain.CodeChunk{ID: "c1", FilePath: "main.go"}, RelevanceScore: 0.9},
+			},
+			Total:      11,
+			NextCursor: "next",
+		})
+	}))
+	defer server.Close()
+
+	req := domain.SearchRequest{
+		SearchQuery: domain.SearchQuery{Query: "auth", MaxResults: 3, SkipRerank: true},
+		Cursor:      "abc",
+	}
+	page, err := New(server.URL+"/").Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got.Query != "auth" || got.MaxResults != 3 || !got.SkipRerank || got.Cursor != "abc" {
+		t.Errorf("server received %+v", got)
+	}
+	if len(page.Results) != 1 || page.Results[0].Chunk.ID != "c1" || page.NextCursor != "next" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestClient_Grep(t *testing.T) {
+	var got domain.GrepRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/grep" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(domain.GrepResponse{
+			Matches:    []domain.GrepMatch{{FilePath: "main.go", Line: 12, Text: "<-ctx.Done()", Ranges: [][2]int{{2, 12}}}},
+			Candidates: 3,
+		})
+	}))
+	defer server.Close()
+
+	req := domain.GrepRequest{Pattern: "ctx.Done()", CaseSensitive: true, Paths: []string{"*.go"}}
+	resp, err := New(server.URL).Grep(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if got.Pattern != "ctx.Done()" || !got.CaseSensitive || len(got.Paths) != 1 {
+		t.Errorf("server received %+v", got)
+	}
+	if len(resp.Matches) != 1 || resp.Matches[0].Line != 12 || resp.Candidates != 3 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestClient_Query_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream") != "true" {
+			t.Errorf("expected stream=true, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event:results\ndata:{\"query_id\":\"q-1\",\"results\":[{\"chunk\":{\"id\":\"c1\"}}]}\n\n"))
+		w.Write([]byte("event:token\ndata:{\"content\":\"Hello\"}\n\n"))
+		w.Write([]byte("event:token\ndata:{\"content\":\" world\"}\n\n"))
+		w.Write([]byte("event:done\ndata:{}\n\n"))
+	}))
+	defer server.Close()
+
+	var queryID string
+	var results []*domain.SearchResult
+	var answer strings.Builder
+	err := New(server.URL).Query(context.Background(), domain.SearchQuery{Query: "q"},
+		func(id string, r []*domain.SearchResult) { queryID, results = id, r },
+		func(token string) error { answer.WriteString(token); return nil })
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if queryID != "q-1" || len(results) != 1 || results[0].Chunk.ID != "c1" {
+		t.Errorf("unexpected query %q results %+v", queryID, results)
+	}
+	if answer.String() != "Hello world" {
+		t.Errorf("expected 'Hello world', got %q", answer.String())
+	}
+}
+
+func TestClient_Chat_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" || r.URL.Query().Get("stream") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		var req domain.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.SessionID != "s-1" || req.Message != "and its callers?" {
+			t.Errorf("unexpected request body %+v", req)
+		}
+		w.Write([]byte("event:results\ndata:{\"session_id\":\"s-1\",\"query_id\":\"q-2\",\"query\":\"callers of Login\",\"results\":[],\"reused\":[\"c1\"]}\n\n"))
+		w.Write([]byte("event:token\ndata:{\"content\":\"handler\"}\n\n"))
+		w.Write([]byte("event:done\ndata:{}\n\n"))
+	}))
+	defer server.Close()
+
+	var got *domain.ChatResponse
+	var answer strings.Builder
+	err := New(server.URL).Chat(context.Background(), domain.ChatRequest{SessionID: "s-1", Message: "and its callers?"},
+		func(resp *domain.ChatResponse) { got = resp },
+		func(token string) error { answer.WriteString(token); return nil })
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if got == nil || got.SessionID != "s-1" || got.Query != "callers of Login" || len(got.Reused) != 1 {
+		t.Errorf("unexpected results event %+v", got)
+	}
+	if answer.String() != "handler" {
+		t.Errorf("expected 'handler', got %q", answer.String())
+	}
+}
+
+func TestClient_Agent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/agent" || r.URL.Query().Get("stream") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte("event:step\ndata:{\"step\":1,\"tool\":\"search\",\"args\":{\"query\":\"login\"},\"observation\":\"[1] auth.go\"}\n\n"))
+		w.Write([]byte("event:result\ndata:{\"query_id\":\"q-1\",\"answer\":\"In auth.go.\",\"steps\":[{\"step\":1,\"tool\":\"search\"}],\"stopped_by\":\"answer\"}\n\n"))
+		w.Write([]byte("event:done\ndata:{}\n\n"))
+	}))
+	defer server.Close()
+
+	var steps []domain.AgentStep
+	resp, err := New(server.URL).Agent(context.Background(), domain.AgentRequest{Question: "where is login"},
+		func(step domain.AgentStep) { steps = append(steps, step) })
+	if err != nil {
+		t.Fatalf("Agent failed: %v", err)
+	}
+	if len(steps) != 1 || steps[0].Tool != "search" || string(steps[0].Args) != `{"query":"login"}` {
+		t.Errorf("unexpected steps %+v", steps)
+	}
+	if resp.QueryID != "q-1" || resp.Answer != "In auth.go." || len(resp.Steps) != 1 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestClient_Query_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"error event", "event:token\ndata:{\"content\":\"partial\"}\n\nevent:error\ndata:{\"error\":\"llm down\"}\n\n"},
+		{"truncated", "event:token\ndata:{\"content\":\"partial\"}\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.stream))
+			}))
+			defer server.Close()
+
+			err := New(server.URL).Query(context.Background(), domain.SearchQuery{Query: "q"}, nil,
+				func(string) error { return nil })
+			if !errors.Is(err, errors.ErrorTypeExternal) {
+				t.Errorf("expected external error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		status int
+		want   errors.ErrorType
+	}{
+		{http.StatusBadRequest, errors.ErrorTypeValidation},
+		{http.StatusNotFound, errors.ErrorTypeNotFound},
+		{http.StatusInternalServerError, errors.ErrorTypeExternal},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			json.NewEncoder(w).Encode(map[string]string{"error": "boom"})
+		}))
+
+		_, err := New(server.URL).Explain(context.Background(), "missing")
+		server.Close()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %d: expected %s, got %v", tt.status, tt.want, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "boom") {
+			t.Errorf("status %d: expected server message in %q", tt.status, err)
+		}
+	}
+}
+
+func TestClient_VerifyAndStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/verify":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["path"] != "/repo" || body["repair"] != true {
+				t.Errorf("unexpected verify body %v", body)
+			}
+			json.NewEncoder(w).Encode(domain.VerifyReport{Root: "/repo", FilesOnDisk: 2, Repaired: true})
+		case "/api/status":
+			json.NewEncoder(w).Encode(map[string]any{"status": "healthy", "index": domain.IndexStatus{FilesTracked: 4}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	report, err := c.Verify(context.Background(), "/repo", true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Root != "/repo" || report.FilesOnDisk != 2 || !report.Repaired {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	status, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Status != "healthy" || status.Index == nil || status.Index.FilesTracked != 4 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestClient_Feedback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/feedback":
+			var req domain.FeedbackRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.QueryID != "q-1" || len(req.Results) != 1 || req.Results[0].Rating != domain.RatingAnswer {
+				t.Errorf("server received %+v", req)
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(domain.Feedback{FeedbackRequest: req})
+		case "/api/feedback/export":
+			if r.URL.Query().Get("name") != "labels" {
+				t.Errorf("expected name=labels, got %q", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"name":"labels","queries":[{"id":"fb-1","query":"q","expected":[{"file_path":"a.go"}]}]}`))
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	fb, err := c.Feedback(context.Background(), domain.FeedbackRequest{
+		QueryID: "q-1",
+		Results: []domain.ResultFeedback{{ChunkID: "c1", Rating: domain.RatingAnswer}},
+	})
+	if err != nil || fb.QueryID != "q-1" {
+		t.Fatalf("Feedback() = %+v, %v", fb, err)
+	}
+	set, err := c.ExportFeedback(context.Background(), "labels")
+	if err != nil || set.Name != "labels" || len(set.Queries) != 1 {
+		t.Errorf("ExportFeedback() = %+v, %v", set, err)
+	}
+}
+
+func TestClient_Analytics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/analytics" || r.URL.Query().Get("since") != "1h0m0s" || r.URL.Query().Get("limit") != "5" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"queries":3,"top_queries":[{"query":"token refresh","count":2}]}`))
+	}))
+	defer server.Close()
+
+	summary, err := New(server.URL).Analytics(context.Background(), time.Hour, 5)
+	if err != nil {
+		t.Fatalf("Analytics failed: %v", err)
+	}
+	if summary.Queries != 3 || len(summary.TopQueries) != 1 || summary.TopQueries[0].Count != 2 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
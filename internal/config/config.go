@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,14 +18,31 @@ type Config struct {
 	LLMModel       string
 
 	// Vector Store Configuration
-	VectorStoreURL string
-	CollectionName string
+	VectorStoreURL     string
+	CollectionName     string
+	VectorStore        string // "qdrant" (default) or "local" — embedded, persisted to VectorStorePath
+	VectorStorePath    string // segment directory for VectorStore=local (default: vector-store)
+	VectorIndex        string // VectorStore=local index: "flat" (exact, default) or "hnsw"
+	VectorQuantization string // VectorStore=local vectors: "none" (default) or "int8"
 
 	// Indexing Configuration
 	TargetCodebase string
 	MaxChunkSize   int
 	ChunkOverlap   int
 
+	// File Selection (shared by the indexer and the watcher)
+	IndexIncludeGlobs []string // .gitignore-style globs; empty means include everything
+	IndexExcludeGlobs []string // .gitignore-style globs always excluded
+	RespectGitignore  bool     // honour nested .gitignore and .ragignore files (default: true)
+	MaxFileSize       int64    // bytes; larger files are skipped (default: 1 MiB)
+	SkipBinaryFiles   bool     // skip files with NUL bytes (default: true)
+	SkipGenerated     bool     // skip "Code generated ... DO NOT EDIT." files (default: true)
+
+	// File Watcher
+	WatchBurstThreshold int           // events per second that switch the watcher to batch mode (default: 50)
+	WatchMode           string        // "auto" (fsnotify, polling where it fails), "fsnotify" or "poll" (default: auto)
+	WatchPollInterval   time.Duration // scan interval for polled directories (default: 2s)
+
 	// Server Configuration
 	ServerPort string
 	LogLevel   string
@@ -33,11 +52,17 @@ type Config struct {
 	RedisURL      string
 	RedisPassword string
 	RedisDB       int
+	RedisEnabled  bool // REDIS_URL is set, or the keyword index or query log is kept in Redis
 
 	// Hybrid Retrieval Configuration
 	HybridEnabled      bool
 	HybridVectorWeight float64
 	FusionStrategy     string
+	KeywordIndex       string // "redis" (default) or "local" — in-process, persisted to KeywordIndexPath
+	KeywordIndexPath   string // segment directory for KeywordIndex=local (default: keyword-index)
+	KeywordIndexPrefix string // key prefix for KeywordIndex=redis; include a hash tag on Redis Cluster (default: rag:)
+	KeywordMaxPostings int    // postings BM25 search reads per token in Redis, 0 for all (default: 10000)
+	TrigramIndex       bool   // in-memory trigram index for grep and exact matches in retrieval (default: true)
 	BM25K1             float64
 	BM25B              float64
 
@@ -52,6 +77,46 @@ type Config struct {
 	// MMR (Maximal Marginal Relevance) — diversity in retrieval
 	UseMMR     bool    // enable MMR reranking (default: true)
 	MMRLambda  float64 // relevance vs diversity trade-off 0–1 (default: 0.7)
+
+	// Reranking
+	Reranker           string        // "heuristic" (default) or "llm" — LLM scores the top candidates after the heuristics
+	RerankerConfig     string        // JSON file with heuristic reranker weights, as written by `rag tune` (default: built-in weights)
+	LLMRerankModel     string        // chat model for LLM reranking (default: LLM_MODEL)
+	LLMRerankTopN      int           // candidates scored by the LLM (default: 10)
+	LLMRerankBudget    time.Duration // latency budget before falling back to heuristic order (default: 3s)
+	LLMRerankCacheSize int           // cached (query, chunk) scores (default: 4096)
+
+	// Query transformations (HyDE and multi-query, requested per query)
+	QueryTransformModel     string        // chat model that writes hypothetical code and paraphrases (default: LLM_MODEL)
+	QueryTransformBudget    time.Duration // time allowed per transformation before searching without it (default: 5s)
+	QueryTransformCacheSize int           // cached transformations (default: 1024)
+
+	// Stage deadlines; a stage that runs out of time is cut short and the
+	// response is marked partial (0 disables)
+	SearchDeadline    time.Duration // parallel vector and keyword search (default: 5s)
+	RerankDeadline    time.Duration // reranking (default: 5s)
+	ExpansionDeadline time.Duration // context expansion (default: 2s)
+
+	// Feedback
+	FeedbackTraceTTL time.Duration // how long after a query feedback can be given on it (default: 168h)
+
+	// Query log
+	QueryLog               string   // "redis" (default), "file" or "off"
+	QueryLogPath           string   // JSON Lines file for QueryLog=file (default: query-log.jsonl)
+	QueryLogMaxEntries     int      // entries kept in the Redis stream (default: 10000)
+	QueryLogMaxBytes       int64    // file size before rotating to .1 (default: 64 MiB)
+	QueryLogRedact         string   // "none" (default), "secrets", "hash" or "omit"
+	QueryLogRedactPatterns []string // extra regular expressions to mask
+
+	// Chat
+	ChatSessionTTL    time.Duration // how long a chat session is kept after its last message (default: 24h)
+	ChatHistoryTokens int           // conversation history sent with each message (default: 1024)
+	ChatMaxTurns      int           // messages kept per session (default: 20)
+	ChatReuseChunks   int           // chunks of the previous answer that may be kept in context (default: 3)
+
+	// Agent
+	AgentMaxSteps  int // tool calls per agent run (default: 6)
+	AgentMaxTokens int // model tokens per agent run (default: 32000)
 }
 
 // Load reads configuration from environment variables and .env file
@@ -65,9 +130,27 @@ func Load() (*Config, error) {
 		LLMModel:       getEnvOrDefault("LLM_MODEL", "llama3.2:1b"),
 		VectorStoreURL: getEnvOrDefault("VECTOR_STORE_URL", "http://localhost:6333"),
 		CollectionName: getEnvOrDefault("COLLECTION_NAME", "code_chunks"),
+
+		VectorStore:        getEnvOrDefault("VECTOR_STORE", "qdrant"),
+		VectorStorePath:    getEnvOrDefault("VECTOR_STORE_PATH", "vector-store"),
+		VectorIndex:        getEnvOrDefault("VECTOR_INDEX", "flat"),
+		VectorQuantization: getEnvOrDefault("VECTOR_QUANTIZATION", "none"),
+
 		TargetCodebase: os.Getenv("TARGET_CODEBASE"),
 		MaxChunkSize:   512, // all-minilm supports 512 tokens
 		ChunkOverlap:   50,
+
+		IndexIncludeGlobs: getEnvAsSlice("INDEX_INCLUDE_GLOBS", nil),
+		IndexExcludeGlobs: getEnvAsSlice("INDEX_EXCLUDE_GLOBS", []string{".*/", "node_modules/", "vendor/"}),
+		RespectGitignore:  getEnvAsBool("RESPECT_GITIGNORE", true),
+		MaxFileSize:       int64(getEnvAsInt("MAX_FILE_SIZE", 1<<20)),
+		SkipBinaryFiles:   getEnvAsBool("SKIP_BINARY_FILES", true),
+		SkipGenerated:     getEnvAsBool("SKIP_GENERATED", true),
+
+		WatchBurstThreshold: getEnvAsInt("WATCH_BURST_THRESHOLD", 50),
+		WatchMode:           getEnvOrDefault("WATCH_MODE", "auto"),
+		WatchPollInterval:   getEnvAsDuration("WATCH_POLL_INTERVAL", 2*time.Second),
+
 		ServerPort:     getEnvOrDefault("SERVER_PORT", "8080"),
 		LogLevel:       getEnvOrDefault("LOG_LEVEL", "debug"),
 		LogFormat:      getEnvOrDefault("LOG_FORMAT", "json"),
@@ -79,6 +162,11 @@ func Load() (*Config, error) {
 		HybridEnabled:      getEnvAsBool("HYBRID_ENABLED", true),
 		HybridVectorWeight: getEnvAsFloat("HYBRID_VECTOR_WEIGHT", 0.7),
 		FusionStrategy:     getEnvOrDefault("FUSION_STRATEGY", "rrf"),
+		KeywordIndex:       getEnvOrDefault("KEYWORD_INDEX", "redis"),
+		KeywordIndexPath:   getEnvOrDefault("KEYWORD_INDEX_PATH", "keyword-index"),
+		KeywordIndexPrefix: getEnvOrDefault("KEYWORD_INDEX_PREFIX", "rag:"),
+		KeywordMaxPostings: getEnvAsInt("KEYWORD_MAX_POSTINGS", 10000),
+		TrigramIndex:       getEnvAsBool("TRIGRAM_INDEX", true),
 		BM25K1:             getEnvAsFloat("BM25_K1", 1.2),
 		BM25B:              getEnvAsFloat("BM25_B", 0.75),
 
@@ -90,12 +178,74 @@ func Load() (*Config, error) {
 
 		UseMMR:    getEnvAsBool("USE_MMR", true),
 		MMRLambda: getEnvAsFloat("MMR_LAMBDA", 0.7),
+
+		Reranker:           getEnvOrDefault("RERANKER", "heuristic"),
+		RerankerConfig:     os.Getenv("RERANKER_CONFIG"),
+		LLMRerankModel:     os.Getenv("LLM_RERANK_MODEL"),
+		LLMRerankTopN:      getEnvAsInt("LLM_RERANK_TOP_N", 10),
+		LLMRerankBudget:    getEnvAsDuration("LLM_RERANK_BUDGET", 3*time.Second),
+		LLMRerankCacheSize: getEnvAsInt("LLM_RERANK_CACHE_SIZE", 4096),
+
+		QueryTransformModel:     os.Getenv("QUERY_TRANSFORM_MODEL"),
+		QueryTransformBudget:    getEnvAsDuration("QUERY_TRANSFORM_BUDGET", 5*time.Second),
+		QueryTransformCacheSize: getEnvAsInt("QUERY_TRANSFORM_CACHE_SIZE", 1024),
+
+		SearchDeadline:    getEnvAsDuration("SEARCH_DEADLINE", 5*time.Second),
+		RerankDeadline:    getEnvAsDuration("RERANK_DEADLINE", 5*time.Second),
+		ExpansionDeadline: getEnvAsDuration("EXPANSION_DEADLINE", 2*time.Second),
+
+		FeedbackTraceTTL: getEnvAsDuration("FEEDBACK_TRACE_TTL", 7*24*time.Hour),
+
+		QueryLog:               getEnvOrDefault("QUERY_LOG", "redis"),
+		QueryLogPath:           getEnvOrDefault("QUERY_LOG_PATH", "query-log.jsonl"),
+		QueryLogMaxEntries:     getEnvAsInt("QUERY_LOG_MAX_ENTRIES", 10000),
+		QueryLogMaxBytes:       int64(getEnvAsInt("QUERY_LOG_MAX_BYTES", 64<<20)),
+		QueryLogRedact:         getEnvOrDefault("QUERY_LOG_REDACT", "none"),
+		QueryLogRedactPatterns: getEnvAsSlice("QUERY_LOG_REDACT_PATTERNS", nil),
+
+		ChatSessionTTL:    getEnvAsDuration("CHAT_SESSION_TTL", 24*time.Hour),
+		ChatHistoryTokens: getEnvAsInt("CHAT_HISTORY_TOKENS", 1024),
+		ChatMaxTurns:      getEnvAsInt("CHAT_MAX_TURNS", 20),
+		ChatReuseChunks:   getEnvAsInt("CHAT_REUSE_CHUNKS", 3),
+
+		AgentMaxSteps:  getEnvAsInt("AGENT_MAX_STEPS", 6),
+		AgentMaxTokens: getEnvAsInt("AGENT_MAX_TOKENS", 32000),
 	}
 
 	// Validate required fields
 	if cfg.OllamaURL == "" {
 		return nil, fmt.Errorf("OLLAMA_URL must be set")
 	}
+	if cfg.Reranker != "heuristic" && cfg.Reranker != "llm" {
+		return nil, fmt.Errorf("RERANKER must be \"heuristic\" or \"llm\", got %q", cfg.Reranker)
+	}
+	switch cfg.QueryLog {
+	case "redis", "file", "off":
+	default:
+		return nil, fmt.Errorf("QUERY_LOG must be \"redis\", \"file\" or \"off\", got %q", cfg.QueryLog)
+	}
+	if cfg.VectorStore != "qdrant" && cfg.VectorStore != "local" {
+		return nil, fmt.Errorf("VECTOR_STORE must be \"qdrant\" or \"local\", got %q", cfg.VectorStore)
+	}
+	if cfg.VectorIndex != "flat" && cfg.VectorIndex != "hnsw" {
+		return nil, fmt.Errorf("VECTOR_INDEX must be \"flat\" or \"hnsw\", got %q", cfg.VectorIndex)
+	}
+	if cfg.VectorQuantization != "none" && cfg.VectorQuantization != "int8" {
+		return nil, fmt.Errorf("VECTOR_QUANTIZATION must be \"none\" or \"int8\", got %q", cfg.VectorQuantization)
+	}
+	if cfg.KeywordIndex != "redis" && cfg.KeywordIndex != "local" {
+		return nil, fmt.Errorf("KEYWORD_INDEX must be \"redis\" or \"local\", got %q", cfg.KeywordIndex)
+	}
+	// Without Redis, chat sessions and feedback are disabled
+	_, redisURLSet := os.LookupEnv("REDIS_URL")
+	cfg.RedisEnabled = redisURLSet || cfg.KeywordIndex == "redis" || cfg.QueryLog == "redis"
+
+	if cfg.LLMRerankModel == "" {
+		cfg.LLMRerankModel = cfg.LLMModel
+	}
+	if cfg.QueryTransformModel == "" {
+		cfg.QueryTransformModel = cfg.LLMModel
+	}
 
 	return cfg, nil
 }
@@ -126,6 +276,31 @@ func getEnvAsFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvAsSlice parses a comma-separated list, trimming blanks
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// getEnvAsDuration parses a Go duration such as "500ms" or "2s"
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		return value == "true"
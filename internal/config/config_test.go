@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -38,25 +39,48 @@ func TestLoad(t *testing.T) {
 		if cfg.OllamaURL != "http://localhost:11434" {
 			t.Errorf("OllamaURL = %v, want default", cfg.OllamaURL)
 		}
+		if cfg.KeywordIndexPrefix != "rag:" || cfg.KeywordMaxPostings != 10000 {
+			t.Errorf("KeywordIndexPrefix = %v, KeywordMaxPostings = %v, want defaults", cfg.KeywordIndexPrefix, cfg.KeywordMaxPostings)
+		}
 	})
 
 	t.Run("custom values", func(t *testing.T) {
 		envVars := map[string]string{
-			"OLLAMA_URL":           "http://custom:11434",
-			"EMBEDDING_MODEL":      "custom-model",
-			"LLM_MODEL":            "custom-llm",
-			"VECTOR_STORE_URL":     "http://custom-vec:6333",
-			"COLLECTION_NAME":      "custom-coll",
-			"SERVER_PORT":          "9090",
-			"LOG_LEVEL":            "debug",
-			"LOG_FORMAT":           "text",
-			"REDIS_URL":            "custom-redis:6379",
-			"REDIS_DB":             "1",
-			"HYBRID_ENABLED":       "false",
-			"HYBRID_VECTOR_WEIGHT": "0.5",
-			"FUSION_STRATEGY":      "custom-fusion",
-			"BM25_K1":              "1.5",
-			"BM25_B":               "0.8",
+			"OLLAMA_URL":            "http://custom:11434",
+			"EMBEDDING_MODEL":       "custom-model",
+			"LLM_MODEL":             "custom-llm",
+			"VECTOR_STORE_URL":      "http://custom-vec:6333",
+			"COLLECTION_NAME":       "custom-coll",
+			"SERVER_PORT":           "9090",
+			"LOG_LEVEL":             "debug",
+			"LOG_FORMAT":            "text",
+			"REDIS_URL":             "custom-redis:6379",
+			"REDIS_DB":              "1",
+			"HYBRID_ENABLED":        "false",
+			"HYBRID_VECTOR_WEIGHT":  "0.5",
+			"FUSION_STRATEGY":       "custom-fusion",
+			"BM25_K1":               "1.5",
+			"BM25_B":                "0.8",
+			"INDEX_EXCLUDE_GLOBS":   "dist/, *.gen.go ,",
+			"MAX_FILE_SIZE":         "2048",
+			"WATCH_BURST_THRESHOLD": "10",
+			"WATCH_MODE":            "poll",
+			"WATCH_POLL_INTERVAL":   "750ms",
+			"RERANKER":              "llm",
+			"LLM_RERANK_BUDGET":     "1500ms",
+			"FEEDBACK_TRACE_TTL":    "24h",
+			"QUERY_LOG":             "file",
+			"QUERY_LOG_REDACT":      "hash",
+			"CHAT_SESSION_TTL":      "2h",
+			"CHAT_HISTORY_TOKENS":   "512",
+			"AGENT_MAX_STEPS":       "3",
+			"QUERY_TRANSFORM_MODEL": "small-llm",
+			"RERANK_DEADLINE":       "0",
+			"KEYWORD_INDEX":         "local",
+			"VECTOR_STORE":          "local",
+			"VECTOR_INDEX":          "hnsw",
+			"VECTOR_QUANTIZATION":   "int8",
+			"TRIGRAM_INDEX":         "false",
 		}
 
 		for k, v := range envVars {
@@ -84,5 +108,108 @@ func TestLoad(t *testing.T) {
 		if cfg.BM25K1 != 1.5 {
 			t.Errorf("BM25K1 = %v", cfg.BM25K1)
 		}
+		if len(cfg.IndexExcludeGlobs) != 2 || cfg.IndexExcludeGlobs[1] != "*.gen.go" {
+			t.Errorf("IndexExcludeGlobs = %v", cfg.IndexExcludeGlobs)
+		}
+		if cfg.MaxFileSize != 2048 {
+			t.Errorf("MaxFileSize = %v", cfg.MaxFileSize)
+		}
+		if cfg.WatchBurstThreshold != 10 {
+			t.Errorf("WatchBurstThreshold = %v", cfg.WatchBurstThreshold)
+		}
+		if cfg.WatchMode != "poll" || cfg.WatchPollInterval != 750*time.Millisecond {
+			t.Errorf("WatchMode = %v, WatchPollInterval = %v", cfg.WatchMode, cfg.WatchPollInterval)
+		}
+		if cfg.Reranker != "llm" || cfg.LLMRerankModel != "custom-llm" || cfg.LLMRerankBudget != 1500*time.Millisecond {
+			t.Errorf("Reranker = %v, LLMRerankModel = %v, LLMRerankBudget = %v", cfg.Reranker, cfg.LLMRerankModel, cfg.LLMRerankBudget)
+		}
+		if cfg.FeedbackTraceTTL != 24*time.Hour {
+			t.Errorf("FeedbackTraceTTL = %v", cfg.FeedbackTraceTTL)
+		}
+		if cfg.QueryLog != "file" || cfg.QueryLogPath != "query-log.jsonl" || cfg.QueryLogRedact != "hash" {
+			t.Errorf("QueryLog = %v, QueryLogPath = %v, QueryLogRedact = %v", cfg.QueryLog, cfg.QueryLogPath, cfg.QueryLogRedact)
+		}
+		if cfg.ChatSessionTTL != 2*time.Hour || cfg.ChatHistoryTokens != 512 || cfg.ChatMaxTurns != 20 {
+			t.Errorf("ChatSessionTTL = %v, ChatHistoryTokens = %v, ChatMaxTurns = %v", cfg.ChatSessionTTL, cfg.ChatHistoryTokens, cfg.ChatMaxTurns)
+		}
+		if cfg.QueryTransformModel != "small-llm" || cfg.QueryTransformBudget != 5*time.Second {
+			t.Errorf("QueryTransformModel = %v, QueryTransformBudget = %v", cfg.QueryTransformModel, cfg.QueryTransformBudget)
+		}
+		if cfg.KeywordIndex != "local" || cfg.KeywordIndexPath != "keyword-index" {
+			t.Errorf("KeywordIndex = %v, KeywordIndexPath = %v", cfg.KeywordIndex, cfg.KeywordIndexPath)
+		}
+		if cfg.TrigramIndex {
+			t.Errorf("TrigramIndex = %v", cfg.TrigramIndex)
+		}
+		if cfg.VectorStore != "local" || cfg.VectorStorePath != "vector-store" || cfg.VectorIndex != "hnsw" || cfg.VectorQuantization != "int8" {
+			t.Errorf("VectorStore = %v, VectorStorePath = %v, VectorIndex = %v, VectorQuantization = %v", cfg.VectorStore, cfg.VectorStorePath, cfg.VectorIndex, cfg.VectorQuantization)
+		}
+		if cfg.SearchDeadline != 5*time.Second || cfg.RerankDeadline != 0 || cfg.ExpansionDeadline != 2*time.Second {
+			t.Errorf("SearchDeadline = %v, RerankDeadline = %v, ExpansionDeadline = %v", cfg.SearchDeadline, cfg.RerankDeadline, cfg.ExpansionDeadline)
+		}
+		if cfg.AgentMaxSteps != 3 || cfg.AgentMaxTokens != 32000 {
+			t.Errorf("AgentMaxSteps = %v, AgentMaxTokens = %v", cfg.AgentMaxSteps, cfg.AgentMaxTokens)
+		}
+	})
+
+	t.Run("redis only when configured or needed", func(t *testing.T) {
+		os.Clearenv()
+		cfg, err := Load()
+		if err != nil || !cfg.RedisEnabled {
+			t.Fatalf("Load() = %v, RedisEnabled = %v, want Redis for the default keyword index", err, cfg != nil && cfg.RedisEnabled)
+		}
+
+		os.Setenv("KEYWORD_INDEX", "local")
+		os.Setenv("QUERY_LOG", "file")
+		if cfg, _ := Load(); cfg.RedisEnabled {
+			t.Error("RedisEnabled without REDIS_URL or anything kept in Redis")
+		}
+		os.Setenv("REDIS_URL", "localhost:6379")
+		if cfg, _ := Load(); !cfg.RedisEnabled {
+			t.Error("RedisEnabled = false with REDIS_URL set")
+		}
+		os.Clearenv()
+	})
+
+	t.Run("invalid query log", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("QUERY_LOG", "kafka")
+		defer os.Unsetenv("QUERY_LOG")
+
+		if _, err := Load(); err == nil {
+			t.Error("expected error for unknown query log sink")
+		}
+	})
+
+	t.Run("invalid keyword index", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("KEYWORD_INDEX", "elasticsearch")
+		defer os.Unsetenv("KEYWORD_INDEX")
+
+		if _, err := Load(); err == nil {
+			t.Error("expected error for unknown keyword index")
+		}
+	})
+
+	t.Run("invalid vector store", func(t *testing.T) {
+		for key, value := range map[string]string{"VECTOR_STORE": "milvus", "VECTOR_INDEX": "ivf", "VECTOR_QUANTIZATION": "int4"} {
+			os.Clearenv()
+			os.Setenv(key, value)
+
+			if _, err := Load(); err == nil {
+				t.Errorf("expected error for %s=%s", key, value)
+			}
+		}
+		os.Clearenv()
+	})
+
+	t.Run("invalid reranker", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("RERANKER", "cross-encoder")
+		defer os.Unsetenv("RERANKER")
+
+		if _, err := Load(); err == nil {
+			t.Error("expected error for unknown reranker")
+		}
 	})
 }
@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // CodeChunk represents a semantically meaningful piece of code
 type CodeChunk struct {
@@ -14,6 +18,8 @@ type CodeChunk struct {
 	Metadata     map[string]string `json:"metadata"`
 	Dependencies []string          `json:"dependencies"` // imported modules, called functions
 	Embedding    []float32         `json:"embedding,omitempty"`
+	Generation   int64             `json:"generation,omitempty"` // indexing run that wrote the chunk
+	FileHash     string            `json:"file_hash,omitempty"`  // content hash of the file when the chunk was written
 	CreatedAt    time.Time         `json:"created_at"`
 	UpdatedAt    time.Time         `json:"updated_at"`
 }
@@ -37,16 +43,156 @@ type SearchQuery struct {
 	FilePath   string            `json:"file_path,omitempty"`
 	MaxResults int               `json:"max_results,omitempty"`
 	Filters    map[string]string `json:"filters,omitempty"`
+
+	// Pipeline stages that run by default and can be turned off per request
+	SkipRerank    bool `json:"skip_rerank,omitempty"`
+	SkipExpansion bool `json:"skip_expansion,omitempty"`
+	SkipHierarchy bool `json:"skip_hierarchy,omitempty"`
+	SkipExact     bool `json:"skip_exact,omitempty"` // exact matching of code-like query terms
+
+	// Query transformations, off by default. HyDE also searches with code the
+	// LLM sketches as an answer; MultiQuery also searches with that many LLM
+	// paraphrases of the query.
+	HyDE       bool `json:"hyde,omitempty"`
+	MultiQuery int  `json:"multi_query,omitempty"`
+
+	// Debug attaches a ResultDebug to every result explaining its ranking
+	Debug bool `json:"debug,omitempty"`
+}
+
+// SearchRequest is a retrieval-only search. MaxResults is the page size;
+// Cursor continues from the NextCursor of a previous page.
+type SearchRequest struct {
+	SearchQuery
+	Cursor      string `json:"cursor,omitempty"`
+	GroupByFile bool   `json:"group_by_file,omitempty"` // page over files instead of chunks
+}
+
+// SearchPage is one page of retrieval-only search results
+type SearchPage struct {
+	Query      string          `json:"query"`
+	Results    []*SearchResult `json:"results,omitempty"`
+	Groups     []*FileGroup    `json:"groups,omitempty"`
+	Total      int             `json:"total"` // results (or files) across all pages
+	NextCursor string          `json:"next_cursor,omitempty"`
+	QueryID    string          `json:"query_id,omitempty"` // identifies this page when giving feedback
+	Partial    bool            `json:"partial,omitempty"`  // a stage ran out of time; results are best effort
+}
+
+// FileGroup holds the results of a search that came from one file
+type FileGroup struct {
+	FilePath string          `json:"file_path"`
+	Score    float32         `json:"score"` // best relevance score in the group
+	Results  []*SearchResult `json:"results"`
 }
 
 // SearchResult represents a single search result
 type SearchResult struct {
 	Chunk          *CodeChunk `json:"chunk"`
 	Score          float32    `json:"-"`
-	Source         string     `json:"source"` // "vector", "keyword", "hybrid"
+	Source         string     `json:"source"`         // "vector", "keyword", "hybrid"
+	Rank           int        `json:"rank,omitempty"` // position in the ranked results; 0 for expanded context
 	VectorScore    float32    `json:"vector_score,omitempty"`
 	KeywordScore   float32    `json:"keyword_score,omitempty"`
+	FusionScore    float32    `json:"fusion_score,omitempty"`
+	RerankScore    float32    `json:"rerank_score,omitempty"`
 	RelevanceScore float32    `json:"relevance_score"`
+
+	Debug *ResultDebug `json:"debug,omitempty"`
+}
+
+// ResultDebug explains how a result was ranked. The retriever attaches one
+// to each result when the query sets Debug, and every pipeline stage fills
+// in its own part; stages that did not run leave theirs nil.
+type ResultDebug struct {
+	VectorRank   int                   `json:"vector_rank,omitempty"` // 1-based; 0 when not found by vector search
+	VectorScore  float32               `json:"vector_score,omitempty"`
+	KeywordRank  int                   `json:"keyword_rank,omitempty"` // 1-based; 0 when not found by keyword search
+	KeywordScore float32               `json:"keyword_score,omitempty"`
+	ExactRank    int                   `json:"exact_rank,omitempty"` // 1-based; 0 when not found by exact search
+	BM25         *BM25Explanation      `json:"bm25,omitempty"`
+	Fusion       *FusionExplanation    `json:"fusion,omitempty"`
+	Rerank       *RerankExplanation    `json:"rerank,omitempty"`
+	LLM          *LLMRerankExplanation `json:"llm,omitempty"` // set when the LLM reranker considered the result
+	MMR          *MMRExplanation       `json:"mmr,omitempty"`
+	Hierarchy    *HierarchyExplanation `json:"hierarchy,omitempty"`
+	Expansion    *ExpansionExplanation `json:"expansion,omitempty"` // set for chunks added by graph expansion
+	Variants     []string              `json:"variants,omitempty"`  // query variants that found the result, when the query was transformed
+}
+
+// BM25Explanation breaks a BM25 score down by query term
+type BM25Explanation struct {
+	K1           float64    `json:"k1"`
+	B            float64    `json:"b"`
+	DocCount     int        `json:"doc_count"`
+	AvgDocLength float64    `json:"avg_doc_length"`
+	DocLength    int        `json:"doc_length"`
+	Terms        []BM25Term `json:"terms"`
+	Score        float64    `json:"score"`
+}
+
+// BM25Term is one query term's contribution to a BM25 score. Terms not in
+// the document have zero TF and score.
+type BM25Term struct {
+	Term        string  `json:"term"`
+	TF          int     `json:"tf"`
+	DF          int     `json:"df"`
+	IDF         float64 `json:"idf"`
+	TFComponent float64 `json:"tf_component"`
+	Score       float64 `json:"score"`
+}
+
+// FusionExplanation shows what each source contributed to the fused score
+type FusionExplanation struct {
+	Strategy            string  `json:"strategy"` // "rrf", "weighted", "max", or "none" for a single source
+	VectorContribution  float32 `json:"vector_contribution"`
+	KeywordContribution float32 `json:"keyword_contribution"`
+	Score               float32 `json:"score"`
+}
+
+// RerankExplanation lists the multipliers the heuristic reranker applied;
+// 1 means the factor did not fire
+type RerankExplanation struct {
+	InputScore   float32 `json:"input_score"`
+	TypeWeight   float32 `json:"type_weight"`
+	ExactMatch   float32 `json:"exact_match"`
+	TokenMatch   float32 `json:"token_match"`
+	PathMatch    float32 `json:"path_match"`
+	PriorityPath float32 `json:"priority_path"`
+	Recency      float32 `json:"recency"`
+	Score        float32 `json:"score"`
+}
+
+// LLMRerankExplanation shows the relevance the LLM reranker assigned
+type LLMRerankExplanation struct {
+	Score    float32 `json:"score"` // 0 to 10
+	Cached   bool    `json:"cached,omitempty"`
+	Fallback string  `json:"fallback,omitempty"` // why the heuristic order was kept: "timeout", "error" or "unparseable"
+}
+
+// MMRExplanation shows the diversity penalty MMR applied when it selected
+// the result
+type MMRExplanation struct {
+	Lambda        float32 `json:"lambda"`
+	Relevance     float32 `json:"relevance"`
+	MaxSimilarity float32 `json:"max_similarity"` // to results selected before it
+	Penalty       float32 `json:"penalty"`
+	Score         float32 `json:"score"`
+}
+
+// HierarchyExplanation shows where the result stood within its file when
+// the per-file cap was applied
+type HierarchyExplanation struct {
+	FileRank   int `json:"file_rank"`
+	MaxPerFile int `json:"max_per_file"`
+	CutInFile  int `json:"cut_in_file"` // results from the same file dropped by the cap
+}
+
+// ExpansionExplanation records how graph expansion reached a chunk
+type ExpansionExplanation struct {
+	Relation string `json:"relation"` // "callee", "caller", "parent_type", "child_method" or "import"
+	From     string `json:"from"`     // chunk it was expanded from
+	Depth    int    `json:"depth"`
 }
 
 // RetrievalContext represents the final context for LLM
@@ -59,13 +205,17 @@ type RetrievalContext struct {
 
 // IndexingJob represents a code indexing task
 type IndexingJob struct {
-	ID         string    `json:"id"`
-	Path       string    `json:"path"`
-	Status     JobStatus `json:"status"`
-	Progress   float32   `json:"progress"`
-	StartedAt  time.Time `json:"started_at"`
-	FinishedAt time.Time `json:"finished_at,omitempty"`
-	Error      string    `json:"error,omitempty"`
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind,omitempty"`
+	Path        string    `json:"path"`
+	Status      JobStatus `json:"status"`
+	Progress    float32   `json:"progress"`
+	FilesTotal  int       `json:"files_total"`
+	FilesDone   int       `json:"files_done"`
+	FilesFailed int       `json:"files_failed"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at,omitempty"`
+	Error       string    `json:"error,omitempty"`
 }
 
 // JobStatus represents the status of an indexing job
@@ -77,3 +227,288 @@ const (
 	JobStatusCompleted JobStatus = "completed"
 	JobStatusFailed    JobStatus = "failed"
 )
+
+// ChunkRef identifies a chunk held by one of the index stores
+type ChunkRef struct {
+	ID         string `json:"id"`
+	FilePath   string `json:"file_path"`
+	Generation int64  `json:"generation"`
+	FileHash   string `json:"file_hash,omitempty"`
+}
+
+// StaleFile selects a file's chunks written by any generation other than
+// Generation, for removal once that generation is stored. Generation 0
+// selects all of the file's chunks.
+type StaleFile struct {
+	FilePath   string
+	Generation int64
+}
+
+// CanonicalChunkID returns id in the form the chunker generates: 32
+// lower-case hex digits. Qdrant stores chunk IDs as UUIDs and returns them
+// hyphenated, so IDs read back from it are canonicalized before they are
+// compared with the IDs held by the other stores. Other IDs are returned
+// unchanged.
+func CanonicalChunkID(id string) string {
+	if len(id) != 36 || id[8] != '-' || id[13] != '-' || id[18] != '-' || id[23] != '-' {
+		return id
+	}
+	hex := id[:8] + id[9:13] + id[14:18] + id[19:23] + id[24:]
+	for i := 0; i < len(hex); i++ {
+		c := hex[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return id
+		}
+	}
+	return strings.ToLower(hex)
+}
+
+// ChunkFilter restricts a vector search by chunk payload. Empty fields
+// match everything.
+type ChunkFilter struct {
+	Language   string
+	PathPrefix string
+	ChunkType  ChunkType
+}
+
+// Matches reports whether chunk passes the filter. Languages are compared
+// case-insensitively.
+func (f *ChunkFilter) Matches(chunk *CodeChunk) bool {
+	if f == nil {
+		return true
+	}
+	return (f.Language == "" || strings.EqualFold(f.Language, chunk.Language)) &&
+		strings.HasPrefix(chunk.FilePath, f.PathPrefix) &&
+		(f.ChunkType == "" || f.ChunkType == chunk.ChunkType)
+}
+
+// VerifyIssue is a single inconsistency found by a verify run
+type VerifyIssue struct {
+	Kind     VerifyIssueKind `json:"kind"`
+	Store    string          `json:"store"` // "vector", "keyword", "graph" or "filesystem"
+	FilePath string          `json:"file_path,omitempty"`
+	ChunkIDs []string        `json:"chunk_ids,omitempty"`
+	Reason   string          `json:"reason"`
+}
+
+// VerifyIssueKind classifies a VerifyIssue
+type VerifyIssueKind string
+
+const (
+	// VerifyOrphan is an index entry with no file (or no vector chunk) behind it
+	VerifyOrphan VerifyIssueKind = "orphan"
+	// VerifyMissing is an indexable file with no chunks in the vector store
+	VerifyMissing VerifyIssueKind = "missing"
+	// VerifyStale is an indexed file whose entries no longer match the file
+	// or disagree between stores
+	VerifyStale VerifyIssueKind = "stale"
+)
+
+// VerifyReport is the result of comparing the indexed root with the stores
+type VerifyReport struct {
+	Root         string         `json:"root"`
+	FilesOnDisk  int            `json:"files_on_disk"`
+	FilesIndexed int            `json:"files_indexed"`
+	Chunks       map[string]int `json:"chunks"` // store -> chunks under root
+	Issues       []VerifyIssue  `json:"issues"`
+	Repaired     bool           `json:"repaired"`
+	RepairErrors []string       `json:"repair_errors,omitempty"`
+	Duration     time.Duration  `json:"duration"`
+}
+
+// Count returns the number of issues of the given kind
+func (r *VerifyReport) Count(kind VerifyIssueKind) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// RelatedChunk is a chunk linked to another through the dependency graph
+type RelatedChunk struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+}
+
+// ChunkExplanation describes a stored chunk and its graph neighbours
+type ChunkExplanation struct {
+	Chunk      *CodeChunk     `json:"chunk"`
+	Calls      []RelatedChunk `json:"calls,omitempty"`
+	CalledBy   []RelatedChunk `json:"called_by,omitempty"`
+	Imports    []RelatedChunk `json:"imports,omitempty"`
+	ImportedBy []RelatedChunk `json:"imported_by,omitempty"`
+	DefinedIn  []RelatedChunk `json:"defined_in,omitempty"`
+	Defines    []RelatedChunk `json:"defines,omitempty"`
+}
+
+// IndexStatus summarises the state of the indexer
+type IndexStatus struct {
+	FilesTracked  int   `json:"files_tracked"` // files with a recorded content hash
+	FilesIndexed  int   `json:"files_indexed"` // counters below are for the last run
+	FilesSkipped  int   `json:"files_skipped"`
+	FilesErrored  int   `json:"files_errored"`
+	ChunksCreated int   `json:"chunks_created"`
+	DurationMS    int64 `json:"duration_ms"`
+	JobsRunning   int   `json:"jobs_running"`
+}
+
+// QueryTrace records what a query or search returned, so feedback can be
+// tied to the results the user actually saw
+type QueryTrace struct {
+	ID        string        `json:"id"`
+	Kind      string        `json:"kind"` // "query", "search", "chat" or "agent"
+	Query     SearchQuery   `json:"query"`
+	Results   []TraceResult `json:"results"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// TraceResult is one returned result with its per-stage scores
+type TraceResult struct {
+	ChunkID        string    `json:"chunk_id"`
+	FilePath       string    `json:"file_path"`
+	Symbol         string    `json:"symbol,omitempty"` // function or type name; methods are Receiver.Name
+	ChunkType      ChunkType `json:"chunk_type"`
+	Language       string    `json:"language"`
+	StartLine      int       `json:"start_line"`
+	EndLine        int       `json:"end_line"`
+	Rank           int       `json:"rank"` // 0 for expanded context
+	Source         string    `json:"source"`
+	VectorScore    float32   `json:"vector_score,omitempty"`
+	KeywordScore   float32   `json:"keyword_score,omitempty"`
+	FusionScore    float32   `json:"fusion_score,omitempty"`
+	RerankScore    float32   `json:"rerank_score,omitempty"`
+	RelevanceScore float32   `json:"relevance_score"`
+}
+
+// FeedbackRating labels a single result
+type FeedbackRating string
+
+const (
+	RatingUp     FeedbackRating = "up"
+	RatingDown   FeedbackRating = "down"
+	RatingAnswer FeedbackRating = "answer" // the result that answered the question
+)
+
+// ResultFeedback is the rating of one result
+type ResultFeedback struct {
+	ChunkID string         `json:"chunk_id"`
+	Rating  FeedbackRating `json:"rating"`
+}
+
+// FeedbackRequest is the body of POST /api/feedback
+type FeedbackRequest struct {
+	QueryID string           `json:"query_id" binding:"required"`
+	Helpful *bool            `json:"helpful,omitempty"` // thumbs up or down on the answer as a whole
+	Results []ResultFeedback `json:"results,omitempty"`
+	Note    string           `json:"note,omitempty"`
+}
+
+// Feedback is submitted feedback together with the trace it refers to
+type Feedback struct {
+	FeedbackRequest
+	Trace     *QueryTrace `json:"trace"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// ChatRequest is the body of POST /api/chat
+type ChatRequest struct {
+	SessionID  string `json:"session_id,omitempty"` // continue this session; empty starts a new one
+	Message    string `json:"message" binding:"required"`
+	MaxResults int    `json:"max_results,omitempty"`
+	Language   string `json:"language,omitempty"`
+	FilePath   string `json:"file_path,omitempty"`
+}
+
+// ChatResponse is the answer to one chat message
+type ChatResponse struct {
+	SessionID string          `json:"session_id"`
+	QueryID   string          `json:"query_id"`
+	Query     string          `json:"query"` // standalone retrieval query the message was condensed into
+	Response  string          `json:"response,omitempty"`
+	Results   []*SearchResult `json:"results"`
+	Reused    []string        `json:"reused,omitempty"`  // chunk IDs carried over from the previous answer
+	Partial   bool            `json:"partial,omitempty"` // a retrieval stage ran out of time
+}
+
+// ChatTurn is one message of a chat session
+type ChatTurn struct {
+	Role     string    `json:"role"` // "user" or "assistant"
+	Content  string    `json:"content"`
+	Query    string    `json:"query,omitempty"`     // user turns: the standalone retrieval query
+	QueryID  string    `json:"query_id,omitempty"`  // assistant turns: the query log and feedback ID
+	ChunkIDs []string  `json:"chunk_ids,omitempty"` // assistant turns: the chunks the answer was given
+	Time     time.Time `json:"time"`
+}
+
+// ChatSession is a conversation with its message history
+type ChatSession struct {
+	ID        string     `json:"id"`
+	Turns     []ChatTurn `json:"turns"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// AgentRequest is the body of POST /api/agent
+type AgentRequest struct {
+	Question  string `json:"question" binding:"required"`
+	MaxSteps  int    `json:"max_steps,omitempty"`  // tool calls allowed; capped by the server
+	MaxTokens int    `json:"max_tokens,omitempty"` // estimated model tokens allowed; capped by the server
+}
+
+// AgentStep is one tool call made by the agent
+type AgentStep struct {
+	Step        int             `json:"step"`
+	Tool        string          `json:"tool"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Observation string          `json:"observation"` // what the tool returned to the model
+	ChunkIDs    []string        `json:"chunk_ids,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	DurationMS  float64         `json:"duration_ms"`
+}
+
+// Reasons an agent run stopped
+const (
+	AgentStopAnswer    = "answer"     // the model answered
+	AgentStopMaxSteps  = "max_steps"  // the step budget ran out
+	AgentStopMaxTokens = "max_tokens" // the token budget ran out
+)
+
+// AgentResponse is the answer of an agent run with its tool trace
+type AgentResponse struct {
+	QueryID    string          `json:"query_id"`
+	Answer     string          `json:"answer"`
+	Steps      []AgentStep     `json:"steps"`
+	Results    []*SearchResult `json:"results"` // chunks the tools returned, in the order first seen
+	TokensUsed int             `json:"tokens_used"`
+	StoppedBy  string          `json:"stopped_by"`
+}
+
+// GrepRequest is the body of POST /api/grep
+type GrepRequest struct {
+	Pattern       string   `json:"pattern" binding:"required"`
+	Regex         bool     `json:"regex,omitempty"`          // Pattern is an RE2 regular expression rather than a literal
+	CaseSensitive bool     `json:"case_sensitive,omitempty"` // default ignores case
+	Paths         []string `json:"paths,omitempty"`          // .gitignore-style globs a file must match; a leading ! excludes
+	MaxResults    int      `json:"max_results,omitempty"`    // matching lines returned; capped by the server
+}
+
+// GrepMatch is one line matching a grep pattern
+type GrepMatch struct {
+	FilePath string   `json:"file_path"`
+	Line     int      `json:"line"`
+	Text     string   `json:"text"`
+	Ranges   [][2]int `json:"ranges"` // byte offsets of each match within Text
+	ChunkID  string   `json:"chunk_id"`
+}
+
+// GrepResponse lists the lines matching a grep pattern in file and line order
+type GrepResponse struct {
+	Matches    []GrepMatch `json:"matches"`
+	Candidates int         `json:"candidates"`          // chunks left to check after the trigram filter
+	Truncated  bool        `json:"truncated,omitempty"` // more lines matched than MaxResults
+}
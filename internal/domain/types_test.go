@@ -47,3 +47,21 @@ func TestSearchResultScore(t *testing.T) {
 		t.Errorf("RelevanceScore = %v, want 0.9", res.RelevanceScore)
 	}
 }
+
+func TestCanonicalChunkID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"0123456789abcdef0123456789abcdef", "0123456789abcdef0123456789abcdef"},
+		{"01234567-89ab-cdef-0123-456789abcdef", "0123456789abcdef0123456789abcdef"},
+		{"01234567-89AB-CDEF-0123-456789ABCDEF", "0123456789abcdef0123456789abcdef"},
+		{"01234567-89ab-cdef-0123-456789abcdeg", "01234567-89ab-cdef-0123-456789abcdeg"},
+		{"/repo/a.go", "/repo/a.go"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalChunkID(tt.id); got != tt.want {
+			t.Errorf("CanonicalChunkID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// Retriever is what an evaluation runs its queries against
+type Retriever interface {
+	Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error)
+}
+
+// PipelineConfig selects the retrieval stages a run uses
+type PipelineConfig struct {
+	Name          string `json:"name"`
+	SkipRerank    bool   `json:"skip_rerank,omitempty"`
+	SkipExpansion bool   `json:"skip_expansion,omitempty"`
+	SkipHierarchy bool   `json:"skip_hierarchy,omitempty"`
+	SkipExact     bool   `json:"skip_exact,omitempty"`
+	HyDE          bool   `json:"hyde,omitempty"`
+	MultiQuery    int    `json:"multi_query,omitempty"`
+}
+
+var presets = map[string]PipelineConfig{
+	"full":         {Name: "full"},
+	"no-rerank":    {Name: "no-rerank", SkipRerank: true},
+	"no-hierarchy": {Name: "no-hierarchy", SkipHierarchy: true},
+	"no-expansion": {Name: "no-expansion", SkipExpansion: true},
+	"no-exact":     {Name: "no-exact", SkipExact: true},
+	"fusion-only":  {Name: "fusion-only", SkipRerank: true, SkipHierarchy: true, SkipExpansion: true},
+	"hyde":         {Name: "hyde", HyDE: true},
+	"multi-query":  {Name: "multi-query", MultiQuery: 3},
+}
+
+// Preset returns a named pipeline configuration
+func Preset(name string) (PipelineConfig, error) {
+	cfg, ok := presets[name]
+	if !ok {
+		return PipelineConfig{}, errors.ValidationError(fmt.Sprintf("unknown config %q (have %s)", name, strings.Join(PresetNames(), ", ")))
+	}
+	return cfg, nil
+}
+
+// PresetNames lists the preset configurations
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options controls an evaluation run
+type Options struct {
+	K       []int            // cutoffs for recall and nDCG (default 1, 5, 10)
+	Configs []PipelineConfig // pipelines to compare (default "full")
+}
+
+// Report is the outcome of an evaluation. It holds no timestamps or other
+// run-specific data apart from latency, so reports from two commits can be
+// diffed directly.
+type Report struct {
+	GoldenSet string         `json:"golden_set"`
+	Queries   int            `json:"queries"`
+	K         []int          `json:"k"`
+	Configs   []ConfigReport `json:"configs"`
+}
+
+// ConfigReport holds the aggregate and per-query results of one pipeline
+type ConfigReport struct {
+	Config  PipelineConfig `json:"config"`
+	Cutoffs []Cutoff       `json:"cutoffs"` // means over all queries
+	MRR     float64        `json:"mrr"`
+	Latency LatencyStats   `json:"latency"`
+	Errors  int            `json:"errors"`
+	Queries []QueryResult  `json:"queries"`
+}
+
+// QueryResult holds the metrics of one golden query
+type QueryResult struct {
+	ID             string   `json:"id"`
+	Query          string   `json:"query"`
+	FirstHit       int      `json:"first_hit"` // 1-based rank of the first relevant result; 0 if none
+	ReciprocalRank float64  `json:"reciprocal_rank"`
+	Cutoffs        []Cutoff `json:"cutoffs"`
+	LatencyMS      float64  `json:"latency_ms"`
+	Missed         []Target `json:"missed,omitempty"` // targets not found within the largest cutoff
+	Error          string   `json:"error,omitempty"`
+}
+
+// Run evaluates every golden query under each configuration. Queries run
+// one at a time so latencies are not skewed by each other; a failing query
+// scores zero and is counted in Errors.
+func Run(ctx context.Context, r Retriever, set *GoldenSet, opts Options) (*Report, error) {
+	ks := normalizeK(opts.K)
+	configs := opts.Configs
+	if len(configs) == 0 {
+		configs = []PipelineConfig{presets["full"]}
+	}
+	maxK := ks[len(ks)-1]
+
+	report := &Report{GoldenSet: set.Name, Queries: len(set.Queries), K: ks}
+	for _, cfg := range configs {
+		logger.Info("Evaluating configuration", "config", cfg.Name, "queries", len(set.Queries))
+
+		cr := ConfigReport{Config: cfg, Queries: make([]QueryResult, 0, len(set.Queries))}
+		latencies := make([]time.Duration, 0, len(set.Queries))
+		for _, gq := range set.Queries {
+			if err := ctx.Err(); err != nil {
+				return nil, errors.Wrap(err, errors.ErrorTypeInternal, "evaluation cancelled")
+			}
+
+			query := domain.SearchQuery{
+				Query:         gq.Query,
+				Language:      gq.Language,
+				MaxResults:    maxK,
+				SkipRerank:    cfg.SkipRerank,
+				SkipExpansion: cfg.SkipExpansion,
+				SkipHierarchy: cfg.SkipHierarchy,
+				SkipExact:     cfg.SkipExact,
+				HyDE:          cfg.HyDE,
+				MultiQuery:    cfg.MultiQuery,
+			}
+			start := time.Now()
+			results, err := r.Retrieve(ctx, query)
+			latency := time.Since(start)
+			latencies = append(latencies, latency)
+
+			if err != nil {
+				logger.Warn("Evaluation query failed", "config", cfg.Name, "id", gq.ID, "error", err)
+				results = nil
+				cr.Errors++
+			}
+			qr := scoreQuery(gq, results, ks)
+			qr.LatencyMS = round(ms(latency))
+			if err != nil {
+				qr.Error = err.Error()
+			}
+			cr.Queries = append(cr.Queries, qr)
+		}
+
+		cr.Cutoffs, cr.MRR = aggregate(cr.Queries, ks)
+		cr.Latency = latencyStats(latencies)
+		report.Configs = append(report.Configs, cr)
+	}
+	return report, nil
+}
+
+func scoreQuery(gq GoldenQuery, results []*domain.SearchResult, ks []int) QueryResult {
+	maxK := ks[len(ks)-1]
+	j := judge(results, gq.Expected, maxK)
+
+	qr := QueryResult{ID: gq.ID, Query: gq.Query, FirstHit: j.firstHit()}
+	if qr.FirstHit > 0 {
+		qr.ReciprocalRank = round(1 / float64(qr.FirstHit))
+	}
+	for _, k := range ks {
+		qr.Cutoffs = append(qr.Cutoffs, Cutoff{K: k, Recall: round(j.recall(k)), NDCG: round(j.ndcg(k))})
+	}
+	for i, pos := range j.foundAt {
+		if pos == 0 {
+			qr.Missed = append(qr.Missed, gq.Expected[i])
+		}
+	}
+	return qr
+}
+
+func aggregate(queries []QueryResult, ks []int) ([]Cutoff, float64) {
+	cutoffs := make([]Cutoff, len(ks))
+	for i, k := range ks {
+		cutoffs[i].K = k
+	}
+	if len(queries) == 0 {
+		return cutoffs, 0
+	}
+
+	mrr := 0.0
+	for _, q := range queries {
+		mrr += q.ReciprocalRank
+		for i, c := range q.Cutoffs {
+			cutoffs[i].Recall += c.Recall
+			cutoffs[i].NDCG += c.NDCG
+		}
+	}
+	n := float64(len(queries))
+	for i := range cutoffs {
+		cutoffs[i].Recall = round(cutoffs[i].Recall / n)
+		cutoffs[i].NDCG = round(cutoffs[i].NDCG / n)
+	}
+	return cutoffs, round(mrr / n)
+}
+
+// normalizeK sorts and de-duplicates the cutoffs, dropping non-positive ones
+func normalizeK(ks []int) []int {
+	seen := make(map[int]bool)
+	var out []int
+	for _, k := range ks {
+		if k > 0 && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	if len(out) == 0 {
+		return []int{1, 5, 10}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// Regression is a metric that dropped below its baseline value
+type Regression struct {
+	Config   string  `json:"config"`
+	Metric   string  `json:"metric"` // "mrr", "recall@k" or "ndcg@k"
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s: %.4f -> %.4f", r.Config, r.Metric, r.Baseline, r.Current)
+}
+
+// Compare returns the quality metrics of current that dropped by more than
+// tolerance against baseline. Configurations and cutoffs that appear in
+// only one report are ignored; latency is not compared.
+func Compare(baseline, current *Report, tolerance float64) []Regression {
+	base := make(map[string]ConfigReport, len(baseline.Configs))
+	for _, cr := range baseline.Configs {
+		base[cr.Config.Name] = cr
+	}
+
+	var regressions []Regression
+	check := func(config, metric string, was, now float64) {
+		if was-now > tolerance {
+			regressions = append(regressions, Regression{Config: config, Metric: metric, Baseline: was, Current: now})
+		}
+	}
+	for _, cr := range current.Configs {
+		b, ok := base[cr.Config.Name]
+		if !ok {
+			continue
+		}
+		check(cr.Config.Name, "mrr", b.MRR, cr.MRR)
+
+		baseCutoffs := make(map[int]Cutoff, len(b.Cutoffs))
+		for _, c := range b.Cutoffs {
+			baseCutoffs[c.K] = c
+		}
+		for _, c := range cr.Cutoffs {
+			bc, ok := baseCutoffs[c.K]
+			if !ok {
+				continue
+			}
+			check(cr.Config.Name, fmt.Sprintf("recall@%d", c.K), bc.Recall, c.Recall)
+			check(cr.Config.Name, fmt.Sprintf("ndcg@%d", c.K), bc.NDCG, c.NDCG)
+		}
+	}
+	return regressions
+}
+
+// LoadReport reads a report written by Save
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "failed to read report")
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "failed to parse report")
+	}
+	return &report, nil
+}
+
+// Save writes the report as indented JSON
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal report")
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to write report")
+	}
+	return nil
+}
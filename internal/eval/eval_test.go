@@ -0,0 +1,139 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+func init() {
+	logger.Init(logger.Config{Level: logger.LevelDebug})
+}
+
+// fakeRetriever answers from a fixed table and records the queries it saw
+type fakeRetriever struct {
+	answers map[string][]*domain.SearchResult
+	seen    []domain.SearchQuery
+}
+
+func (f *fakeRetriever) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
+	f.seen = append(f.seen, query)
+	results, ok := f.answers[query.Query]
+	if !ok {
+		return nil, errors.New("boom")
+	}
+	if query.SkipRerank && len(results) > 1 {
+		// Without reranking the relevant result drops a place
+		results = append([]*domain.SearchResult{results[1], results[0]}, results[2:]...)
+	}
+	return results, nil
+}
+
+func testSet() *GoldenSet {
+	return &GoldenSet{Name: "test", Queries: []GoldenQuery{
+		{ID: "hit", Query: "fusion", Expected: []Target{{FilePath: "fusion.go"}}},
+		{ID: "miss", Query: "bm25", Expected: []Target{{FilePath: "bm25.go"}}},
+		{ID: "error", Query: "unknown", Expected: []Target{{FilePath: "x.go"}}},
+	}}
+}
+
+func testRetriever() *fakeRetriever {
+	return &fakeRetriever{answers: map[string][]*domain.SearchResult{
+		"fusion": {result("fusion.go"), result("other.go")},
+		"bm25":   {result("other.go")},
+	}}
+}
+
+func TestRun(t *testing.T) {
+	r := testRetriever()
+	configs := []PipelineConfig{presets["full"], presets["no-rerank"]}
+
+	report, err := Run(context.Background(), r, testSet(), Options{K: []int{5, 1, 5}, Configs: configs})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(report.K) != 2 || report.K[0] != 1 || report.K[1] != 5 {
+		t.Errorf("K = %v, want [1 5]", report.K)
+	}
+	if len(r.seen) != 6 || r.seen[0].MaxResults != 5 || !r.seen[3].SkipRerank {
+		t.Errorf("unexpected queries %+v", r.seen)
+	}
+
+	full := report.Configs[0]
+	if full.Errors != 1 || full.Queries[2].Error == "" {
+		t.Errorf("expected the failing query to be recorded, got %+v", full.Queries[2])
+	}
+	if full.MRR != 0.3333 {
+		t.Errorf("full MRR = %v, want 0.3333", full.MRR)
+	}
+	if full.Cutoffs[0].Recall != 0.3333 || full.Cutoffs[1].Recall != 0.3333 {
+		t.Errorf("full cutoffs = %+v", full.Cutoffs)
+	}
+	if len(full.Queries[1].Missed) != 1 {
+		t.Errorf("expected bm25.go to be reported missing, got %+v", full.Queries[1].Missed)
+	}
+
+	noRerank := report.Configs[1]
+	if noRerank.Queries[0].FirstHit != 2 || noRerank.Cutoffs[0].Recall != 0 {
+		t.Errorf("no-rerank: unexpected results %+v", noRerank)
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, testRetriever(), testSet(), Options{}); err == nil {
+		t.Error("expected error for cancelled context")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	r := testRetriever()
+	baseline, _ := Run(context.Background(), r, testSet(), Options{Configs: []PipelineConfig{presets["full"]}})
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := baseline.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatalf("LoadReport() error = %v", err)
+	}
+	if got := Compare(loaded, baseline, 0); len(got) != 0 {
+		t.Errorf("identical reports should not regress: %v", got)
+	}
+
+	// The fusion query now ranks its target second
+	r.answers["fusion"] = []*domain.SearchResult{result("other.go"), result("fusion.go")}
+	current, _ := Run(context.Background(), r, testSet(), Options{Configs: []PipelineConfig{presets["full"]}})
+
+	regressions := Compare(loaded, current, 0.01)
+	metrics := make(map[string]bool)
+	for _, reg := range regressions {
+		metrics[reg.Metric] = true
+		if reg.Current >= reg.Baseline {
+			t.Errorf("%s did not drop", reg)
+		}
+	}
+	if !metrics["mrr"] || !metrics["recall@1"] || !metrics["ndcg@1"] || metrics["recall@5"] {
+		t.Errorf("unexpected regressions %v", regressions)
+	}
+	if got := Compare(loaded, current, 1); len(got) != 0 {
+		t.Errorf("a large tolerance should hide regressions, got %v", got)
+	}
+}
+
+func TestPreset(t *testing.T) {
+	cfg, err := Preset("fusion-only")
+	if err != nil || !cfg.SkipRerank || !cfg.SkipExpansion || !cfg.SkipHierarchy {
+		t.Errorf("Preset(fusion-only) = %+v, %v", cfg, err)
+	}
+	if _, err := Preset("nope"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
@@ -0,0 +1,355 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// ChunkSource enumerates indexed chunks
+type ChunkSource interface {
+	ScrollChunks(ctx context.Context, fn func(*domain.CodeChunk) error) error
+}
+
+// LLM is the model that writes the questions
+type LLM interface {
+	Generate(ctx context.Context, messages []llm.ChatMessage) (string, error)
+}
+
+// GenerateOptions controls synthetic golden set generation
+type GenerateOptions struct {
+	Name              string             // golden set name
+	Count             int                // queries to generate (default 200)
+	QuestionsPerChunk int                // questions kept per sampled chunk (default 2)
+	Languages         []string           // only sample these languages (default all)
+	ChunkTypes        []domain.ChunkType // only sample these chunk types (default function, method, class)
+	MinLines          int                // skip chunks shorter than this (default 3)
+	MaxContentChars   int                // truncate chunk content in the prompt (default 4000)
+	Root              string             // make target paths relative to this directory
+	Seed              int64              // sampling seed; the same seed and index give the same sample
+}
+
+// Reasons a generated question is rejected
+const (
+	RejectTooShort     = "too_short"
+	RejectTooLong      = "too_long"
+	RejectContainsCode = "contains_code"
+	RejectDeictic      = "refers_to_snippet" // "this function", "the code above"
+	RejectNamesSymbol  = "names_symbol"      // a keyword lookup, not a question
+	RejectNamesFile    = "names_file"
+	RejectDuplicate    = "duplicate"
+)
+
+// GenerateStats describes a generation run
+type GenerateStats struct {
+	ChunksSeen     int            `json:"chunks_seen"`
+	ChunksEligible int            `json:"chunks_eligible"`
+	ChunksUsed     int            `json:"chunks_used"` // chunks sent to the LLM
+	Strata         map[string]int `json:"strata"`      // language/chunk_type -> queries generated
+	Generated      int            `json:"generated"`
+	Rejected       map[string]int `json:"rejected"`
+	LLMErrors      int            `json:"llm_errors"`
+}
+
+const (
+	minQuestionWords = 4
+	maxQuestionWords = 30
+	// minSymbolLength keeps short names such as New or Run, which are also
+	// ordinary words, from rejecting questions
+	minSymbolLength = 4
+)
+
+var (
+	deicticPattern = regexp.MustCompile(`(?i)\b(this|the above|the following|the given|the provided|above|below)\s+(code|snippet|function|method|class|type|struct|file|chunk|example)\b`)
+	codePattern    = regexp.MustCompile("`|[{};]|:=|=>|\\w\\(\\)")
+	listPrefix     = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s*`)
+)
+
+const generatePrompt = `You write search queries for evaluating a code search engine.
+
+Below is a piece of %s code from the file %s. Write %d different questions
+a developer working in this repository might ask that this code answers.
+
+Rules:
+- Ask about behaviour or purpose, the way someone who has not seen the code would.
+- Do not quote code, and do not use the names of the functions, types or files shown.
+- Do not refer to "this code" or "the snippet"; each question must stand alone.
+- Each question is one sentence of 5 to 25 words.
+
+Reply with a JSON array of strings and nothing else.
+
+%s`
+
+// Generate samples indexed chunks, stratified by language and chunk type,
+// asks the LLM for questions each chunk answers and keeps those that pass
+// the quality filters. Every query targets the chunk it was written from.
+func Generate(ctx context.Context, src ChunkSource, model LLM, opts GenerateOptions) (*GoldenSet, *GenerateStats, error) {
+	opts = opts.withDefaults()
+	stats := &GenerateStats{Strata: make(map[string]int), Rejected: make(map[string]int)}
+
+	candidates, err := sampleChunks(ctx, src, opts, stats)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Info("Sampled chunks for golden set generation", "eligible", stats.ChunksEligible, "sampled", len(candidates))
+
+	set := &GoldenSet{Name: opts.Name}
+	seen := make(map[string]bool)
+	for _, chunk := range candidates {
+		if len(set.Queries) >= opts.Count {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, errors.Wrap(err, errors.ErrorTypeInternal, "generation cancelled")
+		}
+
+		stats.ChunksUsed++
+		reply, err := model.Generate(ctx, []llm.ChatMessage{{Role: "user", Content: questionPrompt(chunk, opts)}})
+		if err != nil {
+			logger.Warn("Question generation failed", "chunk_id", chunk.ID, "error", err)
+			stats.LLMErrors++
+			continue
+		}
+
+		kept := 0
+		for _, q := range parseQuestions(reply) {
+			if kept == opts.QuestionsPerChunk || len(set.Queries) >= opts.Count {
+				break
+			}
+			key := normalizeQuestion(q)
+			reason := rejectReason(q, chunk)
+			if reason == "" && seen[key] {
+				reason = RejectDuplicate
+			}
+			if reason != "" {
+				stats.Rejected[reason]++
+				continue
+			}
+			seen[key] = true
+			kept++
+			set.Queries = append(set.Queries, GoldenQuery{
+				ID:       fmt.Sprintf("syn-%d", len(set.Queries)+1),
+				Query:    q,
+				Expected: []Target{targetFor(chunk, opts.Root)},
+				Source:   "synthetic",
+			})
+			stats.Strata[stratum(chunk)]++
+		}
+	}
+	stats.Generated = len(set.Queries)
+
+	if len(set.Queries) == 0 {
+		return nil, stats, errors.ValidationError("no questions were generated; check that the index is populated and the LLM is reachable")
+	}
+	return set, stats, nil
+}
+
+func (o GenerateOptions) withDefaults() GenerateOptions {
+	if o.Count <= 0 {
+		o.Count = 200
+	}
+	if o.QuestionsPerChunk <= 0 {
+		o.QuestionsPerChunk = 2
+	}
+	if len(o.ChunkTypes) == 0 {
+		o.ChunkTypes = []domain.ChunkType{domain.ChunkTypeFunction, domain.ChunkTypeMethod, domain.ChunkTypeClass}
+	}
+	if o.MinLines <= 0 {
+		o.MinLines = 3
+	}
+	if o.MaxContentChars <= 0 {
+		o.MaxContentChars = 4000
+	}
+	if o.Name == "" {
+		o.Name = "synthetic"
+	}
+	return o
+}
+
+// sampleChunks keeps a uniform sample of each stratum (reservoir sampling,
+// so the index is streamed once) and interleaves the strata round-robin.
+// Small strata are used up first and the rest of the budget falls to the
+// larger ones.
+func sampleChunks(ctx context.Context, src ChunkSource, opts GenerateOptions, stats *GenerateStats) ([]*domain.CodeChunk, error) {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	// Twice the chunks strictly needed, since some yield no usable question
+	capacity := 2 * ((opts.Count + opts.QuestionsPerChunk - 1) / opts.QuestionsPerChunk)
+
+	type reservoir struct {
+		seen   int
+		chunks []*domain.CodeChunk
+	}
+	strata := make(map[string]*reservoir)
+	err := src.ScrollChunks(ctx, func(chunk *domain.CodeChunk) error {
+		stats.ChunksSeen++
+		if !eligible(chunk, opts) {
+			return nil
+		}
+		stats.ChunksEligible++
+
+		r, ok := strata[stratum(chunk)]
+		if !ok {
+			r = &reservoir{}
+			strata[stratum(chunk)] = r
+		}
+		r.seen++
+		if len(r.chunks) < capacity {
+			r.chunks = append(r.chunks, chunk)
+		} else if j := rng.Intn(r.seen); j < capacity {
+			r.chunks[j] = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(strata))
+	for key := range strata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := strata[key]
+		rng.Shuffle(len(r.chunks), func(i, j int) { r.chunks[i], r.chunks[j] = r.chunks[j], r.chunks[i] })
+	}
+
+	var out []*domain.CodeChunk
+	for i := 0; len(out) < stats.ChunksEligible && i < capacity; i++ {
+		for _, key := range keys {
+			if r := strata[key]; i < len(r.chunks) {
+				out = append(out, r.chunks[i])
+			}
+		}
+	}
+	return out, nil
+}
+
+func eligible(chunk *domain.CodeChunk, opts GenerateOptions) bool {
+	if strings.TrimSpace(chunk.Content) == "" || chunk.EndLine-chunk.StartLine+1 < opts.MinLines {
+		return false
+	}
+	if len(opts.Languages) > 0 && !containsFold(opts.Languages, chunk.Language) {
+		return false
+	}
+	for _, t := range opts.ChunkTypes {
+		if chunk.ChunkType == t {
+			return true
+		}
+	}
+	return false
+}
+
+func stratum(chunk *domain.CodeChunk) string {
+	return chunk.Language + "/" + string(chunk.ChunkType)
+}
+
+func questionPrompt(chunk *domain.CodeChunk, opts GenerateOptions) string {
+	content := chunk.Content
+	if len(content) > opts.MaxContentChars {
+		content = content[:opts.MaxContentChars]
+	}
+	// Ask for a few more than are kept, since some will be filtered out
+	return fmt.Sprintf(generatePrompt, chunk.Language, relativePath(chunk.FilePath, opts.Root), opts.QuestionsPerChunk+2, content)
+}
+
+// parseQuestions reads the JSON array the prompt asks for, falling back to
+// one question per line for models that answer with a list
+func parseQuestions(reply string) []string {
+	var questions []string
+	if start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]"); start >= 0 && end > start {
+		if json.Unmarshal([]byte(reply[start:end+1]), &questions) == nil {
+			return trimQuestions(questions)
+		}
+	}
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(listPrefix.ReplaceAllString(line, ""))
+		if strings.HasSuffix(line, "?") {
+			questions = append(questions, strings.Trim(line, `"`))
+		}
+	}
+	return trimQuestions(questions)
+}
+
+func trimQuestions(questions []string) []string {
+	out := questions[:0]
+	for _, q := range questions {
+		if q = strings.TrimSpace(q); q != "" {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// rejectReason returns why q is not a usable golden query for chunk, or ""
+func rejectReason(q string, chunk *domain.CodeChunk) string {
+	words := len(strings.Fields(q))
+	switch {
+	case words < minQuestionWords:
+		return RejectTooShort
+	case words > maxQuestionWords:
+		return RejectTooLong
+	case codePattern.MatchString(q) || strings.Contains(q, "\n"):
+		return RejectContainsCode
+	case deicticPattern.MatchString(q):
+		return RejectDeictic
+	}
+
+	if name := chunk.Metadata["name"]; len(name) >= minSymbolLength && strings.Contains(q, name) {
+		return RejectNamesSymbol
+	}
+	base := filepath.Base(chunk.FilePath)
+	if strings.Contains(strings.ToLower(q), strings.ToLower(base)) {
+		return RejectNamesFile
+	}
+	return ""
+}
+
+func normalizeQuestion(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.TrimRight(q, "?.! "))), " ")
+}
+
+// targetFor identifies chunk by symbol when it has one, since symbols
+// survive edits that shift line numbers, and by line range otherwise
+func targetFor(chunk *domain.CodeChunk, root string) Target {
+	t := Target{FilePath: relativePath(chunk.FilePath, root)}
+	if name := chunk.Metadata["name"]; name != "" {
+		t.Symbol = name
+		if receiver := chunk.Metadata["receiver"]; receiver != "" {
+			t.Symbol = receiver + "." + name
+		}
+		return t
+	}
+	t.StartLine, t.EndLine = chunk.StartLine, chunk.EndLine
+	return t
+}
+
+func relativePath(path, root string) string {
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/llm"
+)
+
+type sliceSource []*domain.CodeChunk
+
+func (s sliceSource) ScrollChunks(ctx context.Context, fn func(*domain.CodeChunk) error) error {
+	for _, c := range s {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeLLM answers with a question built from the file name of the chunk in
+// the prompt, so every question is unique
+type fakeLLM struct {
+	reply func(prompt string) (string, error)
+	calls int
+}
+
+func (f *fakeLLM) Generate(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	f.calls++
+	return f.reply(messages[0].Content)
+}
+
+func uniqueQuestions(prompt string) (string, error) {
+	file := strings.Fields(prompt[strings.Index(prompt, "from the file ")+len("from the file "):])[0]
+	id := strings.NewReplacer("/", " ", ".", " ").Replace(file)
+	return fmt.Sprintf(`Sure! ["How is request %s handled by the server?", "Where do we validate input for %s today?", "What does this function do?"]`, id, id), nil
+}
+
+func chunk(path, lang string, typ domain.ChunkType, name string) *domain.CodeChunk {
+	return &domain.CodeChunk{
+		ID:        path,
+		FilePath:  "/repo/" + path,
+		Language:  lang,
+		ChunkType: typ,
+		Content:   "func body() {\n\treturn\n}",
+		StartLine: 1,
+		EndLine:   10,
+		Metadata:  map[string]string{"name": name},
+	}
+}
+
+func TestGenerate_Stratified(t *testing.T) {
+	var src sliceSource
+	for i := 0; i < 20; i++ {
+		src = append(src, chunk(fmt.Sprintf("go/f%d.go", i), "go", domain.ChunkTypeFunction, ""))
+	}
+	for i := 0; i < 3; i++ {
+		src = append(src, chunk(fmt.Sprintf("py/c%d.py", i), "python", domain.ChunkTypeClass, ""))
+	}
+	src = append(src, chunk("go/imports.go", "go", domain.ChunkTypeImport, ""))
+
+	model := &fakeLLM{reply: uniqueQuestions}
+	set, stats, err := Generate(context.Background(), src, model, GenerateOptions{Count: 8, QuestionsPerChunk: 1, Root: "/repo", Seed: 7})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if len(set.Queries) != 8 || stats.Generated != 8 || model.calls != 8 {
+		t.Fatalf("expected 8 queries from 8 calls, got %d from %d", len(set.Queries), model.calls)
+	}
+	if stats.ChunksSeen != 24 || stats.ChunksEligible != 23 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	// Round-robin: all three python classes are used despite being outnumbered
+	if stats.Strata["python/class"] != 3 || stats.Strata["go/function"] != 5 {
+		t.Errorf("unexpected strata %v", stats.Strata)
+	}
+	if err := set.Validate(); err != nil {
+		t.Errorf("generated set does not validate: %v", err)
+	}
+	q := set.Queries[0]
+	if q.Source != "synthetic" || strings.HasPrefix(q.Expected[0].FilePath, "/") || q.Expected[0].StartLine != 1 {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	again, _, _ := Generate(context.Background(), src, &fakeLLM{reply: uniqueQuestions}, GenerateOptions{Count: 8, QuestionsPerChunk: 1, Root: "/repo", Seed: 7})
+	for i := range set.Queries {
+		if set.Queries[i].Query != again.Queries[i].Query {
+			t.Fatalf("the same seed gave a different sample at %d", i)
+		}
+	}
+}
+
+func TestGenerate_Filters(t *testing.T) {
+	src := sliceSource{chunk("svc/auth.go", "go", domain.ChunkTypeFunction, "RefreshToken")}
+	src[0].Metadata["receiver"] = "Service"
+
+	model := &fakeLLM{reply: func(string) (string, error) {
+		return strings.Join([]string{
+			"1. How are expired sessions renewed for logged in users?",
+			"2. Where is RefreshToken implemented?",
+			"3. What does this function return?",
+			"4. Why?",
+			"5. Which part of auth.go renews sessions?",
+			"6. How do I call `renew()` safely?",
+			"7. How are expired sessions renewed for logged-in users?",
+			"8. How are expired sessions renewed for logged in users",
+		}, "\n"), nil
+	}}
+	set, stats, err := Generate(context.Background(), src, model, GenerateOptions{QuestionsPerChunk: 5})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// Only lines ending in "?" are read from a plain list
+	if len(set.Queries) != 2 {
+		t.Fatalf("expected 2 queries, got %+v", set.Queries)
+	}
+	if got := set.Queries[0].Expected[0]; got.Symbol != "Service.RefreshToken" || got.StartLine != 0 {
+		t.Errorf("unexpected target %+v", got)
+	}
+	want := map[string]int{RejectNamesSymbol: 1, RejectDeictic: 1, RejectTooShort: 1, RejectNamesFile: 1, RejectContainsCode: 1}
+	for reason, n := range want {
+		if stats.Rejected[reason] != n {
+			t.Errorf("Rejected[%s] = %d, want %d (all: %v)", reason, stats.Rejected[reason], n, stats.Rejected)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	src := sliceSource{chunk("a.go", "go", domain.ChunkTypeFunction, "")}
+	model := &fakeLLM{reply: func(string) (string, error) { return "", errors.New("model offline") }}
+
+	_, stats, err := Generate(context.Background(), src, model, GenerateOptions{})
+	if err == nil || stats.LLMErrors != 1 {
+		t.Errorf("expected an error when nothing is generated, got %v (stats %+v)", err, stats)
+	}
+
+	_, _, err = Generate(context.Background(), sliceSource{}, model, GenerateOptions{Languages: []string{"rust"}})
+	if err == nil {
+		t.Error("expected an error for an empty sample")
+	}
+}
@@ -0,0 +1,152 @@
+// Package eval measures retrieval quality against golden sets of queries
+// with known relevant code, so ranking changes can be compared between
+// pipeline configurations and commits.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// GoldenSet is a list of queries with the code that should be retrieved for
+// each of them
+type GoldenSet struct {
+	Name    string        `json:"name,omitempty"`
+	Queries []GoldenQuery `json:"queries"`
+}
+
+// GoldenQuery is one query and its relevant targets
+type GoldenQuery struct {
+	ID       string   `json:"id"`
+	Query    string   `json:"query"`
+	Language string   `json:"language,omitempty"`
+	Expected []Target `json:"expected"`
+	Source   string   `json:"source,omitempty"` // e.g. "manual", "synthetic"
+}
+
+// Target identifies relevant code. FilePath is matched as a path suffix so
+// golden sets can use repository-relative paths; Symbol and the line range
+// narrow the match to a chunk when set.
+type Target struct {
+	FilePath  string `json:"file_path"`
+	Symbol    string `json:"symbol,omitempty"` // function or type name; methods may use Receiver.Name
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Grade     int    `json:"grade,omitempty"` // graded relevance for nDCG; defaults to 1
+}
+
+// LoadGoldenSet reads and validates a golden set file
+func LoadGoldenSet(path string) (*GoldenSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "failed to read golden set")
+	}
+
+	var set GoldenSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "failed to parse golden set")
+	}
+	if set.Name == "" {
+		set.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Save writes the golden set as indented JSON
+func (s *GoldenSet) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal golden set")
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to write golden set")
+	}
+	return nil
+}
+
+// Validate checks that every query has text and at least one target, and
+// assigns IDs to queries that have none
+func (s *GoldenSet) Validate() error {
+	if len(s.Queries) == 0 {
+		return errors.ValidationError("golden set has no queries")
+	}
+	seen := make(map[string]bool, len(s.Queries))
+	for i := range s.Queries {
+		q := &s.Queries[i]
+		if q.ID == "" {
+			q.ID = fmt.Sprintf("q%d", i+1)
+		}
+		if seen[q.ID] {
+			return errors.ValidationError(fmt.Sprintf("duplicate query id %q", q.ID))
+		}
+		seen[q.ID] = true
+		if strings.TrimSpace(q.Query) == "" {
+			return errors.ValidationError(fmt.Sprintf("query %s has no text", q.ID))
+		}
+		if len(q.Expected) == 0 {
+			return errors.ValidationError(fmt.Sprintf("query %s has no expected targets", q.ID))
+		}
+		for _, t := range q.Expected {
+			if t.FilePath == "" {
+				return errors.ValidationError(fmt.Sprintf("query %s has a target without file_path", q.ID))
+			}
+		}
+	}
+	return nil
+}
+
+// Matches reports whether chunk is (part of) the target
+func (t Target) Matches(chunk *domain.CodeChunk) bool {
+	if chunk == nil || !pathMatches(chunk.FilePath, t.FilePath) {
+		return false
+	}
+	if t.Symbol != "" && !symbolMatches(chunk, t.Symbol) {
+		return false
+	}
+	if t.StartLine > 0 || t.EndLine > 0 {
+		end := t.EndLine
+		if end == 0 {
+			end = t.StartLine
+		}
+		if chunk.EndLine < t.StartLine || chunk.StartLine > end {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Target) grade() int {
+	if t.Grade <= 0 {
+		return 1
+	}
+	return t.Grade
+}
+
+// pathMatches compares on path segment boundaries: "retrieval/fusion.go"
+// matches "/src/internal/retrieval/fusion.go" but not ".../myfusion.go"
+func pathMatches(chunkPath, targetPath string) bool {
+	chunkPath = filepath.ToSlash(filepath.Clean(chunkPath))
+	targetPath = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(targetPath)), "./")
+	return chunkPath == targetPath || strings.HasSuffix(chunkPath, "/"+targetPath)
+}
+
+func symbolMatches(chunk *domain.CodeChunk, symbol string) bool {
+	name := chunk.Metadata["name"]
+	if name == "" {
+		return false
+	}
+	if name == symbol {
+		return true
+	}
+	receiver := chunk.Metadata["receiver"]
+	return receiver != "" && receiver+"."+name == symbol
+}
@@ -0,0 +1,101 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+func TestTarget_Matches(t *testing.T) {
+	fn := &domain.CodeChunk{
+		FilePath:  "/src/repo/internal/retrieval/fusion.go",
+		StartLine: 40,
+		EndLine:   60,
+		Metadata:  map[string]string{"name": "FuseResults"},
+	}
+	method := &domain.CodeChunk{
+		FilePath: "/src/repo/internal/reranker/reranker.go",
+		Metadata: map[string]string{"name": "Rerank", "receiver": "MMRReranker"},
+	}
+
+	tests := []struct {
+		name   string
+		target Target
+		chunk  *domain.CodeChunk
+		want   bool
+	}{
+		{"relative path", Target{FilePath: "internal/retrieval/fusion.go"}, fn, true},
+		{"dot-relative path", Target{FilePath: "./retrieval/fusion.go"}, fn, true},
+		{"partial file name", Target{FilePath: "usion.go"}, fn, false},
+		{"other file", Target{FilePath: "retriever.go"}, fn, false},
+		{"symbol", Target{FilePath: "fusion.go", Symbol: "FuseResults"}, fn, true},
+		{"wrong symbol", Target{FilePath: "fusion.go", Symbol: "normalizeScores"}, fn, false},
+		{"method name", Target{FilePath: "reranker.go", Symbol: "Rerank"}, method, true},
+		{"qualified method", Target{FilePath: "reranker.go", Symbol: "MMRReranker.Rerank"}, method, true},
+		{"other receiver", Target{FilePath: "reranker.go", Symbol: "HeuristicReranker.Rerank"}, method, false},
+		{"overlapping lines", Target{FilePath: "fusion.go", StartLine: 55, EndLine: 80}, fn, true},
+		{"single line", Target{FilePath: "fusion.go", StartLine: 60}, fn, true},
+		{"disjoint lines", Target{FilePath: "fusion.go", StartLine: 61, EndLine: 80}, fn, false},
+		{"nil chunk", Target{FilePath: "fusion.go"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.Matches(tt.chunk); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadGoldenSet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "smoke.json")
+	set := &GoldenSet{Queries: []GoldenQuery{
+		{Query: "where is fusion", Expected: []Target{{FilePath: "fusion.go"}}},
+		{ID: "custom", Query: "where is bm25", Expected: []Target{{FilePath: "bm25.go"}}},
+	}}
+	if err := set.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadGoldenSet(path)
+	if err != nil {
+		t.Fatalf("LoadGoldenSet() error = %v", err)
+	}
+	if loaded.Name != "smoke" {
+		t.Errorf("Name = %q, want name derived from the file", loaded.Name)
+	}
+	if loaded.Queries[0].ID != "q1" || loaded.Queries[1].ID != "custom" {
+		t.Errorf("unexpected IDs %q, %q", loaded.Queries[0].ID, loaded.Queries[1].ID)
+	}
+}
+
+func TestLoadGoldenSet_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"empty":        `{"queries": []}`,
+		"no text":      `{"queries": [{"query": " ", "expected": [{"file_path": "a.go"}]}]}`,
+		"no targets":   `{"queries": [{"query": "q"}]}`,
+		"no file path": `{"queries": [{"query": "q", "expected": [{"symbol": "A"}]}]}`,
+		"duplicate id": `{"queries": [{"id": "a", "query": "q", "expected": [{"file_path": "a.go"}]}, {"id": "a", "query": "r", "expected": [{"file_path": "a.go"}]}]}`,
+		"bad json":     `{`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "set.json")
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadGoldenSet(path); !errors.Is(err, errors.ErrorTypeValidation) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	if _, err := LoadGoldenSet(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+// Cutoff holds the metrics computed over the top K results
+type Cutoff struct {
+	K      int     `json:"k"`
+	Recall float64 `json:"recall"`
+	NDCG   float64 `json:"ndcg"`
+}
+
+// LatencyStats summarises per-query retrieval latency
+type LatencyStats struct {
+	MeanMS float64 `json:"mean_ms"`
+	P50MS  float64 `json:"p50_ms"`
+	P95MS  float64 `json:"p95_ms"`
+	MaxMS  float64 `json:"max_ms"`
+}
+
+// judgement is how the results of one query score against its targets
+type judgement struct {
+	gains   []int // grade earned at each position
+	foundAt []int // per target: 1-based position of its first match, 0 if never
+	grades  []int // per target grade
+}
+
+// judge credits each of the first n results with the grade of the best
+// target it matches that no earlier result matched, so a target counts once
+// however many of its chunks are retrieved
+func judge(results []*domain.SearchResult, targets []Target, n int) judgement {
+	j := judgement{
+		foundAt: make([]int, len(targets)),
+		grades:  make([]int, len(targets)),
+	}
+	for i, t := range targets {
+		j.grades[i] = t.grade()
+	}
+	if n > len(results) {
+		n = len(results)
+	}
+	j.gains = make([]int, n)
+
+	for pos, res := range results[:n] {
+		best := -1
+		for i, t := range targets {
+			if j.foundAt[i] != 0 || !t.Matches(res.Chunk) {
+				continue
+			}
+			if best == -1 || j.grades[i] > j.grades[best] {
+				best = i
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		j.gains[pos] = j.grades[best]
+		// Other targets this result covers are found too, but earn no gain
+		for i, t := range targets {
+			if j.foundAt[i] == 0 && t.Matches(res.Chunk) {
+				j.foundAt[i] = pos + 1
+			}
+		}
+	}
+	return j
+}
+
+// recall is the fraction of targets matched in the top k
+func (j judgement) recall(k int) float64 {
+	if len(j.foundAt) == 0 {
+		return 0
+	}
+	found := 0
+	for _, pos := range j.foundAt {
+		if pos > 0 && pos <= k {
+			found++
+		}
+	}
+	return float64(found) / float64(len(j.foundAt))
+}
+
+// ndcg is the normalised discounted cumulative gain of the top k
+func (j judgement) ndcg(k int) float64 {
+	dcg := 0.0
+	for pos, gain := range j.gains {
+		if pos >= k {
+			break
+		}
+		dcg += float64(gain) / math.Log2(float64(pos+2))
+	}
+
+	ideal := append([]int(nil), j.grades...)
+	sort.Sort(sort.Reverse(sort.IntSlice(ideal)))
+	idcg := 0.0
+	for pos, grade := range ideal {
+		if pos >= k {
+			break
+		}
+		idcg += float64(grade) / math.Log2(float64(pos+2))
+	}
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+// firstHit is the 1-based position of the first relevant result, or 0
+func (j judgement) firstHit() int {
+	for pos, gain := range j.gains {
+		if gain > 0 {
+			return pos + 1
+		}
+	}
+	return 0
+}
+
+// latencyStats computes mean and nearest-rank percentiles
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	return LatencyStats{
+		MeanMS: round(ms(total) / float64(len(sorted))),
+		P50MS:  round(ms(percentile(sorted, 0.50))),
+		P95MS:  round(ms(percentile(sorted, 0.95))),
+		MaxMS:  round(ms(sorted[len(sorted)-1])),
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// round keeps reports stable to diff: four decimals is well below the
+// differences worth looking at
+func round(f float64) float64 {
+	return math.Round(f*1e4) / 1e4
+}
@@ -0,0 +1,89 @@
+package eval
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+func result(path string) *domain.SearchResult {
+	return &domain.SearchResult{Chunk: &domain.CodeChunk{FilePath: path}}
+}
+
+func TestJudge(t *testing.T) {
+	targets := []Target{
+		{FilePath: "a.go", Grade: 2},
+		{FilePath: "b.go"},
+		{FilePath: "c.go"},
+	}
+	// a.go twice: the second hit earns nothing
+	results := []*domain.SearchResult{result("x.go"), result("a.go"), result("a.go"), result("b.go")}
+
+	j := judge(results, targets, 10)
+	if got := j.firstHit(); got != 2 {
+		t.Errorf("firstHit() = %d, want 2", got)
+	}
+	if want := []int{0, 2, 0, 1}; !equalInts(j.gains, want) {
+		t.Errorf("gains = %v, want %v", j.gains, want)
+	}
+	if got := j.recall(1); got != 0 {
+		t.Errorf("recall(1) = %v, want 0", got)
+	}
+	if got := j.recall(4); math.Abs(got-2.0/3) > 1e-9 {
+		t.Errorf("recall(4) = %v, want 2/3", got)
+	}
+
+	// DCG = 2/log2(3) + 1/log2(5); IDCG = 2/log2(2) + 1/log2(3) + 1/log2(4)
+	dcg := 2/math.Log2(3) + 1/math.Log2(5)
+	idcg := 2.0 + 1/math.Log2(3) + 0.5
+	if got := j.ndcg(10); math.Abs(got-dcg/idcg) > 1e-9 {
+		t.Errorf("ndcg(10) = %v, want %v", got, dcg/idcg)
+	}
+
+	perfect := judge([]*domain.SearchResult{result("a.go"), result("b.go"), result("c.go")}, targets, 3)
+	if got := perfect.ndcg(3); math.Abs(got-1) > 1e-9 {
+		t.Errorf("perfect ndcg(3) = %v, want 1", got)
+	}
+}
+
+func TestJudge_Cutoff(t *testing.T) {
+	targets := []Target{{FilePath: "a.go"}}
+	j := judge([]*domain.SearchResult{result("x.go"), result("a.go")}, targets, 1)
+	if j.firstHit() != 0 || j.recall(1) != 0 || j.ndcg(1) != 0 {
+		t.Errorf("results beyond the cutoff should not count: %+v", j)
+	}
+
+	empty := judge(nil, targets, 5)
+	if empty.firstHit() != 0 || empty.recall(5) != 0 || empty.ndcg(5) != 0 {
+		t.Errorf("no results should score zero: %+v", empty)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 20; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := latencyStats(latencies)
+	if stats.MeanMS != 10.5 || stats.P50MS != 10 || stats.P95MS != 19 || stats.MaxMS != 20 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if got := latencyStats(nil); got != (LatencyStats{}) {
+		t.Errorf("empty stats = %+v", got)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
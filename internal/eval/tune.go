@@ -0,0 +1,278 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/reranker"
+)
+
+// TuneOptions controls heuristic reranker tuning
+type TuneOptions struct {
+	K          int                      // nDCG cutoff to maximise (default 10)
+	Candidates int                      // results retrieved per query for reranking (default 50)
+	Rounds     int                      // coordinate ascent rounds at most (default 10)
+	Holdout    float64                  // fraction of queries kept out of fitting to check for overfitting (default 0)
+	Initial    reranker.HeuristicConfig // starting weights (default DefaultHeuristicConfig)
+}
+
+// TuneResult is the outcome of a tuning run
+type TuneResult struct {
+	Config         reranker.HeuristicConfig `json:"-"`
+	K              int                      `json:"k"`
+	TrainQueries   int                      `json:"train_queries"`
+	HoldoutQueries int                      `json:"holdout_queries"`
+	Errors         int                      `json:"errors"` // queries whose retrieval failed and were left out
+	Rounds         int                      `json:"rounds"`
+	Before         TuneScore                `json:"before"`
+	After          TuneScore                `json:"after"`
+	Steps          []TuneStep               `json:"steps"`
+}
+
+// TuneScore is the mean nDCG@K of the train and holdout queries
+type TuneScore struct {
+	Train   float64 `json:"train"`
+	Holdout float64 `json:"holdout,omitempty"`
+}
+
+// TuneStep is one accepted weight change
+type TuneStep struct {
+	Round int     `json:"round"`
+	Param string  `json:"param"`
+	From  float32 `json:"from"`
+	To    float32 `json:"to"`
+	NDCG  float64 `json:"ndcg"`
+}
+
+// tuneParam is one weight coordinate ascent moves, with the range it may take
+type tuneParam struct {
+	name     string
+	min, max float32
+	get      func(*reranker.HeuristicConfig) float32
+	set      func(*reranker.HeuristicConfig, float32)
+}
+
+func typeWeightParam(t domain.ChunkType) tuneParam {
+	return tuneParam{
+		name: "type_weight." + string(t), min: 0.1, max: 3,
+		get: func(c *reranker.HeuristicConfig) float32 { return c.TypeWeights[t] },
+		set: func(c *reranker.HeuristicConfig, v float32) { c.TypeWeights[t] = v },
+	}
+}
+
+var tuneParams = []tuneParam{
+	typeWeightParam(domain.ChunkTypeFunction),
+	typeWeightParam(domain.ChunkTypeMethod),
+	typeWeightParam(domain.ChunkTypeClass),
+	typeWeightParam(domain.ChunkTypeImport),
+	typeWeightParam(domain.ChunkTypeComment),
+	typeWeightParam(domain.ChunkTypeOther),
+	{
+		name: "exact_match", min: 1, max: 5,
+		get: func(c *reranker.HeuristicConfig) float32 { return c.ExactMatch },
+		set: func(c *reranker.HeuristicConfig, v float32) { c.ExactMatch = v },
+	},
+	{
+		name: "token_match", min: 0.01, max: 2,
+		get: func(c *reranker.HeuristicConfig) float32 { return c.TokenMatch },
+		set: func(c *reranker.HeuristicConfig, v float32) { c.TokenMatch = v },
+	},
+	{
+		name: "path_match", min: 1, max: 3,
+		get: func(c *reranker.HeuristicConfig) float32 { return c.PathMatch },
+		set: func(c *reranker.HeuristicConfig, v float32) { c.PathMatch = v },
+	},
+	{
+		name: "priority_path", min: 1, max: 3,
+		get: func(c *reranker.HeuristicConfig) float32 { return c.PriorityPath },
+		set: func(c *reranker.HeuristicConfig, v float32) { c.PriorityPath = v },
+	},
+	{
+		name: "recency", min: 0.01, max: 2,
+		get: func(c *reranker.HeuristicConfig) float32 { return c.Recency },
+		set: func(c *reranker.HeuristicConfig, v float32) { c.Recency = v },
+	},
+}
+
+// minStepFraction stops the search once steps are this small a part of a
+// parameter's range
+const minStepFraction = 1.0 / 64
+
+// tuneCase is a golden query with its candidates retrieved once, before
+// reranking, so each weight setting only has to rerank them
+type tuneCase struct {
+	query      GoldenQuery
+	candidates []*domain.SearchResult
+}
+
+// Tune fits the heuristic reranker weights to a golden set by coordinate
+// ascent on mean nDCG@K. Candidates are retrieved once with reranking,
+// hierarchy and expansion off; each step moves one weight up or down and
+// keeps the move if nDCG improves, and the step size halves after a round
+// without improvement.
+func Tune(ctx context.Context, r Retriever, set *GoldenSet, opts TuneOptions) (*TuneResult, error) {
+	if opts.K <= 0 {
+		opts.K = 10
+	}
+	if opts.Candidates <= 0 {
+		opts.Candidates = 50
+	}
+	if opts.Candidates < opts.K {
+		opts.Candidates = opts.K
+	}
+	if opts.Rounds <= 0 {
+		opts.Rounds = 10
+	}
+	if opts.Holdout < 0 || opts.Holdout >= 1 {
+		return nil, errors.ValidationError("holdout must be in [0, 1)")
+	}
+
+	result := &TuneResult{K: opts.K}
+	train, holdout, err := collectCases(ctx, r, set, opts, result)
+	if err != nil {
+		return nil, err
+	}
+	result.TrainQueries, result.HoldoutQueries = len(train), len(holdout)
+
+	current := opts.Initial
+	if current.TypeWeights == nil {
+		current = reranker.DefaultHeuristicConfig()
+	}
+	current = copyConfig(current)
+
+	best, err := meanNDCG(ctx, current, train, opts.K)
+	if err != nil {
+		return nil, err
+	}
+	result.Before = TuneScore{Train: round(best)}
+	if len(holdout) > 0 {
+		h, err := meanNDCG(ctx, current, holdout, opts.K)
+		if err != nil {
+			return nil, err
+		}
+		result.Before.Holdout = round(h)
+	}
+	logger.Info("Tuning reranker weights", "train", len(train), "holdout", len(holdout), "ndcg", best)
+
+	step := float32(0.25) // fraction of each parameter's range
+	for rnd := 1; rnd <= opts.Rounds && step >= minStepFraction; rnd++ {
+		result.Rounds = rnd
+		improved := false
+		for _, p := range tuneParams {
+			from := p.get(&current)
+			delta := step * (p.max - p.min)
+			for _, to := range []float32{from + delta, from - delta} {
+				to = float32(math.Round(float64(min(max(to, p.min), p.max))*1000) / 1000)
+				if to == from {
+					continue
+				}
+				candidate := copyConfig(current)
+				p.set(&candidate, to)
+				score, err := meanNDCG(ctx, candidate, train, opts.K)
+				if err != nil {
+					return nil, err
+				}
+				if score > best+1e-9 {
+					best, current, improved = score, candidate, true
+					result.Steps = append(result.Steps, TuneStep{Round: rnd, Param: p.name, From: from, To: to, NDCG: round(score)})
+					logger.Debug("Accepted weight change", "param", p.name, "from", from, "to", to, "ndcg", score)
+					break
+				}
+			}
+		}
+		if !improved {
+			step /= 2
+		}
+	}
+
+	result.Config = current
+	result.After = TuneScore{Train: round(best)}
+	if len(holdout) > 0 {
+		h, err := meanNDCG(ctx, current, holdout, opts.K)
+		if err != nil {
+			return nil, err
+		}
+		result.After.Holdout = round(h)
+	}
+	return result, nil
+}
+
+// collectCases retrieves the candidates of every golden query, putting every
+// n-th query in the holdout set when a holdout fraction is given
+func collectCases(ctx context.Context, r Retriever, set *GoldenSet, opts TuneOptions, result *TuneResult) (train, holdout []tuneCase, err error) {
+	every := 0
+	if opts.Holdout > 0 {
+		every = max(2, int(math.Round(1/opts.Holdout)))
+	}
+	for i, gq := range set.Queries {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, errors.Wrap(err, errors.ErrorTypeInternal, "tuning cancelled")
+		}
+		results, err := r.Retrieve(ctx, domain.SearchQuery{
+			Query:         gq.Query,
+			Language:      gq.Language,
+			MaxResults:    opts.Candidates,
+			SkipRerank:    true,
+			SkipHierarchy: true,
+			SkipExpansion: true,
+		})
+		if err != nil {
+			logger.Warn("Tuning query failed", "id", gq.ID, "error", err)
+			result.Errors++
+			continue
+		}
+		// The reranker reads Score, which is not sent over HTTP
+		for _, res := range results {
+			res.Score = res.FusionScore
+		}
+
+		c := tuneCase{query: gq, candidates: results}
+		if every > 0 && i%every == every-1 {
+			holdout = append(holdout, c)
+		} else {
+			train = append(train, c)
+		}
+	}
+	if len(train) == 0 {
+		return nil, nil, errors.ValidationError("no golden queries could be retrieved for tuning")
+	}
+	return train, holdout, nil
+}
+
+// meanNDCG reranks every case with cfg and averages nDCG@k
+func meanNDCG(ctx context.Context, cfg reranker.HeuristicConfig, cases []tuneCase, k int) (float64, error) {
+	rr := reranker.NewHeuristicRerankerWithConfig(cfg)
+	total := 0.0
+	for _, c := range cases {
+		results := append([]*domain.SearchResult(nil), c.candidates...)
+		ranked, err := rr.Rerank(ctx, c.query.Query, results)
+		if err != nil {
+			return 0, err
+		}
+		total += judge(ranked, c.query.Expected, k).ndcg(k)
+	}
+	return total / float64(len(cases)), nil
+}
+
+func copyConfig(c reranker.HeuristicConfig) reranker.HeuristicConfig {
+	weights := make(map[domain.ChunkType]float32, len(c.TypeWeights))
+	for t, w := range c.TypeWeights {
+		weights[t] = w
+	}
+	c.TypeWeights = weights
+	c.PriorityPaths = append([]string(nil), c.PriorityPaths...)
+	return c
+}
+
+// String summarises the result in one line
+func (r *TuneResult) String() string {
+	s := fmt.Sprintf("nDCG@%d on %d queries: %.4f -> %.4f", r.K, r.TrainQueries, r.Before.Train, r.After.Train)
+	if r.HoldoutQueries > 0 {
+		s += fmt.Sprintf("; holdout (%d queries): %.4f -> %.4f", r.HoldoutQueries, r.Before.Holdout, r.After.Holdout)
+	}
+	return s
+}
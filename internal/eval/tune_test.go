@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/reranker"
+)
+
+// candidateRetriever returns, for every query, a comment chunk fused above
+// the function chunk the golden set expects
+type candidateRetriever struct {
+	queries []domain.SearchQuery
+}
+
+func (c *candidateRetriever) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
+	c.queries = append(c.queries, query)
+	return []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "c", FilePath: "/nonexistent/" + query.Query + "_doc.go", ChunkType: domain.ChunkTypeComment}, FusionScore: 1},
+		{Chunk: &domain.CodeChunk{ID: "f", FilePath: "/nonexistent/" + query.Query + ".go", ChunkType: domain.ChunkTypeFunction}, FusionScore: 0.3},
+	}, nil
+}
+
+func tuneSet(n int) *GoldenSet {
+	set := &GoldenSet{Name: "tune"}
+	for i := 0; i < n; i++ {
+		q := fmt.Sprintf("q%d", i)
+		set.Queries = append(set.Queries, GoldenQuery{ID: q, Query: q, Expected: []Target{{FilePath: q + ".go"}}})
+	}
+	return set
+}
+
+func TestTune(t *testing.T) {
+	r := &candidateRetriever{}
+	result, err := Tune(context.Background(), r, tuneSet(10), TuneOptions{K: 1, Holdout: 0.2})
+	if err != nil {
+		t.Fatalf("Tune() error = %v", err)
+	}
+
+	if q := r.queries[0]; !q.SkipRerank || !q.SkipHierarchy || !q.SkipExpansion || q.MaxResults != 50 {
+		t.Errorf("candidates should be retrieved before reranking: %+v", q)
+	}
+	if result.TrainQueries != 8 || result.HoldoutQueries != 2 {
+		t.Errorf("split = %d/%d, want 8/2", result.TrainQueries, result.HoldoutQueries)
+	}
+	// 1.2 * 0.3 < 0.5 * 1: the comment wins with the default weights
+	if result.Before.Train != 0 || result.After.Train != 1 || result.After.Holdout != 1 {
+		t.Errorf("scores before %+v, after %+v", result.Before, result.After)
+	}
+	if len(result.Steps) == 0 {
+		t.Fatal("expected accepted steps")
+	}
+
+	cfg := result.Config
+	if cfg.TypeWeights[domain.ChunkTypeFunction]*0.3 <= cfg.TypeWeights[domain.ChunkTypeComment] {
+		t.Errorf("tuned weights still rank the comment first: %v", cfg.TypeWeights)
+	}
+	if def := reranker.DefaultHeuristicConfig(); def.TypeWeights[domain.ChunkTypeComment] != 0.5 {
+		t.Error("tuning must not modify the default config")
+	}
+}
+
+func TestTune_Invalid(t *testing.T) {
+	if _, err := Tune(context.Background(), &candidateRetriever{}, tuneSet(2), TuneOptions{Holdout: 1}); err == nil {
+		t.Error("expected error for holdout 1")
+	}
+	if _, err := Tune(context.Background(), testRetriever(), &GoldenSet{Queries: []GoldenQuery{{Query: "unknown"}}}, TuneOptions{}); err == nil {
+		t.Error("expected error when no query can be retrieved")
+	}
+}
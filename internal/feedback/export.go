@@ -0,0 +1,90 @@
+package feedback
+
+import (
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/eval"
+)
+
+// Relevance grades given to rated results in exported golden sets
+const (
+	gradeUp     = 1
+	gradeAnswer = 2
+)
+
+// GoldenSet turns feedback into a golden set. Each query with at least one
+// result rated up or marked as the answer becomes a golden query targeting
+// those results; results rated down, and feedback without result ratings,
+// carry no relevance label and are left out. Repeated queries are merged.
+func GoldenSet(name string, entries []*domain.Feedback) *eval.GoldenSet {
+	set := &eval.GoldenSet{Name: name, Queries: []eval.GoldenQuery{}}
+	byQuery := make(map[string]int) // normalised query text -> index in set.Queries
+
+	for _, fb := range entries {
+		if fb.Trace == nil {
+			continue
+		}
+		shown := make(map[string]domain.TraceResult, len(fb.Trace.Results))
+		for _, r := range fb.Trace.Results {
+			shown[r.ChunkID] = r
+		}
+
+		var targets []eval.Target
+		for _, rf := range fb.Results {
+			grade := 0
+			switch rf.Rating {
+			case domain.RatingUp:
+				grade = gradeUp
+			case domain.RatingAnswer:
+				grade = gradeAnswer
+			}
+			r, ok := shown[rf.ChunkID]
+			if grade == 0 || !ok {
+				continue
+			}
+			t := eval.Target{FilePath: r.FilePath, Symbol: r.Symbol, Grade: grade}
+			if t.Symbol == "" {
+				t.StartLine, t.EndLine = r.StartLine, r.EndLine
+			}
+			targets = append(targets, t)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		key := strings.Join(strings.Fields(strings.ToLower(fb.Trace.Query.Query)), " ")
+		if i, ok := byQuery[key]; ok {
+			set.Queries[i].Expected = mergeTargets(set.Queries[i].Expected, targets)
+			continue
+		}
+		byQuery[key] = len(set.Queries)
+		set.Queries = append(set.Queries, eval.GoldenQuery{
+			ID:       "fb-" + strings.TrimPrefix(fb.QueryID, "q-"),
+			Query:    fb.Trace.Query.Query,
+			Language: fb.Trace.Query.Language,
+			Expected: mergeTargets(nil, targets),
+			Source:   "feedback",
+		})
+	}
+	return set
+}
+
+// mergeTargets adds targets not already in existing, keeping the higher
+// grade for a target rated twice
+func mergeTargets(existing, targets []eval.Target) []eval.Target {
+	for _, t := range targets {
+		dup := false
+		for i, e := range existing {
+			if e.FilePath == t.FilePath && e.Symbol == t.Symbol && e.StartLine == t.StartLine && e.EndLine == t.EndLine {
+				existing[i].Grade = max(e.Grade, t.Grade)
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			existing = append(existing, t)
+		}
+	}
+	return existing
+}
@@ -0,0 +1,49 @@
+package feedback
+
+import (
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+func feedbackFor(queryID, query string, ratings ...domain.ResultFeedback) *domain.Feedback {
+	trace := NewTrace(queryID, "query", domain.SearchQuery{Query: query, Language: "go"}, testResults())
+	return &domain.Feedback{
+		FeedbackRequest: domain.FeedbackRequest{QueryID: queryID, Results: ratings},
+		Trace:           trace,
+	}
+}
+
+func TestGoldenSet(t *testing.T) {
+	entries := []*domain.Feedback{
+		feedbackFor("q-1", "How are sessions renewed?",
+			domain.ResultFeedback{ChunkID: "c1", Rating: domain.RatingUp},
+			domain.ResultFeedback{ChunkID: "c2", Rating: domain.RatingDown}),
+		// Same question again: c1 is now the answer and c2 was helpful
+		feedbackFor("q-2", "how are  sessions renewed?",
+			domain.ResultFeedback{ChunkID: "c1", Rating: domain.RatingAnswer},
+			domain.ResultFeedback{ChunkID: "c2", Rating: domain.RatingUp}),
+		// Only negative ratings: no label
+		feedbackFor("q-3", "Where is logging configured?",
+			domain.ResultFeedback{ChunkID: "c1", Rating: domain.RatingDown}),
+		{FeedbackRequest: domain.FeedbackRequest{QueryID: "q-4", Note: "no trace"}},
+	}
+
+	set := GoldenSet("feedback", entries)
+	if len(set.Queries) != 1 {
+		t.Fatalf("expected 1 golden query, got %+v", set.Queries)
+	}
+	q := set.Queries[0]
+	if q.ID != "fb-1" || q.Source != "feedback" || q.Language != "go" || len(q.Expected) != 2 {
+		t.Fatalf("unexpected query %+v", q)
+	}
+	if got := q.Expected[0]; got.Symbol != "Session.Refresh" || got.Grade != gradeAnswer || got.StartLine != 0 {
+		t.Errorf("unexpected method target %+v", got)
+	}
+	if got := q.Expected[1]; got.Symbol != "" || got.StartLine != 1 || got.EndLine != 5 || got.Grade != gradeUp {
+		t.Errorf("unexpected line-range target %+v", got)
+	}
+	if err := set.Validate(); err != nil {
+		t.Errorf("exported set does not validate: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+// Package feedback records what each query returned and what users said
+// about it, and turns that feedback into eval golden sets.
+package feedback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps query traces and feedback in Redis. Traces expire after their
+// TTL unless feedback is given; feedback keeps a copy of its trace and does
+// not expire.
+type Store struct {
+	client    *redis.Client
+	keyPrefix string
+	traceTTL  time.Duration
+}
+
+// NewStore creates a feedback store. traceTTL bounds how long after a query
+// feedback can still be given (default 7 days).
+func NewStore(client *redis.Client, keyPrefix string, traceTTL time.Duration) *Store {
+	if traceTTL <= 0 {
+		traceTTL = 7 * 24 * time.Hour
+	}
+	return &Store{client: client, keyPrefix: keyPrefix, traceTTL: traceTTL}
+}
+
+// NewTrace builds the trace of the query or search with the given ID
+func NewTrace(id, kind string, query domain.SearchQuery, results []*domain.SearchResult) *domain.QueryTrace {
+	trace := &domain.QueryTrace{
+		ID:        id,
+		Kind:      kind,
+		Query:     query,
+		Results:   make([]domain.TraceResult, 0, len(results)),
+		CreatedAt: time.Now(),
+	}
+	for _, res := range results {
+		if res.Chunk == nil {
+			continue
+		}
+		trace.Results = append(trace.Results, domain.TraceResult{
+			ChunkID:        res.Chunk.ID,
+			FilePath:       res.Chunk.FilePath,
+			Symbol:         symbolOf(res.Chunk),
+			ChunkType:      res.Chunk.ChunkType,
+			Language:       res.Chunk.Language,
+			StartLine:      res.Chunk.StartLine,
+			EndLine:        res.Chunk.EndLine,
+			Rank:           res.Rank,
+			Source:         res.Source,
+			VectorScore:    res.VectorScore,
+			KeywordScore:   res.KeywordScore,
+			FusionScore:    res.FusionScore,
+			RerankScore:    res.RerankScore,
+			RelevanceScore: res.RelevanceScore,
+		})
+	}
+	return trace
+}
+
+// SaveTrace stores a trace until it expires
+func (s *Store) SaveTrace(ctx context.Context, trace *domain.QueryTrace) error {
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal query trace")
+	}
+	if err := s.client.Set(ctx, s.traceKey(trace.ID), data, s.traceTTL).Err(); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to store query trace")
+	}
+	return nil
+}
+
+// Trace returns a stored trace
+func (s *Store) Trace(ctx context.Context, id string) (*domain.QueryTrace, error) {
+	data, err := s.client.Get(ctx, s.traceKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, errors.NotFoundError(fmt.Sprintf("query %s not found or expired", id))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to load query trace")
+	}
+	var trace domain.QueryTrace
+	if err := json.Unmarshal(data, &trace); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to parse query trace")
+	}
+	return &trace, nil
+}
+
+// Submit validates feedback against the trace of its query and stores it.
+// Feedback given again for the same query replaces the earlier feedback.
+func (s *Store) Submit(ctx context.Context, req domain.FeedbackRequest) (*domain.Feedback, error) {
+	if req.Helpful == nil && len(req.Results) == 0 && req.Note == "" {
+		return nil, errors.ValidationError("feedback needs a rating, result ratings or a note")
+	}
+	trace, err := s.Trace(ctx, req.QueryID)
+	if err != nil {
+		return nil, err
+	}
+
+	shown := make(map[string]bool, len(trace.Results))
+	for _, r := range trace.Results {
+		shown[r.ChunkID] = true
+	}
+	for _, r := range req.Results {
+		switch r.Rating {
+		case domain.RatingUp, domain.RatingDown, domain.RatingAnswer:
+		default:
+			return nil, errors.ValidationError(fmt.Sprintf("invalid rating %q (want up, down or answer)", r.Rating))
+		}
+		if !shown[r.ChunkID] {
+			return nil, errors.ValidationError(fmt.Sprintf("chunk %s was not a result of query %s", r.ChunkID, req.QueryID))
+		}
+	}
+
+	fb := &domain.Feedback{FeedbackRequest: req, Trace: trace, CreatedAt: time.Now()}
+	data, err := json.Marshal(fb)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal feedback")
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.feedbackKey(req.QueryID), data, 0)
+	pipe.ZAdd(ctx, s.feedbackIndexKey(), redis.Z{Score: float64(fb.CreatedAt.Unix()), Member: req.QueryID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to store feedback")
+	}
+
+	logger.Info("Recorded feedback", "query_id", req.QueryID, "ratings", len(req.Results))
+	return fb, nil
+}
+
+// List returns all stored feedback, oldest first
+func (s *Store) List(ctx context.Context) ([]*domain.Feedback, error) {
+	ids, err := s.client.ZRange(ctx, s.feedbackIndexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to list feedback")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.feedbackKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to load feedback")
+	}
+
+	entries := make([]*domain.Feedback, 0, len(values))
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var fb domain.Feedback
+		if err := json.Unmarshal([]byte(data), &fb); err != nil {
+			logger.Warn("Skipping unreadable feedback", "query_id", ids[i], "error", err)
+			continue
+		}
+		entries = append(entries, &fb)
+	}
+	return entries, nil
+}
+
+func (s *Store) traceKey(id string) string    { return s.keyPrefix + "trace:" + id }
+func (s *Store) feedbackKey(id string) string { return s.keyPrefix + "feedback:" + id }
+func (s *Store) feedbackIndexKey() string     { return s.keyPrefix + "feedback" }
+
+func symbolOf(chunk *domain.CodeChunk) string {
+	name := chunk.Metadata["name"]
+	if receiver := chunk.Metadata["receiver"]; name != "" && receiver != "" {
+		return receiver + "." + name
+	}
+	return name
+}
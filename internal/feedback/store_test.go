@@ -0,0 +1,120 @@
+package feedback
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	logger.Init(logger.Config{Level: logger.LevelDebug})
+}
+
+func setupTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewStore(client, "test:", time.Hour), mr
+}
+
+func testResults() []*domain.SearchResult {
+	return []*domain.SearchResult{
+		{
+			Chunk: &domain.CodeChunk{ID: "c1", FilePath: "/repo/auth/session.go", ChunkType: domain.ChunkTypeMethod, StartLine: 10, EndLine: 30,
+				Metadata: map[string]string{"name": "Refresh", "receiver": "Session"}},
+			Rank: 1, FusionScore: 0.03, RerankScore: 0.05, RelevanceScore: 0.05,
+		},
+		{
+			Chunk: &domain.CodeChunk{ID: "c2", FilePath: "/repo/auth/doc.go", ChunkType: domain.ChunkTypeComment, StartLine: 1, EndLine: 5},
+			Rank:  2, RelevanceScore: 0.02,
+		},
+		{Chunk: nil},
+	}
+}
+
+func TestNewTrace(t *testing.T) {
+	trace := NewTrace("q-1", "search", domain.SearchQuery{Query: "renew sessions"}, testResults())
+	if trace.ID != "q-1" || trace.Kind != "search" || len(trace.Results) != 2 {
+		t.Fatalf("unexpected trace %+v", trace)
+	}
+	if r := trace.Results[0]; r.Symbol != "Session.Refresh" || r.RerankScore != 0.05 || r.Rank != 1 {
+		t.Errorf("unexpected trace result %+v", r)
+	}
+}
+
+func TestStore_SubmitAndList(t *testing.T) {
+	store, mr := setupTestStore(t)
+	ctx := context.Background()
+
+	trace := NewTrace("q-1", "query", domain.SearchQuery{Query: "renew sessions"}, testResults())
+	if err := store.SaveTrace(ctx, trace); err != nil {
+		t.Fatalf("SaveTrace() error = %v", err)
+	}
+	if ttl := mr.TTL("test:trace:" + trace.ID); ttl != time.Hour {
+		t.Errorf("trace TTL = %v, want 1h", ttl)
+	}
+
+	helpful := true
+	fb, err := store.Submit(ctx, domain.FeedbackRequest{
+		QueryID: trace.ID,
+		Helpful: &helpful,
+		Results: []domain.ResultFeedback{{ChunkID: "c1", Rating: domain.RatingAnswer}, {ChunkID: "c2", Rating: domain.RatingDown}},
+		Note:    "the doc comment is stale",
+	})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if fb.Trace == nil || fb.Trace.ID != trace.ID {
+		t.Errorf("feedback should carry its trace: %+v", fb)
+	}
+
+	// Feedback outlives the trace
+	mr.FastForward(2 * time.Hour)
+	entries, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Note != "the doc comment is stale" || len(entries[0].Trace.Results) != 2 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if _, err := store.Submit(ctx, domain.FeedbackRequest{QueryID: trace.ID, Note: "late"}); !errors.Is(err, errors.ErrorTypeNotFound) {
+		t.Errorf("expected not found for an expired trace, got %v", err)
+	}
+}
+
+func TestStore_SubmitInvalid(t *testing.T) {
+	store, _ := setupTestStore(t)
+	ctx := context.Background()
+	trace := NewTrace("q-2", "query", domain.SearchQuery{Query: "q"}, testResults())
+	_ = store.SaveTrace(ctx, trace)
+
+	tests := map[string]domain.FeedbackRequest{
+		"empty":          {QueryID: trace.ID},
+		"unknown rating": {QueryID: trace.ID, Results: []domain.ResultFeedback{{ChunkID: "c1", Rating: "meh"}}},
+		"unshown chunk":  {QueryID: trace.ID, Results: []domain.ResultFeedback{{ChunkID: "c9", Rating: domain.RatingUp}}},
+	}
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Submit(ctx, req); !errors.Is(err, errors.ErrorTypeValidation) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	if _, err := store.Submit(ctx, domain.FeedbackRequest{QueryID: "q-missing", Note: "x"}); !errors.Is(err, errors.ErrorTypeNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if entries, _ := store.List(ctx); len(entries) != 0 {
+		t.Errorf("invalid feedback should not be stored: %+v", entries)
+	}
+}
@@ -32,11 +32,12 @@ func (b *Builder) Build(ctx context.Context, chunks []*domain.CodeChunk) *Graph
 	// First pass: Add all nodes
 	for _, chunk := range chunks {
 		node := &Node{
-			ID:       chunk.ID,
-			Type:     string(chunk.ChunkType),
-			Name:     chunk.Metadata["name"],
-			FilePath: chunk.FilePath,
-			Metadata: chunk.Metadata,
+			ID:         chunk.ID,
+			Type:       string(chunk.ChunkType),
+			Name:       chunk.Metadata["name"],
+			FilePath:   chunk.FilePath,
+			Metadata:   chunk.Metadata,
+			Generation: chunk.Generation,
 		}
 		b.graph.AddNode(node)
 	}
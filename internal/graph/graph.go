@@ -2,6 +2,8 @@ package graph
 
 import (
 	"sync"
+
+	"github.com/Guru2308/rag-code/internal/domain"
 )
 
 // RelationType represents the type of relationship between nodes
@@ -15,11 +17,12 @@ const (
 
 // Node represents a code entity in the graph
 type Node struct {
-	ID       string            // Unique identifier (chunk ID)
-	Type     string            // Type of node: "function", "class", "file"
-	Name     string            // Name of the entity
-	FilePath string            // File path
-	Metadata map[string]string // Additional metadata
+	ID         string            // Unique identifier (chunk ID)
+	Type       string            // Type of node: "function", "class", "file"
+	Name       string            // Name of the entity
+	FilePath   string            // File path
+	Metadata   map[string]string // Additional metadata
+	Generation int64             // Indexing run that produced the node
 }
 
 // Edge represents a relationship between two nodes
@@ -32,10 +35,11 @@ type Edge struct {
 // Graph represents an in-memory dependency graph
 type Graph struct {
 	mu       sync.RWMutex
-	nodes    map[string]*Node    // nodeID -> Node
-	edges    map[string][]*Edge  // nodeID -> outgoing edges
-	incoming map[string][]*Edge  // nodeID -> incoming edges (reverse index)
-	index    map[string][]string // name   -> nodeIDs (for lookup by name)
+	nodes    map[string]*Node           // nodeID -> Node
+	edges    map[string][]*Edge         // nodeID -> outgoing edges
+	incoming map[string][]*Edge         // nodeID -> incoming edges (reverse index)
+	index    map[string][]string        // name   -> nodeIDs (for lookup by name)
+	files    map[string]map[string]bool // file path -> nodeIDs (for RemoveStale)
 }
 
 // NewGraph creates a new empty graph
@@ -45,6 +49,7 @@ func NewGraph() *Graph {
 		edges:    make(map[string][]*Edge),
 		incoming: make(map[string][]*Edge),
 		index:    make(map[string][]string),
+		files:    make(map[string]map[string]bool),
 	}
 }
 
@@ -53,12 +58,123 @@ func (g *Graph) AddNode(node *Node) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// Re-adding a node (e.g. an unchanged chunk on reindex) replaces it
+	if prev, ok := g.nodes[node.ID]; ok {
+		g.unindexLocked(prev)
+	}
 	g.nodes[node.ID] = node
 
 	// Index by name for efficient lookup
 	if node.Name != "" {
 		g.index[node.Name] = append(g.index[node.Name], node.ID)
 	}
+	if g.files[node.FilePath] == nil {
+		g.files[node.FilePath] = make(map[string]bool)
+	}
+	g.files[node.FilePath][node.ID] = true
+}
+
+// unindexLocked drops node from the name and file indexes. Callers must hold
+// g.mu.
+func (g *Graph) unindexLocked(node *Node) {
+	delete(g.files[node.FilePath], node.ID)
+	if len(g.files[node.FilePath]) == 0 {
+		delete(g.files, node.FilePath)
+	}
+
+	ids := g.index[node.Name]
+	for i, id := range ids {
+		if id == node.ID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(g.index, node.Name)
+	} else {
+		g.index[node.Name] = ids
+	}
+}
+
+// RemoveStale removes the nodes of filePath that were not produced by
+// generation, together with every edge touching them. Pass 0 to remove all of
+// the file's nodes. It returns the number of nodes removed.
+func (g *Graph) RemoveStale(filePath string, generation int64) int {
+	return g.RemoveStaleFiles([]domain.StaleFile{{FilePath: filePath, Generation: generation}})
+}
+
+// RemoveStaleFiles removes the stale nodes of several files under one lock.
+// It returns the number of nodes removed.
+func (g *Graph) RemoveStaleFiles(files []domain.StaleFile) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stale := make(map[string]bool)
+	for _, f := range files {
+		for id := range g.files[f.FilePath] {
+			if f.Generation == 0 || g.nodes[id].Generation != f.Generation {
+				stale[id] = true
+			}
+		}
+	}
+	g.removeLocked(stale)
+	return len(stale)
+}
+
+// RemoveNodes removes the given nodes and every edge touching them. Unknown
+// IDs are ignored. It returns the number of nodes removed.
+func (g *Graph) RemoveNodes(ids []string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := g.nodes[id]; ok {
+			removed[id] = true
+		}
+	}
+	g.removeLocked(removed)
+	return len(removed)
+}
+
+// removeLocked deletes the nodes in removed with their edges. Callers must
+// hold g.mu.
+func (g *Graph) removeLocked(removed map[string]bool) {
+	for id := range removed {
+		g.unindexLocked(g.nodes[id])
+		delete(g.nodes, id)
+		for _, e := range g.edges[id] {
+			g.incoming[e.To] = dropEdges(g.incoming[e.To], removed)
+		}
+		for _, e := range g.incoming[id] {
+			g.edges[e.From] = dropEdges(g.edges[e.From], removed)
+		}
+		delete(g.edges, id)
+		delete(g.incoming, id)
+	}
+}
+
+// ListChunks returns the chunk ID, file and generation of every node
+func (g *Graph) ListChunks() []domain.ChunkRef {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	refs := make([]domain.ChunkRef, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		refs = append(refs, domain.ChunkRef{ID: node.ID, FilePath: node.FilePath, Generation: node.Generation})
+	}
+	return refs
+}
+
+// dropEdges filters out edges with either end in removed.
+func dropEdges(edges []*Edge, removed map[string]bool) []*Edge {
+	kept := edges[:0]
+	for _, e := range edges {
+		if !removed[e.From] && !removed[e.To] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
 }
 
 // AddEdge adds a directed edge between two nodes and updates the reverse index.
@@ -66,6 +182,13 @@ func (g *Graph) AddEdge(from, to string, relation RelationType) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// Rebuilding a file must not duplicate edges that already exist
+	for _, e := range g.edges[from] {
+		if e.To == to && e.Relation == relation {
+			return
+		}
+	}
+
 	edge := &Edge{
 		From:     from,
 		To:       to,
@@ -201,6 +324,7 @@ func (g *Graph) Clear() {
 	g.edges = make(map[string][]*Edge)
 	g.incoming = make(map[string][]*Edge)
 	g.index = make(map[string][]string)
+	g.files = make(map[string]map[string]bool)
 }
 
 // Stats returns statistics about the graph
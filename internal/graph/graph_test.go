@@ -58,6 +58,78 @@ func TestGraph_AddEdge(t *testing.T) {
 	}
 }
 
+func TestGraph_AddNode_Replaces(t *testing.T) {
+	g := NewGraph()
+	g.AddNode(&Node{ID: "func1", Name: "Old", FilePath: "a.go"})
+	g.AddNode(&Node{ID: "func1", Name: "New", FilePath: "a.go"})
+	g.AddEdge("func1", "func1", RelationCall)
+	g.AddEdge("func1", "func1", RelationCall)
+
+	if nodes := g.GetNodesByName("Old"); len(nodes) != 0 {
+		t.Errorf("replaced node still indexed by old name: %v", nodes)
+	}
+	if nodes := g.GetNodesByName("New"); len(nodes) != 1 {
+		t.Errorf("expected 1 node named New, got %d", len(nodes))
+	}
+	if related := g.GetRelated("func1", RelationCall); len(related) != 1 {
+		t.Errorf("duplicate edges not collapsed, got %d", len(related))
+	}
+}
+
+func TestGraph_RemoveStale(t *testing.T) {
+	g := NewGraph()
+	g.AddNode(&Node{ID: "old", Name: "Old", FilePath: "a.go", Generation: 1})
+	g.AddNode(&Node{ID: "cur", Name: "Cur", FilePath: "a.go", Generation: 2})
+	g.AddNode(&Node{ID: "other", Name: "Other", FilePath: "b.go", Generation: 1})
+	g.AddEdge("other", "old", RelationCall)
+	g.AddEdge("other", "cur", RelationCall)
+
+	if removed := g.RemoveStale("a.go", 2); removed != 1 {
+		t.Fatalf("RemoveStale() = %d, want 1", removed)
+	}
+	if _, ok := g.GetNode("old"); ok {
+		t.Error("stale node should be removed")
+	}
+	if related := g.GetRelated("other", RelationCall); len(related) != 1 || related[0].ID != "cur" {
+		t.Errorf("edges to stale node should be removed, got %v", related)
+	}
+
+	if removed := g.RemoveStale("a.go", 0); removed != 1 {
+		t.Errorf("RemoveStale(0) = %d, want 1", removed)
+	}
+	if _, ok := g.GetNode("other"); !ok {
+		t.Error("nodes of other files must be kept")
+	}
+
+	// A node re-added under a renamed file belongs to the new file only
+	g.AddNode(&Node{ID: "other", Name: "Other", FilePath: "c.go", Generation: 2})
+	if removed := g.RemoveStale("b.go", 0); removed != 0 {
+		t.Errorf("RemoveStale(b.go) = %d after the node moved, want 0", removed)
+	}
+	if removed := g.RemoveStale("c.go", 0); removed != 1 || len(g.files) != 0 {
+		t.Errorf("RemoveStale(c.go) = %d leaving files %v, want 1 and none", removed, g.files)
+	}
+}
+
+func TestGraph_RemoveNodesAndListChunks(t *testing.T) {
+	g := NewGraph()
+	g.AddNode(&Node{ID: "a", Name: "A", FilePath: "a.go", Generation: 5})
+	g.AddNode(&Node{ID: "b", Name: "B", FilePath: "b.go"})
+	g.AddEdge("a", "b", RelationCall)
+
+	if removed := g.RemoveNodes([]string{"b", "unknown"}); removed != 1 {
+		t.Fatalf("RemoveNodes() = %d, want 1", removed)
+	}
+	if related := g.GetRelated("a", RelationCall); len(related) != 0 {
+		t.Errorf("edges to removed node should be dropped, got %v", related)
+	}
+
+	refs := g.ListChunks()
+	if len(refs) != 1 || refs[0].ID != "a" || refs[0].FilePath != "a.go" || refs[0].Generation != 5 {
+		t.Errorf("ListChunks() = %+v", refs)
+	}
+}
+
 func TestGraph_GetNodesByName(t *testing.T) {
 	g := NewGraph()
 
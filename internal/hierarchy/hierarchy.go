@@ -33,18 +33,24 @@ func (f *HierarchicalFilter) Process(ctx context.Context, results []*domain.Sear
 		return results, nil
 	}
 
-	// Group by file
+	// Group by file, keeping files in the order they first appear so that
+	// ties come out the same way every time
 	byFile := make(map[string][]*domain.SearchResult)
+	var files []string
 	for _, res := range results {
 		file := res.Chunk.FilePath
+		if _, ok := byFile[file]; !ok {
+			files = append(files, file)
+		}
 		byFile[file] = append(byFile[file], res)
 	}
 
 	filtered := make([]*domain.SearchResult, 0, len(results))
 
 	// For each file, sort by score and take top N
-	for _, fileResults := range byFile {
-		sort.Slice(fileResults, func(i, j int) bool {
+	for _, file := range files {
+		fileResults := byFile[file]
+		sort.SliceStable(fileResults, func(i, j int) bool {
 			return fileResults[i].RelevanceScore > fileResults[j].RelevanceScore
 		})
 
@@ -52,11 +58,20 @@ func (f *HierarchicalFilter) Process(ctx context.Context, results []*domain.Sear
 		if count > f.MaxResultsPerFile {
 			count = f.MaxResultsPerFile
 		}
+		for i, res := range fileResults[:count] {
+			if res.Debug != nil {
+				res.Debug.Hierarchy = &domain.HierarchyExplanation{
+					FileRank:   i + 1,
+					MaxPerFile: f.MaxResultsPerFile,
+					CutInFile:  len(fileResults) - count,
+				}
+			}
+		}
 		filtered = append(filtered, fileResults[:count]...)
 	}
 
 	// Final sort of all filtered results
-	sort.Slice(filtered, func(i, j int) bool {
+	sort.SliceStable(filtered, func(i, j int) bool {
 		return filtered[i].RelevanceScore > filtered[j].RelevanceScore
 	})
 
@@ -69,3 +69,30 @@ func TestHierarchicalFilter_Empty(t *testing.T) {
 		t.Errorf("Expected 0 results, got %d", len(filtered))
 	}
 }
+
+func TestHierarchicalFilter_Debug(t *testing.T) {
+	f := NewHierarchicalFilter(1)
+
+	results := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "1", FilePath: "a.go"}, RelevanceScore: 0.9, Debug: &domain.ResultDebug{}},
+		{Chunk: &domain.CodeChunk{ID: "2", FilePath: "a.go"}, RelevanceScore: 0.8, Debug: &domain.ResultDebug{}},
+		{Chunk: &domain.CodeChunk{ID: "3", FilePath: "a.go"}, RelevanceScore: 0.7, Debug: &domain.ResultDebug{}},
+		{Chunk: &domain.CodeChunk{ID: "4", FilePath: "b.go"}, RelevanceScore: 0.6, Debug: &domain.ResultDebug{}},
+	}
+
+	filtered, err := f.Process(context.Background(), results)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(filtered) != 2 {
+		t.Fatalf("Process() returned %d results, want 2", len(filtered))
+	}
+
+	want := map[string]int{"1": 2, "4": 0}
+	for _, res := range filtered {
+		h := res.Debug.Hierarchy
+		if h == nil || h.FileRank != 1 || h.MaxPerFile != 1 || h.CutInFile != want[res.Chunk.ID] {
+			t.Errorf("chunk %s: unexpected hierarchy debug %+v", res.Chunk.ID, h)
+		}
+	}
+}
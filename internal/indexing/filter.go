@@ -0,0 +1,506 @@
+package indexing
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// FilterConfig controls which files are selected for indexing and watching.
+// Glob patterns use .gitignore syntax and are matched relative to the root
+// being indexed.
+type FilterConfig struct {
+	IncludeGlobs     []string // when non-empty, only matching files are indexed
+	ExcludeGlobs     []string // always excluded, regardless of ignore files
+	IgnoreFiles      []string // per-directory ignore files, e.g. .gitignore, .ragignore
+	RespectGitignore bool     // honour IgnoreFiles found in the tree
+	MaxFileSize      int64    // bytes; 0 disables the limit
+	SkipBinary       bool     // skip files containing NUL bytes in their header
+	SkipGenerated    bool     // skip files marked "Code generated ... DO NOT EDIT."
+}
+
+// DefaultFilterConfig returns the default file selection rules. The exclude
+// list preserves the historical behaviour of skipping hidden directories,
+// node_modules and vendor.
+func DefaultFilterConfig() FilterConfig {
+	return FilterConfig{
+		ExcludeGlobs:     []string{".*/", "node_modules/", "vendor/"},
+		IgnoreFiles:      []string{".gitignore", ".ragignore"},
+		RespectGitignore: true,
+		MaxFileSize:      1 << 20, // 1 MiB
+		SkipBinary:       true,
+		SkipGenerated:    true,
+	}
+}
+
+// headerSize is how much of a file is inspected for binary / generated markers.
+const headerSize = 8 * 1024
+
+// generatedMarker matches the conventional "generated file" headers emitted by
+// protoc, go generate, mockgen, swag, Bazel and friends.
+var generatedMarker = regexp.MustCompile(`(?i)(code generated .*do not edit|@generated\b|auto-?generated by|this file (is|was) (automatically|auto-?)generated)`)
+
+// generatedSuffixes are file name suffixes that are generated by convention.
+var generatedSuffixes = []string{".pb.go", ".pb.gw.go", "_pb2.py", "_pb2_grpc.py", ".pb.ts", ".min.js", ".min.css"}
+
+// FileFilter decides whether files and directories should be indexed. A single
+// instance is shared by the Indexer and the Watcher so both apply exactly the
+// same rules. It is safe for concurrent use.
+type FileFilter struct {
+	cfg     FilterConfig
+	include []*ignoreRule
+	exclude []*ignoreRule
+
+	mu    sync.RWMutex
+	roots []string                 // registered index/watch roots (absolute)
+	rules map[string][]*ignoreRule // dir -> rules parsed from its ignore files
+}
+
+// NewFileFilter creates a filter from cfg.
+func NewFileFilter(cfg FilterConfig) *FileFilter {
+	return &FileFilter{
+		cfg:     cfg,
+		include: parseIgnoreLines(cfg.IncludeGlobs),
+		exclude: parseIgnoreLines(cfg.ExcludeGlobs),
+		rules:   make(map[string][]*ignoreRule),
+	}
+}
+
+// AddRoot registers a directory as an index/watch root. Ignore files are only
+// consulted between a path and its closest registered root.
+func (f *FileFilter) AddRoot(root string) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.roots {
+		if r == abs {
+			return
+		}
+	}
+	f.roots = append(f.roots, abs)
+}
+
+// IsIgnoreFile reports whether path is one of the configured ignore files, so
+// callers can Invalidate the cached rules when it changes.
+func (f *FileFilter) IsIgnoreFile(path string) bool {
+	base := filepath.Base(path)
+	for _, name := range f.cfg.IgnoreFiles {
+		if base == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Invalidate drops the cached ignore rules for dir.
+func (f *FileFilter) Invalidate(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	delete(f.rules, abs)
+	f.mu.Unlock()
+}
+
+// SkipDir reports whether a directory should be pruned from a walk. The root
+// itself is never skipped.
+func (f *FileFilter) SkipDir(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	root := f.rootFor(abs)
+	if abs == root {
+		return false
+	}
+	return f.dirExcluded(root, abs)
+}
+
+// ShouldIndex reports whether a regular file passes every selection rule:
+// include/exclude globs, ignore files (for the file and all its parent
+// directories), size limit, binary and generated-file detection.
+func (f *FileFilter) ShouldIndex(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if f.cfg.MaxFileSize > 0 && info.Size() > f.cfg.MaxFileSize {
+		logger.Debug("Skipping large file", "path", abs, "size", info.Size(), "max", f.cfg.MaxFileSize)
+		return false
+	}
+
+	root := f.rootFor(abs)
+
+	// Any excluded ancestor directory excludes the file
+	for dir := filepath.Dir(abs); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if f.dirExcluded(root, dir) {
+			return false
+		}
+	}
+
+	rel := relSlash(root, abs)
+	if matchRules(f.exclude, rel, false) {
+		return false
+	}
+	if len(f.include) > 0 && !matchRules(f.include, rel, false) {
+		return false
+	}
+	if f.cfg.RespectGitignore && f.ignored(root, abs, false) {
+		logger.Debug("Skipping ignored file", "path", abs)
+		return false
+	}
+
+	if f.cfg.SkipGenerated && hasGeneratedSuffix(abs) {
+		logger.Debug("Skipping generated file", "path", abs)
+		return false
+	}
+	if f.cfg.SkipBinary || f.cfg.SkipGenerated {
+		header, err := readHeader(abs)
+		if err != nil {
+			return false
+		}
+		if f.cfg.SkipBinary && bytes.IndexByte(header, 0) >= 0 {
+			logger.Debug("Skipping binary file", "path", abs)
+			return false
+		}
+		if f.cfg.SkipGenerated && isGeneratedHeader(header) {
+			logger.Debug("Skipping generated file", "path", abs)
+			return false
+		}
+	}
+
+	return true
+}
+
+// dirExcluded checks a single directory against the exclude globs and ignore files.
+func (f *FileFilter) dirExcluded(root, dir string) bool {
+	if matchRules(f.exclude, relSlash(root, dir), true) {
+		return true
+	}
+	return f.cfg.RespectGitignore && f.ignored(root, dir, true)
+}
+
+// ignored evaluates ignore files from root down to path's parent directory.
+// As in git, the last matching rule wins and deeper files override shallower ones.
+func (f *FileFilter) ignored(root, path string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == root {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel := relSlash(dirs[i], path)
+		for _, rule := range f.rulesFor(dirs[i]) {
+			if rule.match(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// rulesFor returns the (cached) rules parsed from dir's ignore files.
+func (f *FileFilter) rulesFor(dir string) []*ignoreRule {
+	f.mu.RLock()
+	rules, ok := f.rules[dir]
+	f.mu.RUnlock()
+	if ok {
+		return rules
+	}
+
+	for _, name := range f.cfg.IgnoreFiles {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		var lines []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		rules = append(rules, parseIgnoreLines(lines)...)
+	}
+
+	f.mu.Lock()
+	f.rules[dir] = rules
+	f.mu.Unlock()
+	return rules
+}
+
+// rootFor returns the closest registered root containing path. Paths outside
+// every root fall back to the enclosing git work tree, or their own directory.
+func (f *FileFilter) rootFor(path string) string {
+	f.mu.RLock()
+	best := ""
+	for _, r := range f.roots {
+		if (path == r || strings.HasPrefix(path, r+string(filepath.Separator))) && len(r) > len(best) {
+			best = r
+		}
+	}
+	f.mu.RUnlock()
+	if best != "" {
+		return best
+	}
+
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	return filepath.Dir(path)
+}
+
+// ---------------------------------------------------------------------------
+// .gitignore pattern matching
+// ---------------------------------------------------------------------------
+
+// ignoreRule is a single compiled .gitignore pattern.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// match reports whether rel (slash-separated, relative to the rule's directory)
+// is matched by the rule.
+func (r *ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(rel)
+}
+
+// matchRules applies rules in order; the last matching rule wins.
+func matchRules(rules []*ignoreRule, rel string, isDir bool) bool {
+	matched := false
+	for _, rule := range rules {
+		if rule.match(rel, isDir) {
+			matched = !rule.negate
+		}
+	}
+	return matched
+}
+
+// parseIgnoreLines compiles .gitignore-style lines, skipping blanks and comments.
+func parseIgnoreLines(lines []string) []*ignoreRule {
+	var rules []*ignoreRule
+	for _, line := range lines {
+		if rule := parseIgnoreLine(line); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func parseIgnoreLine(line string) *ignoreRule {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	rule := &ignoreRule{}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+
+	// A slash anywhere but the end anchors the pattern to the ignore file's directory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		logger.Warn("Invalid ignore pattern", "pattern", line, "error", err)
+		return nil
+	}
+	rule.re = re
+	return rule
+}
+
+// globToRegexp translates gitignore glob syntax (*, ?, [..], **) into a regexp.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				// "**/" matches zero or more directories, trailing "**" everything
+				if i+2 < len(glob) && glob[i+2] == '/' {
+					sb.WriteString("(.*/)?")
+					i += 2
+				} else {
+					sb.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// PathFilter selects files by glob for searches scoped to part of the tree.
+// Globs use .gitignore syntax but match anywhere in the path, so "*.go" and
+// "internal/retrieval" work on absolute paths; a leading ! excludes.
+type PathFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewPathFilter compiles globs. A filter without include globs matches
+// every file that is not excluded.
+func NewPathFilter(globs []string) (*PathFilter, error) {
+	f := &PathFilter{}
+	for _, glob := range globs {
+		glob = strings.TrimSpace(glob)
+		negate := strings.HasPrefix(glob, "!")
+		glob = strings.Trim(strings.TrimPrefix(glob, "!"), "/")
+		if glob == "" {
+			continue
+		}
+		re, err := regexp.Compile("(^|/)" + globToRegexp(glob) + "(/|$)")
+		if err != nil {
+			return nil, errors.ValidationError("invalid path glob " + glob)
+		}
+		if negate {
+			f.exclude = append(f.exclude, re)
+		} else {
+			f.include = append(f.include, re)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether path is selected
+func (f *PathFilter) Match(path string) bool {
+	path = filepath.ToSlash(path)
+	for _, re := range f.exclude {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Content helpers
+// ---------------------------------------------------------------------------
+
+func readHeader(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, headerSize)
+	n, err := file.Read(buf)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// isGeneratedHeader looks for a generated-code marker in the first lines of a file.
+func isGeneratedHeader(header []byte) bool {
+	lines := bytes.SplitN(header, []byte("\n"), 21)
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+	for _, line := range lines {
+		if generatedMarker.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGeneratedSuffix(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// relSlash returns path relative to base using forward slashes.
+func relSlash(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
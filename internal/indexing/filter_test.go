@@ -0,0 +1,217 @@
+package indexing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+}
+
+func TestIgnoreRule_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "logs/debug.log", false, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"dist/", "dist", true, true},
+		{"dist/", "dist", false, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"**/fixtures", "a/b/fixtures", true, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"file[0-9].txt", "file7.txt", false, true},
+		{"file?.txt", "file10.txt", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"→"+tt.path, func(t *testing.T) {
+			rule := parseIgnoreLine(tt.pattern)
+			if rule == nil {
+				t.Fatalf("parseIgnoreLine(%q) returned nil", tt.pattern)
+			}
+			if got := rule.match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("match(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreLine_SkipsCommentsAndBlanks(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if rule := parseIgnoreLine(line); rule != nil {
+			t.Errorf("parseIgnoreLine(%q) = %v, want nil", line, rule)
+		}
+	}
+}
+
+func TestFileFilter_Gitignore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":           "dist/\n*.log\n!keep.log\n",
+		"main.go":              "package main\n",
+		"debug.log":            "log\n",
+		"keep.log":             "log\n",
+		"dist/bundle.js":       "var x = 1\n",
+		"pkg/.gitignore":       "local.go\n",
+		"pkg/local.go":         "package pkg\n",
+		"pkg/shared.go":        "package pkg\n",
+		".ragignore":           "fixtures/\n",
+		"fixtures/big.json":    "{}\n",
+		"node_modules/x/i.js":  "module.exports = 1\n",
+		".github/workflow.yml": "on: push\n",
+	})
+
+	f := NewFileFilter(DefaultFilterConfig())
+	f.AddRoot(root)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", true},
+		{"debug.log", false},
+		{"keep.log", true},
+		{"dist/bundle.js", false},
+		{"pkg/local.go", false},
+		{"pkg/shared.go", true},
+		{"fixtures/big.json", false},
+		{"node_modules/x/i.js", false},
+		{".github/workflow.yml", false},
+	}
+	for _, tt := range tests {
+		if got := f.ShouldIndex(filepath.Join(root, tt.path)); got != tt.want {
+			t.Errorf("ShouldIndex(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if f.SkipDir(root) {
+		t.Error("SkipDir(root) should never skip the root")
+	}
+	if !f.SkipDir(filepath.Join(root, "dist")) {
+		t.Error("SkipDir(dist) = false, want true")
+	}
+}
+
+func TestFileFilter_Invalidate(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.go": "package a\n"})
+
+	f := NewFileFilter(DefaultFilterConfig())
+	f.AddRoot(root)
+	if !f.ShouldIndex(filepath.Join(root, "a.go")) {
+		t.Fatal("expected a.go to be indexed before .gitignore exists")
+	}
+
+	writeTree(t, root, map[string]string{".gitignore": "a.go\n"})
+	if !f.IsIgnoreFile(filepath.Join(root, ".gitignore")) {
+		t.Fatal("IsIgnoreFile(.gitignore) = false")
+	}
+	f.Invalidate(root)
+	if f.ShouldIndex(filepath.Join(root, "a.go")) {
+		t.Error("expected a.go to be ignored after invalidation")
+	}
+}
+
+func TestFileFilter_Globs(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"src/app.go":       "package src\n",
+		"src/app_test.go":  "package src\n",
+		"scripts/build.sh": "echo hi\n",
+	})
+
+	cfg := DefaultFilterConfig()
+	cfg.IncludeGlobs = []string{"src/**"}
+	cfg.ExcludeGlobs = append(cfg.ExcludeGlobs, "*_test.go")
+	f := NewFileFilter(cfg)
+	f.AddRoot(root)
+
+	if !f.ShouldIndex(filepath.Join(root, "src/app.go")) {
+		t.Error("src/app.go should match include glob")
+	}
+	if f.ShouldIndex(filepath.Join(root, "src/app_test.go")) {
+		t.Error("src/app_test.go should be excluded")
+	}
+	if f.ShouldIndex(filepath.Join(root, "scripts/build.sh")) {
+		t.Error("scripts/build.sh should not match include glob")
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	f, err := NewPathFilter([]string{"internal/retrieval", "*.md", "!*_test.go"})
+	if err != nil {
+		t.Fatalf("NewPathFilter() error = %v", err)
+	}
+	for path, want := range map[string]bool{
+		"/src/rag/internal/retrieval/trigram.go":      true,
+		"/src/rag/internal/retrieval/trigram_test.go": false,
+		"/src/rag/internal/retrievals/x.go":           false,
+		"/src/rag/README.md":                          true,
+		"/src/rag/cmd/rag/main.go":                    false,
+	} {
+		if got := f.Match(path); got != want {
+			t.Errorf("Match(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	all, _ := NewPathFilter(nil)
+	if !all.Match("/any/file.go") {
+		t.Error("an empty filter should match every path")
+	}
+	if _, err := NewPathFilter([]string{"[z-a].go"}); err == nil {
+		t.Error("expected an error for an invalid glob")
+	}
+}
+
+func TestFileFilter_ContentRules(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"gen.go":      "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage gen\n",
+		"api.pb.go":   "package api\n",
+		"plain.go":    "package plain\n",
+		"blob.bin.go": "package x\x00\x01\x02",
+		"big.txt":     strings.Repeat("x", 2048),
+	})
+
+	cfg := DefaultFilterConfig()
+	cfg.MaxFileSize = 1024
+	f := NewFileFilter(cfg)
+	f.AddRoot(root)
+
+	tests := map[string]bool{
+		"gen.go":      false,
+		"api.pb.go":   false,
+		"plain.go":    true,
+		"blob.bin.go": false,
+		"big.txt":     false,
+	}
+	for path, want := range tests {
+		if got := f.ShouldIndex(filepath.Join(root, path)); got != want {
+			t.Errorf("ShouldIndex(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	cfg.SkipGenerated = false
+	if !NewFileFilter(cfg).ShouldIndex(filepath.Join(root, "gen.go")) {
+		t.Error("gen.go should be indexed when SkipGenerated is false")
+	}
+}
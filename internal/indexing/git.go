@@ -0,0 +1,95 @@
+package indexing
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// gitTimeout bounds every local git invocation.
+const gitTimeout = 10 * time.Second
+
+// runGit runs a local git command in dir and returns its trimmed stdout.
+// Only read-only plumbing commands are used; nothing talks to a remote.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeExternal, "git "+args[0]+" failed: "+strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitHead returns the commit HEAD points at, or an error when dir is not
+// inside a git work tree (or git is not installed).
+func gitHead(ctx context.Context, dir string) (string, error) {
+	return runGit(ctx, dir, "rev-parse", "--verify", "-q", "HEAD")
+}
+
+// gitDiffChanges lists files that differ between two commits as absolute
+// paths. Renames are reported as a delete of the old path plus a create of
+// the new one, matching how the watcher treats fsnotify renames.
+func gitDiffChanges(ctx context.Context, dir, from, to string) ([]FileChange, error) {
+	top, err := runGit(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit(ctx, dir, "diff", "--name-status", "-z", "-M", from, to)
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(top, out), nil
+}
+
+// parseNameStatus parses `git diff --name-status -z` output: NUL-separated
+// fields, a status followed by one path, or by two for renames and copies.
+// Paths are relative to the repository top level and, unlike without -z,
+// are not quoted when they contain spaces or non-ASCII characters.
+func parseNameStatus(top, out string) []FileChange {
+	var changes []FileChange
+	abs := func(rel string) string { return filepath.Join(top, filepath.FromSlash(rel)) }
+
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); {
+		status := fields[i]
+		if status == "" {
+			i++
+			continue
+		}
+		paths := 1
+		if status[0] == 'R' || status[0] == 'C' {
+			paths = 2
+		}
+		if i+paths >= len(fields) {
+			break
+		}
+		path := fields[i+1]
+		switch status[0] {
+		case 'A':
+			changes = append(changes, FileChange{Path: abs(path), Event: FileEventCreate})
+		case 'D':
+			changes = append(changes, FileChange{Path: abs(path), Event: FileEventDelete})
+		case 'R':
+			changes = append(changes,
+				FileChange{Path: abs(path), Event: FileEventDelete},
+				FileChange{Path: abs(fields[i+2]), Event: FileEventCreate},
+			)
+		case 'C':
+			changes = append(changes, FileChange{Path: abs(fields[i+2]), Event: FileEventCreate})
+		default: // M, T and anything else is a content change
+			changes = append(changes, FileChange{Path: abs(path), Event: FileEventModify})
+		}
+		i += 1 + paths
+	}
+	return changes
+}
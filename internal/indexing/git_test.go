@@ -0,0 +1,121 @@
+package indexing
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	out := "A\x00new.go\x00M\x00pkg/chan ged.go\x00D\x00na\u00efve.go\x00R087\x00src/a.go\x00src/b\tc.go\x00C100\x00x.go\x00y.go\x00T\x00link\x00"
+	got := parseNameStatus("/repo", out)
+
+	want := []FileChange{
+		{Path: "/repo/new.go", Event: FileEventCreate},
+		{Path: "/repo/pkg/chan ged.go", Event: FileEventModify},
+		{Path: "/repo/na\u00efve.go", Event: FileEventDelete},
+		{Path: "/repo/src/a.go", Event: FileEventDelete},
+		{Path: "/repo/src/b\tc.go", Event: FileEventCreate},
+		{Path: "/repo/y.go", Event: FileEventCreate},
+		{Path: "/repo/link", Event: FileEventModify},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseNameStatus() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// initGitRepo creates a repository with one commit, skipping the test when
+// git is not installed.
+func initGitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	writeTree(t, root, files)
+	gitCommitAll(t, root, "initial")
+	return root
+}
+
+func gitCommitAll(t *testing.T, dir, message string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--no-verify", "-m", message},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestGitDiffChanges(t *testing.T) {
+	root := initGitRepo(t, map[string]string{
+		"keep.go":     "package keep\n",
+		"edit.go":     "package edit\n",
+		"remove.go":   "package remove\n",
+		"old name.go": "package old\n\nfunc Renamed() {}\n",
+	})
+	ctx := context.Background()
+	from, err := gitHead(ctx, root)
+	if err != nil {
+		t.Fatalf("gitHead() error = %v", err)
+	}
+
+	writeTree(t, root, map[string]string{
+		"edit.go":    "package edit\n\nfunc Edited() {}\n",
+		"sub/new.go": "package sub\n",
+	})
+	if out, err := exec.Command("git", "-C", root, "rm", "-q", "remove.go").CombinedOutput(); err != nil {
+		t.Fatalf("git rm: %v\n%s", err, out)
+	}
+	// Git quotes these paths unless -z is used
+	if out, err := exec.Command("git", "-C", root, "mv", "old name.go", "na\u00efve.go").CombinedOutput(); err != nil {
+		t.Fatalf("git mv: %v\n%s", err, out)
+	}
+	gitCommitAll(t, root, "second")
+	to, _ := gitHead(ctx, root)
+
+	changes, err := gitDiffChanges(ctx, root, from, to)
+	if err != nil {
+		t.Fatalf("gitDiffChanges() error = %v", err)
+	}
+	got := make(map[string]FileEvent)
+	for _, c := range changes {
+		got[c.Path] = c.Event
+	}
+	want := map[string]FileEvent{
+		filepath.Join(root, "edit.go"):       FileEventModify,
+		filepath.Join(root, "remove.go"):     FileEventDelete,
+		filepath.Join(root, "sub/new.go"):    FileEventCreate,
+		filepath.Join(root, "old name.go"):   FileEventDelete,
+		filepath.Join(root, "na\u00efve.go"): FileEventCreate,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("gitDiffChanges() = %v, want %v", got, want)
+	}
+	for path, event := range want {
+		if got[path] != event {
+			t.Errorf("%s = %q, want %q", path, got[path], event)
+		}
+	}
+}
+
+func TestGitHead_NotARepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if _, err := gitHead(context.Background(), t.TempDir()); err == nil {
+		t.Error("gitHead() outside a repository should fail")
+	}
+}
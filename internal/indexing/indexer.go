@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Guru2308/rag-code/internal/domain"
@@ -28,15 +29,22 @@ type Indexer struct {
 	mu             sync.RWMutex
 	jobs           map[string]*domain.IndexingJob
 	numWorkers     int
+	embedWorkers   int
+	generation     atomic.Int64      // last generation handed out by nextGeneration
 	fileHashes     map[string]string // path -> md5 hash for incremental indexing
+	noChunks       map[string]string // path -> md5 hash of files that yielded no chunks
 	metrics        *IndexMetrics
 	batchSize      int
 	maxRetries     int
+	filter         *FileFilter
 }
 
 // KeywordIndexer defines the interface for adding chunks to the keyword index
 type KeywordIndexer interface {
 	AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error
+	// RemoveStaleFiles drops each file's entries not written by its
+	// generation (0 drops all)
+	RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error
 }
 
 // Embedder generates embeddings for code chunks
@@ -49,6 +57,8 @@ type Embedder interface {
 type ChunkStore interface {
 	Store(ctx context.Context, chunks []*domain.CodeChunk) error
 	Delete(ctx context.Context, filePath string) error
+	// DeleteStaleFiles removes each file's chunks not written by its generation
+	DeleteStaleFiles(ctx context.Context, files []domain.StaleFile) error
 	Get(ctx context.Context, id string) (*domain.CodeChunk, error)
 	Search(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error)
 }
@@ -60,6 +70,8 @@ type Config struct {
 	BatchSize    int
 	MaxRetries   int
 	NumWorkers   int
+	EmbedWorkers int         // concurrent embedding batches in the pipeline
+	Filter       *FileFilter // shared with the Watcher; nil uses DefaultFilterConfig
 }
 
 // DefaultConfig returns sensible defaults for the indexer
@@ -70,6 +82,7 @@ func DefaultConfig() Config {
 		BatchSize:    20,
 		MaxRetries:   3,
 		NumWorkers:   4,
+		EmbedWorkers: 2,
 	}
 }
 
@@ -156,6 +169,14 @@ func NewIndexerWithConfig(parser Parser, chunker Chunker, embedder Embedder, sto
 	if maxRetries <= 0 {
 		maxRetries = 3
 	}
+	embedWorkers := cfg.EmbedWorkers
+	if embedWorkers <= 0 {
+		embedWorkers = 1
+	}
+	filter := cfg.Filter
+	if filter == nil {
+		filter = NewFileFilter(DefaultFilterConfig())
+	}
 	return &Indexer{
 		parser:         parser,
 		chunker:        chunker,
@@ -165,10 +186,13 @@ func NewIndexerWithConfig(parser Parser, chunker Chunker, embedder Embedder, sto
 		graph:          g,
 		jobs:           make(map[string]*domain.IndexingJob),
 		numWorkers:     numWorkers,
+		embedWorkers:   embedWorkers,
 		fileHashes:     make(map[string]string),
+		noChunks:       make(map[string]string),
 		metrics:        newIndexMetrics(),
 		batchSize:      batchSize,
 		maxRetries:     maxRetries,
+		filter:         filter,
 	}
 }
 
@@ -178,15 +202,54 @@ func NewIndexerWithConfig(parser Parser, chunker Chunker, embedder Embedder, sto
 
 // IndexFile indexes a single file, skipping it when the content has not
 // changed since the last run (incremental indexing).
+//
+// Reindexing is atomic per file: the new chunks are written under a fresh
+// generation first and older generations are removed only once that
+// succeeded, so a failed or in-flight reindex never leaves the file without
+// chunks.
 func (idx *Indexer) IndexFile(ctx context.Context, filePath string) error {
+	file, err := idx.prepareFile(ctx, filePath)
+	if err != nil || file == nil {
+		return err
+	}
+
+	// ── Batch embedding generation ───────────────────────────────────────────
+	if err := idx.embedChunksBatched(ctx, file.chunks); err != nil {
+		idx.metrics.recordFile(false, true)
+		return err
+	}
+
+	// ── Store chunks in batches with retry ───────────────────────────────────
+	if err := idx.storeChunksBatched(ctx, file.chunks); err != nil {
+		idx.metrics.recordFile(false, true)
+		return err
+	}
+
+	idx.commitFiles(ctx, []*pipelineFile{file})
+	return nil
+}
+
+// prepareFile runs the filter, change detection, parse and chunk steps for a
+// file. It returns nil without error when the file is skipped; a skipped
+// file that was indexed before is removed from the index.
+func (idx *Indexer) prepareFile(ctx context.Context, filePath string) (*pipelineFile, error) {
 	logger.Info("Indexing file", "path", filePath)
 
 	// Detect language
 	language := LanguageDetector(filePath)
 	if language == "unknown" {
 		logger.Debug("Skipping unknown file type", "path", filePath)
+		idx.dropIndexed(ctx, filePath)
 		idx.metrics.recordFile(false, false)
-		return nil
+		return nil, nil
+	}
+
+	// Apply ignore files, globs, size, binary and generated-file rules
+	if !idx.filter.ShouldIndex(filePath) {
+		logger.Debug("Skipping filtered file", "path", filePath)
+		idx.dropIndexed(ctx, filePath)
+		idx.metrics.recordFile(false, false)
+		return nil, nil
 	}
 
 	// ── Incremental indexing: skip unchanged files ──────────────────────────
@@ -200,7 +263,7 @@ func (idx *Indexer) IndexFile(ctx context.Context, filePath string) error {
 		if seen && previousHash == currentHash {
 			logger.Debug("File unchanged, skipping", "path", filePath)
 			idx.metrics.recordFile(false, false)
-			return nil
+			return nil, nil
 		}
 	}
 
@@ -208,67 +271,102 @@ func (idx *Indexer) IndexFile(ctx context.Context, filePath string) error {
 	chunks, err := idx.parser.Parse(ctx, filePath)
 	if err != nil {
 		idx.metrics.recordFile(false, true)
-		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to parse file")
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to parse file")
 	}
 
 	if len(chunks) == 0 {
 		logger.Debug("No chunks extracted from file", "path", filePath)
+		idx.dropIndexed(ctx, filePath)
+		if currentHash != "" {
+			// So Verify does not report the file as missing
+			idx.mu.Lock()
+			idx.noChunks[filePath] = currentHash
+			idx.mu.Unlock()
+		}
 		idx.metrics.recordFile(false, false)
-		return nil
+		return nil, nil
 	}
 
 	// Process chunks (split/merge as needed)
 	processedChunks, err := idx.chunker.Chunk(ctx, chunks, 0)
 	if err != nil {
 		idx.metrics.recordFile(false, true)
-		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to chunk file")
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to chunk file")
 	}
 
-	// ── Batch embedding generation ───────────────────────────────────────────
-	if err := idx.embedChunksBatched(ctx, processedChunks); err != nil {
-		idx.metrics.recordFile(false, true)
-		return err
+	generation := idx.nextGeneration()
+	for _, c := range processedChunks {
+		c.Generation = generation
+		c.FileHash = currentHash
 	}
 
-	// Delete existing chunks for this file to prevent stale data
-	if err := idx.store.Delete(ctx, filePath); err != nil {
-		logger.Warn("Failed to delete old chunks", "path", filePath, "error", err)
+	return &pipelineFile{path: filePath, hash: currentHash, generation: generation, chunks: processedChunks}, nil
+}
+
+// nextGeneration returns a new, strictly increasing generation number. Wall
+// clock based so generations keep increasing across restarts.
+func (idx *Indexer) nextGeneration() int64 {
+	for {
+		prev := idx.generation.Load()
+		next := time.Now().UnixNano()
+		if next <= prev {
+			next = prev + 1
+		}
+		if idx.generation.CompareAndSwap(prev, next) {
+			return next
+		}
 	}
+}
 
-	// ── Store chunks in batches with retry ───────────────────────────────────
-	if err := idx.storeChunksBatched(ctx, processedChunks); err != nil {
-		idx.metrics.recordFile(false, true)
-		return err
+// commitFiles finishes files whose new generation has been stored: older
+// generations are dropped from the store, the keyword index and graph are
+// updated the same way, and file hashes and metrics are recorded. Each stage
+// handles the whole batch in one call.
+func (idx *Indexer) commitFiles(ctx context.Context, files []*pipelineFile) {
+	chunks := groupChunks(files)
+	stale := make([]domain.StaleFile, len(files))
+	for i, f := range files {
+		stale[i] = domain.StaleFile{FilePath: f.path, Generation: f.generation}
+	}
+
+	// The new generation is in place, so older chunks can go
+	if err := idx.store.DeleteStaleFiles(ctx, stale); err != nil {
+		logger.Warn("Failed to delete stale chunks", "files", len(files), "error", err)
 	}
 
 	// Update keyword index
 	if idx.keywordIndexer != nil {
-		if err := idx.keywordIndexer.AddToInvertedIndex(ctx, processedChunks); err != nil {
-			logger.Error("Failed to add to keyword index", "error", err, "path", filePath)
+		if err := idx.keywordIndexer.AddToInvertedIndex(ctx, chunks); err != nil {
+			logger.Error("Failed to add to keyword index", "error", err, "files", len(files))
+		} else if err := idx.keywordIndexer.RemoveStaleFiles(ctx, stale); err != nil {
+			logger.Warn("Failed to remove stale keyword entries", "files", len(files), "error", err)
 		}
 	}
 
 	// Update dependency graph
 	if idx.graph != nil {
 		builder := graph.NewBuilderWithGraph(idx.graph)
-		builder.Build(ctx, processedChunks)
+		builder.Build(ctx, chunks)
+		idx.graph.RemoveStaleFiles(stale)
 	}
 
-	// Persist the hash so we can skip this file next time
-	if currentHash != "" {
+	for _, f := range files {
+		// Persist the hash so we can skip this file next time
 		idx.mu.Lock()
-		idx.fileHashes[filePath] = currentHash
+		if f.hash != "" {
+			idx.fileHashes[f.path] = f.hash
+		}
+		delete(idx.noChunks, f.path)
 		idx.mu.Unlock()
-	}
 
-	idx.metrics.recordFile(true, false)
-	idx.metrics.recordChunks(len(processedChunks))
+		idx.metrics.recordFile(true, false)
+		idx.metrics.recordChunks(len(f.chunks))
 
-	logger.Info("File indexed successfully",
-		"path", filePath,
-		"chunks", len(processedChunks),
-	)
-	return nil
+		logger.Info("File indexed successfully",
+			"path", f.path,
+			"chunks", len(f.chunks),
+		)
+	}
 }
 
 // Index handles both files and directories
@@ -290,95 +388,250 @@ func (idx *Indexer) Index(ctx context.Context, path string) error {
 	return idx.IndexFile(ctx, path)
 }
 
-// IndexDirectory indexes all files in a directory recursively with concurrent processing
+// IndexDirectory indexes all files in a directory recursively through the
+// staged indexing pipeline (see indexFiles)
 func (idx *Indexer) IndexDirectory(ctx context.Context, dirPath string) error {
+	if abs, err := filepath.Abs(dirPath); err == nil {
+		dirPath = abs
+	}
 	logger.Info("Indexing directory", "path", dirPath)
 
-	var filesToIndex []string
+	idx.filter.AddRoot(dirPath)
+
+	filesToIndex, err := idx.walkIndexable(dirPath)
+	if err != nil {
+		return err
+	}
+
+	failed := idx.indexFiles(ctx, filesToIndex, nil)
+	if failed > 0 {
+		logger.Warn("Some files failed to index", "failed_count", failed, "total", len(filesToIndex))
+	}
+
+	// Files that vanished since the last run (e.g. during a watcher overflow)
+	pruned := idx.pruneMissing(ctx, dirPath)
+
+	logger.Info("Directory indexing complete", "total_files", len(filesToIndex), "failed", failed, "pruned", pruned)
+	return nil
+}
+
+// walkIndexable lists the files under dirPath with a known language, skipping
+// filtered directories
+func (idx *Indexer) walkIndexable(dirPath string) ([]string, error) {
+	var files []string
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.IsDir() {
-			name := info.Name()
-			if strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" {
+			if idx.filter.SkipDir(path) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 		if LanguageDetector(path) != "unknown" {
-			filesToIndex = append(filesToIndex, path)
+			files = append(files, path)
 		}
 		return nil
 	})
-	if err != nil {
-		return err
-	}
+	return files, err
+}
 
-	numWorkers := idx.numWorkers
-	fileChan := make(chan string, len(filesToIndex))
-	errChan := make(chan error, len(filesToIndex))
-
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for filePath := range fileChan {
-				if err := idx.IndexFile(ctx, filePath); err != nil {
-					logger.Error("Failed to index file in directory", "path", filePath, "error", err)
-					errChan <- err
-				}
+// IndexBatch applies a coalesced set of file changes under root as a single
+// tracked job: deletions are removed from the index and everything else is
+// re-indexed through the worker pool. The returned job is a snapshot taken
+// when the batch finished.
+func (idx *Indexer) IndexBatch(ctx context.Context, root string, changes []FileChange) (*domain.IndexingJob, error) {
+	job := idx.startJob("batch", root, len(changes))
+	logger.Info("Indexing change batch", "job_id", job.ID, "root", root, "changes", len(changes))
+
+	var toIndex []string
+	for _, c := range changes {
+		switch {
+		case c.Event == FileEventDelete:
+			err := idx.DeleteFile(ctx, c.Path)
+			if err != nil {
+				logger.Warn("Failed to delete file in batch", "path", c.Path, "error", err)
 			}
-		}()
+			idx.advanceJob(job, err)
+		case LanguageDetector(c.Path) == "unknown":
+			idx.dropIndexed(ctx, c.Path)
+			idx.advanceJob(job, nil)
+		default:
+			toIndex = append(toIndex, c.Path)
+		}
 	}
 
-	for _, filePath := range filesToIndex {
-		fileChan <- filePath
-	}
-	close(fileChan)
-	wg.Wait()
-	close(errChan)
+	idx.indexFiles(ctx, toIndex, func(_ string, err error) { idx.advanceJob(job, err) })
 
-	var errs []error
-	for err := range errChan {
-		errs = append(errs, err)
+	snapshot := idx.finishJob(job, ctx.Err())
+	logger.Info("Change batch complete",
+		"job_id", snapshot.ID,
+		"files", snapshot.FilesDone,
+		"failed", snapshot.FilesFailed,
+		"duration", snapshot.FinishedAt.Sub(snapshot.StartedAt),
+	)
+	if ctx.Err() != nil {
+		return snapshot, errors.Wrap(ctx.Err(), errors.ErrorTypeInternal, "change batch interrupted")
 	}
+	return snapshot, nil
+}
 
-	if len(errs) > 0 {
-		logger.Warn("Some files failed to index", "failed_count", len(errs), "total", len(filesToIndex))
+// DeleteFile removes a file from the index. When filePath is a directory that
+// has been deleted or renamed away, every indexed file beneath it is removed.
+func (idx *Indexer) DeleteFile(ctx context.Context, filePath string) error {
+	prefix := filePath + string(filepath.Separator)
+	idx.mu.Lock()
+	var nested []string
+	for path := range idx.fileHashes {
+		if strings.HasPrefix(path, prefix) {
+			nested = append(nested, path)
+		}
 	}
+	idx.mu.Unlock()
 
-	logger.Info("Directory indexing complete", "total_files", len(filesToIndex), "failed", len(errs))
-	return nil
+	for _, path := range nested {
+		if err := idx.deleteOne(ctx, path); err != nil {
+			return err
+		}
+	}
+	if len(nested) > 0 {
+		logger.Info("Deleted directory from index", "path", filePath, "files", len(nested))
+		return nil
+	}
+	return idx.deleteOne(ctx, filePath)
 }
 
-// DeleteFile removes a file from the index
-func (idx *Indexer) DeleteFile(ctx context.Context, filePath string) error {
+func (idx *Indexer) deleteOne(ctx context.Context, filePath string) error {
 	logger.Info("Deleting file from index", "path", filePath)
 	idx.mu.Lock()
 	delete(idx.fileHashes, filePath)
+	delete(idx.noChunks, filePath)
 	idx.mu.Unlock()
+
+	if idx.keywordIndexer != nil {
+		if err := idx.keywordIndexer.RemoveStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath}}); err != nil {
+			logger.Warn("Failed to remove keyword entries", "path", filePath, "error", err)
+		}
+	}
+	if idx.graph != nil {
+		idx.graph.RemoveStale(filePath, 0)
+	}
 	return idx.store.Delete(ctx, filePath)
 }
 
-// GetJob returns the status of an indexing job
-func (idx *Indexer) GetJob(jobID string) (*domain.IndexingJob, error) {
+// dropIndexed removes a file that is no longer indexed from the index if an
+// earlier run indexed it, so its old chunks stop being served
+func (idx *Indexer) dropIndexed(ctx context.Context, filePath string) {
 	idx.mu.RLock()
-	defer idx.mu.RUnlock()
+	_, indexed := idx.fileHashes[filePath]
+	idx.mu.RUnlock()
+	if !indexed {
+		return
+	}
+	if err := idx.deleteOne(ctx, filePath); err != nil {
+		logger.Warn("Failed to remove file that is no longer indexed", "path", filePath, "error", err)
+	}
+}
 
-	job, ok := idx.jobs[jobID]
+// LoadIndexedFiles records the files the vector store already holds chunks
+// for, with the content hash they were indexed at, so directory deletes,
+// pruning and change detection work after a restart. Files whose chunks
+// carry no hash are re-indexed the next time they are seen. It returns the
+// number of files added.
+func (idx *Indexer) LoadIndexedFiles(ctx context.Context) (int, error) {
+	inv, ok := idx.store.(Inventory)
 	if !ok {
-		return nil, errors.NotFoundError("job not found")
+		return 0, errors.InternalError("vector store cannot be listed")
+	}
+	refs, err := inv.ListChunks(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeExternal, "failed to list vector store chunks")
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	added := 0
+	for _, ref := range refs {
+		if ref.FilePath == "" {
+			continue
+		}
+		if hash, seen := idx.fileHashes[ref.FilePath]; !seen {
+			idx.fileHashes[ref.FilePath] = ref.FileHash
+			added++
+		} else if hash == "" {
+			idx.fileHashes[ref.FilePath] = ref.FileHash
+		}
+	}
+	return added, nil
+}
+
+// pruneMissing deletes previously indexed files under dirPath that no longer
+// exist on disk, or that are no longer of a known language and so were not
+// walked. It returns the number of files removed.
+func (idx *Indexer) pruneMissing(ctx context.Context, dirPath string) int {
+	prefix := dirPath + string(filepath.Separator)
+	idx.mu.RLock()
+	var missing []string
+	for path := range idx.fileHashes {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) || LanguageDetector(path) == "unknown" {
+			missing = append(missing, path)
+		}
 	}
-	return job, nil
+	idx.mu.RUnlock()
+
+	for _, path := range missing {
+		if err := idx.deleteOne(ctx, path); err != nil {
+			logger.Warn("Failed to prune missing file", "path", path, "error", err)
+		}
+	}
+	return len(missing)
+}
+
+// FileFilter returns the file selection rules used by the indexer so the
+// Watcher can apply the same ones.
+func (idx *Indexer) FileFilter() *FileFilter {
+	return idx.filter
 }
 
 // Metrics returns the current indexing metrics snapshot
 func (idx *Indexer) Metrics() IndexMetrics {
 	idx.metrics.mu.Lock()
 	defer idx.metrics.mu.Unlock()
-	return *idx.metrics
+	return IndexMetrics{
+		FilesIndexed:  idx.metrics.FilesIndexed,
+		FilesSkipped:  idx.metrics.FilesSkipped,
+		FilesErrored:  idx.metrics.FilesErrored,
+		ChunksCreated: idx.metrics.ChunksCreated,
+		ChunksRetried: idx.metrics.ChunksRetried,
+		TotalDuration: idx.metrics.TotalDuration,
+		startTime:     idx.metrics.startTime,
+	}
+}
+
+// Status summarises tracked files, the last run's metrics and running jobs
+func (idx *Indexer) Status() domain.IndexStatus {
+	m := idx.Metrics()
+	status := domain.IndexStatus{
+		FilesIndexed:  m.FilesIndexed,
+		FilesSkipped:  m.FilesSkipped,
+		FilesErrored:  m.FilesErrored,
+		ChunksCreated: m.ChunksCreated,
+		DurationMS:    m.TotalDuration.Milliseconds(),
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	status.FilesTracked = len(idx.fileHashes)
+	for _, job := range idx.jobs {
+		if job.Status == domain.JobStatusRunning {
+			status.JobsRunning++
+		}
+	}
+	return status
 }
 
 // ---------------------------------------------------------------------------
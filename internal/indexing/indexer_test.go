@@ -2,8 +2,11 @@ package indexing
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/Guru2308/rag-code/internal/domain"
@@ -99,6 +102,57 @@ func TestIndexer_IndexFile_NoChunks(t *testing.T) {
 	}
 }
 
+func TestIndexer_IndexFile_DropsFilesNoLongerIndexed(t *testing.T) {
+	var deleted []string
+	var keywordGens []int64
+	mockStore := &mocks.MockChunkStore{
+		DeleteFunc: func(ctx context.Context, filePath string) error {
+			deleted = append(deleted, filePath)
+			return nil
+		},
+	}
+	mockKeyword := &mocks.MockKeywordIndexer{
+		RemoveStaleFilesFunc: func(ctx context.Context, files []domain.StaleFile) error {
+			for _, f := range files {
+				keywordGens = append(keywordGens, f.Generation)
+			}
+			return nil
+		},
+	}
+	mockParser := &mocks.MockParser{
+		ParseFunc: func(ctx context.Context, filePath string) ([]*domain.CodeChunk, error) {
+			return nil, nil
+		},
+	}
+	indexer := NewIndexer(mockParser, nil, nil, mockStore, mockKeyword, nil, 1)
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"empty.go": "package a\n", "vendor/lib.go": "package lib\n"})
+	emptied, ignored := filepath.Join(root, "empty.go"), filepath.Join(root, "vendor", "lib.go")
+	indexer.filter.AddRoot(root)
+	indexer.fileHashes[emptied] = "old"
+	indexer.fileHashes[ignored] = "old"
+
+	// Now yields no chunks, and is now filtered
+	for _, path := range []string{emptied, ignored} {
+		if err := indexer.IndexFile(context.Background(), path); err != nil {
+			t.Fatalf("IndexFile(%s) error = %v", path, err)
+		}
+	}
+	if !slices.Equal(deleted, []string{emptied, ignored}) || !slices.Equal(keywordGens, []int64{0, 0}) {
+		t.Errorf("deleted %v with keyword generations %v, want both files removed", deleted, keywordGens)
+	}
+	if _, ok := indexer.fileHashes[ignored]; ok {
+		t.Error("filtered file should no longer be tracked")
+	}
+
+	// Files that were never indexed are not deleted
+	deleted = nil
+	if err := indexer.IndexFile(context.Background(), ignored); err != nil || len(deleted) != 0 {
+		t.Errorf("IndexFile() = %v, deleted %v, want nothing to delete", err, deleted)
+	}
+}
+
 func TestIndexer_IndexDirectory(t *testing.T) {
 	mockParser := &mocks.MockParser{
 		ParseFunc: func(ctx context.Context, filePath string) ([]*domain.CodeChunk, error) {
@@ -215,3 +269,258 @@ func TestIndexer_GetJob_NotFound(t *testing.T) {
 		t.Error("GetJob() expected error for nonexistent job")
 	}
 }
+
+func TestIndexer_DeleteFile_Directory(t *testing.T) {
+	var deleted []string
+	mockStore := &mocks.MockChunkStore{
+		DeleteFunc: func(ctx context.Context, filePath string) error {
+			deleted = append(deleted, filePath)
+			return nil
+		},
+	}
+
+	indexer := NewIndexer(nil, nil, nil, mockStore, nil, nil, 1)
+	indexer.fileHashes["/repo/pkg/a.go"] = "x"
+	indexer.fileHashes["/repo/pkg/sub/b.go"] = "y"
+	indexer.fileHashes["/repo/pkgother/c.go"] = "z"
+
+	if err := indexer.DeleteFile(context.Background(), "/repo/pkg"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("Expected 2 files deleted under directory, got %v", deleted)
+	}
+	if _, ok := indexer.fileHashes["/repo/pkgother/c.go"]; !ok {
+		t.Error("Sibling directory with shared prefix should not be deleted")
+	}
+}
+
+func TestIndexer_DeleteFile_DirectoryAfterRestart(t *testing.T) {
+	// A new indexer over a populated store knows nothing about its files
+	// until they are loaded from the store
+	store := newMemInventory()
+	keyword := newMemInventory()
+	store.Store(context.Background(), []*domain.CodeChunk{
+		{ID: "1", FilePath: "/repo/pkg/a.go", Generation: 1},
+		{ID: "2", FilePath: "/repo/pkg/sub/b.go", Generation: 1},
+		{ID: "3", FilePath: "/repo/pkgother/c.go", Generation: 1},
+	})
+	keyword.AddToInvertedIndex(context.Background(), []*domain.CodeChunk{{ID: "2", FilePath: "/repo/pkg/sub/b.go", Generation: 1}})
+	indexer := NewIndexer(nil, nil, nil, store, keyword, nil, 1)
+
+	if n, err := indexer.LoadIndexedFiles(context.Background()); err != nil || n != 3 {
+		t.Fatalf("LoadIndexedFiles() = %d, %v, want 3 files", n, err)
+	}
+	if err := indexer.DeleteFile(context.Background(), "/repo/pkg"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if ids := append(store.idsOf("/repo/pkg/a.go"), store.idsOf("/repo/pkg/sub/b.go")...); len(ids) != 0 {
+		t.Errorf("chunks %v left under the deleted directory", ids)
+	}
+	if ids := keyword.idsOf("/repo/pkg/sub/b.go"); len(ids) != 0 {
+		t.Errorf("keyword entries %v left under the deleted directory", ids)
+	}
+	if ids := store.idsOf("/repo/pkgother/c.go"); len(ids) != 1 {
+		t.Error("sibling directory with shared prefix should not be deleted")
+	}
+}
+
+func TestIndexer_IndexDirectory_PrunesMissingFiles(t *testing.T) {
+	var deleted []string
+	mockStore := &mocks.MockChunkStore{
+		DeleteFunc: func(ctx context.Context, filePath string) error {
+			deleted = append(deleted, filePath)
+			return nil
+		},
+	}
+
+	indexer := NewIndexer(&mocks.MockParser{}, nil, nil, mockStore, nil, nil, 1)
+
+	tmpDir := t.TempDir()
+	gone := filepath.Join(tmpDir, "gone.go")
+	indexer.fileHashes[gone] = "stale"
+	// A script whose shebang was removed is no longer of a known language
+	script := filepath.Join(tmpDir, "deploy")
+	os.WriteFile(script, []byte("echo deploy\n"), 0o644)
+	indexer.fileHashes[script] = "stale"
+
+	if err := indexer.IndexDirectory(context.Background(), tmpDir); err != nil {
+		t.Fatalf("IndexDirectory() error = %v", err)
+	}
+	slices.Sort(deleted)
+	if !slices.Equal(deleted, []string{script, gone}) {
+		t.Errorf("Expected %s and %s to be pruned, got %v", gone, script, deleted)
+	}
+}
+
+func TestIndexer_IndexBatch(t *testing.T) {
+	var stored, deleted []string
+	var storeMu sync.Mutex
+	mockParser := &mocks.MockParser{
+		ParseFunc: func(ctx context.Context, filePath string) ([]*domain.CodeChunk, error) {
+			return []*domain.CodeChunk{{ID: filePath, Content: "test", FilePath: filePath}}, nil
+		},
+	}
+	mockChunker := &mocks.MockChunker{
+		ChunkFunc: func(ctx context.Context, chunks []*domain.CodeChunk, maxSize int) ([]*domain.CodeChunk, error) {
+			return chunks, nil
+		},
+	}
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedBatchFunc: func(ctx context.Context, texts []string) ([][]float32, error) {
+			return make([][]float32, len(texts)), nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		StoreFunc: func(ctx context.Context, chunks []*domain.CodeChunk) error {
+			storeMu.Lock()
+			defer storeMu.Unlock()
+			for _, c := range chunks {
+				stored = append(stored, c.FilePath)
+			}
+			return nil
+		},
+		DeleteFunc: func(ctx context.Context, filePath string) error {
+			storeMu.Lock()
+			defer storeMu.Unlock()
+			deleted = append(deleted, filePath)
+			return nil
+		},
+	}
+
+	indexer := NewIndexer(mockParser, mockChunker, mockEmbedder, mockStore, nil, nil, 2)
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.go":      "package a\n",
+		"b.go":      "package b\n",
+		"notes.xyz": "not code\n",
+	})
+	changes := []FileChange{
+		{Path: filepath.Join(root, "a.go"), Event: FileEventCreate},
+		{Path: filepath.Join(root, "b.go"), Event: FileEventModify},
+		{Path: filepath.Join(root, "notes.xyz"), Event: FileEventModify},
+		{Path: filepath.Join(root, "gone.go"), Event: FileEventDelete},
+	}
+
+	job, err := indexer.IndexBatch(context.Background(), root, changes)
+	if err != nil {
+		t.Fatalf("IndexBatch() error = %v", err)
+	}
+	if job.Status != domain.JobStatusCompleted || job.Kind != "batch" {
+		t.Errorf("job = %+v, want completed batch job", job)
+	}
+	if job.FilesTotal != 4 || job.FilesDone != 4 || job.Progress != 1 {
+		t.Errorf("job progress = %d/%d (%v), want 4/4", job.FilesDone, job.FilesTotal, job.Progress)
+	}
+	if len(stored) != 2 {
+		t.Errorf("Expected 2 files stored, got %v", stored)
+	}
+	if !slices.Contains(deleted, filepath.Join(root, "gone.go")) {
+		t.Errorf("Expected gone.go deleted, got %v", deleted)
+	}
+
+	got, err := indexer.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	if got.ID != job.ID || got.Status != domain.JobStatusCompleted {
+		t.Errorf("GetJob() = %+v", got)
+	}
+	if jobs := indexer.ListJobs(); len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("ListJobs() = %v", jobs)
+	}
+}
+
+func TestIndexer_JobHistoryIsCapped(t *testing.T) {
+	indexer := NewIndexer(nil, nil, nil, nil, nil, nil, 1)
+	for i := 0; i < maxJobHistory+10; i++ {
+		job := indexer.startJob("batch", "/repo", 0)
+		indexer.finishJob(job, nil)
+	}
+	if n := len(indexer.ListJobs()); n != maxJobHistory {
+		t.Errorf("ListJobs() returned %d jobs, want %d", n, maxJobHistory)
+	}
+}
+
+func TestIndexer_IndexFile_AtomicReindex(t *testing.T) {
+	var calls []string
+	var storedGen int64
+	storeErr := error(nil)
+	mockParser := &mocks.MockParser{
+		ParseFunc: func(ctx context.Context, filePath string) ([]*domain.CodeChunk, error) {
+			return []*domain.CodeChunk{{ID: "1", Content: "x", FilePath: filePath}}, nil
+		},
+	}
+	mockChunker := &mocks.MockChunker{
+		ChunkFunc: func(ctx context.Context, chunks []*domain.CodeChunk, maxSize int) ([]*domain.CodeChunk, error) {
+			return chunks, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		StoreFunc: func(ctx context.Context, chunks []*domain.CodeChunk) error {
+			calls = append(calls, "store")
+			storedGen = chunks[0].Generation
+			return storeErr
+		},
+		DeleteFunc: func(ctx context.Context, filePath string) error {
+			calls = append(calls, "delete")
+			return nil
+		},
+		DeleteStaleFilesFunc: func(ctx context.Context, files []domain.StaleFile) error {
+			calls = append(calls, "delete_stale")
+			if len(files) != 1 || files[0].Generation != storedGen {
+				t.Errorf("DeleteStaleFiles files = %v, want the stored generation %d", files, storedGen)
+			}
+			return nil
+		},
+	}
+	var keywordGen int64
+	mockKeyword := &mocks.MockKeywordIndexer{
+		RemoveStaleFilesFunc: func(ctx context.Context, files []domain.StaleFile) error {
+			keywordGen = files[0].Generation
+			return nil
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 1
+	indexer := NewIndexerWithConfig(mockParser, mockChunker, &mocks.MockEmbedder{}, mockStore, mockKeyword, nil, cfg)
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.go": "package a\n"})
+	path := filepath.Join(root, "a.go")
+
+	// A failed store must not remove the previous generation
+	storeErr = errors.New("qdrant down")
+	if err := indexer.IndexFile(context.Background(), path); err == nil {
+		t.Fatal("IndexFile() expected error")
+	}
+	if slices.Contains(calls, "delete") || slices.Contains(calls, "delete_stale") {
+		t.Errorf("failed reindex removed existing chunks: %v", calls)
+	}
+
+	calls = nil
+	storeErr = nil
+	if err := indexer.IndexFile(context.Background(), path); err != nil {
+		t.Fatalf("IndexFile() error = %v", err)
+	}
+	if !slices.Equal(calls, []string{"store", "delete_stale"}) {
+		t.Errorf("calls = %v, want store before delete_stale", calls)
+	}
+	if storedGen == 0 || keywordGen != storedGen {
+		t.Errorf("keyword RemoveStale generation = %d, want %d", keywordGen, storedGen)
+	}
+}
+
+func TestIndexer_NextGeneration_Increases(t *testing.T) {
+	indexer := NewIndexer(nil, nil, nil, nil, nil, nil, 1)
+	prev := indexer.nextGeneration()
+	for i := 0; i < 1000; i++ {
+		next := indexer.nextGeneration()
+		if next <= prev {
+			t.Fatalf("generation %d not greater than %d", next, prev)
+		}
+		prev = next
+	}
+}
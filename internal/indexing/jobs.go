@@ -0,0 +1,121 @@
+package indexing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// maxJobHistory caps how many jobs are kept; the oldest finished jobs are
+// dropped first.
+const maxJobHistory = 100
+
+// newJobID returns a short random job ID prefixed with the job kind.
+func newJobID(kind string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return kind + "-" + time.Now().Format("20060102150405.000000000")
+	}
+	return kind + "-" + hex.EncodeToString(b)
+}
+
+// startJob registers a running job over total files.
+func (idx *Indexer) startJob(kind, path string, total int) *domain.IndexingJob {
+	job := &domain.IndexingJob{
+		ID:         newJobID(kind),
+		Kind:       kind,
+		Path:       path,
+		Status:     domain.JobStatusRunning,
+		FilesTotal: total,
+		StartedAt:  time.Now(),
+	}
+	if total == 0 {
+		job.Progress = 1
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.jobs[job.ID] = job
+	idx.pruneJobsLocked()
+	return job
+}
+
+// advanceJob records one processed file.
+func (idx *Indexer) advanceJob(job *domain.IndexingJob, err error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	job.FilesDone++
+	if err != nil {
+		job.FilesFailed++
+	}
+	if job.FilesTotal > 0 {
+		job.Progress = float32(job.FilesDone) / float32(job.FilesTotal)
+	}
+}
+
+// finishJob marks the job completed, or failed when err is set, and returns a
+// snapshot of its final state.
+func (idx *Indexer) finishJob(job *domain.IndexingJob, err error) *domain.IndexingJob {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	job.FinishedAt = time.Now()
+	job.Status = domain.JobStatusCompleted
+	if err != nil {
+		job.Status = domain.JobStatusFailed
+		job.Error = err.Error()
+	}
+	snapshot := *job
+	return &snapshot
+}
+
+// pruneJobsLocked drops the oldest finished jobs beyond maxJobHistory.
+// Callers must hold idx.mu.
+func (idx *Indexer) pruneJobsLocked() {
+	if len(idx.jobs) <= maxJobHistory {
+		return
+	}
+	var finished []*domain.IndexingJob
+	for _, job := range idx.jobs {
+		if job.Status != domain.JobStatusRunning && job.Status != domain.JobStatusPending {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].StartedAt.Before(finished[j].StartedAt) })
+	for _, job := range finished {
+		if len(idx.jobs) <= maxJobHistory {
+			break
+		}
+		delete(idx.jobs, job.ID)
+	}
+}
+
+// GetJob returns the status of an indexing job
+func (idx *Indexer) GetJob(jobID string) (*domain.IndexingJob, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	job, ok := idx.jobs[jobID]
+	if !ok {
+		return nil, errors.NotFoundError("job not found")
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// ListJobs returns all tracked jobs, newest first.
+func (idx *Indexer) ListJobs() []*domain.IndexingJob {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	jobs := make([]*domain.IndexingJob, 0, len(idx.jobs))
+	for _, job := range idx.jobs {
+		snapshot := *job
+		jobs = append(jobs, &snapshot)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.After(jobs[j].StartedAt) })
+	return jobs
+}
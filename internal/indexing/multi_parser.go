@@ -19,6 +19,7 @@ var genericLanguages = map[string]bool{
 //
 //   - .go            → GoParser  (full AST, extracts functions/types/methods)
 //   - code languages → RegexParser (regex-based semantic extraction)
+//   - build / IaC     → RegexParser (Dockerfile stages, Make targets, Terraform blocks)
 //   - config/md/sql  → GenericParser (fixed-size line windows)
 type MultiParser struct {
 	goParser      *GoParser
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Guru2308/rag-code/internal/domain"
 	"github.com/Guru2308/rag-code/internal/errors"
@@ -216,8 +218,48 @@ func (p *GoParser) extractFunctionCalls(fn *ast.FuncDecl) []string {
 	return result
 }
 
-// LanguageDetector determines the programming language of a file based on its extension.
+// filenameLanguages maps well-known extensionless (or oddly-suffixed) build and
+// infrastructure files to a language. Keys are lower-case base names.
+var filenameLanguages = map[string]string{
+	"dockerfile":     "dockerfile",
+	"containerfile":  "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"jenkinsfile":    "groovy",
+	"cmakelists.txt": "cmake",
+	"vagrantfile":    "ruby",
+	"gemfile":        "ruby",
+	"rakefile":       "ruby",
+}
+
+// shebangInterpreters maps the interpreter named in a "#!" line to a language.
+var shebangInterpreters = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"ksh":     "shell",
+	"dash":    "shell",
+	"fish":    "shell",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ruby":    "ruby",
+	"php":     "php",
+	"lua":     "lua",
+	"groovy":  "groovy",
+}
+
+// LanguageDetector determines the programming language of a file. Well-known
+// build files (Dockerfile, Makefile, Jenkinsfile, CMakeLists.txt) are matched by
+// name, everything else by extension, and extensionless scripts by their
+// shebang line.
 func LanguageDetector(filePath string) string {
+	if lang := detectByFilename(filePath); lang != "" {
+		return lang
+	}
+
 	ext := strings.ToLower(filepath.Ext(filePath))
 
 	languageMap := map[string]string{
@@ -326,10 +368,126 @@ func LanguageDetector(filePath string) string {
 		".less":   "web",
 		".svelte": "web",
 		".vue":    "web",
+
+		// Infrastructure / build
+		".dockerfile": "dockerfile",
+		".mk":         "makefile",
+		".mak":        "makefile",
+		".tf":         "terraform",
+		".tfvars":     "terraform",
+		".hcl":        "hcl",
+		".gradle":     "groovy",
+		".groovy":     "groovy",
+		".cmake":      "cmake",
 	}
 
 	if lang, ok := languageMap[ext]; ok {
 		return lang
 	}
+	if ext == "" {
+		if lang := detectByShebang(filePath); lang != "" {
+			return lang
+		}
+	}
 	return "unknown"
 }
+
+// detectByFilename matches build files whose language is implied by their
+// name rather than their extension, including variants like "Dockerfile.dev".
+func detectByFilename(filePath string) string {
+	base := strings.ToLower(filepath.Base(filePath))
+	if lang, ok := filenameLanguages[base]; ok {
+		return lang
+	}
+	if strings.HasPrefix(base, "dockerfile.") || strings.HasPrefix(base, "containerfile.") {
+		return "dockerfile"
+	}
+	if strings.HasPrefix(base, "makefile.") {
+		return "makefile"
+	}
+	return ""
+}
+
+// shebangEntry is a cached shebang detection, valid while the file keeps the
+// same size and modification time.
+type shebangEntry struct {
+	size    int64
+	modTime time.Time
+	lang    string
+}
+
+// shebangCache remembers the shebang language of extensionless files so the
+// walk, the indexer and the parsers do not each reopen the same file.
+var shebangCache = struct {
+	sync.Mutex
+	entries map[string]shebangEntry
+}{entries: make(map[string]shebangEntry)}
+
+// detectByShebang returns the language named by an extensionless file's "#!"
+// line, reading the file only when it changed since the last call.
+func detectByShebang(filePath string) string {
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		shebangCache.Lock()
+		delete(shebangCache.entries, filePath)
+		shebangCache.Unlock()
+		return ""
+	}
+
+	shebangCache.Lock()
+	e, ok := shebangCache.entries[filePath]
+	shebangCache.Unlock()
+	if ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.lang
+	}
+
+	lang := readShebang(filePath)
+	shebangCache.Lock()
+	shebangCache.entries[filePath] = shebangEntry{size: info.Size(), modTime: info.ModTime(), lang: lang}
+	shebangCache.Unlock()
+	return lang
+}
+
+// readShebang reads the first line of a file and maps a "#!" interpreter
+// (e.g. "#!/usr/bin/env python3") to a language.
+func readShebang(filePath string) string {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, 128)
+	n, _ := f.Read(buf)
+	line := string(buf[:n])
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		// Skip env flags such as "-S"
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interp = filepath.Base(f)
+				break
+			}
+		}
+	}
+	if lang, ok := shebangInterpreters[interp]; ok {
+		return lang
+	}
+	// Versioned interpreters such as python3.12
+	if strings.HasPrefix(interp, "python") {
+		return "python"
+	}
+	return ""
+}
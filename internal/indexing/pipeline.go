@@ -0,0 +1,210 @@
+package indexing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// pipelineFlushInterval bounds how long a partial batch waits for more files
+// before it is sent on, so a slow parser never stalls embedding or storage.
+const pipelineFlushInterval = 200 * time.Millisecond
+
+// pipelineFile is a parsed and chunked file travelling through the pipeline.
+type pipelineFile struct {
+	path       string
+	hash       string
+	generation int64
+	chunks     []*domain.CodeChunk
+}
+
+// indexFiles indexes files through a staged pipeline and returns the number
+// of failures. onDone, when set, is called once per file.
+//
+//	parse (numWorkers) → batch → embed (embedWorkers) → batch → store (1)
+//
+// Stages are connected by bounded channels so a slow stage applies
+// backpressure to the ones before it. Chunks from many files are grouped up
+// to batchSize before each EmbedBatch and Store call, so throughput is bound
+// by the embedder rather than by per-file round trips.
+func (idx *Indexer) indexFiles(ctx context.Context, files []string, onDone func(path string, err error)) int {
+	var failed atomic.Int64
+	done := func(path string, err error) {
+		if err != nil {
+			failed.Add(1)
+			logger.Error("Failed to index file", "path", path, "error", err)
+		}
+		if onDone != nil {
+			onDone(path, err)
+		}
+	}
+
+	depth := 2 * idx.numWorkers
+	paths := make(chan string)
+	parsed := make(chan *pipelineFile, depth)
+	embedGroups := make(chan []*pipelineFile)
+	embedded := make(chan *pipelineFile, depth)
+	storeGroups := make(chan []*pipelineFile)
+
+	// Stage 1: filter, hash check, parse and chunk
+	var parseWG sync.WaitGroup
+	for i := 0; i < idx.numWorkers; i++ {
+		parseWG.Add(1)
+		go func() {
+			defer parseWG.Done()
+			for path := range paths {
+				file, err := idx.prepareFile(ctx, path)
+				if err != nil || file == nil {
+					done(path, err)
+					continue
+				}
+				parsed <- file
+			}
+		}()
+	}
+	go func() {
+		parseWG.Wait()
+		close(parsed)
+	}()
+
+	// Stage 2: embed groups of files concurrently
+	go idx.groupFiles(parsed, embedGroups)
+	var embedWG sync.WaitGroup
+	for i := 0; i < idx.embedWorkers; i++ {
+		embedWG.Add(1)
+		go func() {
+			defer embedWG.Done()
+			for group := range embedGroups {
+				idx.embedGroup(ctx, group, embedded, done)
+			}
+		}()
+	}
+	go func() {
+		embedWG.Wait()
+		close(embedded)
+	}()
+
+	// Stage 3: store groups in order and commit them
+	go idx.groupFiles(embedded, storeGroups)
+	storeDone := make(chan struct{})
+	go func() {
+		defer close(storeDone)
+		for group := range storeGroups {
+			idx.storeGroup(ctx, group, done)
+		}
+	}()
+
+	for _, path := range files {
+		paths <- path
+	}
+	close(paths)
+	<-storeDone
+
+	return int(failed.Load())
+}
+
+// groupFiles collects whole files from in until they hold at least batchSize
+// chunks, then sends them on as one group. Partial groups are flushed after
+// pipelineFlushInterval and when in is closed.
+func (idx *Indexer) groupFiles(in <-chan *pipelineFile, out chan<- []*pipelineFile) {
+	defer close(out)
+
+	ticker := time.NewTicker(pipelineFlushInterval)
+	defer ticker.Stop()
+
+	var group []*pipelineFile
+	chunks := 0
+	flush := func() {
+		if len(group) > 0 {
+			out <- group
+			group, chunks = nil, 0
+		}
+	}
+
+	for {
+		select {
+		case file, ok := <-in:
+			if !ok {
+				flush()
+				return
+			}
+			group = append(group, file)
+			chunks += len(file.chunks)
+			if chunks >= idx.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// embedGroup embeds every chunk in group and forwards the files. When the
+// combined call fails each file is retried alone so one bad file does not
+// fail its neighbours.
+func (idx *Indexer) embedGroup(ctx context.Context, group []*pipelineFile, out chan<- *pipelineFile, done func(string, error)) {
+	err := idx.embedChunksBatched(ctx, groupChunks(group))
+	if err == nil {
+		for _, f := range group {
+			out <- f
+		}
+		return
+	}
+	if len(group) == 1 {
+		idx.metrics.recordFile(false, true)
+		done(group[0].path, err)
+		return
+	}
+
+	for _, f := range group {
+		if err := idx.embedChunksBatched(ctx, f.chunks); err != nil {
+			idx.metrics.recordFile(false, true)
+			done(f.path, err)
+			continue
+		}
+		out <- f
+	}
+}
+
+// storeGroup upserts the new generation of every file in group, then commits
+// the files (dropping older generations). Like embedGroup it falls back to
+// per-file stores when the combined store fails; files that still fail keep
+// their previous chunks.
+func (idx *Indexer) storeGroup(ctx context.Context, group []*pipelineFile, done func(string, error)) {
+	stored := group
+	if err := idx.storeChunksBatched(ctx, groupChunks(group)); err != nil {
+		stored = nil
+		for _, f := range group {
+			if len(group) > 1 {
+				err = idx.storeChunksBatched(ctx, f.chunks)
+			}
+			if err != nil {
+				idx.metrics.recordFile(false, true)
+				done(f.path, err)
+				continue
+			}
+			stored = append(stored, f)
+		}
+	}
+	if len(stored) == 0 {
+		return
+	}
+
+	idx.commitFiles(ctx, stored)
+	for _, f := range stored {
+		done(f.path, nil)
+	}
+}
+
+// groupChunks flattens the chunks of a group of files.
+func groupChunks(group []*pipelineFile) []*domain.CodeChunk {
+	var chunks []*domain.CodeChunk
+	for _, f := range group {
+		chunks = append(chunks, f.chunks...)
+	}
+	return chunks
+}
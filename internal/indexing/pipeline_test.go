@@ -0,0 +1,169 @@
+package indexing
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/mocks"
+)
+
+// pipelineMocks returns a parser emitting one chunk per file plus an embedder
+// and store that record the size of every call.
+func pipelineMocks(failStore func(chunks []*domain.CodeChunk) bool) (*mocks.MockParser, *mocks.MockChunker, *mocks.MockEmbedder, *mocks.MockChunkStore, *[]int, *[]int) {
+	var mu sync.Mutex
+	var embedCalls, storeCalls []int
+
+	parser := &mocks.MockParser{
+		ParseFunc: func(ctx context.Context, filePath string) ([]*domain.CodeChunk, error) {
+			return []*domain.CodeChunk{{ID: filePath, Content: "x", FilePath: filePath}}, nil
+		},
+	}
+	chunker := &mocks.MockChunker{
+		ChunkFunc: func(ctx context.Context, chunks []*domain.CodeChunk, maxSize int) ([]*domain.CodeChunk, error) {
+			return chunks, nil
+		},
+	}
+	embedder := &mocks.MockEmbedder{
+		EmbedBatchFunc: func(ctx context.Context, texts []string) ([][]float32, error) {
+			mu.Lock()
+			embedCalls = append(embedCalls, len(texts))
+			mu.Unlock()
+			return make([][]float32, len(texts)), nil
+		},
+	}
+	store := &mocks.MockChunkStore{
+		StoreFunc: func(ctx context.Context, chunks []*domain.CodeChunk) error {
+			if failStore != nil && failStore(chunks) {
+				return fmt.Errorf("store rejected batch")
+			}
+			mu.Lock()
+			storeCalls = append(storeCalls, len(chunks))
+			mu.Unlock()
+			return nil
+		},
+	}
+	return parser, chunker, embedder, store, &embedCalls, &storeCalls
+}
+
+func TestIndexer_IndexFiles_BatchesAcrossFiles(t *testing.T) {
+	parser, chunker, embedder, store, embedCalls, storeCalls := pipelineMocks(nil)
+	var staleMu sync.Mutex
+	var deleteCalls, removeCalls []int
+	store.DeleteStaleFilesFunc = func(ctx context.Context, files []domain.StaleFile) error {
+		staleMu.Lock()
+		deleteCalls = append(deleteCalls, len(files))
+		staleMu.Unlock()
+		return nil
+	}
+	keyword := &mocks.MockKeywordIndexer{
+		RemoveStaleFilesFunc: func(ctx context.Context, files []domain.StaleFile) error {
+			staleMu.Lock()
+			removeCalls = append(removeCalls, len(files))
+			staleMu.Unlock()
+			return nil
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.NumWorkers = 4
+	cfg.BatchSize = 5
+	indexer := NewIndexerWithConfig(parser, chunker, embedder, store, keyword, nil, cfg)
+
+	root := t.TempDir()
+	files := make(map[string]string)
+	var paths []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("f%02d.go", i)
+		files[name] = fmt.Sprintf("package f%d\n", i)
+		paths = append(paths, filepath.Join(root, name))
+	}
+	writeTree(t, root, files)
+
+	var doneMu sync.Mutex
+	completed := 0
+	failed := indexer.indexFiles(context.Background(), paths, func(path string, err error) {
+		doneMu.Lock()
+		completed++
+		doneMu.Unlock()
+	})
+
+	if failed != 0 {
+		t.Errorf("indexFiles() failed = %d, want 0", failed)
+	}
+	if completed != len(paths) {
+		t.Errorf("onDone called %d times, want %d", completed, len(paths))
+	}
+
+	embedded, stored := 0, 0
+	for _, n := range *embedCalls {
+		embedded += n
+	}
+	for _, n := range *storeCalls {
+		stored += n
+	}
+	if embedded != 20 || stored != 20 {
+		t.Errorf("embedded %d and stored %d chunks, want 20 each", embedded, stored)
+	}
+	// One chunk per file: anything fewer than 20 calls means files were grouped
+	if len(*embedCalls) >= 20 || len(*storeCalls) >= 20 {
+		t.Errorf("expected cross-file batching, got %d embed and %d store calls", len(*embedCalls), len(*storeCalls))
+	}
+	// Older generations are dropped once per committed group, not per file
+	if len(deleteCalls) != len(*storeCalls) || len(removeCalls) != len(*storeCalls) {
+		t.Errorf("%d DeleteStaleFiles and %d RemoveStaleFiles calls, want one each per store call (%d)", len(deleteCalls), len(removeCalls), len(*storeCalls))
+	}
+	deletedFiles := 0
+	for _, n := range deleteCalls {
+		deletedFiles += n
+	}
+	if deletedFiles != 20 {
+		t.Errorf("DeleteStaleFiles covered %d files, want 20", deletedFiles)
+	}
+	if m := indexer.Metrics(); m.FilesIndexed != 20 {
+		t.Errorf("FilesIndexed = %d, want 20", m.FilesIndexed)
+	}
+}
+
+func TestIndexer_IndexFiles_IsolatesFailingFile(t *testing.T) {
+	parser, chunker, embedder, store, _, _ := pipelineMocks(func(chunks []*domain.CodeChunk) bool {
+		for _, c := range chunks {
+			if strings.HasSuffix(c.FilePath, "bad.go") {
+				return true
+			}
+		}
+		return false
+	})
+
+	cfg := DefaultConfig()
+	cfg.NumWorkers = 1
+	cfg.MaxRetries = 1
+	cfg.BatchSize = 10
+	indexer := NewIndexerWithConfig(parser, chunker, embedder, store, nil, nil, cfg)
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.go": "package a\n", "bad.go": "package bad\n", "c.go": "package c\n"})
+	paths := []string{filepath.Join(root, "a.go"), filepath.Join(root, "bad.go"), filepath.Join(root, "c.go")}
+
+	var mu sync.Mutex
+	results := make(map[string]error)
+	failed := indexer.indexFiles(context.Background(), paths, func(path string, err error) {
+		mu.Lock()
+		results[filepath.Base(path)] = err
+		mu.Unlock()
+	})
+
+	if failed != 1 {
+		t.Errorf("indexFiles() failed = %d, want 1", failed)
+	}
+	if results["bad.go"] == nil {
+		t.Error("bad.go should have failed")
+	}
+	if results["a.go"] != nil || results["c.go"] != nil {
+		t.Errorf("neighbouring files should succeed, got %v", results)
+	}
+}
@@ -129,6 +129,44 @@ var languagePatterns = map[string][]*regexp.Regexp{
 		regexp.MustCompile(`(?m)^\s*\(defrecord\s+\w+`),
 		regexp.MustCompile(`(?m)^\s*\(defmulti\s+\w+`),
 	},
+
+	// ── Infrastructure / build files ──────────────────────────────────────
+	"dockerfile": {
+		// Each build stage starts at a FROM instruction
+		regexp.MustCompile(`(?mi)^\s*FROM\s+\S+`),
+	},
+	"makefile": {
+		// Rule targets ("build:", "bin/app: main.go", "%.o: %.c"); skips
+		// special targets like .PHONY and variable assignments (":=", "::=")
+		regexp.MustCompile(`(?m)^[\w$%(][\w./%$(){}-]*(\s+[\w./%$(){}-]+)*\s*::?([^=]|$)`),
+		regexp.MustCompile(`(?m)^define\s+\w+`),
+	},
+	"terraform": {
+		regexp.MustCompile(`(?m)^(resource|data|module|variable|output|provider|locals|terraform|moved|import|check)\b[^{]*\{`),
+	},
+	"hcl": {
+		// Any top-level block: `job "web" {`, `source "amazon-ebs" "x" {`
+		regexp.MustCompile(`(?m)^[A-Za-z_][\w-]*(\s+("[^"]*"|[\w-]+))*\s*\{`),
+	},
+	"groovy": {
+		regexp.MustCompile(`(?m)^\s*stage\s*\(\s*['"]`),
+		regexp.MustCompile(`(?m)^\s*def\s+\w+\s*\(`),
+		regexp.MustCompile(`(?m)^\s*(tasks\.register|task)\s*\(?\s*['"]?\w+`),
+		regexp.MustCompile(`(?m)^(plugins|dependencies|repositories|android|allprojects|subprojects|buildscript|pipeline)\s*\{`),
+	},
+	"cmake": {
+		regexp.MustCompile(`(?mi)^\s*(function|macro)\s*\(\s*\w+`),
+		regexp.MustCompile(`(?mi)^\s*(project|add_executable|add_library|add_custom_target)\s*\(`),
+	},
+}
+
+// preambleLanguages keep the lines before the first semantic match as their
+// own chunk. Build files routinely put global ARGs / variables there.
+var preambleLanguages = map[string]bool{
+	"dockerfile": true,
+	"makefile":   true,
+	"cmake":      true,
+	"groovy":     true,
 }
 
 // chunkTypeForLanguage returns the best ChunkType for a matched pattern keyword.
@@ -192,7 +230,23 @@ func (p *RegexParser) Parse(ctx context.Context, filePath string) ([]*domain.Cod
 		return genericChunk(filePath, lang, string(content)), nil
 	}
 
-	chunks := make([]*domain.CodeChunk, 0, len(matchLines))
+	chunks := make([]*domain.CodeChunk, 0, len(matchLines)+1)
+	if preambleLanguages[lang] && matchLines[0] > 0 {
+		preamble := strings.Join(lines[:matchLines[0]], "\n")
+		if strings.TrimSpace(preamble) != "" {
+			chunks = append(chunks, &domain.CodeChunk{
+				ID:        chunkID(filePath, 1),
+				FilePath:  filePath,
+				Language:  lang,
+				Content:   preamble,
+				ChunkType: domain.ChunkTypeOther,
+				StartLine: 1,
+				EndLine:   matchLines[0],
+				Metadata:  map[string]string{"name": "preamble"},
+			})
+		}
+	}
+
 	for i, startLine := range matchLines {
 		// End of this chunk = start of next match (or EOF)
 		endLine := totalLines
@@ -210,18 +264,25 @@ func (p *RegexParser) Parse(ctx context.Context, filePath string) ([]*domain.Cod
 			continue
 		}
 
-		name := extractName(lines[startLine])
+		metadata := map[string]string{}
+		chunkType := chunkTypeForLine(lines[startLine])
+		if block, ok := describeInfraBlock(lang, lines[startLine]); ok {
+			metadata["name"] = block.name
+			metadata["block_type"] = block.kind
+			chunkType = block.chunkType
+		} else {
+			metadata["name"] = extractName(lines[startLine])
+		}
+
 		chunks = append(chunks, &domain.CodeChunk{
 			ID:        chunkID(filePath, startLine+1),
 			FilePath:  filePath,
 			Language:  lang,
 			Content:   chunkContent,
-			ChunkType: chunkTypeForLine(lines[startLine]),
+			ChunkType: chunkType,
 			StartLine: startLine + 1,
 			EndLine:   endLine,
-			Metadata: map[string]string{
-				"name": name,
-			},
+			Metadata:  metadata,
 		})
 	}
 
@@ -285,6 +346,110 @@ func extractName(line string) string {
 	return line
 }
 
+// infraBlock describes a semantic block in a build / infrastructure file.
+type infraBlock struct {
+	name      string           // e.g. "builder", "aws_instance.web", "test"
+	kind      string           // e.g. "stage", "resource", "target"
+	chunkType domain.ChunkType // stages/resources → class, targets/functions → function
+}
+
+var (
+	quotedArgRe  = regexp.MustCompile(`"([^"]*)"`)
+	groovyNameRe = regexp.MustCompile(`^\s*(?:stage|tasks\.register|task)\s*\(?\s*['"]?([\w .-]+?)['"]?\s*[,){]`)
+	cmakeArgsRe  = regexp.MustCompile(`^\s*(\w+)\s*\(\s*([^\s)]+)`)
+)
+
+// describeInfraBlock names the block that starts on line for infrastructure
+// languages. It returns false for every other language so callers fall back
+// to the generic extractName heuristics.
+func describeInfraBlock(lang, line string) (infraBlock, bool) {
+	trimmed := strings.TrimSpace(line)
+	fields := strings.Fields(trimmed)
+	if len(fields) == 0 {
+		return infraBlock{}, false
+	}
+
+	switch lang {
+	case "dockerfile":
+		// FROM [--platform=...] image [AS name]
+		name := ""
+		for i, f := range fields[1:] {
+			if strings.HasPrefix(f, "--") {
+				continue
+			}
+			name = f
+			if rest := fields[i+2:]; len(rest) >= 2 && strings.EqualFold(rest[0], "as") {
+				name = rest[1]
+			}
+			break
+		}
+		return infraBlock{name: name, kind: "stage", chunkType: domain.ChunkTypeClass}, true
+
+	case "makefile":
+		if fields[0] == "define" && len(fields) > 1 {
+			return infraBlock{name: fields[1], kind: "define", chunkType: domain.ChunkTypeFunction}, true
+		}
+		target := strings.TrimSpace(trimmed[:strings.Index(trimmed, ":")])
+		return infraBlock{name: target, kind: "target", chunkType: domain.ChunkTypeFunction}, true
+
+	case "terraform", "hcl":
+		kind := fields[0]
+		var labels []string
+		for _, m := range quotedArgRe.FindAllStringSubmatch(trimmed, -1) {
+			labels = append(labels, m[1])
+		}
+		// Terraform addresses: aws_instance.web, data.aws_ami.x, module.vpc, var.region
+		name := kind
+		if lang == "terraform" {
+			switch kind {
+			case "resource":
+				name = strings.Join(labels, ".")
+			case "data":
+				name = strings.Join(append([]string{"data"}, labels...), ".")
+			case "variable":
+				name = strings.Join(append([]string{"var"}, labels...), ".")
+			default:
+				if len(labels) > 0 {
+					name = kind + "." + strings.Join(labels, ".")
+				}
+			}
+		} else if len(labels) > 0 {
+			name = kind + "." + strings.Join(labels, ".")
+		}
+		return infraBlock{name: name, kind: kind, chunkType: domain.ChunkTypeClass}, true
+
+	case "groovy":
+		switch {
+		case fields[0] == "def":
+			return infraBlock{name: extractName(strings.TrimPrefix(trimmed, "def ")), kind: "function", chunkType: domain.ChunkTypeFunction}, true
+		case strings.HasPrefix(fields[0], "stage"):
+			if m := groovyNameRe.FindStringSubmatch(trimmed); m != nil {
+				return infraBlock{name: m[1], kind: "stage", chunkType: domain.ChunkTypeFunction}, true
+			}
+		case strings.HasPrefix(fields[0], "task"):
+			if m := groovyNameRe.FindStringSubmatch(trimmed); m != nil {
+				return infraBlock{name: m[1], kind: "task", chunkType: domain.ChunkTypeFunction}, true
+			}
+		}
+		block := strings.TrimRight(fields[0], "{")
+		return infraBlock{name: block, kind: "block", chunkType: domain.ChunkTypeOther}, true
+
+	case "cmake":
+		m := cmakeArgsRe.FindStringSubmatch(trimmed)
+		if m == nil {
+			return infraBlock{}, false
+		}
+		kind := strings.ToLower(m[1])
+		chunkType := domain.ChunkTypeClass
+		if kind == "function" || kind == "macro" {
+			chunkType = domain.ChunkTypeFunction
+		}
+		return infraBlock{name: m[2], kind: kind, chunkType: chunkType}, true
+	}
+
+	return infraBlock{}, false
+}
+
 // ─────────────────────────────────────────────
 // GenericParser
 // ─────────────────────────────────────────────
@@ -67,6 +67,20 @@ func TestLanguageDetector_Extended(t *testing.T) {
 		{"style.scss", "web"},
 		{"App.vue", "web"},
 		{"App.svelte", "web"},
+		// Infrastructure / build
+		{"Dockerfile", "dockerfile"},
+		{"Dockerfile.dev", "dockerfile"},
+		{"api.dockerfile", "dockerfile"},
+		{"Makefile", "makefile"},
+		{"GNUmakefile", "makefile"},
+		{"rules.mk", "makefile"},
+		{"main.tf", "terraform"},
+		{"prod.tfvars", "terraform"},
+		{"terragrunt.hcl", "hcl"},
+		{"Jenkinsfile", "groovy"},
+		{"build.gradle", "groovy"},
+		{"CMakeLists.txt", "cmake"},
+		{"toolchain.cmake", "cmake"},
 		// Unknown
 		{"binary.exe", "unknown"},
 		{"archive.zip", "unknown"},
@@ -82,6 +96,53 @@ func TestLanguageDetector_Extended(t *testing.T) {
 	}
 }
 
+func TestLanguageDetector_Shebang(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"deploy", "#!/bin/bash\necho hi\n", "shell"},
+		{"manage", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"serve", "#!/usr/bin/env -S node --no-warnings\n", "javascript"},
+		{"notes", "just some text\n", "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempFile(t, tt.name, tt.content)
+			if got := LanguageDetector(path); got != tt.expected {
+				t.Errorf("LanguageDetector(%q) = %q, want %q", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLanguageDetector_ShebangCached(t *testing.T) {
+	path := writeTempFile(t, "tool", "#!/bin/sh\necho hi\n")
+	if got := LanguageDetector(path); got != "shell" {
+		t.Fatalf("LanguageDetector = %q, want shell", got)
+	}
+
+	// An unchanged file is answered from the cache without being reread
+	shebangCache.Lock()
+	e := shebangCache.entries[path]
+	e.lang = "ruby"
+	shebangCache.entries[path] = e
+	shebangCache.Unlock()
+	if got := LanguageDetector(path); got != "ruby" {
+		t.Errorf("LanguageDetector on unchanged file = %q, want cached ruby", got)
+	}
+
+	// A rewritten file is detected again
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env python3\nprint('hi')\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := LanguageDetector(path); got != "python" {
+		t.Errorf("LanguageDetector after rewrite = %q, want python", got)
+	}
+}
+
 // ─────────────────────────────────────────────
 // RegexParser tests
 // ─────────────────────────────────────────────
@@ -245,6 +306,132 @@ cleanup
 	}
 }
 
+func TestRegexParser_Dockerfile(t *testing.T) {
+	code := `ARG GO_VERSION=1.24
+
+FROM golang:${GO_VERSION} AS builder
+WORKDIR /src
+RUN go build -o /app ./cmd/rag-server
+
+FROM --platform=linux/amd64 alpine:3.20
+COPY --from=builder /app /app
+ENTRYPOINT ["/app"]
+`
+	tmpFile := writeTempFile(t, "Dockerfile", code)
+	chunks, err := NewRegexParser().Parse(context.Background(), tmpFile)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks (preamble + 2 stages), got %d", len(chunks))
+	}
+	assertContainsName(t, chunks, "preamble")
+	assertContainsName(t, chunks, "builder")
+	assertContainsName(t, chunks, "alpine:3.20")
+	if chunks[1].Metadata["block_type"] != "stage" {
+		t.Errorf("Expected block_type 'stage', got %q", chunks[1].Metadata["block_type"])
+	}
+}
+
+func TestRegexParser_Makefile(t *testing.T) {
+	code := `GO := go
+BIN ?= bin/rag-server
+
+.PHONY: build test
+
+build:
+	$(GO) build -o $(BIN) ./cmd/rag-server
+
+test: build
+	$(GO) test ./...
+`
+	tmpFile := writeTempFile(t, "Makefile", code)
+	chunks, err := NewRegexParser().Parse(context.Background(), tmpFile)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	assertContainsName(t, chunks, "build")
+	assertContainsName(t, chunks, "test")
+	for _, c := range chunks {
+		if c.Metadata["name"] == "GO" || c.Metadata["name"] == ".PHONY" {
+			t.Errorf("Variable or special target %q should not start a chunk", c.Metadata["name"])
+		}
+	}
+}
+
+func TestRegexParser_Terraform(t *testing.T) {
+	code := `variable "region" {
+  default = "us-east-1"
+}
+
+resource "aws_instance" "web" {
+  ami = data.aws_ami.ubuntu.id
+}
+
+data "aws_ami" "ubuntu" {
+  most_recent = true
+}
+
+module "vpc" {
+  source = "./modules/vpc"
+}
+`
+	tmpFile := writeTempFile(t, "main.tf", code)
+	chunks, err := NewRegexParser().Parse(context.Background(), tmpFile)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 4 chunks, got %d", len(chunks))
+	}
+	assertContainsName(t, chunks, "var.region")
+	assertContainsName(t, chunks, "aws_instance.web")
+	assertContainsName(t, chunks, "data.aws_ami.ubuntu")
+	assertContainsName(t, chunks, "module.vpc")
+}
+
+func TestRegexParser_Jenkinsfile(t *testing.T) {
+	code := `pipeline {
+  agent any
+  stages {
+    stage('Build') {
+      steps { sh 'make build' }
+    }
+    stage("Test") {
+      steps { sh 'make test' }
+    }
+  }
+}
+`
+	tmpFile := writeTempFile(t, "Jenkinsfile", code)
+	chunks, err := NewRegexParser().Parse(context.Background(), tmpFile)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	assertContainsName(t, chunks, "Build")
+	assertContainsName(t, chunks, "Test")
+}
+
+func TestRegexParser_CMake(t *testing.T) {
+	code := `cmake_minimum_required(VERSION 3.20)
+project(demo)
+
+add_library(core src/core.cpp)
+
+function(add_demo_test name)
+  add_test(NAME ${name} COMMAND ${name})
+endfunction()
+`
+	tmpFile := writeTempFile(t, "CMakeLists.txt", code)
+	chunks, err := NewRegexParser().Parse(context.Background(), tmpFile)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	assertContainsName(t, chunks, "demo")
+	assertContainsName(t, chunks, "core")
+	assertContainsName(t, chunks, "add_demo_test")
+}
+
 func TestRegexParser_FallbackToGeneric(t *testing.T) {
 	// A Python file with no matching patterns should fall back to generic chunking
 	code := strings.Repeat("x = 1\n", 60) // 60 lines, no functions/classes
@@ -0,0 +1,385 @@
+package indexing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// Inventory is implemented by stores that Verify can enumerate and repair
+type Inventory interface {
+	ListChunks(ctx context.Context) ([]domain.ChunkRef, error)
+	RemoveChunks(ctx context.Context, ids []string) error
+}
+
+// VerifyOptions controls a Verify run
+type VerifyOptions struct {
+	// Repair deletes orphans and re-indexes missing and stale files
+	Repair bool
+	// SkipGraph leaves the in-memory graph out, for one-off processes whose
+	// graph was never built
+	SkipGraph bool
+}
+
+// Store names used in verify reports
+const (
+	verifyStoreVector     = "vector"
+	verifyStoreKeyword    = "keyword"
+	verifyStoreGraph      = "graph"
+	verifyStoreFilesystem = "filesystem"
+)
+
+// verifyStore is one store's view of the verified root
+type verifyStore struct {
+	name   string
+	files  map[string]*fileChunks // file path -> chunks
+	loose  []string               // chunk IDs with no file
+	remove func(ctx context.Context, ids []string) error
+}
+
+// fileChunks holds the chunks a store has for one file
+type fileChunks struct {
+	ids         []string
+	generations map[int64]bool
+	hash        string // file content hash recorded with the chunks, if any
+}
+
+// Verify compares the indexable files under root with what the vector store,
+// the keyword index and the dependency graph hold for them, and reports:
+//
+//   - orphans: entries for files that are gone or now excluded, and keyword
+//     or graph entries whose chunk is not in the vector store
+//   - missing: indexable files with no chunks (or no entries in one store)
+//   - stale: files changed since they were indexed, or whose generations
+//     disagree between stores
+//
+// With opts.Repair the orphans are removed and missing and stale files are
+// re-indexed. Files being indexed while Verify runs may show up as stale.
+func (idx *Indexer) Verify(ctx context.Context, root string, opts VerifyOptions) (*domain.VerifyReport, error) {
+	start := time.Now()
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "failed to stat path")
+	}
+	if !info.IsDir() {
+		return nil, errors.ValidationError("verify path must be a directory")
+	}
+	logger.Info("Verifying index", "root", root, "repair", opts.Repair)
+
+	idx.filter.AddRoot(root)
+	files, err := idx.walkIndexable(root)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to walk directory")
+	}
+	onDisk := make(map[string]bool, len(files))
+	for _, path := range files {
+		if idx.filter.ShouldIndex(path) && !isEmptyFile(path) {
+			onDisk[path] = true
+		}
+	}
+
+	stores, vectorIDs, err := idx.loadVerifyStores(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.VerifyReport{
+		Root:         root,
+		FilesOnDisk:  len(onDisk),
+		FilesIndexed: len(stores[0].files),
+		Chunks:       make(map[string]int, len(stores)),
+		Issues:       []domain.VerifyIssue{},
+	}
+	for _, s := range stores {
+		report.Chunks[s.name] = len(s.loose)
+		for _, fc := range s.files {
+			report.Chunks[s.name] += len(fc.ids)
+		}
+	}
+
+	idx.checkStores(report, stores, vectorIDs, onDisk)
+	idx.checkFiles(ctx, report, stores, onDisk)
+
+	if opts.Repair && len(report.Issues) > 0 {
+		report.RepairErrors = idx.repair(ctx, report, stores, onDisk)
+		report.Repaired = true
+	}
+
+	report.Duration = time.Since(start)
+	logger.Info("Verify complete",
+		"root", root,
+		"orphans", report.Count(domain.VerifyOrphan),
+		"missing", report.Count(domain.VerifyMissing),
+		"stale", report.Count(domain.VerifyStale),
+		"repaired", report.Repaired,
+		"duration", report.Duration,
+	)
+	return report, nil
+}
+
+// loadVerifyStores lists every store, keeping the chunks under root. The
+// vector store comes first; vectorIDs holds all of its chunk IDs in
+// canonical form.
+func (idx *Indexer) loadVerifyStores(ctx context.Context, root string, opts VerifyOptions) ([]*verifyStore, map[string]bool, error) {
+	vectorInv, ok := idx.store.(Inventory)
+	if !ok {
+		return nil, nil, errors.InternalError("vector store cannot be listed")
+	}
+	refs, err := vectorInv.ListChunks(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to list vector store chunks")
+	}
+	vectorIDs := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		vectorIDs[domain.CanonicalChunkID(ref.ID)] = true
+	}
+	stores := []*verifyStore{newVerifyStore(verifyStoreVector, root, refs, vectorInv.RemoveChunks)}
+
+	if keywordInv, ok := idx.keywordIndexer.(Inventory); ok {
+		refs, err := keywordInv.ListChunks(ctx)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to list keyword index documents")
+		}
+		stores = append(stores, newVerifyStore(verifyStoreKeyword, root, refs, keywordInv.RemoveChunks))
+	}
+
+	if idx.graph != nil && !opts.SkipGraph {
+		removeNodes := func(_ context.Context, ids []string) error {
+			idx.graph.RemoveNodes(ids)
+			return nil
+		}
+		stores = append(stores, newVerifyStore(verifyStoreGraph, root, idx.graph.ListChunks(), removeNodes))
+	}
+	return stores, vectorIDs, nil
+}
+
+func newVerifyStore(name, root string, refs []domain.ChunkRef, remove func(context.Context, []string) error) *verifyStore {
+	s := &verifyStore{name: name, files: make(map[string]*fileChunks), remove: remove}
+	prefix := root + string(filepath.Separator)
+	for _, ref := range refs {
+		switch {
+		case ref.FilePath == "":
+			s.loose = append(s.loose, ref.ID)
+		case strings.HasPrefix(ref.FilePath, prefix):
+			fc := s.files[ref.FilePath]
+			if fc == nil {
+				fc = &fileChunks{generations: make(map[int64]bool)}
+				s.files[ref.FilePath] = fc
+			}
+			fc.ids = append(fc.ids, ref.ID)
+			fc.generations[ref.Generation] = true
+			if ref.FileHash != "" {
+				fc.hash = ref.FileHash
+			}
+		}
+	}
+	return s
+}
+
+// checkStores reports orphaned and stale entries from each store's side.
+func (idx *Indexer) checkStores(report *domain.VerifyReport, stores []*verifyStore, vectorIDs map[string]bool, onDisk map[string]bool) {
+	vector := stores[0]
+	for _, s := range stores {
+		for _, path := range sortedKeys(s.files) {
+			fc := s.files[path]
+			if !onDisk[path] {
+				addIssue(report, domain.VerifyOrphan, s.name, path, fc.ids, orphanReason(path))
+				continue
+			}
+			if s == vector {
+				if len(fc.generations) > 1 {
+					addIssue(report, domain.VerifyStale, s.name, path, nil, "file has chunks from more than one generation")
+				}
+				continue
+			}
+			if unknown := notIn(fc.ids, vectorIDs); len(unknown) > 0 {
+				addIssue(report, domain.VerifyOrphan, s.name, path, unknown, "chunks are not in the vector store")
+			} else if vf := vector.files[path]; vf != nil && !sameGenerations(fc.generations, vf.generations) {
+				addIssue(report, domain.VerifyStale, s.name, path, nil, "generation differs from the vector store")
+			}
+		}
+		if s != vector {
+			if unknown := notIn(s.loose, vectorIDs); len(unknown) > 0 {
+				sort.Strings(unknown)
+				addIssue(report, domain.VerifyOrphan, s.name, "", unknown, "chunks have no source file and are not in the vector store")
+			}
+		}
+	}
+}
+
+// checkFiles reports indexable files that are missing from a store or have
+// changed since they were indexed. Changes are detected with the file hash
+// the vector store holds for the file, or the one recorded in memory for
+// chunks written without it.
+func (idx *Indexer) checkFiles(ctx context.Context, report *domain.VerifyReport, stores []*verifyStore, onDisk map[string]bool) {
+	for _, path := range sortedKeys(onDisk) {
+		vf := stores[0].files[path]
+		if vf == nil {
+			if !idx.yieldsNoChunks(ctx, path) {
+				addIssue(report, domain.VerifyMissing, stores[0].name, path, nil, "file has no chunks in the vector store")
+			}
+			continue
+		}
+		for _, s := range stores[1:] {
+			if s.files[path] == nil {
+				addIssue(report, domain.VerifyMissing, s.name, path, nil, "indexed file has no entries")
+			}
+		}
+
+		recorded := vf.hash
+		if recorded == "" {
+			idx.mu.RLock()
+			recorded = idx.fileHashes[path]
+			idx.mu.RUnlock()
+		}
+		if recorded != "" {
+			if current, err := hashFile(path); err == nil && current != recorded {
+				addIssue(report, domain.VerifyStale, verifyStoreFilesystem, path, nil, "file changed since it was indexed")
+			}
+		}
+	}
+}
+
+// yieldsNoChunks reports whether path, unchanged, produced no chunks when it
+// was last indexed. Files not recorded since the process started are parsed
+// to find out, and recorded if they yield none.
+func (idx *Indexer) yieldsNoChunks(ctx context.Context, path string) bool {
+	current, err := hashFile(path)
+	if err != nil {
+		return false
+	}
+	idx.mu.RLock()
+	recorded, seen := idx.noChunks[path]
+	idx.mu.RUnlock()
+	if seen {
+		return recorded == current
+	}
+	if idx.parser == nil {
+		return false
+	}
+	chunks, err := idx.parser.Parse(ctx, path)
+	if err != nil || len(chunks) > 0 {
+		return false
+	}
+	idx.mu.Lock()
+	idx.noChunks[path] = current
+	idx.mu.Unlock()
+	return true
+}
+
+// repair fixes the issues in report and returns the errors it ran into.
+// Files that are gone are deleted from every store, orphaned chunks of live
+// files are removed from their store, and missing or stale files are
+// re-indexed even if their content hash is unchanged.
+func (idx *Indexer) repair(ctx context.Context, report *domain.VerifyReport, stores []*verifyStore, onDisk map[string]bool) []string {
+	var errs []string
+	var errsMu sync.Mutex
+	byName := make(map[string]*verifyStore, len(stores))
+	for _, s := range stores {
+		byName[s.name] = s
+	}
+
+	deleted := make(map[string]bool)
+	reindex := make(map[string]bool)
+	for _, issue := range report.Issues {
+		switch {
+		case issue.Kind == domain.VerifyOrphan && issue.FilePath != "" && !onDisk[issue.FilePath]:
+			if deleted[issue.FilePath] {
+				continue
+			}
+			deleted[issue.FilePath] = true
+			if err := idx.deleteOne(ctx, issue.FilePath); err != nil {
+				errs = append(errs, issue.FilePath+": "+err.Error())
+			}
+		case issue.Kind == domain.VerifyOrphan:
+			if err := byName[issue.Store].remove(ctx, issue.ChunkIDs); err != nil {
+				errs = append(errs, issue.Store+": "+err.Error())
+			}
+		default:
+			reindex[issue.FilePath] = true
+		}
+	}
+
+	paths := sortedKeys(reindex)
+	idx.mu.Lock()
+	for _, path := range paths {
+		delete(idx.fileHashes, path)
+	}
+	idx.mu.Unlock()
+	idx.indexFiles(ctx, paths, func(path string, err error) {
+		if err != nil {
+			errsMu.Lock()
+			errs = append(errs, path+": "+err.Error())
+			errsMu.Unlock()
+		}
+	})
+
+	logger.Info("Verify repair complete", "deleted", len(deleted), "reindexed", len(paths), "errors", len(errs))
+	return errs
+}
+
+func addIssue(report *domain.VerifyReport, kind domain.VerifyIssueKind, store, path string, ids []string, reason string) {
+	report.Issues = append(report.Issues, domain.VerifyIssue{
+		Kind:     kind,
+		Store:    store,
+		FilePath: path,
+		ChunkIDs: ids,
+		Reason:   reason,
+	})
+}
+
+// orphanReason explains why an indexed file is not expected in the index.
+func orphanReason(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "file no longer exists"
+	}
+	return "file is excluded from indexing"
+}
+
+func isEmptyFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == 0
+}
+
+// notIn returns the IDs whose canonical form is not in set
+func notIn(ids []string, set map[string]bool) []string {
+	var out []string
+	for _, id := range ids {
+		if !set[domain.CanonicalChunkID(id)] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func sameGenerations(a, b map[int64]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for gen := range a {
+		if !b[gen] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
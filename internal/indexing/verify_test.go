@@ -0,0 +1,290 @@
+package indexing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/graph"
+)
+
+// memInventory is an in-memory chunk store and keyword index that can be
+// listed, so Verify has something to compare. With uuids set it lists chunk
+// IDs hyphenated, as Qdrant does.
+type memInventory struct {
+	mu     sync.Mutex
+	chunks map[string]domain.ChunkRef
+	uuids  bool
+}
+
+func newMemInventory() *memInventory {
+	return &memInventory{chunks: make(map[string]domain.ChunkRef)}
+}
+
+func (m *memInventory) Store(ctx context.Context, chunks []*domain.CodeChunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range chunks {
+		m.chunks[c.ID] = domain.ChunkRef{ID: c.ID, FilePath: c.FilePath, Generation: c.Generation, FileHash: c.FileHash}
+	}
+	return nil
+}
+
+func (m *memInventory) AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error {
+	return m.Store(ctx, chunks)
+}
+
+func (m *memInventory) Delete(ctx context.Context, filePath string) error {
+	return m.DeleteStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath}})
+}
+
+func (m *memInventory) DeleteStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range files {
+		for id, ref := range m.chunks {
+			if ref.FilePath == f.FilePath && (f.Generation == 0 || ref.Generation != f.Generation) {
+				delete(m.chunks, id)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memInventory) RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	return m.DeleteStaleFiles(ctx, files)
+}
+
+func (m *memInventory) Get(ctx context.Context, id string) (*domain.CodeChunk, error) {
+	return nil, nil
+}
+
+func (m *memInventory) Search(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+	return nil, nil
+}
+
+func (m *memInventory) ListChunks(ctx context.Context) ([]domain.ChunkRef, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refs := make([]domain.ChunkRef, 0, len(m.chunks))
+	for _, ref := range m.chunks {
+		if m.uuids && len(ref.ID) == 32 {
+			ref.ID = ref.ID[:8] + "-" + ref.ID[8:12] + "-" + ref.ID[12:16] + "-" + ref.ID[16:20] + "-" + ref.ID[20:]
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// idsOf returns the IDs of path's chunks
+func (m *memInventory) idsOf(path string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id, ref := range m.chunks {
+		if ref.FilePath == path {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *memInventory) RemoveChunks(ctx context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		delete(m.chunks, domain.CanonicalChunkID(id))
+	}
+	return nil
+}
+
+func findIssue(report *domain.VerifyReport, kind domain.VerifyIssueKind, store, path string) bool {
+	for _, issue := range report.Issues {
+		if issue.Kind == kind && issue.Store == store && issue.FilePath == path {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIndexer_Verify(t *testing.T) {
+	// Chunk IDs are generated by the real chunker, and the vector store lists
+	// them hyphenated like Qdrant
+	parser, _, embedder, _, _, _ := pipelineMocks(nil)
+	chunker := NewSemanticChunker(512, 50)
+	store := newMemInventory()
+	store.uuids = true
+	keyword := newMemInventory()
+	g := graph.NewGraph()
+	indexer := NewIndexerWithConfig(parser, chunker, embedder, store, keyword, g, DefaultConfig())
+
+	root := t.TempDir()
+	if abs, err := filepath.EvalSymlinks(root); err == nil {
+		root = abs
+	}
+	writeTree(t, root, map[string]string{
+		"a.go": "package a\n",
+		"b.go": "package b\n",
+		"c.go": "package c\n",
+	})
+	ctx := context.Background()
+	if err := indexer.IndexDirectory(ctx, root); err != nil {
+		t.Fatalf("IndexDirectory() error = %v", err)
+	}
+
+	report, err := indexer.Verify(ctx, root, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("fresh index should verify clean, got %+v", report.Issues)
+	}
+	if report.FilesOnDisk != 3 || report.FilesIndexed != 3 {
+		t.Errorf("files on disk/indexed = %d/%d, want 3/3", report.FilesOnDisk, report.FilesIndexed)
+	}
+
+	a, b, c, d := filepath.Join(root, "a.go"), filepath.Join(root, "b.go"), filepath.Join(root, "c.go"), filepath.Join(root, "d.go")
+	writeTree(t, root, map[string]string{"a.go": "package a\n\nfunc A() {}\n", "d.go": "package d\n"})
+	if err := os.Remove(c); err != nil {
+		t.Fatal(err)
+	}
+	keyword.chunks["ghost"] = domain.ChunkRef{ID: "ghost"}
+	g.RemoveNodes(keyword.idsOf(b))
+
+	report, err = indexer.Verify(ctx, root, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	checks := []struct {
+		kind  domain.VerifyIssueKind
+		store string
+		path  string
+	}{
+		{domain.VerifyOrphan, "vector", c},
+		{domain.VerifyOrphan, "keyword", c},
+		{domain.VerifyOrphan, "graph", c},
+		{domain.VerifyOrphan, "keyword", ""},
+		{domain.VerifyMissing, "vector", d},
+		{domain.VerifyMissing, "graph", b},
+		{domain.VerifyStale, "filesystem", a},
+	}
+	for _, tc := range checks {
+		if !findIssue(report, tc.kind, tc.store, tc.path) {
+			t.Errorf("expected %s issue in %s for %q, got %+v", tc.kind, tc.store, tc.path, report.Issues)
+		}
+	}
+	if len(report.Issues) != len(checks) {
+		t.Errorf("got %d issues, want %d: %+v", len(report.Issues), len(checks), report.Issues)
+	}
+	if report.Repaired {
+		t.Error("report should not be repaired without Repair")
+	}
+
+	report, err = indexer.Verify(ctx, root, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Verify(repair) error = %v", err)
+	}
+	if !report.Repaired || len(report.RepairErrors) != 0 {
+		t.Fatalf("repair failed: repaired=%v errors=%v", report.Repaired, report.RepairErrors)
+	}
+
+	report, err = indexer.Verify(ctx, root, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("index should be clean after repair, got %+v", report.Issues)
+	}
+	if ids := keyword.idsOf(b); len(ids) != 1 {
+		t.Errorf("b.go has keyword chunks %v, want 1", ids)
+	} else if _, ok := g.GetNode(ids[0]); !ok {
+		t.Error("repair should re-index b.go into the graph")
+	}
+}
+
+func TestIndexer_Verify_AfterRestart(t *testing.T) {
+	// notes.go holds only comments and yields no chunks
+	parser, chunker, embedder, _, _, _ := pipelineMocks(nil)
+	parse := parser.ParseFunc
+	parser.ParseFunc = func(ctx context.Context, filePath string) ([]*domain.CodeChunk, error) {
+		if filepath.Base(filePath) == "notes.go" {
+			return nil, nil
+		}
+		return parse(ctx, filePath)
+	}
+	store := newMemInventory()
+	keyword := newMemInventory()
+	root := t.TempDir()
+	if abs, err := filepath.EvalSymlinks(root); err == nil {
+		root = abs
+	}
+	writeTree(t, root, map[string]string{"a.go": "package a\n", "notes.go": "// notes\n"})
+	ctx := context.Background()
+	if err := NewIndexerWithConfig(parser, chunker, embedder, store, keyword, nil, DefaultConfig()).IndexDirectory(ctx, root); err != nil {
+		t.Fatalf("IndexDirectory() error = %v", err)
+	}
+
+	// A new process knows only what the stores hold
+	indexer := NewIndexerWithConfig(parser, chunker, embedder, store, keyword, nil, DefaultConfig())
+	a := filepath.Join(root, "a.go")
+	writeTree(t, root, map[string]string{"a.go": "package a\n\nfunc A() {}\n"})
+
+	report, err := indexer.Verify(ctx, root, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(report.Issues) != 1 || !findIssue(report, domain.VerifyStale, "filesystem", a) {
+		t.Errorf("expected only a.go to be stale, got %+v", report.Issues)
+	}
+
+	report, err = indexer.Verify(ctx, root, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("index should be clean after repair, got %+v", report.Issues)
+	}
+}
+
+func TestIndexer_Verify_SkipGraphAndStaleGenerations(t *testing.T) {
+	parser, chunker, embedder, _, _, _ := pipelineMocks(nil)
+	store := newMemInventory()
+	keyword := newMemInventory()
+	indexer := NewIndexerWithConfig(parser, chunker, embedder, store, keyword, graph.NewGraph(), DefaultConfig())
+
+	root := t.TempDir()
+	if abs, err := filepath.EvalSymlinks(root); err == nil {
+		root = abs
+	}
+	writeTree(t, root, map[string]string{"a.go": "package a\n"})
+	a := filepath.Join(root, "a.go")
+
+	// Chunks left by an interrupted reindex, and a keyword index that lags
+	store.chunks["a-old"] = domain.ChunkRef{ID: "a-old", FilePath: a, Generation: 1}
+	store.chunks[a] = domain.ChunkRef{ID: a, FilePath: a, Generation: 2}
+	keyword.chunks[a] = domain.ChunkRef{ID: a, FilePath: a, Generation: 1}
+
+	report, err := indexer.Verify(context.Background(), root, VerifyOptions{SkipGraph: true})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !findIssue(report, domain.VerifyStale, "vector", a) {
+		t.Errorf("expected mixed generations to be stale, got %+v", report.Issues)
+	}
+	if !findIssue(report, domain.VerifyStale, "keyword", a) {
+		t.Errorf("expected keyword generation mismatch to be stale, got %+v", report.Issues)
+	}
+	if _, ok := report.Chunks["graph"]; ok {
+		t.Error("graph should not be checked with SkipGraph")
+	}
+}
+
+func TestIndexer_Verify_InvalidPath(t *testing.T) {
+	indexer := NewIndexer(nil, nil, nil, newMemInventory(), nil, nil, 1)
+	if _, err := indexer.Verify(context.Background(), filepath.Join(t.TempDir(), "nope"), VerifyOptions{}); err == nil {
+		t.Error("Verify() expected error for missing path")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -21,13 +22,57 @@ type Watcher struct {
 	handler          ChangeHandler
 	mu               sync.RWMutex
 	debounceDuration time.Duration
-	pending          map[string]*time.Timer
+	pending          map[string]*pendingEvent
 	pendingMu        sync.Mutex
+	filter           *FileFilter
+	dirs             map[string]bool        // directories currently watched (fsnotify or polled)
+	polled           map[string]dirSnapshot // directories watched by polling
+	mode             WatchMode
+	pollInterval     time.Duration
+	heads            map[string]string // watch root -> git HEAD at the last sync
+
+	batchHandler   BatchHandler
+	burstThreshold int
+	burst          burstState // guarded by pendingMu
+}
+
+// burstState tracks recent event volume and the batch being coalesced.
+type burstState struct {
+	recent  []time.Time          // event times within burstWindow
+	active  bool                 // true while events are routed into changes
+	changes map[string]FileEvent // path -> merged event
+	timer   *time.Timer          // fires once the burst has gone quiet
+}
+
+const (
+	// burstWindow is the sliding window used to detect bursts.
+	burstWindow = time.Second
+	// burstQuietPeriod is how long a burst must be silent before it is flushed.
+	burstQuietPeriod = 2 * time.Second
+	// defaultBurstThreshold is the number of events within burstWindow that
+	// switches the watcher into batch mode.
+	defaultBurstThreshold = 50
+)
+
+// pendingEvent is a debounced event waiting for its timer to fire.
+type pendingEvent struct {
+	event FileEvent
+	timer *time.Timer
 }
 
 // ChangeHandler is called when files change
 type ChangeHandler func(ctx context.Context, path string, event FileEvent) error
 
+// FileChange is a single path change within a batch.
+type FileChange struct {
+	Path  string    `json:"path"`
+	Event FileEvent `json:"event"`
+}
+
+// BatchHandler is called once for a coalesced burst of changes (e.g. a
+// `git checkout` or `git pull`) under a single watch root.
+type BatchHandler func(ctx context.Context, root string, changes []FileChange) error
+
 // FileEvent represents a file system event
 type FileEvent string
 
@@ -35,11 +80,16 @@ const (
 	FileEventCreate FileEvent = "create"
 	FileEventModify FileEvent = "modify"
 	FileEventDelete FileEvent = "delete"
+	// FileEventRescan asks the handler to re-walk a watch root, e.g. after the
+	// kernel event queue overflowed and individual events were lost.
+	FileEventRescan FileEvent = "rescan"
 )
 
 // NewWatcher creates a new file system watcher.
 // debounceDuration controls how long to wait after the last event before
-// calling the handler. Use 0 to disable debouncing.
+// calling the handler. Use 0 to disable debouncing. When fsnotify cannot be
+// initialised (e.g. the inotify instance limit is reached) every directory is
+// polled instead.
 func NewWatcher(handler ChangeHandler, debounceDuration time.Duration) (*Watcher, error) {
 	if handler == nil {
 		return nil, errors.ValidationError("change handler cannot be nil")
@@ -47,7 +97,8 @@ func NewWatcher(handler ChangeHandler, debounceDuration time.Duration) (*Watcher
 
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to create file watcher")
+		logger.Warn("fsnotify unavailable, falling back to polling", "error", err)
+		w = nil
 	}
 
 	if debounceDuration <= 0 {
@@ -59,10 +110,41 @@ func NewWatcher(handler ChangeHandler, debounceDuration time.Duration) (*Watcher
 		paths:            make([]string, 0),
 		handler:          handler,
 		debounceDuration: debounceDuration,
-		pending:          make(map[string]*time.Timer),
+		pending:          make(map[string]*pendingEvent),
+		filter:           NewFileFilter(DefaultFilterConfig()),
+		dirs:             make(map[string]bool),
+		polled:           make(map[string]dirSnapshot),
+		mode:             WatchModeAuto,
+		pollInterval:     defaultPollInterval,
+		heads:            make(map[string]string),
 	}, nil
 }
 
+// SetBatchHandler enables burst coalescing. Once more than threshold events
+// arrive within a second, further events are collected into one batch that
+// is handed to handler after the burst goes quiet, instead of one debounced
+// ChangeHandler call per path. A threshold <= 0 uses the default of 50.
+func (w *Watcher) SetBatchHandler(handler BatchHandler, threshold int) {
+	if threshold <= 0 {
+		threshold = defaultBurstThreshold
+	}
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+	w.batchHandler = handler
+	w.burstThreshold = threshold
+}
+
+// SetFileFilter replaces the watcher's file selection rules. Pass the
+// Indexer's FileFilter so both apply the same rules.
+func (w *Watcher) SetFileFilter(filter *FileFilter) {
+	if filter == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.filter = filter
+}
+
 // AddPath adds a directory to watch, recursively including all subdirectories.
 // fsnotify does not support recursive watching natively, so we walk the tree.
 func (w *Watcher) AddPath(path string) error {
@@ -72,116 +154,470 @@ func (w *Watcher) AddPath(path string) error {
 
 	absPath, _ := filepath.Abs(path)
 
+	filter := w.fileFilter()
+	filter.AddRoot(absPath)
+
+	dirsAdded, _, err := w.addTree(absPath, false)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to walk watch path")
+	}
+
+	head, gitErr := gitHead(context.Background(), absPath)
+
 	w.mu.Lock()
-	defer w.mu.Unlock()
+	w.paths = append(w.paths, absPath)
+	if gitErr == nil {
+		w.heads[absPath] = head
+	}
+	dirsPolled := 0
+	for dir := range w.polled {
+		if dir == absPath || strings.HasPrefix(dir, absPath+string(filepath.Separator)) {
+			dirsPolled++
+		}
+	}
+	w.mu.Unlock()
+	logger.Info("Added watch path (recursive)",
+		"root", absPath,
+		"dirs_watched", dirsAdded,
+		"dirs_polled", dirsPolled,
+		"git", gitErr == nil,
+	)
+
+	return nil
+}
+
+// addTree registers root and every non-excluded subdirectory with fsnotify
+// (or the poller, see registerDir).
+// When collectFiles is set it also returns the regular files found, so that
+// files created before the watch was in place are not missed.
+func (w *Watcher) addTree(root string, collectFiles bool) (int, []string, error) {
+	filter := w.fileFilter()
 
 	var dirsAdded int
-	err := filepath.Walk(absPath, func(p string, info os.FileInfo, err error) error {
+	var files []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // skip unreadable paths
 		}
 		if !info.IsDir() {
+			if collectFiles {
+				files = append(files, p)
+			}
 			return nil
 		}
-		// Skip hidden dirs and common noise
-		name := info.Name()
-		if strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" {
+		// Skip ignored, hidden and excluded directories
+		if filter.SkipDir(p) {
 			return filepath.SkipDir
 		}
-		if err := w.watcher.Add(p); err != nil {
-			logger.Warn("Failed to watch directory", "path", p, "error", err)
-			return nil
+		if w.registerDir(p) {
+			dirsAdded++
 		}
-		dirsAdded++
 		return nil
 	})
-	if err != nil {
-		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to walk watch path")
-	}
-
-	w.paths = append(w.paths, absPath)
-	logger.Info("Added watch path (recursive)", "root", absPath, "dirs_watched", dirsAdded)
-
-	return nil
+	return dirsAdded, files, err
 }
 
 // Start begins watching for file changes
 func (w *Watcher) Start(ctx context.Context) error {
-	logger.Info("Starting file watcher", "paths", len(w.paths))
+	w.mu.RLock()
+	fsw, mode, interval := w.watcher, w.mode, w.pollInterval
+	w.mu.RUnlock()
+	logger.Info("Starting file watcher", "paths", len(w.paths), "mode", mode, "poll_interval", interval)
+
+	// Nil channels block forever, leaving only the poller when fsnotify is off
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if fsw != nil {
+		events, errs = fsw.Events, fsw.Errors
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("File watcher stopped")
-			return w.watcher.Close()
+			return w.closeFSNotify()
 
-		case event, ok := <-w.watcher.Events:
+		case <-ticker.C:
+			w.poll(ctx)
+
+		case event, ok := <-events:
 			if !ok {
 				return nil
 			}
 			w.handleEvent(ctx, event)
 
-		case err, ok := <-w.watcher.Errors:
+		case err, ok := <-errs:
 			if !ok {
 				return nil
 			}
+			if err == fsnotify.ErrEventOverflow {
+				logger.Warn("File watcher event queue overflowed, rescanning", "error", err)
+				w.rescan(ctx)
+				continue
+			}
 			logger.Error("File watcher error", "error", err)
 		}
 	}
 }
 
-// handleEvent processes a file system event with debouncing.
-// Rapid successive events for the same file are collapsed into one handler call.
+// handleEvent translates a raw fsnotify event and schedules the debounced
+// handler call.
+//
+//   - A created directory is registered recursively and its files reported as created.
+//   - Rename arrives on the old path (the new path gets its own Create), so it is
+//     treated as a delete; renamed or removed directories drop their watches.
+//   - Changes to ignore files only invalidate the filter's cached rules.
 func (w *Watcher) handleEvent(ctx context.Context, event fsnotify.Event) {
-	var fileEvent FileEvent
+	path := event.Name
+	filter := w.fileFilter()
 
+	var fileEvent FileEvent
 	switch {
-	case event.Op&fsnotify.Create == fsnotify.Create:
+	case event.Has(fsnotify.Create):
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			w.handleNewDir(ctx, path)
+			return
+		}
 		fileEvent = FileEventCreate
-	case event.Op&fsnotify.Write == fsnotify.Write:
+	case event.Has(fsnotify.Write):
 		fileEvent = FileEventModify
-	case event.Op&fsnotify.Remove == fsnotify.Remove:
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		if w.forgetDir(path) {
+			logger.Info("Watched directory removed or renamed", "path", path)
+		}
 		fileEvent = FileEventDelete
 	default:
-		return // Ignore rename/chmod etc.
+		return // Ignore chmod
+	}
+
+	// Edited ignore files change the rules for their directory
+	if filter.IsIgnoreFile(path) {
+		filter.Invalidate(filepath.Dir(path))
+		return
+	}
+
+	// Deletes always pass through so stale chunks are removed
+	if fileEvent != FileEventDelete && !filter.ShouldIndex(path) {
+		return
 	}
 
 	logger.Info("File event detected",
-		"path", event.Name,
+		"path", path,
 		"event", fileEvent,
 	)
+	w.schedule(ctx, path, fileEvent)
+}
 
-	path := event.Name
+// handleNewDir starts watching a newly created (or moved-in) directory tree and
+// reports every indexable file already inside it.
+func (w *Watcher) handleNewDir(ctx context.Context, dir string) {
+	filter := w.fileFilter()
+	if filter.SkipDir(dir) {
+		return
+	}
+
+	dirsAdded, files, err := w.addTree(dir, true)
+	if err != nil {
+		logger.Warn("Failed to walk new directory", "path", dir, "error", err)
+	}
+
+	scheduled := 0
+	for _, f := range files {
+		if filter.IsIgnoreFile(f) || !filter.ShouldIndex(f) {
+			continue
+		}
+		w.schedule(ctx, f, FileEventCreate)
+		scheduled++
+	}
+	logger.Info("Watching new directory", "path", dir, "dirs_watched", dirsAdded, "files", scheduled)
+}
+
+// forgetDir drops dir and all of its subdirectories from the watch set.
+// It reports whether dir was a watched directory.
+func (w *Watcher) forgetDir(dir string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.dirs[dir] {
+		return false
+	}
+	prefix := dir + string(filepath.Separator)
+	for d := range w.dirs {
+		if d != dir && !strings.HasPrefix(d, prefix) {
+			continue
+		}
+		delete(w.dirs, d)
+		if _, ok := w.polled[d]; ok {
+			delete(w.polled, d)
+		} else if w.watcher != nil {
+			// Removed directories are dropped by the kernel already; renamed ones are not
+			_ = w.watcher.Remove(d)
+		}
+	}
+	return true
+}
 
+// rescan re-registers every watch root and asks the handler to reconcile it.
+// Used when the kernel event queue overflowed and individual events are lost.
+func (w *Watcher) rescan(ctx context.Context) {
+	w.mu.RLock()
+	roots := append([]string(nil), w.paths...)
+	w.mu.RUnlock()
+
+	for _, root := range roots {
+		if _, _, err := w.addTree(root, false); err != nil {
+			logger.Warn("Failed to rescan watch path", "path", root, "error", err)
+		}
+		w.schedule(ctx, root, FileEventRescan)
+	}
+}
+
+// schedule debounces handler calls per path. Rapid successive events for the
+// same path are collapsed into one call whose event is merged with the
+// pending one (e.g. create followed by write is still reported as create).
+func (w *Watcher) schedule(ctx context.Context, path string, event FileEvent) {
 	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+
+	if event != FileEventRescan && w.inBurst(time.Now()) {
+		w.addToBatch(ctx, path, event)
+		return
+	}
+
 	// Cancel any existing timer for this path
-	if t, ok := w.pending[path]; ok {
-		t.Stop()
+	if prev, ok := w.pending[path]; ok {
+		prev.timer.Stop()
+		event = mergeEvents(prev.event, event)
 	}
-	// Schedule a new timer
-	w.pending[path] = time.AfterFunc(w.debounceDuration, func() {
+
+	pe := &pendingEvent{event: event}
+	pe.timer = time.AfterFunc(w.debounceDuration, func() {
 		w.pendingMu.Lock()
-		delete(w.pending, path)
+		if w.pending[path] == pe {
+			delete(w.pending, path)
+		}
 		w.pendingMu.Unlock()
 
-		if err := w.handler(ctx, path, fileEvent); err != nil {
+		if err := w.handler(ctx, path, pe.event); err != nil {
 			logger.Error("Failed to handle file event",
 				"path", path,
-				"event", fileEvent,
+				"event", pe.event,
 				"error", err,
 			)
 		}
 	})
+	w.pending[path] = pe
+}
+
+// inBurst records an event and reports whether events are currently being
+// coalesced into a batch. Crossing the threshold folds every still-pending
+// debounced path into the new batch. Callers must hold pendingMu.
+func (w *Watcher) inBurst(now time.Time) bool {
+	if w.batchHandler == nil {
+		return false
+	}
+	if w.burst.active {
+		return true
+	}
+
+	cutoff := now.Add(-burstWindow)
+	recent := w.burst.recent[:0]
+	for _, t := range w.burst.recent {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	w.burst.recent = append(recent, now)
+	if len(w.burst.recent) < w.burstThreshold {
+		return false
+	}
+
+	logger.Info("Change burst detected, coalescing into a batch", "events", len(w.burst.recent))
+	w.burst = burstState{active: true, changes: make(map[string]FileEvent)}
+	for path, pe := range w.pending {
+		// Timers that already fired are handled individually
+		if pe.event == FileEventRescan || !pe.timer.Stop() {
+			continue
+		}
+		w.burst.changes[path] = pe.event
+		delete(w.pending, path)
+	}
+	return true
+}
+
+// addToBatch merges an event into the active batch and pushes back the flush.
+// Callers must hold pendingMu.
+func (w *Watcher) addToBatch(ctx context.Context, path string, event FileEvent) {
+	if prev, ok := w.burst.changes[path]; ok {
+		event = mergeEvents(prev, event)
+	}
+	w.burst.changes[path] = event
+
+	if w.burst.timer != nil {
+		w.burst.timer.Stop()
+	}
+	quiet := burstQuietPeriod
+	if w.debounceDuration > quiet {
+		quiet = w.debounceDuration
+	}
+	w.burst.timer = time.AfterFunc(quiet, func() { w.flushBatch(ctx) })
+}
+
+// flushBatch hands the coalesced batch to the BatchHandler, one call per
+// watch root. Inside a git work tree the batch is completed with the files
+// changed between the previously seen HEAD and the current one, and every
+// entry is reconciled with what is actually on disk.
+func (w *Watcher) flushBatch(ctx context.Context) {
+	w.pendingMu.Lock()
+	changes := w.burst.changes
+	handler := w.batchHandler
+	w.burst = burstState{}
 	w.pendingMu.Unlock()
+
+	if len(changes) == 0 || handler == nil {
+		return
+	}
+
+	filter := w.fileFilter()
+	for root, group := range w.groupByRoot(changes) {
+		w.mergeGitDiff(ctx, root, group)
+
+		batch := make([]FileChange, 0, len(group))
+		for path, event := range group {
+			if filter.IsIgnoreFile(path) {
+				filter.Invalidate(filepath.Dir(path))
+				continue
+			}
+			// Trust the disk over the event stream
+			_, statErr := os.Stat(path)
+			switch {
+			case os.IsNotExist(statErr):
+				event = FileEventDelete
+			case event == FileEventDelete:
+				event = FileEventModify
+			}
+			if event != FileEventDelete && !filter.ShouldIndex(path) {
+				continue
+			}
+			batch = append(batch, FileChange{Path: path, Event: event})
+		}
+		sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path })
+
+		logger.Info("Flushing change batch", "root", root, "changes", len(batch))
+		if err := handler(ctx, root, batch); err != nil {
+			logger.Error("Failed to handle change batch", "root", root, "error", err)
+		}
+	}
+}
+
+// groupByRoot splits changes by the closest watch root containing each path.
+func (w *Watcher) groupByRoot(changes map[string]FileEvent) map[string]map[string]FileEvent {
+	w.mu.RLock()
+	roots := append([]string(nil), w.paths...)
+	w.mu.RUnlock()
+
+	groups := make(map[string]map[string]FileEvent)
+	for path, event := range changes {
+		root := filepath.Dir(path)
+		best := -1
+		for _, r := range roots {
+			if strings.HasPrefix(path, r+string(filepath.Separator)) && len(r) > best {
+				root, best = r, len(r)
+			}
+		}
+		if groups[root] == nil {
+			groups[root] = make(map[string]FileEvent)
+		}
+		groups[root][path] = event
+	}
+	return groups
+}
+
+// mergeGitDiff adds files changed between the last seen and the current HEAD
+// of root to changes. Roots outside a git work tree are left untouched.
+func (w *Watcher) mergeGitDiff(ctx context.Context, root string, changes map[string]FileEvent) {
+	head, err := gitHead(ctx, root)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	previous, tracked := w.heads[root]
+	w.heads[root] = head
+	w.mu.Unlock()
+
+	if !tracked || previous == head {
+		return
+	}
+
+	diff, err := gitDiffChanges(ctx, root, previous, head)
+	if err != nil {
+		logger.Warn("Failed to diff git HEADs, using file events only", "root", root, "error", err)
+		return
+	}
+
+	prefix := root + string(filepath.Separator)
+	for _, c := range diff {
+		if !strings.HasPrefix(c.Path, prefix) {
+			continue
+		}
+		if prev, ok := changes[c.Path]; ok {
+			changes[c.Path] = mergeEvents(prev, c.Event)
+		} else {
+			changes[c.Path] = c.Event
+		}
+	}
+	logger.Info("Merged git changes into batch", "root", root, "from", previous, "to", head, "files", len(diff))
+}
+
+// mergeEvents combines a pending event with a newer one for the same path.
+func mergeEvents(prev, next FileEvent) FileEvent {
+	switch {
+	case prev == FileEventRescan || next == FileEventRescan:
+		return FileEventRescan
+	case next == FileEventDelete:
+		return FileEventDelete
+	case prev == FileEventCreate && next == FileEventModify:
+		return FileEventCreate
+	case prev == FileEventDelete:
+		// Deleted and recreated (e.g. editors saving via rename) — content replaced
+		return FileEventModify
+	default:
+		return next
+	}
+}
+
+// fileFilter returns the current filter under the read lock.
+func (w *Watcher) fileFilter() *FileFilter {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.filter
 }
 
 // Stop stops the watcher and cancels all pending debounce timers.
 func (w *Watcher) Stop() error {
 	w.pendingMu.Lock()
-	for _, t := range w.pending {
-		t.Stop()
+	for _, pe := range w.pending {
+		pe.timer.Stop()
+	}
+	w.pending = make(map[string]*pendingEvent)
+	if w.burst.timer != nil {
+		w.burst.timer.Stop()
 	}
-	w.pending = make(map[string]*time.Timer)
+	w.burst = burstState{}
 	w.pendingMu.Unlock()
-	return w.watcher.Close()
+	return w.closeFSNotify()
+}
+
+// closeFSNotify releases the fsnotify watcher, if one is in use.
+func (w *Watcher) closeFSNotify() error {
+	w.mu.RLock()
+	fsw := w.watcher
+	w.mu.RUnlock()
+	if fsw == nil {
+		return nil
+	}
+	return fsw.Close()
 }
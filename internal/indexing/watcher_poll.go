@@ -0,0 +1,184 @@
+package indexing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchMode selects how the Watcher learns about changes.
+type WatchMode string
+
+const (
+	// WatchModeAuto uses fsnotify and polls only the directories it cannot
+	// register (network mounts, exhausted inotify limits, ...).
+	WatchModeAuto WatchMode = "auto"
+	// WatchModeFSNotify uses fsnotify only; directories that cannot be
+	// registered are not watched.
+	WatchModeFSNotify WatchMode = "fsnotify"
+	// WatchModePoll scans every directory for mtime/size changes.
+	WatchModePoll WatchMode = "poll"
+)
+
+// defaultPollInterval is how often polled directories are scanned.
+const defaultPollInterval = 2 * time.Second
+
+// fileStat is the part of a directory entry compared between polls.
+type fileStat struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// dirSnapshot maps entry names in one directory to their last seen state.
+type dirSnapshot map[string]fileStat
+
+// SetWatchMode selects between fsnotify, polling, or fsnotify with a polling
+// fallback (the default), and sets the polling interval. Call it before
+// AddPath. An interval <= 0 uses the default of 2s.
+func (w *Watcher) SetWatchMode(mode WatchMode, interval time.Duration) error {
+	switch mode {
+	case WatchModeAuto, WatchModeFSNotify, WatchModePoll:
+	default:
+		return errors.ValidationError("unknown watch mode: " + string(mode))
+	}
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if mode == WatchModeFSNotify && w.watcher == nil {
+		return errors.InternalError("fsnotify is unavailable on this system")
+	}
+	w.mode = mode
+	w.pollInterval = interval
+	// Release the inotify instance; it would never be used
+	if mode == WatchModePoll && w.watcher != nil {
+		_ = w.watcher.Close()
+		w.watcher = nil
+	}
+	return nil
+}
+
+// registerDir watches dir with fsnotify or, when that is not possible and the
+// mode allows it, by polling. Subdirectories of a polled directory are polled
+// too, since they usually live on the same mount. It reports whether dir is
+// now watched.
+func (w *Watcher) registerDir(dir string) bool {
+	w.mu.RLock()
+	mode, fsw := w.mode, w.watcher
+	_, parentPolled := w.polled[filepath.Dir(dir)]
+	w.mu.RUnlock()
+
+	if mode != WatchModePoll && fsw != nil && !parentPolled {
+		err := fsw.Add(dir)
+		if err == nil {
+			w.mu.Lock()
+			w.dirs[dir] = true
+			w.mu.Unlock()
+			return true
+		}
+		if mode == WatchModeFSNotify {
+			logger.Warn("Failed to watch directory", "path", dir, "error", err)
+			return false
+		}
+		logger.Warn("Failed to watch directory, falling back to polling", "path", dir, "error", err)
+	}
+
+	snapshot, err := readDirSnapshot(dir)
+	if err != nil {
+		logger.Warn("Failed to poll directory", "path", dir, "error", err)
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dirs[dir] = true
+	if _, ok := w.polled[dir]; !ok {
+		w.polled[dir] = snapshot
+	}
+	return true
+}
+
+// poll scans every polled directory once. Parents are scanned before their
+// children so a removed subtree is forgotten before it is visited.
+func (w *Watcher) poll(ctx context.Context) {
+	w.mu.RLock()
+	dirs := make([]string, 0, len(w.polled))
+	for dir := range w.polled {
+		dirs = append(dirs, dir)
+	}
+	w.mu.RUnlock()
+
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		w.pollDir(ctx, dir)
+	}
+}
+
+// pollDir diffs dir against its previous snapshot and feeds the differences
+// through handleEvent as if fsnotify had reported them.
+func (w *Watcher) pollDir(ctx context.Context, dir string) {
+	w.mu.RLock()
+	prev, ok := w.polled[dir]
+	w.mu.RUnlock()
+	if !ok {
+		return // forgotten earlier in this round
+	}
+
+	current, err := readDirSnapshot(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.handleEvent(ctx, fsnotify.Event{Name: dir, Op: fsnotify.Remove})
+		}
+		return
+	}
+
+	w.mu.Lock()
+	if _, ok := w.polled[dir]; ok {
+		w.polled[dir] = current
+	}
+	w.mu.Unlock()
+
+	for name, st := range current {
+		path := filepath.Join(dir, name)
+		old, existed := prev[name]
+		switch {
+		case !existed:
+			w.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case old.isDir != st.isDir:
+			w.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+			w.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case !st.isDir && (old.size != st.size || !old.modTime.Equal(st.modTime)):
+			w.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for name := range prev {
+		if _, ok := current[name]; !ok {
+			w.handleEvent(ctx, fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Remove})
+		}
+	}
+}
+
+// readDirSnapshot records the size, mtime and type of every entry in dir.
+func readDirSnapshot(dir string) (dirSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(dirSnapshot, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue // removed between ReadDir and Info
+		}
+		snapshot[e.Name()] = fileStat{size: info.Size(), modTime: info.ModTime(), isDir: info.IsDir()}
+	}
+	return snapshot, nil
+}
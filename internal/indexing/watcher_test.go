@@ -2,6 +2,7 @@ package indexing
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -188,3 +189,308 @@ func TestWatcher_HandleEvent_Create(t *testing.T) {
 		t.Error("Timed out waiting for file creation event")
 	}
 }
+
+func TestMergeEvents(t *testing.T) {
+	tests := []struct {
+		prev, next, want FileEvent
+	}{
+		{FileEventCreate, FileEventModify, FileEventCreate},
+		{FileEventModify, FileEventModify, FileEventModify},
+		{FileEventCreate, FileEventDelete, FileEventDelete},
+		{FileEventDelete, FileEventCreate, FileEventModify},
+		{FileEventModify, FileEventRescan, FileEventRescan},
+	}
+	for _, tt := range tests {
+		if got := mergeEvents(tt.prev, tt.next); got != tt.want {
+			t.Errorf("mergeEvents(%s, %s) = %s, want %s", tt.prev, tt.next, got, tt.want)
+		}
+	}
+}
+
+// startTestWatcher starts a watcher on a temp dir and returns the dir and a
+// channel of (path, event) pairs delivered to the handler.
+func startTestWatcher(t *testing.T, opts ...func(*Watcher)) (string, <-chan [2]string) {
+	t.Helper()
+	events := make(chan [2]string, 32)
+	handler := func(ctx context.Context, path string, event FileEvent) error {
+		events <- [2]string{path, string(event)}
+		return nil
+	}
+
+	watcher, err := NewWatcher(handler, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	t.Cleanup(func() { watcher.Stop() })
+	for _, opt := range opts {
+		opt(watcher)
+	}
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	if err := watcher.AddPath(tmpDir); err != nil {
+		t.Fatalf("AddPath() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go watcher.Start(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	return tmpDir, events
+}
+
+// waitForEvent drains events until every wanted (path, event) pair has
+// arrived, in any order, or the timeout expires.
+func waitForEvent(t *testing.T, events <-chan [2]string, pairs ...string) {
+	t.Helper()
+	want := make(map[[2]string]bool)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		want[[2]string{pairs[i], pairs[i+1]}] = true
+	}
+	timeout := time.After(3 * time.Second)
+	for len(want) > 0 {
+		select {
+		case got := <-events:
+			delete(want, got)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for events %v", want)
+		}
+	}
+}
+
+func TestWatcher_NewDirectoryIsWatched(t *testing.T) {
+	tmpDir, events := startTestWatcher(t)
+
+	// Simulate a checkout adding a nested package in one go
+	nested := filepath.Join(tmpDir, "pkg", "sub")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	early := filepath.Join(nested, "early.go")
+	if err := os.WriteFile(early, []byte("package sub\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitForEvent(t, events, early, string(FileEventCreate))
+
+	// Files created after registration must also be seen
+	time.Sleep(100 * time.Millisecond)
+	late := filepath.Join(nested, "late.go")
+	if err := os.WriteFile(late, []byte("package sub\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitForEvent(t, events, late, string(FileEventCreate))
+}
+
+func TestWatcher_RenameIsDeletePlusCreate(t *testing.T) {
+	tmpDir, events := startTestWatcher(t)
+
+	oldPath := filepath.Join(tmpDir, "old.go")
+	if err := os.WriteFile(oldPath, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitForEvent(t, events, oldPath, string(FileEventCreate))
+
+	newPath := filepath.Join(tmpDir, "new.go")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	waitForEvent(t, events,
+		oldPath, string(FileEventDelete),
+		newPath, string(FileEventCreate),
+	)
+}
+
+func TestWatcher_DirectoryRename(t *testing.T) {
+	tmpDir, events := startTestWatcher(t)
+
+	oldDir := filepath.Join(tmpDir, "olddir")
+	if err := os.Mkdir(oldDir, 0755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	newDir := filepath.Join(tmpDir, "newdir")
+	if err := os.Rename(oldDir, newDir); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	waitForEvent(t, events, oldDir, string(FileEventDelete))
+
+	// The moved directory is watched under its new name
+	time.Sleep(100 * time.Millisecond)
+	inside := filepath.Join(newDir, "a.go")
+	if err := os.WriteFile(inside, []byte("package a\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitForEvent(t, events, inside, string(FileEventCreate))
+}
+
+func TestWatcher_BurstIsCoalescedIntoBatch(t *testing.T) {
+	singles := make(chan string, 64)
+	watcher, err := NewWatcher(func(ctx context.Context, path string, event FileEvent) error {
+		singles <- path
+		return nil
+	}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	t.Cleanup(func() { watcher.Stop() })
+
+	batches := make(chan []FileChange, 4)
+	watcher.SetBatchHandler(func(ctx context.Context, root string, changes []FileChange) error {
+		batches <- changes
+		return nil
+	}, 5)
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	if err := watcher.AddPath(root); err != nil {
+		t.Fatalf("AddPath() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go watcher.Start(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%02d.go", i)] = "package f\n"
+	}
+	writeTree(t, root, files)
+
+	select {
+	case batch := <-batches:
+		seen := make(map[string]bool)
+		for _, c := range batch {
+			seen[c.Path] = true
+		}
+		individual := len(singles)
+		if len(seen)+individual < len(files) {
+			t.Errorf("batch (%d) + individual events (%d) should cover all %d files", len(seen), individual, len(files))
+		}
+		if len(seen) < len(files)/2 {
+			t.Errorf("expected most files in the batch, got %d", len(seen))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for batch")
+	}
+}
+
+func TestWatcher_MergeGitDiff(t *testing.T) {
+	root := initGitRepo(t, map[string]string{
+		"a.go": "package a\n",
+		"b.go": "package b\n",
+	})
+	watcher, err := NewWatcher(func(ctx context.Context, path string, event FileEvent) error { return nil }, time.Second)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+	if err := watcher.AddPath(root); err != nil {
+		t.Fatalf("AddPath() error = %v", err)
+	}
+
+	// Simulate a checkout whose file events were partly lost
+	writeTree(t, root, map[string]string{"a.go": "package a\n\nvar X = 1\n", "c.go": "package c\n"})
+	gitCommitAll(t, root, "second")
+
+	changes := map[string]FileEvent{filepath.Join(root, "c.go"): FileEventModify}
+	watcher.mergeGitDiff(context.Background(), root, changes)
+
+	if changes[filepath.Join(root, "a.go")] != FileEventModify {
+		t.Errorf("a.go missing from merged batch: %v", changes)
+	}
+	if changes[filepath.Join(root, "c.go")] != FileEventCreate {
+		t.Errorf("c.go = %q, want create", changes[filepath.Join(root, "c.go")])
+	}
+	if _, ok := changes[filepath.Join(root, "b.go")]; ok {
+		t.Error("unchanged b.go should not be in the batch")
+	}
+
+	// HEAD is now up to date, so a second merge adds nothing
+	again := map[string]FileEvent{}
+	watcher.mergeGitDiff(context.Background(), root, again)
+	if len(again) != 0 {
+		t.Errorf("second merge = %v, want empty", again)
+	}
+}
+
+func pollEvery(t *testing.T, interval time.Duration) func(*Watcher) {
+	return func(w *Watcher) {
+		if err := w.SetWatchMode(WatchModePoll, interval); err != nil {
+			t.Fatalf("SetWatchMode() error = %v", err)
+		}
+	}
+}
+
+func TestWatcher_SetWatchMode_Invalid(t *testing.T) {
+	watcher, err := NewWatcher(func(ctx context.Context, path string, event FileEvent) error { return nil }, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	if err := watcher.SetWatchMode("inotify", 0); err == nil {
+		t.Error("SetWatchMode() should reject unknown modes")
+	}
+	if err := watcher.SetWatchMode(WatchModePoll, 0); err != nil {
+		t.Fatalf("SetWatchMode(poll) error = %v", err)
+	}
+	if watcher.pollInterval != defaultPollInterval {
+		t.Errorf("pollInterval = %v, want default %v", watcher.pollInterval, defaultPollInterval)
+	}
+}
+
+func TestWatcher_PollMode(t *testing.T) {
+	tmpDir, events := startTestWatcher(t, pollEvery(t, 20*time.Millisecond))
+
+	file := filepath.Join(tmpDir, "poll.go")
+	if err := os.WriteFile(file, []byte("package poll\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitForEvent(t, events, file, string(FileEventCreate))
+
+	// Size change is detected even within the same mtime tick
+	if err := os.WriteFile(file, []byte("package poll\n\nfunc F() {}\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	waitForEvent(t, events, file, string(FileEventModify))
+
+	nested := filepath.Join(tmpDir, "pkg", "sub", "n.go")
+	writeTree(t, tmpDir, map[string]string{"pkg/sub/n.go": "package sub\n"})
+	waitForEvent(t, events, nested, string(FileEventCreate))
+
+	if err := os.RemoveAll(filepath.Join(tmpDir, "pkg")); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	waitForEvent(t, events, filepath.Join(tmpDir, "pkg"), string(FileEventDelete))
+
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	waitForEvent(t, events, file, string(FileEventDelete))
+}
+
+func TestWatcher_PollingChildrenOfPolledDirs(t *testing.T) {
+	watcher, err := NewWatcher(func(ctx context.Context, path string, event FileEvent) error { return nil }, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a/b/c.go": "package b\n"})
+
+	// Pretend fsnotify refused the root, as on a network mount
+	watcher.polled[root] = dirSnapshot{}
+	if !watcher.registerDir(filepath.Join(root, "a")) {
+		t.Fatal("registerDir() = false")
+	}
+	if _, ok := watcher.polled[filepath.Join(root, "a")]; !ok {
+		t.Error("subdirectory of a polled directory should be polled")
+	}
+}
@@ -31,6 +31,11 @@ func NewOllamaLLM(baseURL, model string) *OllamaLLM {
 	}
 }
 
+// Model returns the name of the model used for generation
+func (l *OllamaLLM) Model() string {
+	return l.model
+}
+
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 )
@@ -23,7 +24,8 @@ const (
 // Config holds logger configuration
 type Config struct {
 	Level  Level
-	Format string // "json" or "text"
+	Format string    // "json" or "text"
+	Output io.Writer // defaults to os.Stdout
 }
 
 // Init initializes the global logger
@@ -46,11 +48,16 @@ func Init(cfg Config) error {
 		Level: level,
 	}
 
+	out := cfg.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
 	var handler slog.Handler
 	if cfg.Format == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(out, opts)
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(out, opts)
 	}
 
 	defaultLogger = slog.New(handler)
@@ -28,6 +28,17 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestInit_Output(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Init(Config{Level: LevelInfo, Format: "json", Output: &buf}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	Info("to buffer", "key", "value")
+	if !bytes.Contains(buf.Bytes(), []byte(`"msg":"to buffer"`)) {
+		t.Errorf("expected log line in configured output, got %q", buf.String())
+	}
+}
+
 func TestGlobalLoggerWrappers(t *testing.T) {
 	// Initialize with a buffer to prevent stdout noise, though real impl writes to os.Stdout directly
 	// Since we can't easily swap out os.Stdout in parallel tests safely without race conditions or affecting other tests,
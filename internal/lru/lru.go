@@ -0,0 +1,57 @@
+// Package lru provides a fixed-size least-recently-used cache, used to keep
+// model outputs such as rerank scores and query transformations.
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a fixed-size LRU cache, safe for concurrent use
+type Cache[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// New returns a cache holding up to size entries
+func New[K comparable, V any](size int) *Cache[K, V] {
+	return &Cache[K, V]{size: size, order: list.New(), entries: make(map[K]*list.Element)}
+}
+
+// Get returns the value cached under key and marks it recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry[K, V]).value, true
+}
+
+// Put caches value under key, evicting the least recently used entry when
+// the cache is full
+func (c *Cache[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*entry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[K, V]).key)
+	}
+}
@@ -0,0 +1,28 @@
+package lru
+
+import "testing"
+
+func TestCache_Evicts(t *testing.T) {
+	c := New[string, float32](2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a")
+	c.Put("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry should be evicted")
+	}
+	if s, ok := c.Get("a"); !ok || s != 1 {
+		t.Errorf("Get(a) = %v, %v", s, ok)
+	}
+}
+
+func TestCache_PutReplaces(t *testing.T) {
+	c := New[string, []string](1)
+	c.Put("q", []string{"old"})
+	c.Put("q", []string{"new"})
+
+	if v, ok := c.Get("q"); !ok || len(v) != 1 || v[0] != "new" {
+		t.Errorf("Get(q) = %v, %v, want the replaced value", v, ok)
+	}
+}
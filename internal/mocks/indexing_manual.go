@@ -53,10 +53,12 @@ func (m *MockEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]floa
 
 // MockChunkStore implements indexing.ChunkStore
 type MockChunkStore struct {
-	StoreFunc  func(ctx context.Context, chunks []*domain.CodeChunk) error
-	DeleteFunc func(ctx context.Context, filePath string) error
-	GetFunc    func(ctx context.Context, id string) (*domain.CodeChunk, error)
-	SearchFunc func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error)
+	StoreFunc            func(ctx context.Context, chunks []*domain.CodeChunk) error
+	DeleteFunc           func(ctx context.Context, filePath string) error
+	DeleteStaleFilesFunc func(ctx context.Context, files []domain.StaleFile) error
+	GetFunc              func(ctx context.Context, id string) (*domain.CodeChunk, error)
+	SearchFunc           func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error)
+	ListChunksFunc       func(ctx context.Context) ([]domain.ChunkRef, error)
 }
 
 func (m *MockChunkStore) Store(ctx context.Context, chunks []*domain.CodeChunk) error {
@@ -73,6 +75,13 @@ func (m *MockChunkStore) Delete(ctx context.Context, filePath string) error {
 	return nil
 }
 
+func (m *MockChunkStore) DeleteStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	if m.DeleteStaleFilesFunc != nil {
+		return m.DeleteStaleFilesFunc(ctx, files)
+	}
+	return nil
+}
+
 func (m *MockChunkStore) Get(ctx context.Context, id string) (*domain.CodeChunk, error) {
 	if m.GetFunc != nil {
 		return m.GetFunc(ctx, id)
@@ -87,9 +96,17 @@ func (m *MockChunkStore) Search(ctx context.Context, vector []float32, limit int
 	return nil, nil
 }
 
+func (m *MockChunkStore) ListChunks(ctx context.Context) ([]domain.ChunkRef, error) {
+	if m.ListChunksFunc != nil {
+		return m.ListChunksFunc(ctx)
+	}
+	return nil, nil
+}
+
 // MockKeywordIndexer implements indexing.KeywordIndexer
 type MockKeywordIndexer struct {
 	AddToInvertedIndexFunc func(ctx context.Context, chunks []*domain.CodeChunk) error
+	RemoveStaleFilesFunc   func(ctx context.Context, files []domain.StaleFile) error
 }
 
 func (m *MockKeywordIndexer) AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error {
@@ -98,3 +115,10 @@ func (m *MockKeywordIndexer) AddToInvertedIndex(ctx context.Context, chunks []*d
 	}
 	return nil
 }
+
+func (m *MockKeywordIndexer) RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	if m.RemoveStaleFilesFunc != nil {
+		return m.RemoveStaleFilesFunc(ctx, files)
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 type MockKeywordSearcher struct {
 	SearchFunc             func(ctx context.Context, tokens []string, limit int) ([]string, error)
 	AddToInvertedIndexFunc func(ctx context.Context, chunks []*domain.CodeChunk) error
+	RemoveStaleFilesFunc   func(ctx context.Context, files []domain.StaleFile) error
 }
 
 func (m *MockKeywordSearcher) Search(ctx context.Context, tokens []string, limit int) ([]string, error) {
@@ -26,6 +27,13 @@ func (m *MockKeywordSearcher) AddToInvertedIndex(ctx context.Context, chunks []*
 	return nil
 }
 
+func (m *MockKeywordSearcher) RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	if m.RemoveStaleFilesFunc != nil {
+		return m.RemoveStaleFilesFunc(ctx, files)
+	}
+	return nil
+}
+
 // MockScorer implements retrieval.Scorer
 type MockScorer struct {
 	ScoreFunc func(ctx context.Context, queryTokens []string, docID string) (float64, error)
@@ -12,6 +12,7 @@ type MockQdrantClient struct {
 	DeleteFunc           func(ctx context.Context, in *qdrant.DeletePoints) (*qdrant.UpdateResult, error)
 	GetFunc              func(ctx context.Context, in *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error)
 	QueryFunc            func(ctx context.Context, in *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error)
+	ScrollAndOffsetFunc  func(ctx context.Context, in *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error)
 	CollectionExistsFunc func(ctx context.Context, collectionName string) (bool, error)
 	CreateCollectionFunc func(ctx context.Context, in *qdrant.CreateCollection) error
 }
@@ -44,6 +45,13 @@ func (m *MockQdrantClient) Query(ctx context.Context, in *qdrant.QueryPoints) ([
 	return nil, nil
 }
 
+func (m *MockQdrantClient) ScrollAndOffset(ctx context.Context, in *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	if m.ScrollAndOffsetFunc != nil {
+		return m.ScrollAndOffsetFunc(ctx, in)
+	}
+	return nil, nil, nil
+}
+
 func (m *MockQdrantClient) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
 	if m.CollectionExistsFunc != nil {
 		return m.CollectionExistsFunc(ctx, collectionName)
@@ -0,0 +1,208 @@
+package querylog
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// SummaryOptions controls a summary
+type SummaryOptions struct {
+	Since time.Time // only entries logged at or after this time
+	Limit int       // entries per list (default 10)
+}
+
+// Summary describes the logged queries
+type Summary struct {
+	Since             time.Time      `json:"since"`
+	Queries           int            `json:"queries"`
+	Errors            int            `json:"errors"`
+	ZeroResults       int            `json:"zero_results"` // queries that succeeded but returned nothing
+	MeanAnswerLength  float64        `json:"mean_answer_length"`
+	Latency           StageLatency   `json:"latency"` // end to end
+	TopQueries        []QueryCount   `json:"top_queries"`
+	ZeroResultQueries []QueryCount   `json:"zero_result_queries"`
+	SlowestStages     []StageLatency `json:"slowest_stages"` // by p95
+	TopFiles          []FileCount    `json:"top_files"`
+	Models            map[string]int `json:"models,omitempty"`
+}
+
+// QueryCount is how often a query was asked. Queries are grouped ignoring
+// case and whitespace; the first spelling seen is shown.
+type QueryCount struct {
+	Query    string    `json:"query"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// StageLatency summarizes the latency of one stage in milliseconds
+type StageLatency struct {
+	Stage  string  `json:"stage"`
+	Count  int     `json:"count"`
+	MeanMS float64 `json:"mean_ms"`
+	P50MS  float64 `json:"p50_ms"`
+	P95MS  float64 `json:"p95_ms"`
+	MaxMS  float64 `json:"max_ms"`
+}
+
+// FileCount is the number of queries a file was returned for
+type FileCount struct {
+	FilePath string `json:"file_path"`
+	Count    int    `json:"count"`
+}
+
+// Summarize aggregates entries. Queries whose text was omitted by
+// redaction are left out of the query lists but counted everywhere else.
+func Summarize(entries []*Entry, opts SummaryOptions) *Summary {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	s := &Summary{Since: opts.Since, Queries: len(entries), Models: make(map[string]int)}
+
+	top := newQueryCounter()
+	zero := newQueryCounter()
+	stages := make(map[string][]float64)
+	var total []float64
+	files := make(map[string]int)
+	answers, answerChars := 0, 0
+
+	for _, e := range entries {
+		total = append(total, e.TotalMS)
+		for stage, ms := range e.StagesMS {
+			stages[stage] = append(stages[stage], ms)
+		}
+		if e.Model != "" {
+			s.Models[e.Model]++
+		}
+		top.add(e)
+
+		switch {
+		case e.Error != "":
+			s.Errors++
+		case len(e.Results) == 0:
+			s.ZeroResults++
+			zero.add(e)
+		}
+		if e.Kind == "query" && e.Error == "" {
+			answers++
+			answerChars += e.AnswerLength
+		}
+
+		seen := make(map[string]bool, len(e.Results))
+		for _, r := range e.Results {
+			if !seen[r.FilePath] {
+				seen[r.FilePath] = true
+				files[r.FilePath]++
+			}
+		}
+	}
+
+	if answers > 0 {
+		s.MeanAnswerLength = round(float64(answerChars) / float64(answers))
+	}
+	s.Latency = latency("total", total)
+	s.TopQueries = top.top(limit)
+	s.ZeroResultQueries = zero.top(limit)
+
+	s.SlowestStages = make([]StageLatency, 0, len(stages))
+	for stage, ms := range stages {
+		s.SlowestStages = append(s.SlowestStages, latency(stage, ms))
+	}
+	sort.Slice(s.SlowestStages, func(i, j int) bool {
+		a, b := s.SlowestStages[i], s.SlowestStages[j]
+		if a.P95MS != b.P95MS {
+			return a.P95MS > b.P95MS
+		}
+		return a.Stage < b.Stage
+	})
+
+	s.TopFiles = make([]FileCount, 0, len(files))
+	for path, n := range files {
+		s.TopFiles = append(s.TopFiles, FileCount{FilePath: path, Count: n})
+	}
+	sort.Slice(s.TopFiles, func(i, j int) bool {
+		if s.TopFiles[i].Count != s.TopFiles[j].Count {
+			return s.TopFiles[i].Count > s.TopFiles[j].Count
+		}
+		return s.TopFiles[i].FilePath < s.TopFiles[j].FilePath
+	})
+	if len(s.TopFiles) > limit {
+		s.TopFiles = s.TopFiles[:limit]
+	}
+	return s
+}
+
+// queryCounter counts queries by their normalized text
+type queryCounter struct {
+	counts map[string]*QueryCount
+}
+
+func newQueryCounter() *queryCounter {
+	return &queryCounter{counts: make(map[string]*QueryCount)}
+}
+
+func (c *queryCounter) add(e *Entry) {
+	key := normalizeQuery(e.Query)
+	if key == "" {
+		return
+	}
+	qc, ok := c.counts[key]
+	if !ok {
+		qc = &QueryCount{Query: e.Query}
+		c.counts[key] = qc
+	}
+	qc.Count++
+	if e.Time.After(qc.LastSeen) {
+		qc.LastSeen = e.Time
+	}
+}
+
+// top returns the n most frequent queries, most recent first among equals
+func (c *queryCounter) top(n int) []QueryCount {
+	out := make([]QueryCount, 0, len(c.counts))
+	for _, qc := range c.counts {
+		out = append(out, *qc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if !out[i].LastSeen.Equal(out[j].LastSeen) {
+			return out[i].LastSeen.After(out[j].LastSeen)
+		}
+		return out[i].Query < out[j].Query
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+func latency(stage string, ms []float64) StageLatency {
+	l := StageLatency{Stage: stage, Count: len(ms)}
+	if len(ms) == 0 {
+		return l
+	}
+	sorted := append([]float64(nil), ms...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	l.MeanMS = round(sum / float64(len(sorted)))
+	l.P50MS = round(percentile(sorted, 0.50))
+	l.P95MS = round(percentile(sorted, 0.95))
+	l.MaxMS = round(sorted[len(sorted)-1])
+	return l
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,55 @@
+package querylog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+	entry := func(query string, files []string, stages map[string]float64, err string) *Entry {
+		e := &Entry{Kind: "query", Query: query, Time: now, StagesMS: stages, Error: err, Model: "llama", AnswerLength: 100}
+		for _, f := range files {
+			e.Results = append(e.Results, ResultEntry{FilePath: f})
+		}
+		return e
+	}
+	entries := []*Entry{
+		entry("token refresh", []string{"auth.go", "auth.go", "session.go"}, map[string]float64{"embed": 10, "generate": 900}, ""),
+		entry("Token  Refresh", []string{"auth.go"}, map[string]float64{"embed": 30, "generate": 1100}, ""),
+		entry("where is the cache", nil, map[string]float64{"embed": 20}, ""),
+		entry("broken", nil, map[string]float64{"embed": 5}, "retrieval failed"),
+	}
+	entries[2].Kind, entries[2].AnswerLength = "search", 0
+
+	s := Summarize(entries, SummaryOptions{Limit: 1})
+	if s.Queries != 4 || s.Errors != 1 || s.ZeroResults != 1 {
+		t.Errorf("counts = %d queries, %d errors, %d zero results", s.Queries, s.Errors, s.ZeroResults)
+	}
+	if len(s.TopQueries) != 1 || s.TopQueries[0].Query != "token refresh" || s.TopQueries[0].Count != 2 {
+		t.Errorf("unexpected top queries %+v", s.TopQueries)
+	}
+	if len(s.ZeroResultQueries) != 1 || s.ZeroResultQueries[0].Query != "where is the cache" {
+		t.Errorf("unexpected zero-result queries %+v", s.ZeroResultQueries)
+	}
+	// auth.go counts once per query
+	if len(s.TopFiles) != 1 || s.TopFiles[0] != (FileCount{FilePath: "auth.go", Count: 2}) {
+		t.Errorf("unexpected top files %+v", s.TopFiles)
+	}
+	if len(s.SlowestStages) != 2 || s.SlowestStages[0].Stage != "generate" || s.SlowestStages[0].P95MS != 1100 {
+		t.Errorf("unexpected stages %+v", s.SlowestStages)
+	}
+	if embed := s.SlowestStages[1]; embed.Count != 4 || embed.MeanMS != 16.25 || embed.P50MS != 10 || embed.MaxMS != 30 {
+		t.Errorf("unexpected embed latency %+v", embed)
+	}
+	if s.MeanAnswerLength != 100 || s.Models["llama"] != 4 {
+		t.Errorf("MeanAnswerLength = %v, Models = %v", s.MeanAnswerLength, s.Models)
+	}
+}
+
+func TestSummarize_Empty(t *testing.T) {
+	s := Summarize(nil, SummaryOptions{})
+	if s.Queries != 0 || s.TopQueries == nil || s.SlowestStages == nil || s.TopFiles == nil {
+		t.Errorf("empty summary should have empty lists: %+v", s)
+	}
+}
@@ -0,0 +1,105 @@
+package querylog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// FileSink appends entries to a JSON Lines file. When the file would grow
+// past maxBytes it is moved to path.1, replacing the previous one, and a new
+// file is started, so at most two files' worth of entries are kept.
+type FileSink struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+}
+
+// NewFileSink creates a sink writing to path (default maxBytes 64 MiB)
+func NewFileSink(path string, maxBytes int64) *FileSink {
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	return &FileSink{path: path, maxBytes: maxBytes}
+}
+
+// Append writes e as one line
+func (s *FileSink) Append(ctx context.Context, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal query log entry")
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, err := os.Stat(s.path); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > s.maxBytes {
+		if err := os.Rename(s.path, s.rotatedPath()); err != nil {
+			return errors.Wrap(err, errors.ErrorTypeInternal, "failed to rotate query log")
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to open query log")
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to write query log")
+	}
+	return nil
+}
+
+// Read returns the entries logged at or after since from the rotated and
+// current files
+func (s *FileSink) Read(ctx context.Context, since time.Time) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []*Entry
+	for _, path := range []string{s.rotatedPath(), s.path} {
+		if err := readFile(path, since, &entries); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func (s *FileSink) rotatedPath() string {
+	return s.path + ".1"
+}
+
+func readFile(path string, since time.Time, entries *[]*Entry) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to open query log")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			logger.Warn("Skipping unreadable query log line", "path", path, "line", line, "error", err)
+			continue
+		}
+		if !e.Time.Before(since) {
+			*entries = append(*entries, &e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to read query log")
+	}
+	return nil
+}
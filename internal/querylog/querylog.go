@@ -0,0 +1,134 @@
+// Package querylog records every query and search with its results, stage
+// latencies and outcome, and summarizes the log for analytics.
+package querylog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/logger"
+)
+
+// Entry is one logged query or search
+type Entry struct {
+	ID           string             `json:"id"`
+	Time         time.Time          `json:"time"`
+	Kind         string             `json:"kind"` // "query", "search", "chat" or "agent"
+	Query        string             `json:"query"`
+	Language     string             `json:"language,omitempty"`
+	FilePath     string             `json:"file_path,omitempty"`
+	Filters      map[string]string  `json:"filters,omitempty"`
+	MaxResults   int                `json:"max_results,omitempty"`
+	Skipped      []string           `json:"skipped,omitempty"` // pipeline stages turned off by the request
+	Partial      []string           `json:"partial,omitempty"` // pipeline stages cut short by their deadline
+	Results      []ResultEntry      `json:"results"`
+	StagesMS     map[string]float64 `json:"stages_ms,omitempty"`
+	TotalMS      float64            `json:"total_ms"`
+	Model        string             `json:"model,omitempty"`
+	AnswerLength int                `json:"answer_length,omitempty"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// ResultEntry is one returned chunk
+type ResultEntry struct {
+	ChunkID        string  `json:"chunk_id"`
+	FilePath       string  `json:"file_path"`
+	Rank           int     `json:"rank"` // 0 for expanded context
+	FusionScore    float32 `json:"fusion_score,omitempty"`
+	RelevanceScore float32 `json:"relevance_score"`
+}
+
+// Sink stores entries
+type Sink interface {
+	Append(ctx context.Context, e *Entry) error
+	// Read returns the entries logged at or after since, oldest first
+	Read(ctx context.Context, since time.Time) ([]*Entry, error)
+}
+
+// NewID returns a new random query ID
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("q-%x", time.Now().UnixNano())
+	}
+	return "q-" + hex.EncodeToString(b)
+}
+
+// NewEntry starts the entry of a request received at start
+func NewEntry(id, kind string, query domain.SearchQuery, start time.Time) *Entry {
+	e := &Entry{
+		ID:         id,
+		Time:       start,
+		Kind:       kind,
+		Query:      query.Query,
+		Language:   query.Language,
+		FilePath:   query.FilePath,
+		Filters:    query.Filters,
+		MaxResults: query.MaxResults,
+		Results:    []ResultEntry{},
+	}
+	if query.SkipRerank {
+		e.Skipped = append(e.Skipped, "rerank")
+	}
+	if query.SkipHierarchy {
+		e.Skipped = append(e.Skipped, "hierarchy")
+	}
+	if query.SkipExpansion {
+		e.Skipped = append(e.Skipped, "expansion")
+	}
+	if query.SkipExact {
+		e.Skipped = append(e.Skipped, "exact")
+	}
+	return e
+}
+
+// SetResults records the returned chunks
+func (e *Entry) SetResults(results []*domain.SearchResult) {
+	e.Results = make([]ResultEntry, 0, len(results))
+	for _, res := range results {
+		if res.Chunk == nil {
+			continue
+		}
+		e.Results = append(e.Results, ResultEntry{
+			ChunkID:        res.Chunk.ID,
+			FilePath:       res.Chunk.FilePath,
+			Rank:           res.Rank,
+			FusionScore:    res.FusionScore,
+			RelevanceScore: res.RelevanceScore,
+		})
+	}
+}
+
+// Logger redacts entries and writes them to a sink
+type Logger struct {
+	sink     Sink
+	redactor *Redactor
+}
+
+// New creates a query logger. A nil redactor logs entries unchanged.
+func New(sink Sink, redactor *Redactor) *Logger {
+	return &Logger{sink: sink, redactor: redactor}
+}
+
+// Log redacts and stores e. Logging must not fail a request, so errors are
+// only reported in the server log. The entry is written even when ctx has
+// been cancelled by a client disconnecting.
+func (l *Logger) Log(ctx context.Context, e *Entry) {
+	l.redactor.Apply(e)
+	if err := l.sink.Append(context.WithoutCancel(ctx), e); err != nil {
+		logger.Warn("Failed to log query", "query_id", e.ID, "error", err)
+	}
+}
+
+// Summarize reads the entries logged since opts.Since and summarizes them
+func (l *Logger) Summarize(ctx context.Context, opts SummaryOptions) (*Summary, error) {
+	entries, err := l.sink.Read(ctx, opts.Since)
+	if err != nil {
+		return nil, err
+	}
+	return Summarize(entries, opts), nil
+}
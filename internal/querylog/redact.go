@@ -0,0 +1,99 @@
+package querylog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// Redaction modes
+const (
+	RedactNone    = "none"    // log queries as given
+	RedactSecrets = "secrets" // mask emails, credentials and tokens
+	RedactHash    = "hash"    // replace query text with a hash; identical queries still group together
+	RedactOmit    = "omit"    // drop query text
+)
+
+// secretPatterns are masked in RedactSecrets mode, and in error messages
+// and filters in the hash and omit modes
+var secretPatterns = []string{
+	`[\w.+-]+@[\w-]+\.[\w.-]+`, // email addresses
+	`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key|auth)\s*[:=]\s*\S+`,
+	`\bAKIA[0-9A-Z]{16}\b`,                    // AWS access key IDs
+	`\beyJ[\w-]+\.[\w-]+\.[\w-]+`,             // JWTs
+	`\b(gh[pousr]|sk|xox[abp])[-_][\w-]{16,}`, // GitHub, OpenAI and Slack style tokens
+	`\b[A-Fa-f0-9]{32,}\b`,                    // long hex secrets
+}
+
+const redacted = "[REDACTED]"
+
+// Redactor removes sensitive text from entries before they are stored
+type Redactor struct {
+	mode     string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor creates a redactor for mode. extra patterns are masked in
+// addition to the built-in ones.
+func NewRedactor(mode string, extra []string) (*Redactor, error) {
+	switch mode {
+	case "", RedactNone:
+		return &Redactor{mode: RedactNone}, nil
+	case RedactSecrets, RedactHash, RedactOmit:
+	default:
+		return nil, errors.ValidationError(fmt.Sprintf("unknown redaction mode %q (want none, secrets, hash or omit)", mode))
+	}
+
+	r := &Redactor{mode: mode}
+	for _, p := range append(append([]string(nil), secretPatterns...), extra...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrorTypeValidation, fmt.Sprintf("invalid redaction pattern %q", p))
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Apply redacts e in place. A nil Redactor leaves e unchanged.
+func (r *Redactor) Apply(e *Entry) {
+	if r == nil || r.mode == RedactNone {
+		return
+	}
+
+	switch r.mode {
+	case RedactSecrets:
+		e.Query = r.mask(e.Query)
+	case RedactHash:
+		sum := sha256.Sum256([]byte(normalizeQuery(e.Query)))
+		e.Query = "sha256:" + hex.EncodeToString(sum[:8])
+	case RedactOmit:
+		e.Query = ""
+	}
+	e.FilePath = r.mask(e.FilePath)
+	e.Error = r.mask(e.Error)
+	if len(e.Filters) > 0 {
+		filters := make(map[string]string, len(e.Filters))
+		for k, v := range e.Filters {
+			filters[k] = r.mask(v)
+		}
+		e.Filters = filters
+	}
+}
+
+func (r *Redactor) mask(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// normalizeQuery folds case and whitespace so trivially different queries
+// count as one
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
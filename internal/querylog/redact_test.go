@@ -0,0 +1,77 @@
+package querylog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+func TestRedactor(t *testing.T) {
+	query := "why does login fail for bob@example.com with token=abc123"
+	tests := []struct {
+		mode    string
+		want    string
+		wantNot []string
+	}{
+		{RedactNone, query, nil},
+		{RedactSecrets, "why does login fail for [REDACTED] with [REDACTED]", nil},
+		{RedactHash, "sha256:", []string{"bob", "login"}},
+		{RedactOmit, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			r, err := NewRedactor(tt.mode, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := &Entry{Query: query, Error: "auth failed for bob@example.com", Filters: map[string]string{"owner": "bob@example.com"}}
+			r.Apply(e)
+
+			if tt.mode == RedactHash {
+				if !strings.HasPrefix(e.Query, tt.want) {
+					t.Errorf("Query = %q, want a hash", e.Query)
+				}
+			} else if e.Query != tt.want {
+				t.Errorf("Query = %q, want %q", e.Query, tt.want)
+			}
+			for _, s := range tt.wantNot {
+				if strings.Contains(e.Query, s) {
+					t.Errorf("Query %q should not contain %q", e.Query, s)
+				}
+			}
+			if tt.mode != RedactNone && (strings.Contains(e.Error, "bob") || strings.Contains(e.Filters["owner"], "bob")) {
+				t.Errorf("error and filters should be masked: %q %v", e.Error, e.Filters)
+			}
+		})
+	}
+}
+
+func TestRedactor_HashGroupsQueries(t *testing.T) {
+	r, _ := NewRedactor(RedactHash, nil)
+	a, b := &Entry{Query: "Token  refresh"}, &Entry{Query: "token refresh"}
+	r.Apply(a)
+	r.Apply(b)
+	if a.Query != b.Query {
+		t.Errorf("equivalent queries hashed differently: %q, %q", a.Query, b.Query)
+	}
+}
+
+func TestNewRedactor_Invalid(t *testing.T) {
+	if _, err := NewRedactor("scramble", nil); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("expected validation error for unknown mode, got %v", err)
+	}
+	if _, err := NewRedactor(RedactSecrets, []string{"("}); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("expected validation error for bad pattern, got %v", err)
+	}
+
+	r, err := NewRedactor(RedactSecrets, []string{`ACME-\d+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Entry{Query: "what does ACME-1234 change"}
+	r.Apply(e)
+	if e.Query != "what does [REDACTED] change" {
+		t.Errorf("custom pattern not applied: %q", e.Query)
+	}
+}
@@ -0,0 +1,72 @@
+package querylog
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSink keeps the most recent entries in a Redis stream. Older entries
+// are trimmed as new ones arrive.
+type RedisSink struct {
+	client *redis.Client
+	key    string
+	maxLen int64
+}
+
+// NewRedisSink creates a sink writing to the stream at key, keeping about
+// maxLen entries (default 10000)
+func NewRedisSink(client *redis.Client, key string, maxLen int64) *RedisSink {
+	if maxLen <= 0 {
+		maxLen = 10000
+	}
+	return &RedisSink{client: client, key: key, maxLen: maxLen}
+}
+
+// Append adds e to the stream
+func (s *RedisSink) Append(ctx context.Context, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal query log entry")
+	}
+	err = s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.key,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]any{"entry": data},
+	}).Err()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to append to query log")
+	}
+	return nil
+}
+
+// Read returns the entries added at or after since. Stream IDs start with
+// the time an entry was added, so only that part of the stream is read.
+func (s *RedisSink) Read(ctx context.Context, since time.Time) ([]*Entry, error) {
+	start := "-"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+	messages, err := s.client.XRange(ctx, s.key, start, "+").Result()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to read query log")
+	}
+
+	entries := make([]*Entry, 0, len(messages))
+	for _, msg := range messages {
+		data, _ := msg.Values["entry"].(string)
+		var e Entry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			logger.Warn("Skipping unreadable query log entry", "id", msg.ID, "error", err)
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,152 @@
+package querylog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	logger.Init(logger.Config{Level: logger.LevelDebug})
+}
+
+func testEntry(query string, at time.Time) *Entry {
+	e := NewEntry(NewID(), "search", domain.SearchQuery{Query: query, Language: "go", SkipRerank: true}, at)
+	e.SetResults([]*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "c1", FilePath: "/repo/a.go"}, Rank: 1, RelevanceScore: 0.5},
+		{Chunk: nil},
+	})
+	e.StagesMS = map[string]float64{"embed": 12}
+	return e
+}
+
+func TestNewEntry(t *testing.T) {
+	e := testEntry("token refresh", time.Now())
+	if e.Language != "go" || len(e.Skipped) != 1 || e.Skipped[0] != "rerank" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if len(e.Results) != 1 || e.Results[0].ChunkID != "c1" || e.Results[0].Rank != 1 {
+		t.Errorf("unexpected results %+v", e.Results)
+	}
+}
+
+// testSink checks a sink returns what was appended since a given time
+func testSink(t *testing.T, sink Sink) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	for i, q := range []string{"old", "recent", "newest"} {
+		if err := sink.Append(ctx, testEntry(q, base.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	all, err := sink.Read(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(all) != 3 || all[0].Query != "old" || all[2].Query != "newest" || all[1].StagesMS["embed"] != 12 {
+		t.Fatalf("unexpected entries %+v", all)
+	}
+}
+
+func TestRedisSink(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	sink := NewRedisSink(client, "test:querylog", 0)
+	testSink(t, sink)
+
+	// miniredis trims exactly, so only the newest two entries remain
+	trimmed := NewRedisSink(client, "test:trimmed", 2)
+	for _, q := range []string{"first", "second", "third"} {
+		_ = trimmed.Append(context.Background(), testEntry(q, time.Now()))
+	}
+	entries, _ := trimmed.Read(context.Background(), time.Time{})
+	if len(entries) != 2 || entries[0].Query != "second" {
+		t.Errorf("expected the stream to be trimmed to 2 entries, got %d", len(entries))
+	}
+	// Stream IDs are assigned when entries are added, so a future since
+	// excludes everything
+	if entries, _ := sink.Read(context.Background(), time.Now().Add(time.Minute)); len(entries) != 0 {
+		t.Errorf("expected no entries after now, got %d", len(entries))
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	testSink(t, NewFileSink(path, 0))
+
+	sink := NewFileSink(path, 0)
+	entries, _ := sink.Read(context.Background(), time.Now().Add(-time.Hour+90*time.Second))
+	if len(entries) != 1 || entries[0].Query != "newest" {
+		t.Errorf("expected only the newest entry, got %+v", entries)
+	}
+}
+
+func TestFileSink_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	sink := NewFileSink(path, 1) // every append rotates
+	ctx := context.Background()
+	for _, q := range []string{"first", "second", "third"} {
+		if err := sink.Append(ctx, testEntry(q, time.Now())); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected a rotated file: %v", err)
+	}
+	entries, err := sink.Read(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Query != "second" || entries[1].Query != "third" {
+		t.Errorf("expected the last two entries, got %+v", entries)
+	}
+}
+
+func TestFileSink_SkipsBadLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	sink := NewFileSink(path, 0)
+	_ = sink.Append(context.Background(), testEntry("good", time.Now()))
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString("{truncated\n")
+	f.Close()
+
+	entries, err := sink.Read(context.Background(), time.Time{})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Read() = %d entries, %v", len(entries), err)
+	}
+}
+
+func TestLogger_Redacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	redactor, err := NewRedactor(RedactOmit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(NewFileSink(path, 0), redactor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // a disconnected client must not lose the entry
+	l.Log(ctx, testEntry("where is the admin password checked", time.Now()))
+
+	summary, err := l.Summarize(context.Background(), SummaryOptions{})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.Queries != 1 || len(summary.TopQueries) != 0 {
+		t.Errorf("expected one query without text, got %+v", summary)
+	}
+}
@@ -0,0 +1,88 @@
+package reranker
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// heuristicConfigFile is the JSON form of HeuristicConfig, as written by
+// `rag tune` and loaded by the server from RERANKER_CONFIG
+type heuristicConfigFile struct {
+	PriorityPaths   []string                     `json:"priority_paths"`
+	RecencyHalfLife string                       `json:"recency_half_life"`
+	TypeWeights     map[domain.ChunkType]float32 `json:"type_weights"`
+	ExactMatch      float32                      `json:"exact_match"`
+	TokenMatch      float32                      `json:"token_match"`
+	PathMatch       float32                      `json:"path_match"`
+	PriorityPath    float32                      `json:"priority_path"`
+	Recency         float32                      `json:"recency"`
+}
+
+// LoadHeuristicConfig reads a config file. Fields missing from the file keep
+// their defaults.
+func LoadHeuristicConfig(path string) (HeuristicConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HeuristicConfig{}, errors.Wrap(err, errors.ErrorTypeValidation, "failed to read reranker config")
+	}
+
+	def := DefaultHeuristicConfig()
+	file := heuristicConfigFile{
+		PriorityPaths:   def.PriorityPaths,
+		RecencyHalfLife: def.RecencyHalfLife.String(),
+		TypeWeights:     def.TypeWeights,
+		ExactMatch:      def.ExactMatch,
+		TokenMatch:      def.TokenMatch,
+		PathMatch:       def.PathMatch,
+		PriorityPath:    def.PriorityPath,
+		Recency:         def.Recency,
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return HeuristicConfig{}, errors.Wrap(err, errors.ErrorTypeValidation, "failed to parse reranker config")
+	}
+	halfLife, err := time.ParseDuration(file.RecencyHalfLife)
+	if err != nil {
+		return HeuristicConfig{}, errors.Wrap(err, errors.ErrorTypeValidation, "invalid recency_half_life in reranker config")
+	}
+
+	return HeuristicConfig{
+		PriorityPaths:   file.PriorityPaths,
+		RecencyHalfLife: halfLife,
+		TypeWeights:     file.TypeWeights,
+		ExactMatch:      file.ExactMatch,
+		TokenMatch:      file.TokenMatch,
+		PathMatch:       file.PathMatch,
+		PriorityPath:    file.PriorityPath,
+		Recency:         file.Recency,
+	}, nil
+}
+
+// Save writes the config as indented JSON
+func (c HeuristicConfig) Save(path string) error {
+	c = c.withDefaults()
+	file := heuristicConfigFile{
+		PriorityPaths:   c.PriorityPaths,
+		RecencyHalfLife: c.RecencyHalfLife.String(),
+		TypeWeights:     c.TypeWeights,
+		ExactMatch:      c.ExactMatch,
+		TokenMatch:      c.TokenMatch,
+		PathMatch:       c.PathMatch,
+		PriorityPath:    c.PriorityPath,
+		Recency:         c.Recency,
+	}
+	if file.PriorityPaths == nil {
+		file.PriorityPaths = []string{}
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to marshal reranker config")
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "failed to write reranker config")
+	}
+	return nil
+}
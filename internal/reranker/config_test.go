@@ -0,0 +1,106 @@
+package reranker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+func TestHeuristicConfig_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reranker.json")
+	cfg := DefaultHeuristicConfig()
+	cfg.ExactMatch = 2.25
+	cfg.TypeWeights[domain.ChunkTypeComment] = 0.3
+	cfg.RecencyHalfLife = 7 * 24 * time.Hour
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadHeuristicConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHeuristicConfig() error = %v", err)
+	}
+	if loaded.ExactMatch != 2.25 || loaded.TypeWeights[domain.ChunkTypeComment] != 0.3 || loaded.RecencyHalfLife != 7*24*time.Hour {
+		t.Errorf("unexpected config %+v", loaded)
+	}
+}
+
+func TestLoadHeuristicConfig_Partial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reranker.json")
+	content := `{"type_weights": {"function": 1.6}, "priority_paths": [], "path_match": 1.3}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadHeuristicConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHeuristicConfig() error = %v", err)
+	}
+	def := DefaultHeuristicConfig()
+	if cfg.TypeWeights[domain.ChunkTypeFunction] != 1.6 || cfg.TypeWeights[domain.ChunkTypeClass] != def.TypeWeights[domain.ChunkTypeClass] {
+		t.Errorf("type weights should merge with the defaults: %v", cfg.TypeWeights)
+	}
+	if len(cfg.PriorityPaths) != 0 || cfg.PathMatch != 1.3 || cfg.ExactMatch != def.ExactMatch {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"recency_half_life": "soon"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHeuristicConfig(path); err == nil {
+		t.Error("expected error for an invalid half-life")
+	}
+}
+
+func TestLoadHeuristicConfig_ZeroDisablesFactor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reranker.json")
+	content := `{"recency": 0, "token_match": 0, "type_weights": {"comment": 0}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadHeuristicConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHeuristicConfig() error = %v", err)
+	}
+
+	// The config file itself was just modified, so recency would boost it
+	results := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "f", Content: "func refresh() {}", ChunkType: domain.ChunkTypeFunction, FilePath: path}, Score: 1, Debug: &domain.ResultDebug{}},
+		{Chunk: &domain.CodeChunk{ID: "c", Content: "// refresh", ChunkType: domain.ChunkTypeComment, FilePath: path}, Score: 1, Debug: &domain.ResultDebug{}},
+	}
+	ranked, _ := NewHeuristicRerankerWithConfig(cfg).Rerank(context.Background(), "refresh tokens", results)
+	f := ranked[0].Debug.Rerank
+	if ranked[0].Chunk.ID != "f" || f.Recency != 1 || f.TokenMatch != 1 {
+		t.Errorf("factors of %s = %+v, want recency and token match off", ranked[0].Chunk.ID, f)
+	}
+	if f.TypeWeight != DefaultHeuristicConfig().TypeWeights[domain.ChunkTypeFunction] {
+		t.Errorf("function weight = %v, want the default", f.TypeWeight)
+	}
+	if ranked[1].RelevanceScore != 0 {
+		t.Errorf("comment score = %v, want 0 with a comment weight of 0", ranked[1].RelevanceScore)
+	}
+}
+
+func TestHeuristicReranker_CustomWeights(t *testing.T) {
+	results := func() []*domain.SearchResult {
+		return []*domain.SearchResult{
+			{Chunk: &domain.CodeChunk{ID: "f", Content: "x", ChunkType: domain.ChunkTypeFunction, FilePath: "/nonexistent/a.go"}, Score: 1},
+			{Chunk: &domain.CodeChunk{ID: "c", Content: "x", ChunkType: domain.ChunkTypeComment, FilePath: "/nonexistent/b.go"}, Score: 1},
+		}
+	}
+
+	ranked, _ := NewHeuristicReranker().Rerank(context.Background(), "zzz", results())
+	if ranked[0].Chunk.ID != "f" {
+		t.Fatalf("functions should outrank comments by default")
+	}
+
+	cfg := HeuristicConfig{TypeWeights: map[domain.ChunkType]float32{domain.ChunkTypeComment: 2}}
+	ranked, _ = NewHeuristicRerankerWithConfig(cfg).Rerank(context.Background(), "zzz", results())
+	if ranked[0].Chunk.ID != "c" || ranked[0].RelevanceScore != 2 {
+		t.Errorf("expected the comment weight to apply, got %s with %v", ranked[0].Chunk.ID, ranked[0].RelevanceScore)
+	}
+}
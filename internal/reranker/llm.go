@@ -0,0 +1,238 @@
+package reranker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/lru"
+)
+
+// ---------------------------------------------------------------------------
+// LLM Reranker
+// ---------------------------------------------------------------------------
+
+// ChatModel is the chat model the LLM reranker asks for relevance scores
+type ChatModel interface {
+	Generate(ctx context.Context, messages []llm.ChatMessage) (string, error)
+}
+
+// LLMConfig allows customisation of the LLM reranker
+type LLMConfig struct {
+	TopN          int           // candidates scored by the model (default 10)
+	Budget        time.Duration // time allowed for the model call (default 3s)
+	MaxChunkChars int           // chunk content sent per candidate (default 1200)
+	CacheSize     int           // cached (query, chunk) scores (default 4096)
+}
+
+// DefaultLLMConfig returns sensible defaults
+func DefaultLLMConfig() LLMConfig {
+	return LLMConfig{
+		TopN:          10,
+		Budget:        3 * time.Second,
+		MaxChunkChars: 1200,
+		CacheSize:     4096,
+	}
+}
+
+// Fallback reasons recorded in LLMRerankExplanation
+const (
+	fallbackTimeout     = "timeout"
+	fallbackError       = "error"
+	fallbackUnparseable = "unparseable"
+)
+
+// LLMReranker acts as a cross-encoder: the model reads the query together
+// with each of the top candidates and scores its relevance from 0 to 10.
+// The inner reranker runs first and supplies the candidates; its order is
+// kept when the model fails or exceeds the latency budget, and breaks ties
+// between equal model scores.
+//
+// Scores are cached by query and chunk ID. Chunk IDs are content hashes, so
+// an edited chunk is scored again.
+type LLMReranker struct {
+	inner Reranker
+	model ChatModel
+	cfg   LLMConfig
+	cache *lru.Cache[string, float32]
+}
+
+// NewLLMReranker creates an LLM reranker over inner (usually the heuristic
+// reranker). Wrap it in an MMRReranker to diversify the final order.
+func NewLLMReranker(inner Reranker, model ChatModel, cfg LLMConfig) *LLMReranker {
+	def := DefaultLLMConfig()
+	if cfg.TopN <= 0 {
+		cfg.TopN = def.TopN
+	}
+	if cfg.Budget <= 0 {
+		cfg.Budget = def.Budget
+	}
+	if cfg.MaxChunkChars <= 0 {
+		cfg.MaxChunkChars = def.MaxChunkChars
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = def.CacheSize
+	}
+	return &LLMReranker{inner: inner, model: model, cfg: cfg, cache: lru.New[string, float32](cfg.CacheSize)}
+}
+
+// Rerank orders the top candidates by model score. The rest follow in the
+// inner order, with scores scaled below the lowest model-scored result so
+// an outer MMR pass sees one consistent scale.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, results []*domain.SearchResult) ([]*domain.SearchResult, error) {
+	ranked, err := r.inner.Rerank(ctx, query, results)
+	if err != nil || len(ranked) == 0 {
+		return ranked, err
+	}
+
+	n := r.cfg.TopN
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+	head := ranked[:n]
+
+	scores := make([]float32, n)
+	cached := make([]bool, n)
+	var missing []int
+	for i, res := range head {
+		if res.Chunk == nil {
+			continue
+		}
+		if s, ok := r.cache.Get(cacheKey(query, res.Chunk)); ok {
+			scores[i], cached[i] = s, true
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) > 0 {
+		start := time.Now()
+		callCtx, cancel := context.WithTimeout(ctx, r.cfg.Budget)
+		got, err := r.score(callCtx, query, head, missing)
+		timedOut := callCtx.Err() == context.DeadlineExceeded
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.Wrap(ctx.Err(), errors.ErrorTypeInternal, "reranking cancelled")
+			}
+			reason := fallbackError
+			switch {
+			case timedOut:
+				reason = fallbackTimeout
+			case errors.Is(err, errors.ErrorTypeValidation):
+				reason = fallbackUnparseable
+			}
+			logger.Warn("LLM reranking failed, keeping heuristic order",
+				"reason", reason, "candidates", len(missing), "elapsed_ms", time.Since(start).Milliseconds(), "error", err)
+			for _, res := range head {
+				if res.Debug != nil {
+					res.Debug.LLM = &domain.LLMRerankExplanation{Fallback: reason}
+				}
+			}
+			return ranked, nil
+		}
+		for j, i := range missing {
+			scores[i] = got[j]
+			r.cache.Put(cacheKey(query, head[i].Chunk), got[j])
+		}
+		logger.Debug("LLM reranking complete", "scored", len(missing), "cached", n-len(missing), "elapsed_ms", time.Since(start).Milliseconds())
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	out := make([]*domain.SearchResult, 0, len(ranked))
+	floor := float32(1)
+	for _, i := range order {
+		res := head[i]
+		res.RelevanceScore = scores[i] / 10
+		if res.RelevanceScore < floor {
+			floor = res.RelevanceScore
+		}
+		if res.Debug != nil {
+			res.Debug.LLM = &domain.LLMRerankExplanation{Score: scores[i], Cached: cached[i]}
+		}
+		out = append(out, res)
+	}
+
+	tail := ranked[n:]
+	if len(tail) > 0 && tail[0].RelevanceScore > 0 {
+		scale := floor / tail[0].RelevanceScore
+		for _, res := range tail {
+			res.RelevanceScore *= scale
+		}
+	}
+	return append(out, tail...), nil
+}
+
+const llmRerankPrompt = `Rate how relevant each code snippet is to the developer's question, from 0 (unrelated) to 10 (directly answers it). Judge what the code does, not whether it shares words with the question.
+
+Question: %s
+
+%s
+Reply with a JSON object mapping each snippet number to its score, for example {"1": 7, "2": 0}, and nothing else.`
+
+// score asks the model to rate head[i] for each i in idx, in one call
+func (r *LLMReranker) score(ctx context.Context, query string, head []*domain.SearchResult, idx []int) ([]float32, error) {
+	var snippets strings.Builder
+	for j, i := range idx {
+		c := head[i].Chunk
+		content := c.Content
+		if len(content) > r.cfg.MaxChunkChars {
+			// Cut at a rune boundary so the prompt stays valid UTF-8
+			end := r.cfg.MaxChunkChars
+			for end > 0 && !utf8.RuneStart(content[end]) {
+				end--
+			}
+			content = content[:end] + "\n..."
+		}
+		fmt.Fprintf(&snippets, "[%d] %s:%d-%d\n```%s\n%s\n```\n\n", j+1, c.FilePath, c.StartLine, c.EndLine, c.Language, content)
+	}
+
+	reply, err := r.model.Generate(ctx, []llm.ChatMessage{
+		{Role: "user", Content: fmt.Sprintf(llmRerankPrompt, query, snippets.String())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseScores(reply, len(idx))
+}
+
+// parseScores reads {"1": 7, ...} from reply, tolerating text around the
+// object. Every snippet must have a score.
+func parseScores(reply string, n int) ([]float32, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.ValidationError("no JSON object in reranker reply")
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &raw); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "failed to parse reranker reply")
+	}
+
+	scores := make([]float32, n)
+	for i := range scores {
+		s, ok := raw[strconv.Itoa(i+1)]
+		if !ok {
+			return nil, errors.ValidationError(fmt.Sprintf("reranker reply has no score for snippet %d", i+1))
+		}
+		scores[i] = float32(min(max(s, 0), 10))
+	}
+	return scores, nil
+}
+
+func cacheKey(query string, chunk *domain.CodeChunk) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ") + "\x00" + chunk.ID
+}
@@ -0,0 +1,202 @@
+package reranker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/llm"
+)
+
+// passthrough keeps the input order, standing in for the heuristic reranker
+type passthrough struct{}
+
+func (passthrough) Rerank(ctx context.Context, query string, results []*domain.SearchResult) ([]*domain.SearchResult, error) {
+	for _, res := range results {
+		res.RelevanceScore = res.Score
+	}
+	return results, nil
+}
+
+// scriptedModel scores snippets by looking up the chunk's file path
+type scriptedModel struct {
+	scores map[string]float64
+	delay  time.Duration
+	reply  string
+	calls  int
+	asked  int    // snippets in the last prompt
+	prompt string // the last prompt
+}
+
+func (m *scriptedModel) Generate(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	m.calls++
+	m.prompt = messages[0].Content
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if m.reply != "" {
+		return m.reply, nil
+	}
+
+	var parts []string
+	m.asked = 0
+	for _, line := range strings.Split(messages[0].Content, "\n") {
+		var n int
+		var loc string
+		if _, err := fmt.Sscanf(line, "[%d] %s", &n, &loc); err != nil {
+			continue
+		}
+		m.asked++
+		path := loc[:strings.Index(loc, ":")]
+		parts = append(parts, fmt.Sprintf("%q: %v", fmt.Sprint(n), m.scores[path]))
+	}
+	return "Scores: {" + strings.Join(parts, ", ") + "}", nil
+}
+
+func candidates(paths ...string) []*domain.SearchResult {
+	results := make([]*domain.SearchResult, len(paths))
+	for i, p := range paths {
+		results[i] = &domain.SearchResult{
+			Chunk: &domain.CodeChunk{ID: "id-" + p, FilePath: p, Content: "func x() {}", StartLine: 1, EndLine: 3},
+			Score: float32(len(paths)-i) / 10,
+			Debug: &domain.ResultDebug{},
+		}
+	}
+	return results
+}
+
+func paths(results []*domain.SearchResult) string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Chunk.FilePath)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestLLMReranker_Rerank(t *testing.T) {
+	model := &scriptedModel{scores: map[string]float64{"a.go": 2, "b.go": 9, "c.go": 2, "d.go": 0}}
+	r := NewLLMReranker(passthrough{}, model, LLMConfig{TopN: 3})
+
+	ranked, err := r.Rerank(context.Background(), "How are sessions renewed?", candidates("a.go", "b.go", "c.go", "d.go"))
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	// b.go first; a.go and c.go tie and keep the inner order; d.go was not scored
+	if got := paths(ranked); got != "b.go,a.go,c.go,d.go" {
+		t.Errorf("order = %s", got)
+	}
+	if ranked[0].RelevanceScore != 0.9 || ranked[0].Debug.LLM.Score != 9 || ranked[0].Debug.LLM.Cached {
+		t.Errorf("unexpected top result %+v %+v", ranked[0], ranked[0].Debug.LLM)
+	}
+	if tail := ranked[3].RelevanceScore; tail <= 0 || tail > ranked[2].RelevanceScore {
+		t.Errorf("tail score %v should be scaled below %v", tail, ranked[2].RelevanceScore)
+	}
+	if ranked[3].Debug.LLM != nil {
+		t.Error("unscored results should have no LLM explanation")
+	}
+
+	// Same query, differently spaced: everything comes from the cache
+	ranked, _ = r.Rerank(context.Background(), "how are  sessions renewed?", candidates("a.go", "b.go", "c.go", "d.go"))
+	if model.calls != 1 || !ranked[0].Debug.LLM.Cached || paths(ranked) != "b.go,a.go,c.go,d.go" {
+		t.Errorf("expected a cache hit, got %d calls and %s", model.calls, paths(ranked))
+	}
+
+	// Only the new candidate is sent to the model
+	r.Rerank(context.Background(), "how are sessions renewed?", candidates("e.go", "a.go", "b.go"))
+	if model.calls != 2 || model.asked != 1 {
+		t.Errorf("expected one uncached snippet in a second call, got %d calls asking %d", model.calls, model.asked)
+	}
+}
+
+func TestLLMReranker_Fallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  *scriptedModel
+		reason string
+	}{
+		{"timeout", &scriptedModel{delay: time.Second}, fallbackTimeout},
+		{"unparseable", &scriptedModel{reply: "I think the second one."}, fallbackUnparseable},
+		{"incomplete", &scriptedModel{reply: `{"1": 3}`}, fallbackUnparseable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewLLMReranker(passthrough{}, tt.model, LLMConfig{Budget: 20 * time.Millisecond})
+
+			start := time.Now()
+			ranked, err := r.Rerank(context.Background(), "query", candidates("a.go", "b.go"))
+			if err != nil {
+				t.Fatalf("Rerank failed: %v", err)
+			}
+			if time.Since(start) > 500*time.Millisecond {
+				t.Error("the latency budget was not enforced")
+			}
+			if paths(ranked) != "a.go,b.go" || ranked[0].Debug.LLM.Fallback != tt.reason {
+				t.Errorf("expected inner order with fallback %q, got %s %+v", tt.reason, paths(ranked), ranked[0].Debug.LLM)
+			}
+		})
+	}
+}
+
+func TestLLMReranker_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	model := &scriptedModel{delay: time.Second}
+	r := NewLLMReranker(passthrough{}, model, LLMConfig{})
+
+	go func() { time.Sleep(10 * time.Millisecond); cancel() }()
+	if _, err := r.Rerank(ctx, "query", candidates("a.go")); err == nil {
+		t.Error("expected error for cancelled context")
+	}
+}
+
+func TestLLMReranker_WithMMR(t *testing.T) {
+	model := &scriptedModel{scores: map[string]float64{"a.go": 1, "b.go": 8, "c.go": 8}}
+	results := candidates("a.go", "b.go", "c.go")
+	// b and c are near duplicates
+	results[0].Chunk.Embedding = []float32{0, 1}
+	results[1].Chunk.Embedding = []float32{1, 0}
+	results[2].Chunk.Embedding = []float32{1, 0.01}
+
+	r := NewMMRReranker(NewLLMReranker(passthrough{}, model, LLMConfig{}), 0.5)
+	ranked, err := r.Rerank(context.Background(), "query", results)
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if got := paths(ranked); got != "b.go,a.go,c.go" {
+		t.Errorf("order = %s, want the duplicate demoted", got)
+	}
+}
+
+func TestLLMReranker_TruncatesAtRuneBoundary(t *testing.T) {
+	model := &scriptedModel{scores: map[string]float64{"a.go": 5}}
+	r := NewLLMReranker(passthrough{}, model, LLMConfig{TopN: 1, MaxChunkChars: 5})
+
+	results := candidates("a.go")
+	results[0].Chunk.Content = "// héllo wörld"
+	if _, err := r.Rerank(context.Background(), "greeting", results); err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if !utf8.ValidString(model.prompt) {
+		t.Errorf("prompt is not valid UTF-8: %q", model.prompt)
+	}
+	if !strings.Contains(model.prompt, "// h\n...") {
+		t.Errorf("snippet should be cut before the split rune, prompt = %q", model.prompt)
+	}
+}
+
+func TestParseScores_Clamps(t *testing.T) {
+	scores, err := parseScores(`{"1": 14, "2": -3, "3": 6.5}`, 3)
+	if err != nil || scores[0] != 10 || scores[1] != 0 || scores[2] != 6.5 {
+		t.Errorf("parseScores = %v, %v", scores, err)
+	}
+	if _, err := parseScores("{}", 1); err == nil {
+		t.Error("expected error for a missing score")
+	}
+}
@@ -30,14 +30,31 @@ type HeuristicReranker struct {
 	weights         map[domain.ChunkType]float32
 	priorityPaths   []string      // path substrings that deserve a boost (e.g. "cmd/", "api/")
 	recencyHalfLife time.Duration // how quickly recency bonus decays
+	exactMatch      float32
+	tokenMatch      float32
+	pathMatch       float32
+	priorityPath    float32
+	recency         float32
 }
 
-// HeuristicConfig allows customisation of the reranker
+// HeuristicConfig allows customisation of the reranker. The zero config
+// means DefaultHeuristicConfig; any other config is taken as given, so
+// start from DefaultHeuristicConfig to change a few weights. A bonus of 0 or
+// a multiplier of 1 turns its factor off, and chunk types missing from
+// TypeWeights are weighted 1. LoadHeuristicConfig fills in what a file
+// leaves out.
 type HeuristicConfig struct {
 	// PriorityPaths are filename substrings that get a recency / priority boost.
 	// Example: []string{"cmd/", "api/", "main"}
 	PriorityPaths   []string
 	RecencyHalfLife time.Duration // default: 30 days
+
+	TypeWeights  map[domain.ChunkType]float32 // multiplier per chunk type
+	ExactMatch   float32                      // multiplier when the content contains the whole query (default 1.5)
+	TokenMatch   float32                      // bonus at full query-token coverage, scaled by coverage (default 0.3)
+	PathMatch    float32                      // multiplier when a query token appears in the path (default 1.1)
+	PriorityPath float32                      // multiplier for PriorityPaths (default 1.15)
+	Recency      float32                      // bonus for a just-modified file, decaying with age (default 0.3)
 }
 
 // DefaultHeuristicConfig returns sensible defaults
@@ -45,6 +62,19 @@ func DefaultHeuristicConfig() HeuristicConfig {
 	return HeuristicConfig{
 		PriorityPaths:   []string{"cmd/", "api/", "main", "handler", "server"},
 		RecencyHalfLife: 30 * 24 * time.Hour,
+		TypeWeights: map[domain.ChunkType]float32{
+			domain.ChunkTypeFunction: 1.2,
+			domain.ChunkTypeClass:    1.1,
+			domain.ChunkTypeMethod:   1.15,
+			domain.ChunkTypeImport:   0.8,
+			domain.ChunkTypeComment:  0.5,
+			domain.ChunkTypeOther:    1.0,
+		},
+		ExactMatch:   1.5,
+		TokenMatch:   0.3,
+		PathMatch:    1.1,
+		PriorityPath: 1.15,
+		Recency:      0.3,
 	}
 }
 
@@ -55,22 +85,37 @@ func NewHeuristicReranker() *HeuristicReranker {
 
 // NewHeuristicRerankerWithConfig creates a reranker with a custom config
 func NewHeuristicRerankerWithConfig(cfg HeuristicConfig) *HeuristicReranker {
-	halfLife := cfg.RecencyHalfLife
-	if halfLife <= 0 {
-		halfLife = 30 * 24 * time.Hour
-	}
+	cfg = cfg.withDefaults()
 	return &HeuristicReranker{
-		weights: map[domain.ChunkType]float32{
-			domain.ChunkTypeFunction: 1.2,
-			domain.ChunkTypeClass:    1.1,
-			domain.ChunkTypeMethod:   1.15,
-			domain.ChunkTypeImport:   0.8,
-			domain.ChunkTypeComment:  0.5,
-			domain.ChunkTypeOther:    1.0,
-		},
+		weights:         cfg.TypeWeights,
 		priorityPaths:   cfg.PriorityPaths,
-		recencyHalfLife: halfLife,
+		recencyHalfLife: cfg.RecencyHalfLife,
+		exactMatch:      cfg.ExactMatch,
+		tokenMatch:      cfg.TokenMatch,
+		pathMatch:       cfg.PathMatch,
+		priorityPath:    cfg.PriorityPath,
+		recency:         cfg.Recency,
+	}
+}
+
+// withDefaults returns DefaultHeuristicConfig for the zero config and
+// otherwise keeps every weight as given, so a weight of 0 stays 0. Only a
+// RecencyHalfLife that is not positive, which recency cannot decay over,
+// takes the default.
+func (c HeuristicConfig) withDefaults() HeuristicConfig {
+	def := DefaultHeuristicConfig()
+	if c.isZero() {
+		return def
+	}
+	if c.RecencyHalfLife <= 0 {
+		c.RecencyHalfLife = def.RecencyHalfLife
 	}
+	return c
+}
+
+func (c HeuristicConfig) isZero() bool {
+	return c.PriorityPaths == nil && c.RecencyHalfLife == 0 && c.TypeWeights == nil &&
+		c.ExactMatch == 0 && c.TokenMatch == 0 && c.PathMatch == 0 && c.PriorityPath == 0 && c.Recency == 0
 }
 
 // Rerank applies multi-factor heuristics to improve result ordering
@@ -86,17 +131,24 @@ func (r *HeuristicReranker) Rerank(ctx context.Context, query string, results []
 		if res.Chunk == nil {
 			continue
 		}
-		score := res.Score
+		factors := domain.RerankExplanation{
+			InputScore:   res.Score,
+			TypeWeight:   1,
+			ExactMatch:   1,
+			TokenMatch:   1,
+			PathMatch:    1,
+			PriorityPath: 1,
+		}
 
 		// 1. Code-type bonus ────────────────────────────────────────────────
 		if weight, ok := r.weights[res.Chunk.ChunkType]; ok {
-			score *= weight
+			factors.TypeWeight = weight
 		}
 
 		// 2. Exact content match ────────────────────────────────────────────
 		contentLower := strings.ToLower(res.Chunk.Content)
 		if strings.Contains(contentLower, queryLower) {
-			score *= 1.5
+			factors.ExactMatch = r.exactMatch
 		}
 
 		// 3. Partial token matches in content ───────────────────────────────
@@ -108,7 +160,7 @@ func (r *HeuristicReranker) Rerank(ctx context.Context, query string, results []
 		}
 		if len(queryTokens) > 0 && matchedTokens > 0 {
 			tokenRatio := float32(matchedTokens) / float32(len(queryTokens))
-			score *= 1.0 + 0.3*tokenRatio // up to +30% for full token coverage
+			factors.TokenMatch = 1.0 + r.tokenMatch*tokenRatio // up to +30% for full token coverage by default
 		}
 
 		// 4. Keyword match in file path ─────────────────────────────────────
@@ -118,7 +170,7 @@ func (r *HeuristicReranker) Rerank(ctx context.Context, query string, results []
 				continue
 			}
 			if strings.Contains(pathLower, token) {
-				score *= 1.1
+				factors.PathMatch = r.pathMatch
 				break
 			}
 		}
@@ -126,28 +178,33 @@ func (r *HeuristicReranker) Rerank(ctx context.Context, query string, results []
 		// 5. File priority boost ────────────────────────────────────────────
 		for _, pattern := range r.priorityPaths {
 			if strings.Contains(pathLower, strings.ToLower(pattern)) {
-				score *= 1.15
+				factors.PriorityPath = r.priorityPath
 				break
 			}
 		}
 
 		// 6. Recency bonus ──────────────────────────────────────────────────
-		score *= r.recencyBonus(res.Chunk.FilePath)
+		factors.Recency = r.recencyBonus(res.Chunk.FilePath)
 
-		res.RelevanceScore = score
+		factors.Score = factors.InputScore * factors.TypeWeight * factors.ExactMatch * factors.TokenMatch *
+			factors.PathMatch * factors.PriorityPath * factors.Recency
+		res.RelevanceScore = factors.Score
+		if res.Debug != nil {
+			res.Debug.Rerank = &factors
+		}
 	}
 
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		return results[i].RelevanceScore > results[j].RelevanceScore
 	})
 
 	return results, nil
 }
 
-// recencyBonus returns a multiplier in [1.0, 1.3] based on how recently
-// the file was modified relative to r.recencyHalfLife.
-// Files modified within the last half-life get a 30% boost that decays
-// exponentially. Files that can't be stat-ed get no bonus.
+// recencyBonus returns a multiplier in [1.0, 1+r.recency] based on how
+// recently the file was modified relative to r.recencyHalfLife.
+// Files modified within the last half-life get a boost (30% by default) that
+// decays exponentially. Files that can't be stat-ed get no bonus.
 func (r *HeuristicReranker) recencyBonus(filePath string) float32 {
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -157,9 +214,9 @@ func (r *HeuristicReranker) recencyBonus(filePath string) float32 {
 	if age < 0 {
 		age = 0
 	}
-	// Exponential decay: bonus = 0.3 * exp(-age / halfLife)
+	// Exponential decay: bonus = recency * exp(-age / halfLife)
 	ratio := float64(age) / float64(r.recencyHalfLife)
-	bonus := 0.3 * math.Exp(-ratio)
+	bonus := float64(r.recency) * math.Exp(-ratio)
 	logger.Debug("Recency bonus", "file", filePath, "age_days", int(age.Hours()/24), "bonus", bonus)
 	return float32(1.0 + bonus)
 }
@@ -205,7 +262,7 @@ func (m *MMRReranker) Rerank(ctx context.Context, query string, results []*domai
 
 	for len(candidates) > 0 {
 		bestIdx := -1
-		var bestMMR float32 = -1e9
+		var bestMMR, bestSim float32 = -1e9, 0
 
 		for i, c := range candidates {
 			relevance := c.RelevanceScore
@@ -214,10 +271,20 @@ func (m *MMRReranker) Rerank(ctx context.Context, query string, results []*domai
 
 			if bestIdx == -1 || mmrScore > bestMMR {
 				bestMMR = mmrScore
+				bestSim = maxSim
 				bestIdx = i
 			}
 		}
 
+		if best := candidates[bestIdx]; best.Debug != nil {
+			best.Debug.MMR = &domain.MMRExplanation{
+				Lambda:        m.lambda,
+				Relevance:     best.RelevanceScore,
+				MaxSimilarity: bestSim,
+				Penalty:       (1 - m.lambda) * bestSim,
+				Score:         bestMMR,
+			}
+		}
 		selected = append(selected, candidates[bestIdx])
 		candidates = append(candidates[:bestIdx], candidates[bestIdx+1:]...)
 	}
@@ -5,8 +5,13 @@ import (
 	"testing"
 
 	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/logger"
 )
 
+func init() {
+	logger.Init(logger.Config{Level: logger.LevelDebug})
+}
+
 func TestHeuristicReranker_Rerank(t *testing.T) {
 	r := NewHeuristicReranker()
 	ctx := context.Background()
@@ -76,3 +81,88 @@ func TestHeuristicReranker_EmptyResults(t *testing.T) {
 		t.Errorf("Expected 0 results, got %d", len(reranked))
 	}
 }
+
+func TestHeuristicReranker_Debug(t *testing.T) {
+	cfg := DefaultHeuristicConfig()
+	cfg.PriorityPaths = []string{"api/"}
+	r := NewHeuristicRerankerWithConfig(cfg)
+
+	results := []*domain.SearchResult{
+		{
+			Chunk: &domain.CodeChunk{
+				ID:        "1",
+				Content:   "func parseToken() {}",
+				ChunkType: domain.ChunkTypeFunction,
+				FilePath:  "api/token.go",
+			},
+			Score: 0.5,
+			Debug: &domain.ResultDebug{},
+		},
+		{
+			Chunk: &domain.CodeChunk{ID: "2", Content: "// nothing", ChunkType: domain.ChunkTypeComment, FilePath: "doc.go"},
+			Score: 0.5,
+		},
+	}
+
+	reranked, err := r.Rerank(context.Background(), "token", results)
+	if err != nil {
+		t.Fatalf("Rerank() error = %v", err)
+	}
+
+	var debugged, plain *domain.SearchResult
+	for _, res := range reranked {
+		if res.Chunk.ID == "1" {
+			debugged = res
+		} else {
+			plain = res
+		}
+	}
+	if plain.Debug != nil {
+		t.Error("expected no debug output for results without a ResultDebug")
+	}
+
+	f := debugged.Debug.Rerank
+	if f == nil {
+		t.Fatal("expected rerank factors to be recorded")
+	}
+	if f.InputScore != 0.5 || f.TypeWeight != 1.2 || f.ExactMatch != 1.5 || f.TokenMatch != 1.3 ||
+		f.PathMatch != 1.1 || f.PriorityPath != 1.15 || f.Recency != 1 {
+		t.Errorf("unexpected factors: %+v", f)
+	}
+	if f.Score != debugged.RelevanceScore {
+		t.Errorf("factor score %v != relevance score %v", f.Score, debugged.RelevanceScore)
+	}
+}
+
+func TestMMRReranker_Debug(t *testing.T) {
+	m := NewMMRReranker(passthroughReranker{}, 0.5)
+
+	results := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "1", Embedding: []float32{1, 0}}, RelevanceScore: 1, Debug: &domain.ResultDebug{}},
+		{Chunk: &domain.CodeChunk{ID: "2", Embedding: []float32{1, 0}}, RelevanceScore: 0.9, Debug: &domain.ResultDebug{}},
+		{Chunk: &domain.CodeChunk{ID: "3", Embedding: []float32{0, 1}}, RelevanceScore: 0.8, Debug: &domain.ResultDebug{}},
+	}
+
+	reranked, err := m.Rerank(context.Background(), "q", results)
+	if err != nil {
+		t.Fatalf("Rerank() error = %v", err)
+	}
+
+	// The duplicate of the first result is penalised below the distinct one
+	if reranked[1].Chunk.ID != "3" || reranked[2].Chunk.ID != "2" {
+		t.Fatalf("unexpected MMR order: %s, %s, %s", reranked[0].Chunk.ID, reranked[1].Chunk.ID, reranked[2].Chunk.ID)
+	}
+	if p := reranked[0].Debug.MMR; p == nil || p.Penalty != 0 {
+		t.Errorf("first pick should have no penalty, got %+v", p)
+	}
+	if p := reranked[2].Debug.MMR; p == nil || p.MaxSimilarity < 0.99 || p.Penalty < 0.49 {
+		t.Errorf("duplicate should carry the similarity penalty, got %+v", p)
+	}
+}
+
+// passthroughReranker keeps the incoming relevance scores
+type passthroughReranker struct{}
+
+func (passthroughReranker) Rerank(ctx context.Context, query string, results []*domain.SearchResult) ([]*domain.SearchResult, error) {
+	return results, nil
+}
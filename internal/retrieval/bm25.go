@@ -4,6 +4,8 @@ import (
 	"context"
 	"math"
 	"strconv"
+
+	"github.com/Guru2308/rag-code/internal/domain"
 )
 
 // Red isIndexInterface defines methods needed by BM25 scorer
@@ -65,82 +67,153 @@ func (bm *BM25Scorer) Score(ctx context.Context, queryTokens []string, docID str
 			continue
 		}
 
-		// Calculate IDF: log((N - df + 0.5) / (df + 0.5))
-		idf := math.Log((float64(docCount)-float64(df)+0.5)/(float64(df)+0.5) + 1.0)
-
-		// Calculate normalized term frequency
-		// TF component: (tf * (k1 + 1)) / (tf + k1 * (1 - b + b * (docLen / avgDocLen)))
-		denominator := float64(tf) + bm.k1*(1-bm.b+bm.b*(float64(docLength)/avgDocLength))
-		tfComponent := (float64(tf) * (bm.k1 + 1)) / denominator
-
-		// BM25 score for this term
-		score += idf * tfComponent
+		score += bm.termScore(tf, df, docCount, docLength, avgDocLength)
 	}
 
 	return score, nil
 }
 
-// ScoreBatch calculates BM25 scores for multiple documents
+// BM25Stats is everything BM25 needs to score a set of documents for a query
+type BM25Stats struct {
+	DocCount     int
+	AvgDocLength float64
+	DocLengths   map[string]int            // by document ID
+	DocFreqs     map[string]int            // by token
+	TermFreqs    map[string]map[string]int // by document ID, then token
+}
+
+// BM25StatsReader is implemented by indexes that can read the stats for many
+// documents at once
+type BM25StatsReader interface {
+	GetBM25Stats(ctx context.Context, tokens, docIDs []string) (*BM25Stats, error)
+}
+
+// ScoreBatch calculates BM25 scores for multiple documents. Indexes that
+// implement BM25StatsReader are read once for the whole batch; others are
+// read per document, skipping documents that fail.
 func (bm *BM25Scorer) ScoreBatch(ctx context.Context, queryTokens []string, docIDs []string) (map[string]float64, error) {
-	scores := make(map[string]float64)
+	scores := make(map[string]float64, len(docIDs))
+
+	reader, ok := bm.redisIdx.(BM25StatsReader)
+	if !ok {
+		for _, docID := range docIDs {
+			score, err := bm.Score(ctx, queryTokens, docID)
+			if err != nil {
+				continue
+			}
+			scores[docID] = score
+		}
+		return scores, nil
+	}
 
+	stats, err := reader.GetBM25Stats(ctx, queryTokens, docIDs)
+	if err != nil {
+		return nil, err
+	}
 	for _, docID := range docIDs {
-		score, err := bm.Score(ctx, queryTokens, docID)
-		if err != nil {
-			continue
+		score := 0.0
+		if stats.DocCount > 0 {
+			for _, token := range queryTokens {
+				score += bm.termScore(stats.TermFreqs[docID][token], stats.DocFreqs[token], stats.DocCount, stats.DocLengths[docID], stats.AvgDocLength)
+			}
 		}
 		scores[docID] = score
 	}
-
 	return scores, nil
 }
 
+// termScore is one term's BM25 contribution; zero when the term is absent
+func (bm *BM25Scorer) termScore(tf, df, docCount, docLength int, avgDocLength float64) float64 {
+	if tf == 0 || df == 0 {
+		return 0
+	}
+
+	// Calculate IDF: log((N - df + 0.5) / (df + 0.5))
+	idf := math.Log((float64(docCount)-float64(df)+0.5)/(float64(df)+0.5) + 1.0)
+
+	// TF component: (tf * (k1 + 1)) / (tf + k1 * (1 - b + b * (docLen / avgDocLen)))
+	denominator := float64(tf) + bm.k1*(1-bm.b+bm.b*(float64(docLength)/avgDocLength))
+	return idf * (float64(tf) * (bm.k1 + 1)) / denominator
+}
+
+// Breakdown returns the BM25 score for a document split by query term
+func (bm *BM25Scorer) Breakdown(ctx context.Context, queryTokens []string, docID string) (*domain.BM25Explanation, error) {
+	docCount, err := bm.redisIdx.GetDocCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	avgDocLength, err := bm.redisIdx.GetAvgDocLength(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docLength, err := bm.redisIdx.GetDocLength(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := &domain.BM25Explanation{
+		K1:           bm.k1,
+		B:            bm.b,
+		DocCount:     docCount,
+		AvgDocLength: avgDocLength,
+		DocLength:    docLength,
+		Terms:        make([]domain.BM25Term, 0, len(queryTokens)),
+	}
+
+	for _, token := range queryTokens {
+		term := domain.BM25Term{Term: token}
+		term.TF, _ = bm.redisIdx.GetTermFrequency(ctx, token, docID)
+		term.DF, _ = bm.redisIdx.GetDocFrequency(ctx, token)
+
+		if term.TF > 0 && term.DF > 0 {
+			term.IDF = math.Log((float64(docCount)-float64(term.DF)+0.5)/(float64(term.DF)+0.5) + 1.0)
+			denominator := float64(term.TF) + bm.k1*(1-bm.b+bm.b*(float64(docLength)/avgDocLength))
+			term.TFComponent = (float64(term.TF) * (bm.k1 + 1)) / denominator
+			term.Score = term.IDF * term.TFComponent
+			breakdown.Score += term.Score
+		}
+		breakdown.Terms = append(breakdown.Terms, term)
+	}
+
+	return breakdown, nil
+}
+
 // Explain returns a detailed explanation of the BM25 score calculation
 func (bm *BM25Scorer) Explain(ctx context.Context, queryTokens []string, docID string) (string, error) {
-	docCount, _ := bm.redisIdx.GetDocCount(ctx)
-	avgDocLength, _ := bm.redisIdx.GetAvgDocLength(ctx)
-	docLength, _ := bm.redisIdx.GetDocLength(ctx, docID)
+	breakdown, err := bm.Breakdown(ctx, queryTokens, docID)
+	if err != nil {
+		return "", err
+	}
 
 	explanation := "BM25 Score Breakdown:\n"
 	explanation += "--------------------\n"
 	explanation += "Parameters:\n"
-	explanation += "  k1 (term freq saturation): " + formatFloat(bm.k1) + "\n"
-	explanation += "  b (length normalization): " + formatFloat(bm.b) + "\n"
+	explanation += "  k1 (term freq saturation): " + formatFloat(breakdown.K1) + "\n"
+	explanation += "  b (length normalization): " + formatFloat(breakdown.B) + "\n"
 	explanation += "\n"
 	explanation += "Collection Stats:\n"
-	explanation += "  Total documents: " + formatInt(docCount) + "\n"
-	explanation += "  Avg document length: " + formatFloat(avgDocLength) + "\n"
-	explanation += "  This document length: " + formatInt(docLength) + "\n"
+	explanation += "  Total documents: " + formatInt(breakdown.DocCount) + "\n"
+	explanation += "  Avg document length: " + formatFloat(breakdown.AvgDocLength) + "\n"
+	explanation += "  This document length: " + formatInt(breakdown.DocLength) + "\n"
 	explanation += "\n"
 	explanation += "Term Scores:\n"
 
-	totalScore := 0.0
-
-	for _, token := range queryTokens {
-		tf, _ := bm.redisIdx.GetTermFrequency(ctx, token, docID)
-		df, _ := bm.redisIdx.GetDocFrequency(ctx, token)
-
-		if tf == 0 || df == 0 {
-			explanation += "  '" + token + "': not found in document\n"
+	for _, term := range breakdown.Terms {
+		if term.TF == 0 || term.DF == 0 {
+			explanation += "  '" + term.Term + "': not found in document\n"
 			continue
 		}
 
-		idf := math.Log((float64(docCount)-float64(df)+0.5)/(float64(df)+0.5) + 1.0)
-		denominator := float64(tf) + bm.k1*(1-bm.b+bm.b*(float64(docLength)/avgDocLength))
-		tfComponent := (float64(tf) * (bm.k1 + 1)) / denominator
-		termScore := idf * tfComponent
-		totalScore += termScore
-
-		explanation += "  '" + token + "':\n"
-		explanation += "    TF: " + formatInt(tf) + "\n"
-		explanation += "    DF: " + formatInt(df) + "\n"
-		explanation += "    IDF: " + formatFloat(idf) + "\n"
-		explanation += "    TF component: " + formatFloat(tfComponent) + "\n"
-		explanation += "    Term score: " + formatFloat(termScore) + "\n"
+		explanation += "  '" + term.Term + "':\n"
+		explanation += "    TF: " + formatInt(term.TF) + "\n"
+		explanation += "    DF: " + formatInt(term.DF) + "\n"
+		explanation += "    IDF: " + formatFloat(term.IDF) + "\n"
+		explanation += "    TF component: " + formatFloat(term.TFComponent) + "\n"
+		explanation += "    Term score: " + formatFloat(term.Score) + "\n"
 	}
 
 	explanation += "\n"
-	explanation += "Total BM25 Score: " + formatFloat(totalScore) + "\n"
+	explanation += "Total BM25 Score: " + formatFloat(breakdown.Score) + "\n"
 
 	return explanation, nil
 }
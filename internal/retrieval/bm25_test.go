@@ -2,6 +2,7 @@ package retrieval
 
 import (
 	"context"
+	"math"
 	"testing"
 
 	"github.com/Guru2308/rag-code/internal/mocks"
@@ -136,6 +137,40 @@ func TestBM25Scorer_ScoreBatch(t *testing.T) {
 	}
 }
 
+func TestBM25Scorer_ScoreBatch_Pipelined(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+	docs := []*IndexedDocument{
+		{ID: "doc1", Length: 4, Tokens: map[string]int{"token": 2, "refresh": 1, "jwt": 1}},
+		{ID: "doc2", Length: 6, Tokens: map[string]int{"token": 1, "session": 5}},
+		{ID: "doc3", Length: 2, Tokens: map[string]int{"cache": 2}},
+	}
+	if err := idx.AddDocuments(ctx, docs); err != nil {
+		t.Fatalf("AddDocuments() error = %v", err)
+	}
+
+	scorer := NewBM25Scorer(1.2, 0.75, idx)
+	tokens := []string{"token", "refresh", "missing"}
+	scores, err := scorer.ScoreBatch(ctx, tokens, []string{"doc1", "doc2", "doc3", "unknown"})
+	if err != nil {
+		t.Fatalf("ScoreBatch() error = %v", err)
+	}
+	// One pipelined read must score exactly as the per-document reads do
+	for _, id := range []string{"doc1", "doc2", "doc3", "unknown"} {
+		want, err := scorer.Score(ctx, tokens, id)
+		if err != nil {
+			t.Fatalf("Score(%s) error = %v", id, err)
+		}
+		if math.Abs(scores[id]-want) > 1e-9 {
+			t.Errorf("ScoreBatch()[%s] = %v, want %v", id, scores[id], want)
+		}
+	}
+	if scores["doc1"] <= scores["doc2"] || scores["doc3"] != 0 {
+		t.Errorf("unexpected scores %v", scores)
+	}
+}
+
 func TestBM25Scorer_Explain(t *testing.T) {
 	mockRedis := &mocks.MockRedisIndex{
 		GetDocCountFunc: func(ctx context.Context) (int, error) {
@@ -189,3 +224,51 @@ func TestFormatInt(t *testing.T) {
 		t.Errorf("formatInt(12345) = %s, want 12345", result)
 	}
 }
+
+func TestBM25Scorer_Breakdown(t *testing.T) {
+	mockRedis := &mocks.MockRedisIndex{
+		GetDocCountFunc: func(ctx context.Context) (int, error) {
+			return 100, nil
+		},
+		GetAvgDocLengthFunc: func(ctx context.Context) (float64, error) {
+			return 50.0, nil
+		},
+		GetDocLengthFunc: func(ctx context.Context, docID string) (int, error) {
+			return 45, nil
+		},
+		GetTermFrequencyFunc: func(ctx context.Context, term, docID string) (int, error) {
+			if term == "test" {
+				return 3, nil
+			}
+			return 0, nil
+		},
+		GetDocFrequencyFunc: func(ctx context.Context, term string) (int, error) {
+			if term == "test" {
+				return 10, nil
+			}
+			return 0, nil
+		},
+	}
+
+	scorer := NewBM25Scorer(1.2, 0.75, mockRedis)
+	tokens := []string{"test", "missing"}
+	breakdown, err := scorer.Breakdown(context.Background(), tokens, "doc1")
+	if err != nil {
+		t.Fatalf("Breakdown() error = %v", err)
+	}
+
+	if len(breakdown.Terms) != 2 {
+		t.Fatalf("Breakdown() terms = %d, want 2", len(breakdown.Terms))
+	}
+	if term := breakdown.Terms[0]; term.Term != "test" || term.TF != 3 || term.DF != 10 || term.Score <= 0 {
+		t.Errorf("unexpected breakdown for 'test': %+v", term)
+	}
+	if term := breakdown.Terms[1]; term.TF != 0 || term.Score != 0 {
+		t.Errorf("expected no score for missing term, got %+v", term)
+	}
+
+	score, _ := scorer.Score(context.Background(), tokens, "doc1")
+	if math.Abs(breakdown.Score-score) > 1e-9 {
+		t.Errorf("Breakdown() total = %v, Score() = %v", breakdown.Score, score)
+	}
+}
@@ -0,0 +1,263 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+)
+
+type constEmbedder struct{}
+
+func (constEmbedder) Embed(context.Context, string) ([]float32, error) { return []float32{1}, nil }
+func (constEmbedder) EmbedBatch(context.Context, []string) ([][]float32, error) {
+	return nil, errors.New("not implemented")
+}
+
+// batchStore finds vectorIDs by vector search and serves chunks in batches.
+// Its search waits until the keyword search has started, if told to. With
+// uuids set it returns chunk IDs hyphenated, as Qdrant does.
+type batchStore struct {
+	vectorIDs   []string
+	uuids       bool
+	waitFor     <-chan struct{}
+	started     chan struct{}
+	mu          sync.Mutex
+	batchCalls  int
+	singleCalls int
+}
+
+func (s *batchStore) Search(ctx context.Context, _ []float32, _ int) ([]*domain.SearchResult, error) {
+	close(s.started)
+	if s.waitFor != nil {
+		select {
+		case <-s.waitFor:
+		case <-time.After(time.Second):
+			return nil, errors.New("keyword search never started")
+		}
+	}
+	var out []*domain.SearchResult
+	for i, id := range s.vectorIDs {
+		out = append(out, &domain.SearchResult{Chunk: &domain.CodeChunk{ID: id}, Score: 0.9 - float32(i)*0.1})
+	}
+	return out, nil
+}
+
+func (s *batchStore) GetBatch(_ context.Context, ids []string) ([]*domain.CodeChunk, error) {
+	s.mu.Lock()
+	s.batchCalls++
+	s.mu.Unlock()
+	var out []*domain.CodeChunk
+	for _, id := range ids {
+		if id == "gone" {
+			continue
+		}
+		if s.uuids && len(id) == 32 {
+			id = id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
+		}
+		out = append(out, &domain.CodeChunk{ID: id})
+	}
+	return out, nil
+}
+
+func (s *batchStore) Get(context.Context, string) (*domain.CodeChunk, error) {
+	s.mu.Lock()
+	s.singleCalls++
+	s.mu.Unlock()
+	return nil, errors.New("use GetBatch")
+}
+
+func (s *batchStore) Store(context.Context, []*domain.CodeChunk) error           { return nil }
+func (s *batchStore) Delete(context.Context, string) error                       { return nil }
+func (s *batchStore) DeleteStaleFiles(context.Context, []domain.StaleFile) error { return nil }
+
+// slowKeyword returns ids once the vector search has started, or blocks
+// until its context ends when block is set
+type slowKeyword struct {
+	ids     []string
+	block   bool
+	waitFor <-chan struct{}
+	started chan struct{}
+}
+
+func (k *slowKeyword) Search(ctx context.Context, _ []string, _ int) ([]string, error) {
+	close(k.started)
+	if k.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	select {
+	case <-k.waitFor:
+	case <-time.After(time.Second):
+		return nil, errors.New("vector search never started")
+	}
+	return k.ids, nil
+}
+
+func (k *slowKeyword) AddToInvertedIndex(context.Context, []*domain.CodeChunk) error { return nil }
+func (k *slowKeyword) RemoveStaleFiles(context.Context, []domain.StaleFile) error    { return nil }
+
+// batchScorer scores every document 1 and counts its calls
+type batchScorer struct{ batchCalls int }
+
+func (s *batchScorer) Score(context.Context, []string, string) (float64, error) {
+	return 0, errors.New("use ScoreBatch")
+}
+
+func (s *batchScorer) ScoreBatch(_ context.Context, _ []string, docIDs []string) (map[string]float64, error) {
+	s.batchCalls++
+	scores := make(map[string]float64, len(docIDs))
+	for _, id := range docIDs {
+		scores[id] = 1
+	}
+	return scores, nil
+}
+
+// blockingReranker never finishes before its context ends
+type blockingReranker struct{}
+
+func (blockingReranker) Rerank(ctx context.Context, _ string, _ []*domain.SearchResult) ([]*domain.SearchResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func newParallelRetriever(blockKeyword bool) (*Retriever, *batchStore, *batchScorer) {
+	vectorStarted, keywordStarted := make(chan struct{}), make(chan struct{})
+	store := &batchStore{vectorIDs: []string{"v1", "v2"}, started: vectorStarted}
+	keyword := &slowKeyword{ids: []string{"k1", "gone", "k2"}, block: blockKeyword, started: keywordStarted, waitFor: vectorStarted}
+	if !blockKeyword {
+		store.waitFor = keywordStarted
+	}
+	scorer := &batchScorer{}
+	r := NewRetriever(constEmbedder{}, store, keyword, scorer, NewQueryPreprocessor(), nil, nil, nil, DefaultFusionConfig())
+	return r, store, scorer
+}
+
+func TestRetriever_ParallelSearch(t *testing.T) {
+	// Each search waits for the other to start, so this only finishes if
+	// they run concurrently
+	r, store, scorer := newParallelRetriever(false)
+	ctx, timings := WithStageTimings(context.Background())
+	results, err := r.Retrieve(ctx, domain.SearchQuery{Query: "refresh token", MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	ids := resultIDs(results)
+	if len(ids) != 4 {
+		t.Errorf("results = %v, want v1, v2, k1 and k2", ids)
+	}
+	if store.batchCalls != 1 || store.singleCalls != 0 || scorer.batchCalls != 1 {
+		t.Errorf("hydration made %d batch and %d single gets and %d batch scores, want one batch each", store.batchCalls, store.singleCalls, scorer.batchCalls)
+	}
+	if partial := timings.PartialStages(); len(partial) != 0 {
+		t.Errorf("partial stages = %v, want none", partial)
+	}
+}
+
+func TestRetriever_BatchIDsInOtherForm(t *testing.T) {
+	// The keyword index holds the chunker's hex IDs; the store hands the
+	// chunks back under hyphenated UUIDs, as Qdrant does
+	const k1, k2 = "dfe7bd5d38ad3a3191dd4317ece83bba", "8d1b564ddaf67b0cb21b01ad6a4eaea1"
+	vectorStarted := make(chan struct{})
+	store := &batchStore{vectorIDs: []string{"v1"}, uuids: true, started: vectorStarted}
+	keyword := &slowKeyword{ids: []string{k1, k2}, started: make(chan struct{}), waitFor: vectorStarted}
+	r := NewRetriever(constEmbedder{}, store, keyword, &batchScorer{}, NewQueryPreprocessor(), nil, nil, nil, DefaultFusionConfig())
+
+	results, err := r.Retrieve(context.Background(), domain.SearchQuery{Query: "refresh token", MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	found := make(map[string]bool)
+	for _, res := range results {
+		if res.Source != "vector" {
+			found[domain.CanonicalChunkID(res.Chunk.ID)] = true
+		}
+	}
+	if !found[k1] || !found[k2] {
+		t.Errorf("results = %v, want both keyword hits", resultIDs(results))
+	}
+}
+
+func TestRetriever_SearchDeadline(t *testing.T) {
+	r, _, _ := newParallelRetriever(true)
+	r.SetDeadlines(StageDeadlines{Search: 50 * time.Millisecond})
+
+	page, err := r.Search(context.Background(), domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "refresh token", MaxResults: 10}})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if ids := resultIDs(page.Results); !reflect.DeepEqual(ids, []string{"v1", "v2"}) {
+		t.Errorf("results = %v, want the vector results", ids)
+	}
+	if !page.Partial {
+		t.Error("page should be marked partial")
+	}
+
+	// A cancelled request is not a deadline: the stage is not marked partial
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, timings := WithStageTimings(ctx)
+	r, _, _ = newParallelRetriever(true)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	r.Retrieve(ctx, domain.SearchQuery{Query: "refresh token", MaxResults: 10})
+	if partial := timings.PartialStages(); len(partial) != 0 {
+		t.Errorf("partial stages = %v after cancellation, want none", partial)
+	}
+}
+
+func TestRetriever_RerankDeadline(t *testing.T) {
+	r, _, _ := newParallelRetriever(false)
+	r.reranker = blockingReranker{}
+	r.SetDeadlines(StageDeadlines{Rerank: 20 * time.Millisecond})
+
+	ctx, timings := WithStageTimings(context.Background())
+	results, err := r.Retrieve(ctx, domain.SearchQuery{Query: "refresh token", MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("got %d results, want the fused results", len(results))
+	}
+	if partial := timings.PartialStages(); !reflect.DeepEqual(partial, []string{StageRerank}) {
+		t.Errorf("partial stages = %v, want rerank", partial)
+	}
+}
+
+// scoredKeyword ranks documents itself
+type scoredKeyword struct{ docs []ScoredDoc }
+
+func (k *scoredKeyword) Search(context.Context, []string, int) ([]string, error) {
+	return nil, errors.New("use SearchScored")
+}
+
+func (k *scoredKeyword) SearchScored(context.Context, []string, int) ([]ScoredDoc, error) {
+	return k.docs, nil
+}
+
+func (k *scoredKeyword) AddToInvertedIndex(context.Context, []*domain.CodeChunk) error { return nil }
+func (k *scoredKeyword) RemoveStaleFiles(context.Context, []domain.StaleFile) error    { return nil }
+
+func TestRetriever_ScoredKeywordSearch(t *testing.T) {
+	store := &batchStore{started: make(chan struct{})}
+	keyword := &scoredKeyword{docs: []ScoredDoc{{ID: "k1", Score: 4.5}, {ID: "k2", Score: 1.5}}}
+	scorer := &batchScorer{}
+	r := NewRetriever(constEmbedder{}, store, keyword, scorer, NewQueryPreprocessor(), nil, nil, nil, DefaultFusionConfig())
+
+	results, err := r.Retrieve(context.Background(), domain.SearchQuery{Query: "refresh token", MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if ids := resultIDs(results); !reflect.DeepEqual(ids, []string{"k1", "k2"}) {
+		t.Errorf("results = %v, want k1, k2", ids)
+	}
+	if results[0].KeywordScore != 4.5 || scorer.batchCalls != 0 {
+		t.Errorf("keyword score = %v with %d scorer calls, want the index's score and no scoring", results[0].KeywordScore, scorer.batchCalls)
+	}
+}
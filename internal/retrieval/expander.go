@@ -32,6 +32,7 @@ type ExpandConfig struct {
 	IncludeChildMethods   bool // Include child methods when chunk is a class/struct
 	MaxDepth               int  // Maximum depth for recursive expansion
 	MaxChunks              int  // Maximum number of chunks to return
+	Explain                bool // Record how each expanded chunk was reached (debug queries)
 }
 
 // DefaultExpandConfig returns sensible defaults for expansion
@@ -153,6 +154,7 @@ func (e *ContextExpander) getRelatedChunks(
 				Score:          0.5,
 				Source:         "expansion:callee",
 				RelevanceScore: 0.5,
+				Debug:          expansionDebug(config, "callee", chunkID, depth),
 			})
 
 			if depth+1 < config.MaxDepth && currentCount+len(related) < config.MaxChunks {
@@ -201,6 +203,7 @@ func (e *ContextExpander) getRelatedChunks(
 				Score:          0.4, // Slightly lower: caller context is broader
 				Source:         "expansion:caller",
 				RelevanceScore: 0.4,
+				Debug:          expansionDebug(config, "caller", chunkID, depth),
 			})
 		}
 	}
@@ -238,6 +241,7 @@ func (e *ContextExpander) getRelatedChunks(
 				Score:          0.55,
 				Source:         "expansion:parent_type",
 				RelevanceScore: 0.55,
+				Debug:          expansionDebug(config, "parent_type", chunkID, depth),
 			})
 		}
 	}
@@ -274,6 +278,7 @@ func (e *ContextExpander) getRelatedChunks(
 				Score:          0.55,
 				Source:         "expansion:child_method",
 				RelevanceScore: 0.55,
+				Debug:          expansionDebug(config, "child_method", chunkID, depth),
 			})
 		}
 	}
@@ -301,9 +306,21 @@ func (e *ContextExpander) getRelatedChunks(
 				Score:          0.3, // Even lower score for imports
 				Source:         "expansion:import",
 				RelevanceScore: 0.3,
+				Debug:          expansionDebug(config, "import", chunkID, depth),
 			})
 		}
 	}
 
 	return related
 }
+
+// expansionDebug records how an expanded chunk was reached, or returns nil
+// when the query did not ask for debug output
+func expansionDebug(config ExpandConfig, relation, from string, depth int) *domain.ResultDebug {
+	if !config.Explain {
+		return nil
+	}
+	return &domain.ResultDebug{
+		Expansion: &domain.ExpansionExplanation{Relation: relation, From: from, Depth: depth + 1},
+	}
+}
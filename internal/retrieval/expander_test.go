@@ -44,6 +44,17 @@ func (m *mockChunkStore) Delete(ctx context.Context, filePath string) error {
 	return nil
 }
 
+func (m *mockChunkStore) DeleteStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	for _, f := range files {
+		for id, chunk := range m.chunks {
+			if chunk.FilePath == f.FilePath && chunk.Generation != f.Generation {
+				delete(m.chunks, id)
+			}
+		}
+	}
+	return nil
+}
+
 func (m *mockChunkStore) Search(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
 	// Simple mock: return empty results
 	return []*domain.SearchResult{}, nil
@@ -19,6 +19,18 @@ const (
 	FusionMax
 )
 
+// String returns the strategy name used in debug output
+func (s FusionStrategy) String() string {
+	switch s {
+	case FusionWeighted:
+		return "weighted"
+	case FusionMax:
+		return "max"
+	default:
+		return "rrf"
+	}
+}
+
 // FusionConfig holds configuration for result fusion
 type FusionConfig struct {
 	Strategy     FusionStrategy
@@ -39,71 +51,105 @@ func DefaultFusionConfig() FusionConfig {
 func FuseResults(vectorResults, keywordResults []*domain.SearchResult, config FusionConfig) []*domain.SearchResult {
 	switch config.Strategy {
 	case FusionRRF:
-		return reciprocalRankFusion(vectorResults, keywordResults, config.RRFConstant)
+		return hybridRRF(vectorResults, keywordResults, config.RRFConstant)
 	case FusionWeighted:
 		return weightedCombination(vectorResults, keywordResults, config.VectorWeight)
 	case FusionMax:
 		return maxScoreFusion(vectorResults, keywordResults)
 	default:
-		return reciprocalRankFusion(vectorResults, keywordResults, config.RRFConstant)
+		return hybridRRF(vectorResults, keywordResults, config.RRFConstant)
 	}
 }
 
-// reciprocalRankFusion implements RRF: score = sum(1 / (k + rank_i))
-// This is parameter-free and robust for combining heterogeneous rankers
-func reciprocalRankFusion(vectorResults, keywordResults []*domain.SearchResult, k int) []*domain.SearchResult {
-	// Build a map of chunk ID to combined RRF score
-	scores := make(map[string]*fusionScore)
-
-	// Process vector results
-	for rank, result := range vectorResults {
-		chunkID := result.Chunk.ID
-		if _, exists := scores[chunkID]; !exists {
-			scores[chunkID] = &fusionScore{
-				chunk:        result.Chunk,
-				vectorScore:  result.Score,
-				keywordScore: 0,
-			}
+// ExplainFusion reports what each source contributed to a result fused by
+// FuseResults, given its 1-based rank in each source (0 when absent).
+// VectorScore and KeywordScore are read from the fused result, so for
+// weighted fusion they are already normalized.
+func ExplainFusion(result *domain.SearchResult, vectorRank, keywordRank int, config FusionConfig) *domain.FusionExplanation {
+	explanation := &domain.FusionExplanation{Strategy: config.Strategy.String(), Score: result.Score}
+	switch config.Strategy {
+	case FusionWeighted:
+		explanation.VectorContribution = float32(config.VectorWeight) * result.VectorScore
+		explanation.KeywordContribution = float32(1-config.VectorWeight) * result.KeywordScore
+	case FusionMax:
+		explanation.VectorContribution = result.VectorScore
+		explanation.KeywordContribution = result.KeywordScore
+	default:
+		if vectorRank > 0 {
+			explanation.VectorContribution = 1.0 / float32(config.RRFConstant+vectorRank)
+		}
+		if keywordRank > 0 {
+			explanation.KeywordContribution = 1.0 / float32(config.RRFConstant+keywordRank)
 		}
-		scores[chunkID].rrfScore += 1.0 / float32(k+rank+1)
-		scores[chunkID].vectorRank = rank + 1
 	}
+	return explanation
+}
 
-	// Process keyword results
-	for rank, result := range keywordResults {
-		chunkID := result.Chunk.ID
-		if _, exists := scores[chunkID]; !exists {
-			scores[chunkID] = &fusionScore{
-				chunk:        result.Chunk,
-				vectorScore:  0,
-				keywordScore: result.Score,
+// reciprocalRankFusion implements RRF over any number of rankings:
+// score = sum(1 / (k + rank_i)) over the rankings a result appears in.
+// This is parameter-free and robust for combining heterogeneous rankers.
+// Each fused result is a copy of the result's first occurrence that keeps the
+// best VectorScore and KeywordScore any ranking gave it; ties are broken by
+// chunk ID.
+func reciprocalRankFusion(rankings [][]*domain.SearchResult, k int) []*domain.SearchResult {
+	byID := make(map[string]*domain.SearchResult)
+	var results []*domain.SearchResult
+	for _, ranking := range rankings {
+		for rank, res := range ranking {
+			fused, ok := byID[res.Chunk.ID]
+			if !ok {
+				copied := *res
+				copied.Score = 0
+				fused = &copied
+				byID[res.Chunk.ID] = fused
+				results = append(results, fused)
 			}
-		} else {
-			scores[chunkID].keywordScore = result.Score
+			fused.Score += 1.0 / float32(k+rank+1)
+			fused.VectorScore = max(fused.VectorScore, res.VectorScore)
+			fused.KeywordScore = max(fused.KeywordScore, res.KeywordScore)
 		}
-		scores[chunkID].rrfScore += 1.0 / float32(k+rank+1)
-		scores[chunkID].keywordRank = rank + 1
-	}
-
-	// Convert to results and sort by RRF score
-	results := make([]*domain.SearchResult, 0, len(scores))
-	for _, fs := range scores {
-		results = append(results, &domain.SearchResult{
-			Chunk:        fs.chunk,
-			Score:        fs.rrfScore,
-			Source:       "hybrid",
-			VectorScore:  fs.vectorScore,
-			KeywordScore: fs.keywordScore,
-		})
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID
 	})
-
 	return results
 }
 
+// FuseRankings fuses several rankings from the same source, such as the
+// results of each query variant, with RRF. A single ranking is returned as
+// is.
+func FuseRankings(rankings [][]*domain.SearchResult, k int) []*domain.SearchResult {
+	if len(rankings) == 1 {
+		return rankings[0]
+	}
+	if k <= 0 {
+		k = DefaultFusionConfig().RRFConstant
+	}
+	return reciprocalRankFusion(rankings, k)
+}
+
+// hybridRRF fuses vector and keyword results with RRF, recording each
+// result's score in its source's field
+func hybridRRF(vectorResults, keywordResults []*domain.SearchResult, k int) []*domain.SearchResult {
+	asSource := func(results []*domain.SearchResult, vector bool) []*domain.SearchResult {
+		out := make([]*domain.SearchResult, len(results))
+		for i, res := range results {
+			out[i] = &domain.SearchResult{Chunk: res.Chunk, Score: res.Score, Source: "hybrid"}
+			if vector {
+				out[i].VectorScore = res.Score
+			} else {
+				out[i].KeywordScore = res.Score
+			}
+		}
+		return out
+	}
+	return reciprocalRankFusion([][]*domain.SearchResult{asSource(vectorResults, true), asSource(keywordResults, false)}, k)
+}
+
 // weightedCombination combines scores using a weighted average
 // finalScore = α * vectorScore + (1-α) * keywordScore
 func weightedCombination(vectorResults, keywordResults []*domain.SearchResult, alpha float64) []*domain.SearchResult {
@@ -272,9 +318,6 @@ type fusionScore struct {
 	chunk        *domain.CodeChunk
 	vectorScore  float32
 	keywordScore float32
-	rrfScore     float32
-	vectorRank   int
-	keywordRank  int
 }
 
 // CalculateRelevance computes a relevance score based on various factors
@@ -141,3 +141,69 @@ func TestTruncateResults_LimitLargerThanResults(t *testing.T) {
 		t.Errorf("TruncateResults() expected 1 result, got %d", len(truncated))
 	}
 }
+
+func TestExplainFusion(t *testing.T) {
+	vector := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "a"}, Score: 0.9},
+		{Chunk: &domain.CodeChunk{ID: "b"}, Score: 0.8},
+	}
+	keyword := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "b"}, Score: 5},
+	}
+
+	config := DefaultFusionConfig()
+	var fusedB *domain.SearchResult
+	for _, res := range FuseResults(vector, keyword, config) {
+		if res.Chunk.ID == "b" {
+			fusedB = res
+		}
+	}
+
+	explanation := ExplainFusion(fusedB, 2, 1, config)
+	if explanation.Strategy != "rrf" {
+		t.Errorf("Strategy = %q, want rrf", explanation.Strategy)
+	}
+	sum := explanation.VectorContribution + explanation.KeywordContribution
+	if diff := sum - fusedB.Score; diff > 1e-6 || diff < -1e-6 {
+		t.Errorf("contributions sum to %v, fused score is %v", sum, fusedB.Score)
+	}
+
+	config.Strategy = FusionWeighted
+	weighted := ExplainFusion(&domain.SearchResult{VectorScore: 1, KeywordScore: 0.5, Score: 0.85}, 1, 1, config)
+	if weighted.Strategy != "weighted" || weighted.VectorContribution != 0.7 {
+		t.Errorf("unexpected weighted explanation: %+v", weighted)
+	}
+}
+
+func TestFuseRankings(t *testing.T) {
+	first := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "a"}, Score: 0.9, VectorScore: 0.9, Source: "vector"},
+		{Chunk: &domain.CodeChunk{ID: "b"}, Score: 0.5, VectorScore: 0.5, Source: "vector"},
+	}
+	second := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "b"}, Score: 0.8, VectorScore: 0.8, Source: "vector"},
+	}
+	third := []*domain.SearchResult{
+		{Chunk: &domain.CodeChunk{ID: "c"}, Score: 0.7, VectorScore: 0.7, Source: "vector"},
+		{Chunk: &domain.CodeChunk{ID: "b"}, Score: 0.6, VectorScore: 0.6, Source: "vector"},
+	}
+
+	if got := FuseRankings([][]*domain.SearchResult{first}, 60); len(got) != 2 || got[0] != first[0] {
+		t.Errorf("a single ranking should be returned as is, got %v", got)
+	}
+
+	fused := FuseRankings([][]*domain.SearchResult{first, second, third}, 60)
+	if ids := []string{fused[0].Chunk.ID, fused[1].Chunk.ID, fused[2].Chunk.ID}; len(fused) != 3 || ids[0] != "b" || ids[1] != "a" || ids[2] != "c" {
+		t.Fatalf("fused order = %v, want b (in all three), then a and c by ID", ids)
+	}
+	b := fused[0]
+	if want := float32(1.0/62 + 1.0/61 + 1.0/62); b.Score-want > 1e-6 || want-b.Score > 1e-6 {
+		t.Errorf("score of b = %v, want %v", b.Score, want)
+	}
+	if b.VectorScore != 0.8 || b.Source != "vector" {
+		t.Errorf("b kept vector score %v and source %q, want the best score and its source", b.VectorScore, b.Source)
+	}
+	if second[0].Score != 0.8 {
+		t.Error("fusing should not modify the input rankings")
+	}
+}
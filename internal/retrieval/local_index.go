@@ -0,0 +1,420 @@
+package retrieval
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/segmentlog"
+)
+
+// defaultMinCompactBytes is the size delta segments may reach before they
+// are compacted into a new base, whatever the size of the base
+const defaultMinCompactBytes = 1 << 20
+
+// LocalIndex is an in-process inverted index for BM25 search, persisted to
+// a segment log in a directory. It stands in for RedisIndex when running
+// without Redis: every write is appended as a delta segment, and the deltas
+// are folded into a new base segment once they outgrow it.
+type LocalIndex struct {
+	mu          sync.RWMutex
+	dir         string
+	k1          float64
+	b           float64
+	docs        map[string]*localDoc
+	files       map[string]map[string]bool // file path -> document IDs
+	postings    map[string]map[string]int  // token -> document ID -> term frequency
+	totalLength int
+	log         *segmentlog.Log[segment]
+}
+
+type localDoc struct {
+	length     int
+	filePath   string
+	generation int64
+	tokens     []string
+}
+
+// segment is the on-disk form of a batch of changes, or of the whole index
+// for a base segment. DocCount and TotalLength are the index stats after the
+// segment is applied and are checked on load.
+type segment struct {
+	Docs        []segmentDoc
+	Postings    map[string][]posting // postings of documents new in this segment
+	Deleted     []string
+	DocCount    int
+	TotalLength int
+}
+
+// segmentDoc is a document added by a segment, or one whose file or
+// generation it changed
+type segmentDoc struct {
+	ID         string
+	Length     int
+	FilePath   string
+	Generation int64
+}
+
+type posting struct {
+	DocID string
+	TF    int
+}
+
+// NewLocalIndex opens the index persisted in dir, creating dir if needed
+func NewLocalIndex(dir string) (*LocalIndex, error) {
+	idx := &LocalIndex{
+		dir:      dir,
+		k1:       1.2,
+		b:        0.75,
+		docs:     make(map[string]*localDoc),
+		files:    make(map[string]map[string]bool),
+		postings: make(map[string]map[string]int),
+	}
+	log, err := segmentlog.Open(dir, "keyword index", defaultMinCompactBytes, idx.replay)
+	if err != nil {
+		return nil, err
+	}
+	idx.log = log
+	if len(idx.docs) > 0 {
+		logger.Info("Loaded keyword index", "dir", dir, "documents", len(idx.docs))
+	}
+	return idx, nil
+}
+
+// SetBM25Params sets the BM25 parameters Search ranks with
+func (l *LocalIndex) SetBM25Params(k1, b float64) {
+	l.mu.Lock()
+	l.k1, l.b = k1, b
+	l.mu.Unlock()
+}
+
+// AddToInvertedIndex adds chunks to the index
+func (l *LocalIndex) AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error {
+	return l.AddDocuments(ctx, indexedDocuments(chunks))
+}
+
+// AddDocuments adds documents to the index. As with RedisIndex, documents
+// already indexed only have their file and generation refreshed.
+func (l *LocalIndex) AddDocuments(_ context.Context, docs []*IndexedDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seg := &segment{Postings: make(map[string][]posting)}
+	seen := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		if seen[doc.ID] {
+			continue
+		}
+		seen[doc.ID] = true
+		seg.Docs = append(seg.Docs, segmentDoc{ID: doc.ID, Length: doc.Length, FilePath: doc.FilePath, Generation: doc.Generation})
+		if _, ok := l.docs[doc.ID]; ok {
+			continue
+		}
+		for token, tf := range doc.Tokens {
+			seg.Postings[token] = append(seg.Postings[token], posting{DocID: doc.ID, TF: tf})
+		}
+	}
+	return l.commit(seg)
+}
+
+// RemoveStale removes the documents of filePath that were not written by
+// generation. Pass 0 to remove all of the file's documents.
+func (l *LocalIndex) RemoveStale(ctx context.Context, filePath string, generation int64) error {
+	return l.RemoveStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath, Generation: generation}})
+}
+
+// RemoveStaleFiles removes the stale documents of several files in one
+// segment
+func (l *LocalIndex) RemoveStaleFiles(_ context.Context, files []domain.StaleFile) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var stale []string
+	for _, f := range files {
+		for id := range l.files[f.FilePath] {
+			if f.Generation == 0 || l.docs[id].generation != f.Generation {
+				stale = append(stale, id)
+			}
+		}
+	}
+	return l.remove(stale)
+}
+
+// RemoveDocument removes a document from the index
+func (l *LocalIndex) RemoveDocument(ctx context.Context, docID string) error {
+	return l.RemoveChunks(ctx, []string{docID})
+}
+
+// RemoveChunks removes documents by ID
+func (l *LocalIndex) RemoveChunks(_ context.Context, docIDs []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.remove(docIDs)
+}
+
+// remove commits the removal of the indexed documents among docIDs
+func (l *LocalIndex) remove(docIDs []string) error {
+	var deleted []string
+	for _, id := range docIDs {
+		if _, ok := l.docs[id]; ok {
+			deleted = append(deleted, id)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	sort.Strings(deleted)
+	return l.commit(&segment{Deleted: deleted})
+}
+
+// ListChunks returns every document in the index with its file and generation
+func (l *LocalIndex) ListChunks(_ context.Context) ([]domain.ChunkRef, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	refs := make([]domain.ChunkRef, 0, len(l.docs))
+	for id, doc := range l.docs {
+		refs = append(refs, domain.ChunkRef{ID: id, FilePath: doc.filePath, Generation: doc.generation})
+	}
+	return refs, nil
+}
+
+// Search returns the IDs of the limit documents that score best by BM25
+// for the given tokens
+func (l *LocalIndex) Search(ctx context.Context, tokens []string, limit int) ([]string, error) {
+	docs, err := l.SearchScored(ctx, tokens, limit)
+	if err != nil || docs == nil {
+		return nil, err
+	}
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
+// SearchScored ranks documents by BM25 and returns the top limit with
+// their scores. A limit of 0 returns every match.
+func (l *LocalIndex) SearchScored(_ context.Context, tokens []string, limit int) ([]ScoredDoc, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	n := len(l.docs)
+	if n == 0 {
+		return []ScoredDoc{}, nil
+	}
+	avgLength := l.avgDocLength()
+	if avgLength <= 0 {
+		avgLength = 1
+	}
+	scores := make(map[string]float64)
+	for _, token := range tokens {
+		postings := l.postings[token]
+		df := len(postings)
+		if df == 0 {
+			continue
+		}
+		idf := math.Log((float64(n)-float64(df)+0.5)/(float64(df)+0.5) + 1.0)
+		for id, tf := range postings {
+			dl := float64(l.docs[id].length)
+			scores[id] += idf * float64(tf) * (l.k1 + 1) / (float64(tf) + l.k1*(1-l.b+l.b*dl/avgLength))
+		}
+	}
+
+	ranked := make([]ScoredDoc, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, ScoredDoc{ID: id, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+// GetTermFrequency returns the term frequency for a token in a document
+func (l *LocalIndex) GetTermFrequency(_ context.Context, token, docID string) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.postings[token][docID], nil
+}
+
+// GetDocFrequency returns the document frequency for a token
+func (l *LocalIndex) GetDocFrequency(_ context.Context, token string) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.postings[token]), nil
+}
+
+// GetDocCount returns the total number of documents in the index
+func (l *LocalIndex) GetDocCount(_ context.Context) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.docs), nil
+}
+
+// GetAvgDocLength returns the average document length
+func (l *LocalIndex) GetAvgDocLength(_ context.Context) (float64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.avgDocLength(), nil
+}
+
+// GetDocLength returns the length of a specific document
+func (l *LocalIndex) GetDocLength(_ context.Context, docID string) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if doc, ok := l.docs[docID]; ok {
+		return doc.length, nil
+	}
+	return 0, nil
+}
+
+func (l *LocalIndex) avgDocLength() float64 {
+	if len(l.docs) == 0 {
+		return 0
+	}
+	return float64(l.totalLength) / float64(len(l.docs))
+}
+
+// commit writes seg as a delta segment and then applies it, so a failed
+// write leaves the index as it was. Once the deltas
+// outgrow the base they are compacted, so the work of rewriting the base is
+// spread over the writes that made it necessary.
+func (l *LocalIndex) commit(seg *segment) error {
+	seg.DocCount, seg.TotalLength = len(l.docs), l.totalLength
+	for _, id := range seg.Deleted {
+		seg.DocCount--
+		seg.TotalLength -= l.docs[id].length
+	}
+	for _, d := range seg.Docs {
+		if _, ok := l.docs[d.ID]; !ok {
+			seg.DocCount++
+			seg.TotalLength += d.Length
+		}
+	}
+
+	due, err := l.log.Append(seg)
+	if err != nil {
+		return err
+	}
+	l.apply(seg)
+	if due {
+		return l.compact()
+	}
+	return nil
+}
+
+// apply adds seg's changes to the in-memory index
+func (l *LocalIndex) apply(seg *segment) {
+	for _, id := range seg.Deleted {
+		doc, ok := l.docs[id]
+		if !ok {
+			continue
+		}
+		for _, token := range doc.tokens {
+			delete(l.postings[token], id)
+			if len(l.postings[token]) == 0 {
+				delete(l.postings, token)
+			}
+		}
+		l.totalLength -= doc.length
+		l.unlinkFile(id, doc.filePath)
+		delete(l.docs, id)
+	}
+
+	for _, d := range seg.Docs {
+		if doc, ok := l.docs[d.ID]; ok {
+			l.unlinkFile(d.ID, doc.filePath)
+			doc.filePath, doc.generation = d.FilePath, d.Generation
+			l.linkFile(d.ID, d.FilePath)
+			continue
+		}
+		l.docs[d.ID] = &localDoc{length: d.Length, filePath: d.FilePath, generation: d.Generation}
+		l.linkFile(d.ID, d.FilePath)
+		l.totalLength += d.Length
+	}
+	for token, postings := range seg.Postings {
+		for _, p := range postings {
+			doc, ok := l.docs[p.DocID]
+			if !ok {
+				continue
+			}
+			if l.postings[token] == nil {
+				l.postings[token] = make(map[string]int)
+			}
+			if _, dup := l.postings[token][p.DocID]; !dup {
+				doc.tokens = append(doc.tokens, token)
+			}
+			l.postings[token][p.DocID] = p.TF
+		}
+	}
+}
+
+// linkFile records document id under filePath
+func (l *LocalIndex) linkFile(id, filePath string) {
+	if l.files[filePath] == nil {
+		l.files[filePath] = make(map[string]bool)
+	}
+	l.files[filePath][id] = true
+}
+
+// unlinkFile drops document id from filePath's documents
+func (l *LocalIndex) unlinkFile(id, filePath string) {
+	delete(l.files[filePath], id)
+	if len(l.files[filePath]) == 0 {
+		delete(l.files, filePath)
+	}
+}
+
+// replay applies a segment read back from the log, checking it against its
+// stats
+func (l *LocalIndex) replay(seg *segment, file string) error {
+	l.apply(seg)
+	if seg.DocCount != len(l.docs) || seg.TotalLength != l.totalLength {
+		return errors.New(errors.ErrorTypeInternal, "keyword index segment "+file+" does not match its stats")
+	}
+	return nil
+}
+
+// compact writes the whole index as a new base segment, replacing the
+// segments before it
+func (l *LocalIndex) compact() error {
+	base := &segment{
+		Docs:        make([]segmentDoc, 0, len(l.docs)),
+		Postings:    make(map[string][]posting, len(l.postings)),
+		DocCount:    len(l.docs),
+		TotalLength: l.totalLength,
+	}
+	for id, doc := range l.docs {
+		base.Docs = append(base.Docs, segmentDoc{ID: id, Length: doc.length, FilePath: doc.filePath, Generation: doc.generation})
+	}
+	for token, postings := range l.postings {
+		list := make([]posting, 0, len(postings))
+		for id, tf := range postings {
+			list = append(list, posting{DocID: id, TF: tf})
+		}
+		base.Postings[token] = list
+	}
+
+	size, err := l.log.Compact(base)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Compacted keyword index", "dir", l.dir, "documents", len(l.docs), "bytes", size)
+	return nil
+}
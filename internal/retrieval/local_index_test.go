@@ -0,0 +1,187 @@
+package retrieval
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/segmentlog"
+)
+
+var localTestChunks = []*domain.CodeChunk{
+	{ID: "a1", FilePath: "auth.go", Generation: 1, Content: "func RefreshToken(token string) error { return validateToken(token) }"},
+	{ID: "a2", FilePath: "auth.go", Generation: 1, Content: "func validateToken(token string) error { return nil }"},
+	{ID: "s1", FilePath: "session.go", Generation: 1, Content: "func PurgeSessions(store Store) { store.Purge() }"},
+	{ID: "c1", FilePath: "cache.go", Generation: 1, Content: "type Cache struct { entries map[string]string }"},
+}
+
+func TestLocalIndex_MatchesRedisIndex(t *testing.T) {
+	ctx := context.Background()
+	redisIdx, mr := setupTestRedis(t)
+	defer mr.Close()
+	local, err := NewLocalIndex(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalIndex() error = %v", err)
+	}
+	for _, idx := range []KeywordIndex{redisIdx, local} {
+		if err := idx.AddToInvertedIndex(ctx, localTestChunks); err != nil {
+			t.Fatalf("AddToInvertedIndex() error = %v", err)
+		}
+		// Re-adding is a no-op apart from the generation
+		if err := idx.AddToInvertedIndex(ctx, localTestChunks[:1]); err != nil {
+			t.Fatalf("AddToInvertedIndex() error = %v", err)
+		}
+	}
+
+	tokens := NewQueryPreprocessor().Preprocess("refresh token store").Filtered
+	want, err := redisIdx.SearchScored(ctx, tokens, 0)
+	if err != nil {
+		t.Fatalf("RedisIndex.SearchScored() error = %v", err)
+	}
+	got, err := local.SearchScored(ctx, tokens, 0)
+	if err != nil {
+		t.Fatalf("LocalIndex.SearchScored() error = %v", err)
+	}
+	if len(got) != len(want) || len(got) == 0 {
+		t.Fatalf("LocalIndex.SearchScored() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || math.Abs(got[i].Score-want[i].Score) > 1e-9 {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// BM25Scorer reads both the same way
+	redisScorer, localScorer := NewBM25Scorer(1.2, 0.75, redisIdx), NewBM25Scorer(1.2, 0.75, local)
+	for _, c := range localTestChunks {
+		w, _ := redisScorer.Score(ctx, tokens, c.ID)
+		g, _ := localScorer.Score(ctx, tokens, c.ID)
+		if math.Abs(g-w) > 1e-9 {
+			t.Errorf("Score(%s) = %v, want %v", c.ID, g, w)
+		}
+	}
+
+	if ids, _ := local.Search(ctx, tokens, 1); len(ids) != 1 || ids[0] != want[0].ID {
+		t.Errorf("Search() with limit 1 = %v, want [%s]", ids, want[0].ID)
+	}
+}
+
+func TestLocalIndex_Persistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	idx, err := NewLocalIndex(dir)
+	if err != nil {
+		t.Fatalf("NewLocalIndex() error = %v", err)
+	}
+	if err := idx.AddToInvertedIndex(ctx, localTestChunks); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	// auth.go is reindexed: a1 is unchanged, a2 is replaced by a3
+	reindexed := []*domain.CodeChunk{
+		{ID: "a1", FilePath: "auth.go", Generation: 2, Content: localTestChunks[0].Content},
+		{ID: "a3", FilePath: "auth.go", Generation: 2, Content: "func validateToken(token string) error { return errExpired }"},
+	}
+	if err := idx.AddToInvertedIndex(ctx, reindexed); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	if err := idx.RemoveStale(ctx, "auth.go", 2); err != nil {
+		t.Fatalf("RemoveStale() error = %v", err)
+	}
+	if err := idx.RemoveChunks(ctx, []string{"c1", "missing"}); err != nil {
+		t.Fatalf("RemoveChunks() error = %v", err)
+	}
+
+	reopened, err := NewLocalIndex(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	assertSameLocalIndex(t, idx, reopened)
+
+	refs, _ := reopened.ListChunks(ctx)
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ID < refs[j].ID })
+	wantRefs := []domain.ChunkRef{{ID: "a1", FilePath: "auth.go", Generation: 2}, {ID: "a3", FilePath: "auth.go", Generation: 2}, {ID: "s1", FilePath: "session.go", Generation: 1}}
+	if !reflect.DeepEqual(refs, wantRefs) {
+		t.Errorf("ListChunks() = %v, want %v", refs, wantRefs)
+	}
+	wantFiles := map[string]map[string]bool{"auth.go": {"a1": true, "a3": true}, "session.go": {"s1": true}}
+	if !reflect.DeepEqual(reopened.files, wantFiles) {
+		t.Errorf("files = %v, want %v", reopened.files, wantFiles)
+	}
+	if df, _ := reopened.GetDocFrequency(ctx, "cache"); df != 0 {
+		t.Errorf("GetDocFrequency(cache) = %d after removal, want 0", df)
+	}
+}
+
+func TestLocalIndex_Compaction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	idx, err := NewLocalIndex(dir)
+	if err != nil {
+		t.Fatalf("NewLocalIndex() error = %v", err)
+	}
+	idx.log.SetMinCompact(0)
+	for _, c := range localTestChunks {
+		if err := idx.AddToInvertedIndex(ctx, []*domain.CodeChunk{c}); err != nil {
+			t.Fatalf("AddToInvertedIndex() error = %v", err)
+		}
+	}
+	if err := idx.RemoveStale(ctx, "cache.go", 0); err != nil {
+		t.Fatalf("RemoveStale() error = %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	bases := 0
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), segmentlog.BaseExt) {
+			bases++
+		}
+	}
+	if bases != 1 || len(entries) > 3 {
+		t.Errorf("segments after compaction = %d (%d bases), want one base and few deltas", len(entries), bases)
+	}
+
+	reopened, err := NewLocalIndex(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	assertSameLocalIndex(t, idx, reopened)
+	if n, _ := reopened.GetDocCount(ctx); n != 3 {
+		t.Errorf("GetDocCount() = %d, want 3", n)
+	}
+}
+
+func TestLocalIndex_CorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := NewLocalIndex(dir)
+	if err != nil {
+		t.Fatalf("NewLocalIndex() error = %v", err)
+	}
+	if err := idx.AddToInvertedIndex(context.Background(), localTestChunks); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "00000002.delta"), []byte("not a segment"), 0o644)
+
+	if _, err := NewLocalIndex(dir); !errors.Is(err, errors.ErrorTypeInternal) {
+		t.Errorf("NewLocalIndex() error = %v, want an internal error", err)
+	}
+}
+
+func assertSameLocalIndex(t *testing.T, want, got *LocalIndex) {
+	t.Helper()
+	if len(got.docs) != len(want.docs) || got.totalLength != want.totalLength {
+		t.Fatalf("reopened index has %d docs of total length %d, want %d and %d", len(got.docs), got.totalLength, len(want.docs), want.totalLength)
+	}
+	if !reflect.DeepEqual(got.postings, want.postings) {
+		t.Errorf("reopened postings differ:\n got %v\nwant %v", got.postings, want.postings)
+	}
+	if !reflect.DeepEqual(got.files, want.files) {
+		t.Errorf("reopened files differ:\n got %v\nwant %v", got.files, want.files)
+	}
+}
@@ -5,64 +5,120 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/Guru2308/rag-code/internal/domain"
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisIndex implements a Redis-backed inverted index for BM25 search
+// defaultMaxPostings is the number of postings Search reads per token
+const defaultMaxPostings = 10000
+
+// RedisIndex implements a Redis-backed inverted index for BM25 search. Each
+// token's postings are a sorted set of document IDs scored by term
+// frequency, so a token's document frequency is the set's cardinality and
+// Search can rank documents by BM25 inside Redis.
+//
+// Search passes every key it reads to Redis, so on Redis Cluster they must
+// hash to one slot: give the index a key prefix with a hash tag, such as
+// "rag:{keyword}:".
 type RedisIndex struct {
-	client    *redis.Client
-	keyPrefix string
+	client      *redis.Client
+	keyPrefix   string
+	k1          float64
+	b           float64
+	maxPostings int
 }
 
 // IndexedDocument represents a document in the inverted index
 type IndexedDocument struct {
-	ID      string
-	Content string
-	Length  int
-	Tokens  map[string]int // token -> term frequency
+	ID         string
+	Content    string
+	Length     int
+	Tokens     map[string]int // token -> term frequency
+	FilePath   string         // source file, used to replace a file's documents on reindex
+	Generation int64          // indexing run that produced the document
 }
 
 // NewRedisIndex creates a new Redis-backed inverted index
 func NewRedisIndex(client *redis.Client, keyPrefix string) *RedisIndex {
 	return &RedisIndex{
-		client:    client,
-		keyPrefix: keyPrefix,
+		client:      client,
+		keyPrefix:   keyPrefix,
+		k1:          1.2,
+		b:           0.75,
+		maxPostings: defaultMaxPostings,
 	}
 }
 
-// AddDocuments adds multiple documents to the inverted index
+// SetBM25Params sets the BM25 parameters Search ranks with. They should
+// match the BM25Scorer's so both agree on scores.
+func (r *RedisIndex) SetBM25Params(k1, b float64) {
+	r.k1, r.b = k1, b
+}
+
+// SetMaxPostings caps the postings Search reads per token; 0 reads them
+// all. See bm25Script for what the cap costs in accuracy.
+func (r *RedisIndex) SetMaxPostings(n int) {
+	r.maxPostings = n
+}
+
+// AddDocuments adds multiple documents to the inverted index. Documents that
+// are already indexed (chunk IDs are content hashes, so the content is the
+// same) only have their file generation refreshed; postings and document
+// frequencies are never counted twice.
 func (r *RedisIndex) AddDocuments(ctx context.Context, docs []*IndexedDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	existsPipe := r.client.Pipeline()
+	exists := make([]*redis.BoolCmd, len(docs))
+	for i, doc := range docs {
+		exists[i] = existsPipe.HExists(ctx, r.docLengthsKey(), doc.ID)
+	}
+	if _, err := existsPipe.Exec(ctx); err != nil {
+		return err
+	}
+
 	pipe := r.client.Pipeline()
+	var added []*IndexedDocument
+	seen := make(map[string]bool, len(docs))
+	for i, doc := range docs {
+		if doc.FilePath != "" {
+			pipe.HSet(ctx, r.fileDocsKey(doc.FilePath), doc.ID, doc.Generation)
+			pipe.Set(ctx, r.docFileKey(doc.ID), doc.FilePath, 0)
+		}
+		if exists[i].Val() || seen[doc.ID] {
+			continue
+		}
+		seen[doc.ID] = true
+		added = append(added, doc)
 
-	for _, doc := range docs {
 		// Store document metadata
-		pipe.Set(ctx, r.docLengthKey(doc.ID), doc.Length, 0)
+		pipe.HSet(ctx, r.docLengthsKey(), doc.ID, doc.Length)
 		pipe.Set(ctx, r.docContentKey(doc.ID), doc.Content[:min(200, len(doc.Content))], 0)
 
 		// Add to inverted index
 		for token, freq := range doc.Tokens {
-			// Add document to token's posting list
-			pipe.SAdd(ctx, r.tokenIndexKey(token), doc.ID)
-
-			// Store term frequency
-			pipe.Set(ctx, r.termFreqKey(token, doc.ID), freq, 0)
+			// Add document to token's postings, scored by term frequency
+			pipe.ZAdd(ctx, r.postingsKey(token), redis.Z{Score: float64(freq), Member: doc.ID})
 
-			// Increment document frequency for this token
-			pipe.Incr(ctx, r.docFreqKey(token))
+			// Remember the token so the document can be removed later
+			pipe.SAdd(ctx, r.docTokensKey(doc.ID), token)
 		}
 	}
 
-	// Increment total document count
-	pipe.IncrBy(ctx, r.statsKey("doc_count"), int64(len(docs)))
+	if len(added) > 0 {
+		// Increment total document count
+		pipe.IncrBy(ctx, r.statsKey("doc_count"), int64(len(added)))
+
+		// Update average document length
+		totalLength := 0
+		for _, doc := range added {
+			totalLength += doc.Length
+		}
 
-	// Update average document length
-	totalLength := 0
-	for _, doc := range docs {
-		totalLength += doc.Length
-	}
-	if len(docs) > 0 {
 		// Get current stats
 		docCount, err := r.GetDocCount(ctx)
 		if err != nil {
@@ -75,7 +131,7 @@ func (r *RedisIndex) AddDocuments(ctx context.Context, docs []*IndexedDocument)
 		}
 
 		// Calculate new average
-		newCount := docCount + len(docs)
+		newCount := docCount + len(added)
 		newAvg := (currentAvg*float64(docCount) + float64(totalLength)) / float64(newCount)
 		pipe.Set(ctx, r.statsKey("avg_doc_length"), newAvg, 0)
 	}
@@ -86,6 +142,11 @@ func (r *RedisIndex) AddDocuments(ctx context.Context, docs []*IndexedDocument)
 
 // AddToInvertedIndex adds chunks to the inverted index (adapting domain.CodeChunk)
 func (r *RedisIndex) AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error {
+	return r.AddDocuments(ctx, indexedDocuments(chunks))
+}
+
+// indexedDocuments tokenizes chunks for a keyword index
+func indexedDocuments(chunks []*domain.CodeChunk) []*IndexedDocument {
 	indexedDocs := make([]*IndexedDocument, len(chunks))
 	preprocessor := NewQueryPreprocessor() // Use default preprocessor
 
@@ -98,82 +159,364 @@ func (r *RedisIndex) AddToInvertedIndex(ctx context.Context, chunks []*domain.Co
 		}
 
 		indexedDocs[i] = &IndexedDocument{
-			ID:      chunk.ID,
-			Content: chunk.Content,
-			Length:  len(processed.Tokens),
-			Tokens:  tf,
+			ID:         chunk.ID,
+			Content:    chunk.Content,
+			Length:     len(processed.Tokens),
+			Tokens:     tf,
+			FilePath:   chunk.FilePath,
+			Generation: chunk.Generation,
+		}
+	}
+	return indexedDocs
+}
+
+// RemoveStale removes the documents of filePath that were not written by
+// generation. Pass 0 to remove all of the file's documents.
+func (r *RedisIndex) RemoveStale(ctx context.Context, filePath string, generation int64) error {
+	return r.RemoveStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath, Generation: generation}})
+}
+
+// RemoveStaleFiles removes the stale documents of several files, reading and
+// writing each stage in one pipelined round trip
+func (r *RedisIndex) RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	readPipe := r.client.Pipeline()
+	fileDocs := make([]*redis.MapStringStringCmd, len(files))
+	for i, f := range files {
+		fileDocs[i] = readPipe.HGetAll(ctx, r.fileDocsKey(f.FilePath))
+	}
+	if _, err := readPipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	var stale []string
+	pipe := r.client.Pipeline()
+	for i, f := range files {
+		docs := fileDocs[i].Val()
+		var fileStale []string
+		for docID, gen := range docs {
+			if f.Generation == 0 || gen != strconv.FormatInt(f.Generation, 10) {
+				fileStale = append(fileStale, docID)
+			}
+		}
+		switch {
+		case len(fileStale) == 0:
+			continue
+		case len(fileStale) == len(docs):
+			pipe.Del(ctx, r.fileDocsKey(f.FilePath))
+		default:
+			pipe.HDel(ctx, r.fileDocsKey(f.FilePath), fileStale...)
 		}
+		stale = append(stale, fileStale...)
+	}
+	if len(stale) == 0 {
+		return nil
 	}
 
-	return r.AddDocuments(ctx, indexedDocs)
+	if err := r.removeDocuments(ctx, stale); err != nil {
+		return err
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 // RemoveDocument removes a document from the inverted index
 func (r *RedisIndex) RemoveDocument(ctx context.Context, docID string) error {
-	// Get all tokens for this document (we need to scan)
-	// This is expensive - in production, we'd store a doc->tokens mapping
+	filePath, err := r.client.Get(ctx, r.docFileKey(docID)).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if err := r.removeDocuments(ctx, []string{docID}); err != nil {
+		return err
+	}
+	if filePath != "" {
+		return r.client.HDel(ctx, r.fileDocsKey(filePath), docID).Err()
+	}
+	return nil
+}
+
+// removeDocuments drops documents from their posting lists and updates the
+// corpus stats.
+func (r *RedisIndex) removeDocuments(ctx context.Context, docIDs []string) error {
+	readPipe := r.client.Pipeline()
+	lengths := make([]*redis.StringCmd, len(docIDs))
+	tokens := make([]*redis.StringSliceCmd, len(docIDs))
+	for i, docID := range docIDs {
+		lengths[i] = readPipe.HGet(ctx, r.docLengthsKey(), docID)
+		tokens[i] = readPipe.SMembers(ctx, r.docTokensKey(docID))
+	}
+	if _, err := readPipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
 
 	pipe := r.client.Pipeline()
+	removed, removedLength := 0, 0
+	for i, docID := range docIDs {
+		length, err := lengths[i].Int()
+		if err != nil {
+			continue // not indexed
+		}
+		removed++
+		removedLength += length
 
-	// Remove document metadata
-	pipe.Del(ctx, r.docLengthKey(docID))
-	pipe.Del(ctx, r.docContentKey(docID))
+		for _, token := range tokens[i].Val() {
+			pipe.ZRem(ctx, r.postingsKey(token), docID)
+		}
 
-	// Decrement doc count
-	pipe.Decr(ctx, r.statsKey("doc_count"))
+		// Remove document metadata
+		pipe.HDel(ctx, r.docLengthsKey(), docID)
+		pipe.Del(ctx, r.docContentKey(docID), r.docTokensKey(docID), r.docFileKey(docID))
+	}
+	if removed == 0 {
+		return nil
+	}
 
-	_, err := pipe.Exec(ctx)
+	docCount, err := r.GetDocCount(ctx)
+	if err != nil {
+		return err
+	}
+	currentAvg, err := r.GetAvgDocLength(ctx)
+	if err != nil {
+		return err
+	}
+	newAvg := 0.0
+	if remaining := docCount - removed; remaining > 0 {
+		newAvg = max(0, (currentAvg*float64(docCount)-float64(removedLength))/float64(remaining))
+	}
+	pipe.DecrBy(ctx, r.statsKey("doc_count"), int64(removed))
+	pipe.Set(ctx, r.statsKey("avg_doc_length"), newAvg, 0)
+
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
-// Search returns document IDs that contain any of the given tokens
+// ListChunks returns every document in the index with its file and
+// generation. Documents indexed without a file, and IDs left in posting lists
+// after their document was removed, are returned with an empty FilePath.
+func (r *RedisIndex) ListChunks(ctx context.Context) ([]domain.ChunkRef, error) {
+	refs := make(map[string]domain.ChunkRef)
+
+	// Documents with a length are indexed
+	ids, err := r.client.HKeys(ctx, r.docLengthsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		refs[id] = domain.ChunkRef{ID: id}
+	}
+
+	// Files map their documents to the generation that wrote them
+	if err := r.scanKeys(ctx, r.fileDocsKey("*"), func(key string) error {
+		filePath := strings.TrimSuffix(strings.TrimPrefix(key, r.keyPrefix+"file:"), ":docs")
+		docs, err := r.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		for id, gen := range docs {
+			generation, _ := strconv.ParseInt(gen, 10, 64)
+			refs[id] = domain.ChunkRef{ID: id, FilePath: filePath, Generation: generation}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Posting list members whose document is gone
+	if err := r.scanKeys(ctx, r.postingsKey("*"), func(key string) error {
+		members, err := r.client.ZRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		for _, id := range members {
+			if _, ok := refs[id]; !ok {
+				refs[id] = domain.ChunkRef{ID: id}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	list := make([]domain.ChunkRef, 0, len(refs))
+	for _, ref := range refs {
+		list = append(list, ref)
+	}
+	return list, nil
+}
+
+// RemoveChunks removes documents by ID, including any posting list entries
+// that outlived their document.
+func (r *RedisIndex) RemoveChunks(ctx context.Context, docIDs []string) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	for _, docID := range docIDs {
+		if err := r.RemoveDocument(ctx, docID); err != nil {
+			return err
+		}
+	}
+	return r.purgePostings(ctx, docIDs)
+}
+
+// purgePostings drops docIDs from every posting list that still holds them
+func (r *RedisIndex) purgePostings(ctx context.Context, docIDs []string) error {
+	members := make([]any, len(docIDs))
+	for i, id := range docIDs {
+		members[i] = id
+	}
+	return r.scanKeys(ctx, r.postingsKey("*"), func(key string) error {
+		return r.client.ZRem(ctx, key, members...).Err()
+	})
+}
+
+// scanKeys calls fn for every key matching pattern
+func (r *RedisIndex) scanKeys(ctx context.Context, pattern string, fn func(key string) error) error {
+	iter := r.client.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// ScoredDoc is a document ranked by Search, with its BM25 score
+type ScoredDoc struct {
+	ID    string
+	Score float64
+}
+
+// bm25Script ranks documents by BM25 and returns the top ARGV[3] (all when
+// 0) as ID, score pairs, best first. KEYS[1] and KEYS[2] are the document
+// count and average length, KEYS[3] the hash of document lengths, and the
+// rest the tokens' posting lists; every key the script touches is declared,
+// as Redis Cluster requires.
+//
+// The script runs atomically, so Redis serves nothing else while it scores.
+// Its cost is the postings it reads plus sorting the documents they match,
+// so a token's postings are capped at ARGV[4] (no cap when 0): a token in
+// more documents than that keeps its true document frequency, but only the
+// documents where it is most frequent are scored for it. Such a token has a
+// low IDF, so this only drops small contributions to other documents' scores.
+// Scores are returned as strings because Redis truncates Lua numbers.
+var bm25Script = redis.NewScript(`
+local k1, b, limit, cap = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+local avgdl = tonumber(redis.call('GET', KEYS[2]) or '0')
+if n == 0 then
+  return {}
+end
+if avgdl <= 0 then
+  avgdl = 1
+end
+
+local scores, lengths = {}, {}
+for k = 4, #KEYS do
+  local df = redis.call('ZCARD', KEYS[k])
+  if df > 0 then
+    local postings
+    if cap > 0 and df > cap then
+      postings = redis.call('ZREVRANGE', KEYS[k], 0, cap - 1, 'WITHSCORES')
+    else
+      postings = redis.call('ZRANGE', KEYS[k], 0, -1, 'WITHSCORES')
+    end
+    local idf = math.log((n - df + 0.5) / (df + 0.5) + 1)
+    for i = 1, #postings, 2 do
+      local id, tf = postings[i], tonumber(postings[i + 1])
+      local dl = lengths[id]
+      if dl == nil then
+        dl = tonumber(redis.call('HGET', KEYS[3], id) or '0')
+        lengths[id] = dl
+      end
+      scores[id] = (scores[id] or 0) + idf * tf * (k1 + 1) / (tf + k1 * (1 - b + b * dl / avgdl))
+    end
+  end
+end
+
+local ranked = {}
+for id, score in pairs(scores) do
+  ranked[#ranked + 1] = {id, score}
+end
+table.sort(ranked, function(x, y)
+  if x[2] ~= y[2] then
+    return x[2] > y[2]
+  end
+  return x[1] < y[1]
+end)
+
+local out = {}
+if limit <= 0 or limit > #ranked then
+  limit = #ranked
+end
+for i = 1, limit do
+  out[#out + 1] = ranked[i][1]
+  out[#out + 1] = string.format('%.17g', ranked[i][2])
+end
+return out
+`)
+
+// Search returns the IDs of the limit documents that score best by BM25
+// for the given tokens
 func (r *RedisIndex) Search(ctx context.Context, tokens []string, limit int) ([]string, error) {
+	docs, err := r.SearchScored(ctx, tokens, limit)
+	if err != nil || docs == nil {
+		return nil, err
+	}
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
+// SearchScored ranks documents by BM25 in Redis and returns the top limit
+// with their scores, in one round trip. A limit of 0 returns every match.
+func (r *RedisIndex) SearchScored(ctx context.Context, tokens []string, limit int) ([]ScoredDoc, error) {
 	if len(tokens) == 0 {
 		return nil, nil
 	}
 
-	// Get all document IDs that contain at least one token
-	// Use SUNION to combine all posting lists
-	keys := make([]string, len(tokens))
-	for i, token := range tokens {
-		keys[i] = r.tokenIndexKey(token)
+	keys := []string{r.statsKey("doc_count"), r.statsKey("avg_doc_length"), r.docLengthsKey()}
+	for _, token := range tokens {
+		keys = append(keys, r.postingsKey(token))
 	}
-
-	docIDs, err := r.client.SUnion(ctx, keys...).Result()
+	reply, err := bm25Script.Run(ctx, r.client, keys, r.k1, r.b, limit, r.maxPostings).StringSlice()
 	if err != nil {
 		return nil, err
 	}
 
-	// Limit results
-	if limit > 0 && len(docIDs) > limit {
-		docIDs = docIDs[:limit]
+	docs := make([]ScoredDoc, 0, len(reply)/2)
+	for i := 0; i+1 < len(reply); i += 2 {
+		score, err := strconv.ParseFloat(reply[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, ScoredDoc{ID: reply[i], Score: score})
 	}
-
-	return docIDs, nil
+	return docs, nil
 }
 
 // GetTermFrequency returns the term frequency for a token in a document
 func (r *RedisIndex) GetTermFrequency(ctx context.Context, token, docID string) (int, error) {
-	val, err := r.client.Get(ctx, r.termFreqKey(token, docID)).Result()
+	tf, err := r.client.ZScore(ctx, r.postingsKey(token), docID).Result()
 	if err == redis.Nil {
 		return 0, nil
 	}
 	if err != nil {
 		return 0, err
 	}
-	return strconv.Atoi(val)
+	return int(tf), nil
 }
 
 // GetDocFrequency returns the document frequency for a token
 func (r *RedisIndex) GetDocFrequency(ctx context.Context, token string) (int, error) {
-	val, err := r.client.Get(ctx, r.docFreqKey(token)).Result()
-	if err == redis.Nil {
-		return 0, nil
-	}
+	df, err := r.client.ZCard(ctx, r.postingsKey(token)).Result()
 	if err != nil {
 		return 0, err
 	}
-	return strconv.Atoi(val)
+	return int(df), nil
 }
 
 // GetDocCount returns the total number of documents in the index
@@ -202,7 +545,7 @@ func (r *RedisIndex) GetAvgDocLength(ctx context.Context) (float64, error) {
 
 // GetDocLength returns the length of a specific document
 func (r *RedisIndex) GetDocLength(ctx context.Context, docID string) (int, error) {
-	val, err := r.client.Get(ctx, r.docLengthKey(docID)).Result()
+	val, err := r.client.HGet(ctx, r.docLengthsKey(), docID).Result()
 	if err == redis.Nil {
 		return 0, nil
 	}
@@ -212,6 +555,133 @@ func (r *RedisIndex) GetDocLength(ctx context.Context, docID string) (int, error
 	return strconv.Atoi(val)
 }
 
+// GetBM25Stats reads the collection stats, document lengths, document
+// frequencies and term frequencies needed to score docIDs for tokens, in one
+// pipelined round trip
+func (r *RedisIndex) GetBM25Stats(ctx context.Context, tokens, docIDs []string) (*BM25Stats, error) {
+	pipe := r.client.Pipeline()
+	docCount := pipe.Get(ctx, r.statsKey("doc_count"))
+	avgLength := pipe.Get(ctx, r.statsKey("avg_doc_length"))
+	lengths := make([]*redis.StringCmd, len(docIDs))
+	for i, docID := range docIDs {
+		lengths[i] = pipe.HGet(ctx, r.docLengthsKey(), docID)
+	}
+	dfs := make([]*redis.IntCmd, len(tokens))
+	for i, token := range tokens {
+		dfs[i] = pipe.ZCard(ctx, r.postingsKey(token))
+	}
+	tfs := make([][]*redis.FloatCmd, len(docIDs))
+	for i, docID := range docIDs {
+		tfs[i] = make([]*redis.FloatCmd, len(tokens))
+		for j, token := range tokens {
+			tfs[i][j] = pipe.ZScore(ctx, r.postingsKey(token), docID)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	// Missing keys read as zero, like the single-key getters
+	stats := &BM25Stats{
+		DocLengths: make(map[string]int, len(docIDs)),
+		DocFreqs:   make(map[string]int, len(tokens)),
+		TermFreqs:  make(map[string]map[string]int, len(docIDs)),
+	}
+	stats.DocCount, _ = docCount.Int()
+	stats.AvgDocLength, _ = avgLength.Float64()
+	for i, token := range tokens {
+		stats.DocFreqs[token] = int(dfs[i].Val())
+	}
+	for i, docID := range docIDs {
+		stats.DocLengths[docID], _ = lengths[i].Int()
+		stats.TermFreqs[docID] = make(map[string]int, len(tokens))
+		for j, token := range tokens {
+			stats.TermFreqs[docID][token] = int(tfs[i][j].Val())
+		}
+	}
+	return stats, nil
+}
+
+// Migrate converts keys written by older versions: each token's set of
+// document IDs and its per-document term frequency keys become one sorted
+// set, dropping its stored document frequency, and per-document length keys
+// are moved into the hash of document lengths. It returns the number of
+// tokens and documents converted.
+func (r *RedisIndex) Migrate(ctx context.Context) (int, error) {
+	tokens, err := r.migratePostings(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	docs, err := r.migrateDocLengths(ctx)
+	return tokens + docs, err
+}
+
+// migratePostings converts posting lists written before postings were sorted
+// sets and returns the number of tokens converted
+func (r *RedisIndex) migratePostings(ctx context.Context) (int, error) {
+	tokenPrefix := r.keyPrefix + "index:token:"
+	migrated := 0
+	err := r.scanKeys(ctx, r.tokenIndexKey("*"), func(key string) error {
+		token := strings.TrimPrefix(key, tokenPrefix)
+		docIDs, err := r.client.SMembers(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		readPipe := r.client.Pipeline()
+		tfs := make([]*redis.StringCmd, len(docIDs))
+		for i, docID := range docIDs {
+			tfs[i] = readPipe.Get(ctx, r.termFreqKey(token, docID))
+		}
+		if _, err := readPipe.Exec(ctx); err != nil && err != redis.Nil {
+			return err
+		}
+
+		pipe := r.client.TxPipeline()
+		for i, docID := range docIDs {
+			tf, err := tfs[i].Int()
+			if err != nil {
+				tf = 1 // the posting outlived its term frequency
+			}
+			pipe.ZAdd(ctx, r.postingsKey(token), redis.Z{Score: float64(tf), Member: docID})
+			pipe.Del(ctx, r.termFreqKey(token, docID))
+		}
+		pipe.Del(ctx, key, r.docFreqKey(token))
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		migrated++
+		return nil
+	})
+	return migrated, err
+}
+
+// migrateDocLengths moves per-document length keys into the hash of document
+// lengths and returns the number of documents moved
+func (r *RedisIndex) migrateDocLengths(ctx context.Context) (int, error) {
+	migrated := 0
+	err := r.scanKeys(ctx, r.docLengthKey("*"), func(key string) error {
+		length, err := r.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(key, r.keyPrefix+"doc:"), ":length")
+		// Written before the key is deleted, so an interrupted move is redone
+		pipe := r.client.Pipeline()
+		pipe.HSet(ctx, r.docLengthsKey(), id, length)
+		pipe.Del(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		migrated++
+		return nil
+	})
+	return migrated, err
+}
+
 // GetDocumentsByIDs retrieves document content previews
 func (r *RedisIndex) GetDocumentsByIDs(ctx context.Context, docIDs []string) (map[string]string, error) {
 	if len(docIDs) == 0 {
@@ -269,6 +739,11 @@ func (r *RedisIndex) Clear(ctx context.Context) error {
 }
 
 // Key generation helpers
+func (r *RedisIndex) postingsKey(token string) string {
+	return fmt.Sprintf("%sindex:postings:%s", r.keyPrefix, token)
+}
+
+// Keys of older layouts, read by Migrate
 func (r *RedisIndex) tokenIndexKey(token string) string {
 	return fmt.Sprintf("%sindex:token:%s", r.keyPrefix, token)
 }
@@ -285,10 +760,27 @@ func (r *RedisIndex) docLengthKey(docID string) string {
 	return fmt.Sprintf("%sdoc:%s:length", r.keyPrefix, docID)
 }
 
+// docLengthsKey is the hash of every document's length, keyed by ID
+func (r *RedisIndex) docLengthsKey() string {
+	return r.statsKey("doc_lengths")
+}
+
 func (r *RedisIndex) docContentKey(docID string) string {
 	return fmt.Sprintf("%sdoc:%s:content", r.keyPrefix, docID)
 }
 
+func (r *RedisIndex) docTokensKey(docID string) string {
+	return fmt.Sprintf("%sdoc:%s:tokens", r.keyPrefix, docID)
+}
+
+func (r *RedisIndex) docFileKey(docID string) string {
+	return fmt.Sprintf("%sdoc:%s:file", r.keyPrefix, docID)
+}
+
+func (r *RedisIndex) fileDocsKey(filePath string) string {
+	return fmt.Sprintf("%sfile:%s:docs", r.keyPrefix, filePath)
+}
+
 func (r *RedisIndex) statsKey(name string) string {
 	return fmt.Sprintf("%sstats:%s", r.keyPrefix, name)
 }
@@ -2,6 +2,10 @@ package retrieval
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/Guru2308/rag-code/internal/domain"
@@ -152,6 +156,141 @@ func TestRedisIndex_RemoveDocument(t *testing.T) {
 	}
 }
 
+func TestRedisIndex_RemoveStale(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	oldGen := []*domain.CodeChunk{
+		{ID: "keep", Content: "func shared() {}", FilePath: "a.go", Generation: 1},
+		{ID: "gone", Content: "func removed() {}", FilePath: "a.go", Generation: 1},
+	}
+	if err := idx.AddToInvertedIndex(ctx, oldGen); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+
+	// Reindex: the unchanged chunk is re-added under the new generation
+	newGen := []*domain.CodeChunk{
+		{ID: "keep", Content: "func shared() {}", FilePath: "a.go", Generation: 2},
+		{ID: "added", Content: "func added() {}", FilePath: "a.go", Generation: 2},
+	}
+	if err := idx.AddToInvertedIndex(ctx, newGen); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	if df, _ := idx.GetDocFrequency(ctx, "shared"); df != 1 {
+		t.Errorf("re-adding a document changed doc frequency: got %d, want 1", df)
+	}
+
+	if err := idx.RemoveStale(ctx, "a.go", 2); err != nil {
+		t.Fatalf("RemoveStale() error = %v", err)
+	}
+
+	if count, _ := idx.GetDocCount(ctx); count != 2 {
+		t.Errorf("GetDocCount() = %d, want 2", count)
+	}
+	if ids, _ := idx.Search(ctx, []string{"removed"}, 10); len(ids) != 0 {
+		t.Errorf("Search(removed) = %v, want no results", ids)
+	}
+	if ids, _ := idx.Search(ctx, []string{"shared"}, 10); len(ids) != 1 || ids[0] != "keep" {
+		t.Errorf("Search(shared) = %v, want [keep]", ids)
+	}
+
+	// Generation 0 removes the whole file
+	if err := idx.RemoveStale(ctx, "a.go", 0); err != nil {
+		t.Fatalf("RemoveStale() error = %v", err)
+	}
+	if count, _ := idx.GetDocCount(ctx); count != 0 {
+		t.Errorf("GetDocCount() after removing file = %d, want 0", count)
+	}
+	if mr.Exists("test:file:a.go:docs") {
+		t.Error("file document set should be deleted")
+	}
+}
+
+func TestRedisIndex_RemoveStaleFiles(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	chunks := []*domain.CodeChunk{
+		{ID: "a1", Content: "func alpha() {}", FilePath: "a.go", Generation: 1},
+		{ID: "a2", Content: "func alphaNext() {}", FilePath: "a.go", Generation: 2},
+		{ID: "b1", Content: "func beta() {}", FilePath: "b.go", Generation: 1},
+		{ID: "c1", Content: "func gamma() {}", FilePath: "c.go", Generation: 1},
+	}
+	if err := idx.AddToInvertedIndex(ctx, chunks); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+
+	// a.go keeps generation 2, b.go is dropped and d.go was never indexed
+	files := []domain.StaleFile{{FilePath: "a.go", Generation: 2}, {FilePath: "b.go"}, {FilePath: "d.go"}}
+	if err := idx.RemoveStaleFiles(ctx, files); err != nil {
+		t.Fatalf("RemoveStaleFiles() error = %v", err)
+	}
+	refs, _ := idx.ListChunks(ctx)
+	var ids []string
+	for _, ref := range refs {
+		ids = append(ids, ref.ID)
+	}
+	sort.Strings(ids)
+	if !reflect.DeepEqual(ids, []string{"a2", "c1"}) {
+		t.Errorf("documents left = %v, want [a2 c1]", ids)
+	}
+	if count, _ := idx.GetDocCount(ctx); count != 2 {
+		t.Errorf("GetDocCount() = %d, want 2", count)
+	}
+	if mr.Exists("test:file:b.go:docs") || !mr.Exists("test:file:a.go:docs") {
+		t.Error("only the emptied file's document set should be deleted")
+	}
+}
+
+func TestRedisIndex_ListAndRemoveChunks(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	chunks := []*domain.CodeChunk{
+		{ID: "a1", Content: "func alpha() {}", FilePath: "a.go", Generation: 7},
+		{ID: "b1", Content: "func beta() {}"},
+	}
+	if err := idx.AddToInvertedIndex(ctx, chunks); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	// A posting left behind by a document removed without cleanup
+	mr.ZAdd("test:index:postings:alpha", 1, "leaked")
+
+	refs, err := idx.ListChunks(ctx)
+	if err != nil {
+		t.Fatalf("ListChunks() error = %v", err)
+	}
+	byID := make(map[string]domain.ChunkRef)
+	for _, ref := range refs {
+		byID[ref.ID] = ref
+	}
+	if len(byID) != 3 {
+		t.Fatalf("ListChunks() = %v, want a1, b1 and leaked", refs)
+	}
+	if ref := byID["a1"]; ref.FilePath != "a.go" || ref.Generation != 7 {
+		t.Errorf("a1 = %+v, want file a.go generation 7", ref)
+	}
+	if ref := byID["leaked"]; ref.FilePath != "" {
+		t.Errorf("leaked posting should have no file, got %+v", ref)
+	}
+
+	if err := idx.RemoveChunks(ctx, []string{"leaked", "b1"}); err != nil {
+		t.Fatalf("RemoveChunks() error = %v", err)
+	}
+	if ids, _ := idx.Search(ctx, []string{"alpha"}, 10); len(ids) != 1 || ids[0] != "a1" {
+		t.Errorf("Search(alpha) = %v, want [a1]", ids)
+	}
+	if count, _ := idx.GetDocCount(ctx); count != 1 {
+		t.Errorf("GetDocCount() = %d, want 1", count)
+	}
+	if df, _ := idx.GetDocFrequency(ctx, "alpha"); df != 1 {
+		t.Errorf("GetDocFrequency(alpha) = %d, want 1", df)
+	}
+}
+
 func TestRedisIndex_Search(t *testing.T) {
 	idx, mr := setupTestRedis(t)
 	defer mr.Close()
@@ -182,6 +321,137 @@ func TestRedisIndex_Search(t *testing.T) {
 	}
 }
 
+func TestRedisIndex_Search_RanksByBM25(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	// Many weak matches and one strong one: the strong one must survive a
+	// limit of one
+	var docs []*IndexedDocument
+	for i := 0; i < 20; i++ {
+		docs = append(docs, &IndexedDocument{ID: fmt.Sprintf("weak%02d", i), Length: 40, Tokens: map[string]int{"token": 1, "filler": 39}})
+	}
+	docs = append(docs, &IndexedDocument{ID: "strong", Length: 6, Tokens: map[string]int{"token": 3, "refresh": 3}})
+	if err := idx.AddDocuments(ctx, docs); err != nil {
+		t.Fatalf("AddDocuments() error = %v", err)
+	}
+
+	tokens := []string{"token", "refresh"}
+	ids, err := idx.Search(ctx, tokens, 1)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "strong" {
+		t.Errorf("Search() = %v, want [strong]", ids)
+	}
+
+	// Scores from Redis match the BM25 scorer's
+	scored, err := idx.SearchScored(ctx, tokens, 0)
+	if err != nil {
+		t.Fatalf("SearchScored() error = %v", err)
+	}
+	if len(scored) != 21 {
+		t.Fatalf("SearchScored() returned %d documents, want 21", len(scored))
+	}
+	scorer := NewBM25Scorer(1.2, 0.75, idx)
+	for _, doc := range []ScoredDoc{scored[0], scored[20]} {
+		want, _ := scorer.Score(ctx, tokens, doc.ID)
+		if math.Abs(doc.Score-want) > 1e-9 {
+			t.Errorf("score of %s = %v, want %v", doc.ID, doc.Score, want)
+		}
+	}
+	for i := 1; i < len(scored); i++ {
+		if scored[i].Score > scored[i-1].Score {
+			t.Fatalf("SearchScored() is not sorted at %d: %v", i, scored)
+		}
+	}
+
+	// Document frequencies follow removals
+	if err := idx.RemoveDocument(ctx, "strong"); err != nil {
+		t.Fatalf("RemoveDocument() error = %v", err)
+	}
+	if df, _ := idx.GetDocFrequency(ctx, "refresh"); df != 0 {
+		t.Errorf("GetDocFrequency(refresh) = %d after removal, want 0", df)
+	}
+	if ids, _ := idx.Search(ctx, []string{"refresh"}, 10); len(ids) != 0 {
+		t.Errorf("Search(refresh) = %v after removal, want none", ids)
+	}
+}
+
+func TestRedisIndex_Search_MaxPostings(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	// Only the documents where a common token is most frequent are scored for
+	// it, but its document frequency still counts every document
+	var docs []*IndexedDocument
+	for i := 1; i <= 4; i++ {
+		docs = append(docs, &IndexedDocument{ID: fmt.Sprintf("doc%d", i), Length: 10, Tokens: map[string]int{"common": i, "filler": 10 - i}})
+	}
+	if err := idx.AddDocuments(ctx, docs); err != nil {
+		t.Fatalf("AddDocuments() error = %v", err)
+	}
+	idx.SetMaxPostings(2)
+
+	scored, err := idx.SearchScored(ctx, []string{"common"}, 0)
+	if err != nil {
+		t.Fatalf("SearchScored() error = %v", err)
+	}
+	if len(scored) != 2 || scored[0].ID != "doc4" || scored[1].ID != "doc3" {
+		t.Fatalf("SearchScored() = %v, want doc4 and doc3", scored)
+	}
+	want, _ := NewBM25Scorer(1.2, 0.75, idx).Score(ctx, []string{"common"}, "doc4")
+	if math.Abs(scored[0].Score-want) > 1e-9 {
+		t.Errorf("score of doc4 = %v, want %v", scored[0].Score, want)
+	}
+
+	idx.SetMaxPostings(0)
+	if ids, _ := idx.Search(ctx, []string{"common"}, 0); len(ids) != 4 {
+		t.Errorf("Search() without a cap = %v, want every document", ids)
+	}
+}
+
+func TestRedisIndex_Migrate(t *testing.T) {
+	idx, mr := setupTestRedis(t)
+	defer mr.Close()
+	ctx := context.Background()
+
+	// The layout before postings were sorted sets
+	mr.SAdd("test:index:token:alpha", "doc1", "doc2")
+	mr.Set("test:tf:alpha:doc1", "3")
+	mr.Set("test:tf:alpha:doc2", "1")
+	mr.Set("test:stats:token:alpha:df", "5") // drifted
+	mr.Set("test:doc:doc1:length", "4")
+	mr.Set("test:doc:doc2:length", "4")
+	mr.Set("test:stats:doc_count", "2")
+	mr.Set("test:stats:avg_doc_length", "4")
+
+	migrated, err := idx.Migrate(ctx)
+	if err != nil || migrated != 3 {
+		t.Fatalf("Migrate() = %d, %v, want 1 token and 2 documents", migrated, err)
+	}
+	if mr.Exists("test:index:token:alpha") || mr.Exists("test:tf:alpha:doc1") || mr.Exists("test:stats:token:alpha:df") || mr.Exists("test:doc:doc1:length") {
+		t.Error("legacy keys should be deleted")
+	}
+	if length, _ := idx.GetDocLength(ctx, "doc1"); length != 4 {
+		t.Errorf("GetDocLength() = %d, want 4", length)
+	}
+	if tf, _ := idx.GetTermFrequency(ctx, "alpha", "doc1"); tf != 3 {
+		t.Errorf("GetTermFrequency() = %d, want 3", tf)
+	}
+	if df, _ := idx.GetDocFrequency(ctx, "alpha"); df != 2 {
+		t.Errorf("GetDocFrequency() = %d, want 2", df)
+	}
+	if ids, _ := idx.Search(ctx, []string{"alpha"}, 10); len(ids) != 2 || ids[0] != "doc1" {
+		t.Errorf("Search() = %v, want doc1 first", ids)
+	}
+	if migrated, _ := idx.Migrate(ctx); migrated != 0 {
+		t.Errorf("second Migrate() converted %d keys, want 0", migrated)
+	}
+}
+
 func TestRedisIndex_Search_EmptyTokens(t *testing.T) {
 	idx, mr := setupTestRedis(t)
 	defer mr.Close()
@@ -400,6 +670,11 @@ func TestRedisIndex_KeyGenerationHelpers(t *testing.T) {
 		fn       func() string
 		expected string
 	}{
+		{
+			name:     "postingsKey",
+			fn:       func() string { return idx.postingsKey("hello") },
+			expected: "test:index:postings:hello",
+		},
 		{
 			name:     "tokenIndexKey",
 			fn:       func() string { return idx.tokenIndexKey("hello") },
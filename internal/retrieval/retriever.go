@@ -2,10 +2,16 @@ package retrieval
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/graph"
 	"github.com/Guru2308/rag-code/internal/hierarchy"
 	"github.com/Guru2308/rag-code/internal/indexing"
 	"github.com/Guru2308/rag-code/internal/logger"
@@ -16,6 +22,16 @@ import (
 type KeywordSearcher interface {
 	Search(ctx context.Context, tokens []string, limit int) ([]string, error)
 	AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error
+	RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error
+}
+
+// KeywordIndex is a complete keyword index: searchable, readable by
+// BM25Scorer and listable by Verify. RedisIndex and LocalIndex implement it.
+type KeywordIndex interface {
+	KeywordSearcher
+	RedisIndexInterface
+	indexing.Inventory
+	SetBM25Params(k1, b float64)
 }
 
 // Scorer defines interface for scoring documents
@@ -23,6 +39,29 @@ type Scorer interface {
 	Score(ctx context.Context, queryTokens []string, docID string) (float64, error)
 }
 
+// ScoreExplainer is implemented by scorers that can break a score down by
+// query term for debug output
+type ScoreExplainer interface {
+	Breakdown(ctx context.Context, queryTokens []string, docID string) (*domain.BM25Explanation, error)
+}
+
+// BatchGetter is implemented by chunk stores that can fetch many chunks in
+// one request. Missing chunks are left out.
+type BatchGetter interface {
+	GetBatch(ctx context.Context, ids []string) ([]*domain.CodeChunk, error)
+}
+
+// BatchScorer is implemented by scorers that can score many documents at once
+type BatchScorer interface {
+	ScoreBatch(ctx context.Context, queryTokens []string, docIDs []string) (map[string]float64, error)
+}
+
+// ScoredSearcher is implemented by keyword indexes that rank by BM25
+// themselves and return each match's score, so no separate scoring is needed
+type ScoredSearcher interface {
+	SearchScored(ctx context.Context, tokens []string, limit int) ([]ScoredDoc, error)
+}
+
 // Retriever handles the retrieval of relevant code chunks
 type Retriever struct {
 	embedder     indexing.Embedder
@@ -34,6 +73,9 @@ type Retriever struct {
 	reranker     reranker.Reranker
 	hierarchy    hierarchy.Processor
 	config       FusionConfig
+	transformer  *QueryTransformer
+	deadlines    StageDeadlines
+	trigram      *TrigramIndex
 }
 
 // NewRetriever creates a new hybrid retriever
@@ -61,8 +103,39 @@ func NewRetriever(
 	}
 }
 
+// SetTransformer enables the HyDE and multi-query transformations that
+// queries can ask for. Without a transformer they are ignored.
+func (r *Retriever) SetTransformer(t *QueryTransformer) {
+	r.transformer = t
+}
+
+// SetDeadlines bounds the search, rerank and expansion stages. A stage that
+// runs out of time is skipped or cut short, the request goes on with what it
+// has, and the stage is marked partial in the request's StageTimings.
+func (r *Retriever) SetDeadlines(d StageDeadlines) {
+	r.deadlines = d
+}
+
+// SetTrigramIndex adds exact matching as a third retrieval source: code-like
+// terms of a query are looked up verbatim and the chunks containing them are
+// fused with the keyword results. The index is kept up to date with the
+// keyword index.
+func (r *Retriever) SetTrigramIndex(t *TrigramIndex) {
+	r.trigram = t
+}
+
 // Retrieve finds relevant code chunks for a query using hybrid search
 func (r *Retriever) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
+	results, err := r.rank(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return r.expand(ctx, query, results), nil
+}
+
+// rank runs hybrid search, reranking and hierarchical filtering, returning
+// at most query.MaxResults results
+func (r *Retriever) rank(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
 	logger.Info("Retrieving code chunks", "query", query.Query, "max_results", query.MaxResults)
 
 	processed := r.preprocessor.Preprocess(query.Query)
@@ -78,13 +151,43 @@ func (r *Retriever) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*
 	searchQuery := query
 	searchQuery.MaxResults = searchLimit
 
-	vectorResults, err := r.executeVectorSearch(ctx, searchQuery)
+	// Every query variant is searched by vector and keyword in parallel; each
+	// source's rankings are merged with RRF before the sources are fused.
+	// Exact matches count as a lexical ranking of the query itself.
+	variants := r.queryVariants(ctx, query)
+	searches, err := r.search(ctx, variants, searchQuery, processed.Filtered)
 	if err != nil {
 		return nil, err
 	}
+	var vectorLists, keywordLists [][]*domain.SearchResult
+	found := make(map[string][]string) // chunk ID to the variants that found it
+	for i, v := range variants {
+		s := searches[i]
+		// A failed vector search only drops this variant's vector list; its
+		// keyword and exact-match lists are still fused.
+		if s.vectorErr != nil {
+			logger.Warn("Search with query variant failed", "variant", v.name, "error", s.vectorErr)
+		} else {
+			vectorLists = append(vectorLists, s.vector)
+		}
+		if v.keyword {
+			keywordLists = append(keywordLists, s.keyword)
+		}
+		if len(s.exact) > 0 {
+			keywordLists = append(keywordLists, s.exact)
+		}
+		if len(variants) > 1 {
+			for _, res := range slices.Concat(s.vector, s.keyword, s.exact) {
+				if ids := found[res.Chunk.ID]; len(ids) == 0 || ids[len(ids)-1] != v.name {
+					found[res.Chunk.ID] = append(ids, v.name)
+				}
+			}
+		}
+	}
+	vectorResults := FuseRankings(vectorLists, r.config.RRFConstant)
+	keywordResults := FuseRankings(keywordLists, r.config.RRFConstant)
 
-	keywordResults := r.executeKeywordSearch(ctx, processed.Filtered, searchLimit)
-
+	start := time.Now()
 	combined := r.combineResults(vectorResults, keywordResults)
 
 	// Multi-language filter: restrict to chunks matching query.Language when set
@@ -95,20 +198,41 @@ func (r *Retriever) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*
 
 	// Finalize initial results
 	finalResults := r.finalizeResults(combined, query.MaxResults, query.Query)
+	if query.Debug {
+		r.attachDebug(ctx, finalResults, vectorResults, keywordResults, searches[0].exact, processed.Filtered)
+		if len(variants) > 1 {
+			for _, res := range finalResults {
+				res.Debug.Variants = found[res.Chunk.ID]
+			}
+		}
+	}
+	timeStage(ctx, StageFusion, start)
 
 	// Phase 5: Reranking
-	if r.reranker != nil {
-		reranked, err := r.reranker.Rerank(ctx, query.Query, finalResults)
-		if err != nil {
+	if r.reranker != nil && !query.SkipRerank {
+		start := time.Now()
+		rerankCtx, cancel := withDeadline(ctx, r.deadlines.Rerank)
+		reranked, err := r.reranker.Rerank(rerankCtx, query.Query, finalResults)
+		timeStage(ctx, StageRerank, start)
+		if err != nil && timedOut(ctx, rerankCtx) {
+			logger.Warn("Reranking ran out of time, keeping fused order", "deadline", r.deadlines.Rerank)
+			StageTimingsFrom(ctx).MarkPartial(StageRerank)
+		} else if err != nil {
 			logger.Error("Reranking failed", "error", err)
 		} else {
 			finalResults = reranked
+			for _, res := range finalResults {
+				res.RerankScore = res.RelevanceScore
+			}
 		}
+		cancel()
 	}
 
 	// Phase 6: Hierarchical Filtering
-	if r.hierarchy != nil {
+	if r.hierarchy != nil && !query.SkipHierarchy {
+		start := time.Now()
 		filtered, err := r.hierarchy.Process(ctx, finalResults)
+		timeStage(ctx, StageHierarchy, start)
 		if err != nil {
 			logger.Error("Hierarchical processing failed", "error", err)
 		} else {
@@ -116,35 +240,226 @@ func (r *Retriever) Retrieve(ctx context.Context, query domain.SearchQuery) ([]*
 		}
 	}
 
-	// Apply Phase 4: Context Expansion (doing this after reranking/filtering ensures we expand the BEST chunks)
-	if r.expander != nil {
-		// Enabled by default unless explicitly disabled in filters
-		enabled := true
-		if val, ok := query.Filters["expand_context"]; ok && val == "false" {
-			enabled = false
-		}
+	for i, res := range finalResults {
+		res.Rank = i + 1
+	}
+	return finalResults, nil
+}
 
-		if enabled {
-			// Using DefaultExpandConfig for now
-			expanded, err := r.expander.Expand(ctx, finalResults, DefaultExpandConfig())
-			if err != nil {
-				logger.Error("Context expansion failed", "error", err)
-			} else {
-				finalResults = expanded
+// variantSearch holds the results of searching one query variant
+type variantSearch struct {
+	vector    []*domain.SearchResult
+	vectorErr error
+	keyword   []*domain.SearchResult
+	exact     []*domain.SearchResult
+}
+
+// search runs the vector and keyword searches of every variant, and the
+// exact search of the query itself, concurrently under the search deadline. A search cut short by the deadline
+// contributes nothing and marks its stage partial; other failures of the
+// query's own vector search fail the request.
+func (r *Retriever) search(ctx context.Context, variants []queryVariant, query domain.SearchQuery, tokens []string) ([]variantSearch, error) {
+	searchCtx, cancel := withDeadline(ctx, r.deadlines.Search)
+	defer cancel()
+
+	searches := make([]variantSearch, len(variants))
+	var wg sync.WaitGroup
+	for i, v := range variants {
+		variantQuery := query
+		variantQuery.Query = v.text
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searches[i].vector, searches[i].vectorErr = r.executeVectorSearch(searchCtx, variantQuery)
+		}()
+		if !v.keyword {
+			continue
+		}
+		variantTokens := tokens
+		if v.name != VariantQuery {
+			variantTokens = r.preprocessor.Preprocess(v.text).Filtered
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searches[i].keyword = r.executeKeywordSearch(searchCtx, variantTokens, query.MaxResults)
+		}()
+	}
+	exact := r.exactTerms(query)
+	if len(exact) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searches[0].exact = r.executeExactSearch(searchCtx, exact, query.MaxResults)
+		}()
+	}
+	wg.Wait()
+
+	if timedOut(ctx, searchCtx) {
+		logger.Warn("Search ran out of time, continuing with partial results", "deadline", r.deadlines.Search)
+		timings := StageTimingsFrom(ctx)
+		for i := range searches {
+			if searches[i].vectorErr != nil || searches[i].vector == nil {
+				timings.MarkPartial(StageVectorSearch)
+				searches[i].vectorErr = nil
+			}
+			if variants[i].keyword && r.keyword != nil && r.scorer != nil && searches[i].keyword == nil {
+				timings.MarkPartial(StageKeywordSearch)
 			}
 		}
+		if len(exact) > 0 && searches[0].exact == nil {
+			timings.MarkPartial(StageExactSearch)
+		}
+	}
+	if searches[0].vectorErr != nil {
+		return nil, searches[0].vectorErr
 	}
+	return searches, nil
+}
 
-	return finalResults, nil
+// queryVariant is one text a query is searched with
+type queryVariant struct {
+	name    string
+	text    string
+	keyword bool // also searched by keyword; hypothetical code is not
+}
+
+// queryVariants returns the query itself followed by the transformations
+// it asks for. A transformation that fails is left out, so the search falls
+// back to the query alone.
+func (r *Retriever) queryVariants(ctx context.Context, query domain.SearchQuery) []queryVariant {
+	variants := []queryVariant{{name: VariantQuery, text: query.Query, keyword: true}}
+	if r.transformer == nil || (!query.HyDE && query.MultiQuery <= 0) {
+		return variants
+	}
+	defer timeStage(ctx, StageTransform, time.Now())
+
+	// The two model calls are independent
+	var wg sync.WaitGroup
+	var code string
+	var hydeErr error
+	if query.HyDE {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, hydeErr = r.transformer.Hypothetical(ctx, query.Query, query.Language)
+		}()
+	}
+	paraphrases, err := r.transformer.Paraphrases(ctx, query.Query, query.MultiQuery)
+	wg.Wait()
+
+	if query.HyDE {
+		if hydeErr != nil {
+			logger.Warn("HyDE failed, searching without hypothetical code", "error", hydeErr)
+		} else {
+			variants = append(variants, queryVariant{name: VariantHyDE, text: code})
+		}
+	}
+	if err != nil {
+		logger.Warn("Multi-query expansion failed, searching without paraphrases", "error", err)
+	}
+	for i, p := range paraphrases {
+		variants = append(variants, queryVariant{name: fmt.Sprintf("%s %d", variantParaphrase, i+1), text: p, keyword: true})
+	}
+	logger.Debug("Transformed query", "query", query.Query, "variants", len(variants))
+	return variants
+}
+
+// expand applies Phase 4: Context Expansion. Doing this after
+// reranking/filtering ensures we expand the BEST chunks.
+func (r *Retriever) expand(ctx context.Context, query domain.SearchQuery, results []*domain.SearchResult) []*domain.SearchResult {
+	// Enabled by default unless explicitly disabled
+	if r.expander == nil || query.SkipExpansion || query.Filters["expand_context"] == "false" {
+		return results
+	}
+
+	// Using DefaultExpandConfig for now
+	config := DefaultExpandConfig()
+	config.Explain = query.Debug
+	defer timeStage(ctx, StageExpansion, time.Now())
+	expandCtx, cancel := withDeadline(ctx, r.deadlines.Expansion)
+	defer cancel()
+	expanded, err := r.expander.Expand(expandCtx, results, config)
+	if err != nil && timedOut(ctx, expandCtx) {
+		logger.Warn("Context expansion ran out of time, returning unexpanded results", "deadline", r.deadlines.Expansion)
+		StageTimingsFrom(ctx).MarkPartial(StageExpansion)
+		return results
+	}
+	if err != nil {
+		logger.Error("Context expansion failed", "error", err)
+		return results
+	}
+	return expanded
+}
+
+// attachDebug gives each result a ResultDebug with its rank and score in
+// each source, its BM25 breakdown and its fusion contribution. The reranker,
+// hierarchy and expander fill in the rest.
+func (r *Retriever) attachDebug(ctx context.Context, results, vectorResults, keywordResults, exactResults []*domain.SearchResult, tokens []string) {
+	vectorRanks := make(map[string]int, len(vectorResults))
+	for i, res := range vectorResults {
+		vectorRanks[res.Chunk.ID] = i + 1
+	}
+	keywordRanks := make(map[string]int, len(keywordResults))
+	for i, res := range keywordResults {
+		keywordRanks[res.Chunk.ID] = i + 1
+	}
+	exactRanks := make(map[string]int, len(exactResults))
+	for i, res := range exactResults {
+		exactRanks[res.Chunk.ID] = i + 1
+	}
+	fused := len(vectorResults) > 0 && len(keywordResults) > 0
+	explainer, _ := r.scorer.(ScoreExplainer)
+
+	for _, res := range results {
+		id := res.Chunk.ID
+		debug := &domain.ResultDebug{
+			VectorRank:  vectorRanks[id],
+			KeywordRank: keywordRanks[id],
+			ExactRank:   exactRanks[id],
+		}
+		if debug.VectorRank > 0 {
+			debug.VectorScore = vectorResults[debug.VectorRank-1].VectorScore
+		}
+		if debug.KeywordRank > 0 {
+			debug.KeywordScore = keywordResults[debug.KeywordRank-1].KeywordScore
+			if explainer != nil {
+				breakdown, err := explainer.Breakdown(ctx, tokens, id)
+				if err != nil {
+					logger.Debug("BM25 breakdown failed", "chunk_id", id, "error", err)
+				}
+				debug.BM25 = breakdown
+			}
+		}
+
+		if fused {
+			debug.Fusion = ExplainFusion(res, debug.VectorRank, debug.KeywordRank, r.config)
+		} else {
+			debug.Fusion = &domain.FusionExplanation{
+				Strategy:            "none",
+				VectorContribution:  debug.VectorScore,
+				KeywordContribution: debug.KeywordScore,
+				Score:               res.Score,
+			}
+		}
+		res.Debug = debug
+	}
 }
 
 func (r *Retriever) executeVectorSearch(ctx context.Context, query domain.SearchQuery) ([]*domain.SearchResult, error) {
+	start := time.Now()
 	queryVector, err := r.embedder.Embed(ctx, query.Query)
+	timeStage(ctx, StageEmbed, start)
 	if err != nil {
 		return nil, err
 	}
 
-	vectorResults, err := r.vectorSearch(ctx, queryVector, query.MaxResults*2)
+	defer timeStage(ctx, StageVectorSearch, time.Now())
+	var filter *domain.ChunkFilter
+	if query.Language != "" {
+		filter = &domain.ChunkFilter{Language: strings.TrimSpace(query.Language)}
+	}
+	vectorResults, err := r.vectorSearch(ctx, queryVector, query.MaxResults*2, filter)
 	if err != nil {
 		logger.Error("Vector search failed", "error", err)
 		return nil, nil
@@ -152,6 +467,7 @@ func (r *Retriever) executeVectorSearch(ctx context.Context, query domain.Search
 
 	for _, res := range vectorResults {
 		res.Source = "vector"
+		res.VectorScore = res.Score
 	}
 	return vectorResults, nil
 }
@@ -160,21 +476,43 @@ func (r *Retriever) executeKeywordSearch(ctx context.Context, tokens []string, l
 	if r.keyword == nil || r.scorer == nil {
 		return nil
 	}
+	defer timeStage(ctx, StageKeywordSearch, time.Now())
 
-	docIDs, err := r.keyword.Search(ctx, tokens, limit*2)
+	docIDs, scores, err := r.keywordSearch(ctx, tokens, limit*2)
 	if err != nil {
 		logger.Error("Keyword search failed", "error", err)
 		return nil
 	}
 
+	// Chunks and scores are fetched at the same time, each in as few round
+	// trips as the store and scorer allow
+	var (
+		wg       sync.WaitGroup
+		chunks   map[string]*domain.CodeChunk
+		scoreErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		chunks = r.getChunks(ctx, docIDs)
+	}()
+	if scores == nil {
+		scores, scoreErr = r.scoreDocs(ctx, tokens, docIDs)
+	}
+	wg.Wait()
+	if scoreErr != nil {
+		logger.Error("Keyword scoring failed", "error", scoreErr)
+		return nil
+	}
+
 	results := make([]*domain.SearchResult, 0, len(docIDs))
 	for _, id := range docIDs {
-		chunk, err := r.store.Get(ctx, id)
-		if err != nil {
+		chunk, ok := chunks[id]
+		if !ok {
 			continue
 		}
-		score, err := r.scorer.Score(ctx, tokens, id)
-		if err != nil {
+		score, ok := scores[id]
+		if !ok {
 			continue
 		}
 		results = append(results, &domain.SearchResult{
@@ -187,6 +525,110 @@ func (r *Retriever) executeKeywordSearch(ctx context.Context, tokens []string, l
 	return results
 }
 
+// exactTerms returns the terms of query to search verbatim, or nil when
+// exact search is off for it
+func (r *Retriever) exactTerms(query domain.SearchQuery) []string {
+	if r.trigram == nil || query.SkipExact {
+		return nil
+	}
+	return exactTerms(query.Query)
+}
+
+// executeExactSearch finds the chunks containing terms verbatim
+func (r *Retriever) executeExactSearch(ctx context.Context, terms []string, limit int) []*domain.SearchResult {
+	defer timeStage(ctx, StageExactSearch, time.Now())
+
+	docs, err := r.trigram.SearchExact(ctx, terms, limit)
+	if err != nil {
+		logger.Error("Exact search failed", "error", err)
+		return nil
+	}
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	chunks := r.getChunks(ctx, ids)
+
+	results := make([]*domain.SearchResult, 0, len(docs))
+	for _, doc := range docs {
+		chunk, ok := chunks[doc.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, &domain.SearchResult{
+			Chunk:        chunk,
+			Score:        float32(doc.Score),
+			Source:       "exact",
+			KeywordScore: float32(doc.Score),
+		})
+	}
+	return results
+}
+
+// keywordSearch returns the IDs of the documents matching tokens, with
+// their scores if the index ranks them itself
+func (r *Retriever) keywordSearch(ctx context.Context, tokens []string, limit int) ([]string, map[string]float64, error) {
+	scored, ok := r.keyword.(ScoredSearcher)
+	if !ok {
+		docIDs, err := r.keyword.Search(ctx, tokens, limit)
+		return docIDs, nil, err
+	}
+	docs, err := scored.SearchScored(ctx, tokens, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	docIDs := make([]string, len(docs))
+	scores := make(map[string]float64, len(docs))
+	for i, doc := range docs {
+		docIDs[i] = doc.ID
+		scores[doc.ID] = doc.Score
+	}
+	return docIDs, scores, nil
+}
+
+// getChunks fetches the chunks with the given IDs, keyed by the requested
+// ID, leaving out any that cannot be read. A batch can come back with IDs in
+// another form (Qdrant hyphenates them), so they are matched canonically.
+func (r *Retriever) getChunks(ctx context.Context, ids []string) map[string]*domain.CodeChunk {
+	chunks := make(map[string]*domain.CodeChunk, len(ids))
+	if batch, ok := r.store.(BatchGetter); ok {
+		found, err := batch.GetBatch(ctx, ids)
+		if err != nil {
+			logger.Error("Batch chunk fetch failed", "error", err)
+		}
+		byID := make(map[string]*domain.CodeChunk, len(found))
+		for _, c := range found {
+			byID[domain.CanonicalChunkID(c.ID)] = c
+		}
+		for _, id := range ids {
+			if c, ok := byID[domain.CanonicalChunkID(id)]; ok {
+				chunks[id] = c
+			}
+		}
+		return chunks
+	}
+	for _, id := range ids {
+		if c, err := r.store.Get(ctx, id); err == nil {
+			chunks[id] = c
+		}
+	}
+	return chunks
+}
+
+// scoreDocs scores the given documents, leaving out any that cannot be scored
+func (r *Retriever) scoreDocs(ctx context.Context, tokens, ids []string) (map[string]float64, error) {
+	if batch, ok := r.scorer.(BatchScorer); ok {
+		return batch.ScoreBatch(ctx, tokens, ids)
+	}
+	scores := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		if score, err := r.scorer.Score(ctx, tokens, id); err == nil {
+			scores[id] = score
+		}
+	}
+	return scores, nil
+}
+
 // filterByLanguage keeps only results whose chunk language matches (case-insensitive).
 func (r *Retriever) filterByLanguage(results []*domain.SearchResult, lang string) []*domain.SearchResult {
 	if lang == "" {
@@ -211,8 +653,12 @@ func (r *Retriever) combineResults(vectorResults, keywordResults []*domain.Searc
 }
 
 func (r *Retriever) finalizeResults(results []*domain.SearchResult, limit int, query string) []*domain.SearchResult {
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
+	// Ties are broken by ID so that the same index always ranks the same way
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID
 	})
 
 	if limit <= 0 {
@@ -223,21 +669,45 @@ func (r *Retriever) finalizeResults(results []*domain.SearchResult, limit int, q
 	}
 
 	for _, res := range results {
+		res.FusionScore = res.Score
 		res.RelevanceScore = CalculateRelevance(res, query)
 	}
 	return results
 }
 
-// AddToInvertedIndex adds chunks to the keyword index
+// AddToInvertedIndex adds chunks to the keyword and trigram indexes
 func (r *Retriever) AddToInvertedIndex(ctx context.Context, chunks []*domain.CodeChunk) error {
+	if r.trigram != nil {
+		if err := r.trigram.AddToInvertedIndex(ctx, chunks); err != nil {
+			return err
+		}
+	}
 	if r.keyword == nil {
 		return nil
 	}
 	return r.keyword.AddToInvertedIndex(ctx, chunks)
 }
 
-// vectorSearch performs a vector search.
-func (r *Retriever) vectorSearch(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+// RemoveStaleFiles removes the files' keyword and trigram index entries from
+// other generations
+func (r *Retriever) RemoveStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	if r.trigram != nil {
+		if err := r.trigram.RemoveStaleFiles(ctx, files); err != nil {
+			return err
+		}
+	}
+	if r.keyword == nil {
+		return nil
+	}
+	return r.keyword.RemoveStaleFiles(ctx, files)
+}
+
+// vectorSearch performs a vector search, applying filter in the store when
+// it supports that. Results are filtered by language again after fusion.
+func (r *Retriever) vectorSearch(ctx context.Context, vector []float32, limit int, filter *domain.ChunkFilter) ([]*domain.SearchResult, error) {
+	if filtered, ok := r.store.(FilteredSearchableStore); ok && filter != nil {
+		return filtered.SearchFiltered(ctx, vector, limit, filter)
+	}
 	searchable, ok := r.store.(SearchableStore)
 	if !ok {
 		logger.Warn("Store does not support direct vector search")
@@ -251,3 +721,66 @@ func (r *Retriever) vectorSearch(ctx context.Context, vector []float32, limit in
 type SearchableStore interface {
 	Search(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error)
 }
+
+// FilteredSearchableStore is a store that can filter a vector search by
+// chunk payload
+type FilteredSearchableStore interface {
+	SearchFiltered(ctx context.Context, vector []float32, limit int, filter *domain.ChunkFilter) ([]*domain.SearchResult, error)
+}
+
+// ListChunks lists the keyword index documents, for verification
+func (r *Retriever) ListChunks(ctx context.Context) ([]domain.ChunkRef, error) {
+	inv, ok := r.keyword.(indexing.Inventory)
+	if !ok {
+		return nil, errors.InternalError("keyword index cannot be listed")
+	}
+	return inv.ListChunks(ctx)
+}
+
+// RemoveChunks removes keyword and trigram index documents by ID
+func (r *Retriever) RemoveChunks(ctx context.Context, ids []string) error {
+	if r.trigram != nil {
+		if err := r.trigram.RemoveChunks(ctx, ids); err != nil {
+			return err
+		}
+	}
+	inv, ok := r.keyword.(indexing.Inventory)
+	if !ok {
+		return errors.InternalError("keyword index cannot remove chunks")
+	}
+	return inv.RemoveChunks(ctx, ids)
+}
+
+// Explain returns a stored chunk with its callers, callees, imports and
+// parent/child definitions from the dependency graph
+func (r *Retriever) Explain(ctx context.Context, chunkID string) (*domain.ChunkExplanation, error) {
+	chunk, err := r.store.Get(ctx, chunkID)
+	if err != nil {
+		return nil, err
+	}
+	if chunk == nil {
+		return nil, errors.NotFoundError("chunk not found")
+	}
+	chunk.Embedding = nil
+
+	explanation := &domain.ChunkExplanation{Chunk: chunk}
+	if r.expander == nil || r.expander.graph == nil {
+		return explanation, nil
+	}
+	g := r.expander.graph
+	explanation.Calls = relatedChunks(g.GetRelated(chunkID, graph.RelationCall))
+	explanation.CalledBy = relatedChunks(g.GetIncoming(chunkID, graph.RelationCall))
+	explanation.Imports = relatedChunks(g.GetRelated(chunkID, graph.RelationImport))
+	explanation.ImportedBy = relatedChunks(g.GetIncoming(chunkID, graph.RelationImport))
+	explanation.DefinedIn = relatedChunks(g.GetIncoming(chunkID, graph.RelationDefine))
+	explanation.Defines = relatedChunks(g.GetRelated(chunkID, graph.RelationDefine))
+	return explanation, nil
+}
+
+func relatedChunks(nodes []*graph.Node) []domain.RelatedChunk {
+	related := make([]domain.RelatedChunk, 0, len(nodes))
+	for _, n := range nodes {
+		related = append(related, domain.RelatedChunk{ID: n.ID, Name: n.Name, Type: n.Type, FilePath: n.FilePath})
+	}
+	return related
+}
@@ -3,12 +3,17 @@ package retrieval_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/graph"
+	"github.com/Guru2308/rag-code/internal/hierarchy"
 	"github.com/Guru2308/rag-code/internal/logger"
 	"github.com/Guru2308/rag-code/internal/mocks"
+	"github.com/Guru2308/rag-code/internal/reranker"
 	"github.com/Guru2308/rag-code/internal/retrieval"
+	"github.com/Guru2308/rag-code/internal/vectorstore"
 )
 
 func init() {
@@ -246,6 +251,63 @@ func TestRetriever_AddToInvertedIndex_NilKeyword(t *testing.T) {
 	}
 }
 
+func TestRetriever_Retrieve_ExactMatch(t *testing.T) {
+	chunks := map[string]*domain.CodeChunk{
+		"doc1": {ID: "doc1", FilePath: "a.go", Content: "func cancel() {}"},
+		"doc2": {ID: "doc2", FilePath: "b.go", Content: "func handle() {}"},
+		"doc3": {ID: "doc3", FilePath: "c.go", Content: "func wait(ctx context.Context) { <-ctx.Done() }"},
+	}
+	store := &mocks.MockChunkStore{
+		GetFunc: func(ctx context.Context, id string) (*domain.CodeChunk, error) {
+			return chunks[id], nil
+		},
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{{Chunk: chunks["doc1"], Score: 0.9}, {Chunk: chunks["doc2"], Score: 0.8}}, nil
+		},
+	}
+	embedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(embedder, store, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.DefaultFusionConfig())
+	retriever.SetTrigramIndex(retrieval.NewTrigramIndex())
+	// With no keyword index, chunks still reach the trigram index
+	err := retriever.AddToInvertedIndex(context.Background(), []*domain.CodeChunk{chunks["doc1"], chunks["doc2"], chunks["doc3"]})
+	if err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+
+	query := domain.SearchQuery{Query: "where is ctx.Done() handled", MaxResults: 3, Debug: true}
+	results, err := retriever.Retrieve(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	var exact *domain.SearchResult
+	for _, res := range results {
+		if res.Chunk.ID == "doc3" {
+			exact = res
+		}
+	}
+	if exact == nil {
+		t.Fatalf("exact match doc3 missing from %d results", len(results))
+	}
+	if exact.Debug == nil || exact.Debug.ExactRank != 1 || exact.Debug.VectorRank != 0 {
+		t.Errorf("doc3 debug = %+v, want exact rank 1 only", exact.Debug)
+	}
+
+	query.SkipExact = true
+	results, err = retriever.Retrieve(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	for _, res := range results {
+		if res.Chunk.ID == "doc3" {
+			t.Error("doc3 found with exact search skipped")
+		}
+	}
+}
+
 func TestRetriever_Retrieve_EmptyQuery(t *testing.T) {
 	mockEmbedder := &mocks.MockEmbedder{
 		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
@@ -311,3 +373,158 @@ func TestRetriever_Retrieve_LanguageFilter(t *testing.T) {
 		t.Errorf("Language filter: got %d results, want 2 (go chunks only)", len(results))
 	}
 }
+
+func TestRetriever_Retrieve_FilteredStore(t *testing.T) {
+	// Python chunks are nearer the query, so the Go chunk is only found if
+	// the store applies the language filter during the search
+	store, err := vectorstore.NewLocalStore(t.TempDir(), vectorstore.LocalConfig{Index: "hnsw"})
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	chunks := []*domain.CodeChunk{{ID: "go1", Language: "go", Content: "func main() {}", Embedding: []float32{0, 1}}}
+	for i := 0; i < 10; i++ {
+		chunks = append(chunks, &domain.CodeChunk{ID: fmt.Sprintf("py%d", i), Language: "python", Content: "def foo(): pass", Embedding: []float32{1, float32(i) / 100}})
+	}
+	if err := store.Store(context.Background(), chunks); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{1, 0}, nil
+		},
+	}
+	retriever := retrieval.NewRetriever(mockEmbedder, store, nil, nil, retrieval.NewQueryPreprocessor(), nil, nil, nil, retrieval.FusionConfig{Strategy: retrieval.FusionRRF})
+
+	results, err := retriever.Retrieve(context.Background(), domain.SearchQuery{Query: "main", MaxResults: 1, Language: "Go"})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.ID != "go1" {
+		t.Errorf("got %d results, want go1", len(results))
+	}
+}
+
+func TestRetriever_Retrieve_Debug(t *testing.T) {
+	chunks := map[string]*domain.CodeChunk{
+		"doc1":   {ID: "doc1", FilePath: "a.go", Content: "func parse() {}", ChunkType: domain.ChunkTypeFunction},
+		"doc2":   {ID: "doc2", FilePath: "b.go", Content: "func tokenize() {}", ChunkType: domain.ChunkTypeFunction},
+		"doc3":   {ID: "doc3", FilePath: "c.go", Content: "tokenize helper", ChunkType: domain.ChunkTypeOther},
+		"helper": {ID: "helper", FilePath: "d.go", Content: "func helper() {}", ChunkType: domain.ChunkTypeFunction},
+	}
+	store := &mocks.MockChunkStore{
+		GetFunc: func(ctx context.Context, id string) (*domain.CodeChunk, error) {
+			return chunks[id], nil
+		},
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{
+				{Chunk: chunks["doc1"], Score: 0.9},
+				{Chunk: chunks["doc2"], Score: 0.8},
+			}, nil
+		},
+	}
+	embedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	keyword := &mocks.MockKeywordSearcher{
+		SearchFunc: func(ctx context.Context, tokens []string, limit int) ([]string, error) {
+			return []string{"doc2", "doc3"}, nil
+		},
+	}
+	redisIdx := &mocks.MockRedisIndex{
+		GetDocCountFunc:     func(ctx context.Context) (int, error) { return 10, nil },
+		GetAvgDocLengthFunc: func(ctx context.Context) (float64, error) { return 5, nil },
+		GetDocLengthFunc:    func(ctx context.Context, docID string) (int, error) { return 4, nil },
+		GetTermFrequencyFunc: func(ctx context.Context, term, docID string) (int, error) {
+			return 1, nil
+		},
+		GetDocFrequencyFunc: func(ctx context.Context, term string) (int, error) { return 2, nil },
+	}
+
+	g := graph.NewGraph()
+	g.AddNode(&graph.Node{ID: "doc1", Name: "parse"})
+	g.AddNode(&graph.Node{ID: "helper", Name: "helper"})
+	g.AddEdge("doc1", "helper", graph.RelationCall)
+
+	r := retrieval.NewRetriever(embedder, store, keyword, retrieval.NewBM25Scorer(1.2, 0.75, redisIdx),
+		retrieval.NewQueryPreprocessor(), retrieval.NewContextExpander(g, store),
+		reranker.NewHeuristicReranker(), hierarchy.NewHierarchicalFilter(3), retrieval.DefaultFusionConfig())
+
+	results, err := r.Retrieve(context.Background(), domain.SearchQuery{Query: "tokenize", MaxResults: 5, Debug: true})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	byID := make(map[string]*domain.ResultDebug)
+	for _, res := range results {
+		if res.Debug == nil {
+			t.Fatalf("result %s has no debug output", res.Chunk.ID)
+		}
+		byID[res.Chunk.ID] = res.Debug
+	}
+
+	d := byID["doc2"]
+	if d.VectorRank != 2 || d.KeywordRank != 1 || d.VectorScore != 0.8 {
+		t.Errorf("doc2: unexpected source ranks %+v", d)
+	}
+	if d.BM25 == nil || len(d.BM25.Terms) == 0 || d.BM25.Score <= 0 {
+		t.Errorf("doc2: expected BM25 breakdown, got %+v", d.BM25)
+	}
+	if d.Fusion == nil || d.Fusion.Strategy != "rrf" || d.Fusion.VectorContribution == 0 || d.Fusion.KeywordContribution == 0 {
+		t.Errorf("doc2: unexpected fusion %+v", d.Fusion)
+	}
+	if d.Rerank == nil || d.Rerank.TypeWeight != 1.2 || d.Hierarchy == nil {
+		t.Errorf("doc2: expected rerank and hierarchy debug, got %+v / %+v", d.Rerank, d.Hierarchy)
+	}
+
+	if d := byID["doc1"]; d.KeywordRank != 0 || d.BM25 != nil {
+		t.Errorf("doc1: vector-only result should have no keyword debug, got %+v", d)
+	}
+	if e := byID["helper"].Expansion; e == nil || e.Relation != "callee" || e.From != "doc1" || e.Depth != 1 {
+		t.Errorf("helper: unexpected expansion debug %+v", e)
+	}
+
+	// Without debug nothing is attached
+	results, _ = r.Retrieve(context.Background(), domain.SearchQuery{Query: "tokenize", MaxResults: 5})
+	for _, res := range results {
+		if res.Debug != nil {
+			t.Errorf("result %s has debug output without debug mode", res.Chunk.ID)
+		}
+	}
+}
+
+func TestRetriever_Retrieve_StageTimings(t *testing.T) {
+	mockEmbedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	mockStore := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			return []*domain.SearchResult{{Chunk: &domain.CodeChunk{ID: "doc1"}, Score: 0.9}}, nil
+		},
+	}
+	rr := reranker.NewHeuristicReranker()
+	retriever := retrieval.NewRetriever(mockEmbedder, mockStore, nil, nil, retrieval.NewQueryPreprocessor(), nil, rr, nil, retrieval.DefaultFusionConfig())
+
+	ctx, timings := retrieval.WithStageTimings(context.Background())
+	if _, err := retriever.Retrieve(ctx, domain.SearchQuery{Query: "search"}); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	got := timings.Milliseconds()
+	for _, stage := range []string{retrieval.StageEmbed, retrieval.StageVectorSearch, retrieval.StageFusion, retrieval.StageRerank} {
+		if _, ok := got[stage]; !ok {
+			t.Errorf("stage %q not timed: %v", stage, got)
+		}
+	}
+	// No keyword index or hierarchy is configured
+	if _, ok := got[retrieval.StageKeywordSearch]; ok {
+		t.Errorf("keyword search should not be timed: %v", got)
+	}
+
+	// Retrieval without timings in the context still works
+	if _, err := retriever.Retrieve(context.Background(), domain.SearchQuery{Query: "search"}); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+}
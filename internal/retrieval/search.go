@@ -0,0 +1,172 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// A search ranks a fixed window of results and returns one page of it. The
+// window size travels in the cursor, so later pages re-rank the same window
+// and see the same order as long as the index has not changed in between.
+const (
+	defaultPageSize   = 10
+	maxPageSize       = 100
+	searchWindowPages = 5 // pages ranked up front for a new search
+	minSearchWindow   = 50
+	maxSearchWindow   = 200
+)
+
+// searchCursor is the decoded form of SearchPage.NextCursor
+type searchCursor struct {
+	Offset      int    `json:"o"`
+	Window      int    `json:"w"`
+	Fingerprint string `json:"f"` // ties the cursor to the query it came from
+}
+
+// Search runs retrieval without generating an answer and returns one page
+// of results. Context expansion is applied to the page only, so expanded
+// chunks (Rank 0) follow the ranked results they were expanded from.
+func (r *Retriever) Search(ctx context.Context, req domain.SearchRequest) (*domain.SearchPage, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, errors.ValidationError("query is required")
+	}
+	pageSize := req.MaxResults
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		return nil, errors.ValidationError(fmt.Sprintf("max_results must be at most %d", maxPageSize))
+	}
+
+	fingerprint := searchFingerprint(req)
+	cursor := searchCursor{Window: searchWindow(pageSize), Fingerprint: fingerprint}
+	if req.Cursor != "" {
+		decoded, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if decoded.Fingerprint != fingerprint {
+			return nil, errors.ValidationError("cursor was issued for a different query")
+		}
+		cursor = decoded
+	}
+
+	// Stages that run out of time are reported on the page
+	timings := StageTimingsFrom(ctx)
+	if timings == nil {
+		ctx, timings = WithStageTimings(ctx)
+	}
+
+	query := req.SearchQuery
+	query.MaxResults = cursor.Window
+	ranked, err := r.rank(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.SearchPage{Query: req.Query}
+	if req.GroupByFile {
+		groups := groupByFile(ranked)
+		page.Total = len(groups)
+
+		var results []*domain.SearchResult
+		for _, group := range pageOf(groups, cursor.Offset, pageSize) {
+			results = append(results, group.Results...)
+		}
+		page.Groups = groupByFile(r.expand(ctx, query, results))
+	} else {
+		page.Total = len(ranked)
+		page.Results = r.expand(ctx, query, pageOf(ranked, cursor.Offset, pageSize))
+	}
+
+	if next := cursor.Offset + pageSize; next < page.Total {
+		cursor.Offset = next
+		page.NextCursor = encodeCursor(cursor)
+	}
+	page.Partial = len(timings.PartialStages()) > 0
+	return page, nil
+}
+
+// searchWindow returns how many results to rank for a new search
+func searchWindow(pageSize int) int {
+	window := pageSize * searchWindowPages
+	if window < minSearchWindow {
+		window = minSearchWindow
+	}
+	if window > maxSearchWindow {
+		window = maxSearchWindow
+	}
+	return window
+}
+
+// searchFingerprint hashes everything about a request that affects ranking.
+// The page size, cursor and debug flag are left out so clients can change
+// them between pages.
+func searchFingerprint(req domain.SearchRequest) string {
+	req.MaxResults = 0
+	req.Cursor = ""
+	req.Debug = false
+	data, _ := json.Marshal(req) // map keys are marshalled in sorted order
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodeCursor(c searchCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (searchCursor, error) {
+	var c searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Offset < 0 || c.Window <= 0 || c.Window > maxSearchWindow {
+		return searchCursor{}, errors.ValidationError("invalid cursor")
+	}
+	return c, nil
+}
+
+// groupByFile groups results by file, ordering files by their first (best
+// ranked) result
+func groupByFile(results []*domain.SearchResult) []*domain.FileGroup {
+	var groups []*domain.FileGroup
+	byFile := make(map[string]*domain.FileGroup)
+	for _, res := range results {
+		if res.Chunk == nil {
+			continue
+		}
+		group, ok := byFile[res.Chunk.FilePath]
+		if !ok {
+			group = &domain.FileGroup{FilePath: res.Chunk.FilePath, Score: res.RelevanceScore}
+			byFile[res.Chunk.FilePath] = group
+			groups = append(groups, group)
+		}
+		if res.RelevanceScore > group.Score {
+			group.Score = res.RelevanceScore
+		}
+		group.Results = append(group.Results, res)
+	}
+	return groups
+}
+
+// pageOf returns items[offset:offset+size], clamped to the slice
+func pageOf[T any](items []T, offset, size int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	end := offset + size
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
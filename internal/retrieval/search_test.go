@@ -0,0 +1,194 @@
+package retrieval_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/hierarchy"
+	"github.com/Guru2308/rag-code/internal/mocks"
+	"github.com/Guru2308/rag-code/internal/reranker"
+	"github.com/Guru2308/rag-code/internal/retrieval"
+)
+
+// countingReranker records calls and leaves the order untouched
+type countingReranker struct{ calls int }
+
+func (r *countingReranker) Rerank(ctx context.Context, query string, results []*domain.SearchResult) ([]*domain.SearchResult, error) {
+	r.calls++
+	for _, res := range results {
+		res.RelevanceScore = res.Score * 2
+	}
+	return results, nil
+}
+
+// newSearchRetriever returns a retriever over n vector-only chunks spread
+// across five files. Scores come in pairs so ranking depends on tie-breaks.
+func newSearchRetriever(n int, rerank reranker.Reranker, hier hierarchy.Processor) *retrieval.Retriever {
+	store := &mocks.MockChunkStore{
+		SearchFunc: func(ctx context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+			var results []*domain.SearchResult
+			// Return in reverse ID order to check ties are not left to input order
+			for i := n - 1; i >= 0; i-- {
+				results = append(results, &domain.SearchResult{
+					Chunk: &domain.CodeChunk{ID: fmt.Sprintf("c%02d", i), FilePath: fmt.Sprintf("f%d.go", i%5)},
+					Score: 1 - float32(i/2)*0.01,
+				})
+			}
+			if len(results) > limit {
+				results = results[:limit]
+			}
+			return results, nil
+		},
+	}
+	embedder := &mocks.MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{0.1}, nil
+		},
+	}
+	return retrieval.NewRetriever(embedder, store, nil, nil, retrieval.NewQueryPreprocessor(), nil, rerank, hier, retrieval.DefaultFusionConfig())
+}
+
+func TestRetriever_Search_Pagination(t *testing.T) {
+	r := newSearchRetriever(25, nil, nil)
+	ctx := context.Background()
+
+	var ids []string
+	req := domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler", MaxResults: 10}}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		page, err := r.Search(ctx, req)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if page.Total != 25 {
+			t.Errorf("Total = %d, want 25", page.Total)
+		}
+		for _, res := range page.Results {
+			if res.Rank != len(ids)+1 {
+				t.Errorf("result %s has rank %d, want %d", res.Chunk.ID, res.Rank, len(ids)+1)
+			}
+			if res.VectorScore == 0 || res.FusionScore == 0 {
+				t.Errorf("result %s is missing stage scores: %+v", res.Chunk.ID, res)
+			}
+			ids = append(ids, res.Chunk.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+
+	if len(ids) != 25 {
+		t.Fatalf("got %d results across pages, want 25", len(ids))
+	}
+	for i, id := range ids {
+		if want := fmt.Sprintf("c%02d", i); id != want {
+			t.Errorf("position %d = %s, want %s", i, id, want)
+		}
+	}
+}
+
+func TestRetriever_Search_GroupByFile(t *testing.T) {
+	r := newSearchRetriever(25, nil, nil)
+
+	req := domain.SearchRequest{
+		SearchQuery: domain.SearchQuery{Query: "handler", MaxResults: 2},
+		GroupByFile: true,
+	}
+	page, err := r.Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if page.Total != 5 || len(page.Groups) != 2 || page.Results != nil {
+		t.Fatalf("unexpected page: total=%d groups=%d results=%d", page.Total, len(page.Groups), len(page.Results))
+	}
+	if page.Groups[0].FilePath != "f0.go" || page.Groups[1].FilePath != "f1.go" {
+		t.Errorf("groups = %s, %s; want f0.go, f1.go", page.Groups[0].FilePath, page.Groups[1].FilePath)
+	}
+	if len(page.Groups[0].Results) != 5 {
+		t.Errorf("f0.go has %d results, want 5", len(page.Groups[0].Results))
+	}
+
+	req.Cursor = page.NextCursor
+	page, err = r.Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Search() page 2 error = %v", err)
+	}
+	if len(page.Groups) != 2 || page.Groups[0].FilePath != "f2.go" {
+		t.Errorf("unexpected second page: %+v", page.Groups)
+	}
+}
+
+func TestRetriever_Search_Toggles(t *testing.T) {
+	rerank := &countingReranker{}
+	r := newSearchRetriever(10, rerank, hierarchy.NewHierarchicalFilter(1))
+	ctx := context.Background()
+
+	page, err := r.Search(ctx, domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler"}})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if rerank.calls != 1 || page.Total != 5 {
+		t.Errorf("default: rerank calls = %d, total = %d; want 1, 5", rerank.calls, page.Total)
+	}
+	if page.Results[0].RerankScore == 0 {
+		t.Error("expected rerank score to be recorded")
+	}
+
+	page, err = r.Search(ctx, domain.SearchRequest{SearchQuery: domain.SearchQuery{
+		Query:         "handler",
+		SkipRerank:    true,
+		SkipHierarchy: true,
+	}})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if rerank.calls != 1 || page.Total != 10 {
+		t.Errorf("skipped: rerank calls = %d, total = %d; want 1, 10", rerank.calls, page.Total)
+	}
+	if page.Results[0].RerankScore != 0 {
+		t.Error("expected no rerank score when reranking is skipped")
+	}
+}
+
+func TestRetriever_Search_Validation(t *testing.T) {
+	r := newSearchRetriever(25, nil, nil)
+	ctx := context.Background()
+
+	page, err := r.Search(ctx, domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler", MaxResults: 5}})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  domain.SearchRequest
+	}{
+		{"empty query", domain.SearchRequest{}},
+		{"page too large", domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler", MaxResults: 1000}}},
+		{"malformed cursor", domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler"}, Cursor: "!!"}},
+		{"cursor for another query", domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "other"}, Cursor: page.NextCursor}},
+		{"cursor with other options", domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler", SkipRerank: true}, Cursor: page.NextCursor}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Search(ctx, tt.req); !errors.Is(err, errors.ErrorTypeValidation) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	// Changing the page size keeps the cursor valid
+	next, err := r.Search(ctx, domain.SearchRequest{SearchQuery: domain.SearchQuery{Query: "handler", MaxResults: 20}, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Search() with new page size error = %v", err)
+	}
+	if len(next.Results) != 20 || next.Results[0].Rank != 6 {
+		t.Errorf("unexpected page after resizing: %d results, first rank %d", len(next.Results), next.Results[0].Rank)
+	}
+}
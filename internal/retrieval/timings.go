@@ -0,0 +1,123 @@
+package retrieval
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Retrieval stages timed by StageTimings
+const (
+	StageTransform     = "transform"
+	StageEmbed         = "embed"
+	StageVectorSearch  = "vector_search"
+	StageKeywordSearch = "keyword_search"
+	StageExactSearch   = "exact_search"
+	StageFusion        = "fusion"
+	StageRerank        = "rerank"
+	StageHierarchy     = "hierarchy"
+	StageExpansion     = "expansion"
+)
+
+// StageTimings collects how long each stage of a request took. A stage that
+// runs more than once (such as expansion of several pages) accumulates.
+// Stages cut short by their deadline are marked partial.
+type StageTimings struct {
+	mu      sync.Mutex
+	stages  map[string]time.Duration
+	partial map[string]bool
+}
+
+type stageTimingsKey struct{}
+
+// WithStageTimings returns a context under which the retriever records its
+// stage timings in the returned StageTimings
+func WithStageTimings(ctx context.Context) (context.Context, *StageTimings) {
+	t := &StageTimings{stages: make(map[string]time.Duration), partial: make(map[string]bool)}
+	return context.WithValue(ctx, stageTimingsKey{}, t), t
+}
+
+// Record adds d to stage. It does nothing on a nil StageTimings.
+func (t *StageTimings) Record(stage string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.stages[stage] += d
+	t.mu.Unlock()
+}
+
+// MarkPartial records that stage hit its deadline, so the results it
+// contributed are incomplete. It does nothing on a nil StageTimings.
+func (t *StageTimings) MarkPartial(stage string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.partial[stage] = true
+	t.mu.Unlock()
+}
+
+// PartialStages returns the stages marked partial, sorted
+func (t *StageTimings) PartialStages() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var stages []string
+	for stage := range t.partial {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	return stages
+}
+
+// Milliseconds returns a copy of the timings in milliseconds
+func (t *StageTimings) Milliseconds() map[string]float64 {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]float64, len(t.stages))
+	for stage, d := range t.stages {
+		out[stage] = float64(d.Microseconds()) / 1000
+	}
+	return out
+}
+
+// StageTimingsFrom returns the timings carried by ctx, or nil. Callers
+// outside the retriever use it to time their own stages.
+func StageTimingsFrom(ctx context.Context) *StageTimings {
+	t, _ := ctx.Value(stageTimingsKey{}).(*StageTimings)
+	return t
+}
+
+// timeStage records the time since start under stage, if ctx carries timings
+func timeStage(ctx context.Context, stage string, start time.Time) {
+	StageTimingsFrom(ctx).Record(stage, time.Since(start))
+}
+
+// StageDeadlines bound how long the retriever waits for each stage before
+// going on without it. Zero means no deadline.
+type StageDeadlines struct {
+	Search    time.Duration // vector and keyword search, run in parallel
+	Rerank    time.Duration
+	Expansion time.Duration
+}
+
+// withDeadline bounds a stage's context by d, if d is set
+func withDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// timedOut reports whether stageCtx ended because of its own deadline
+// rather than because the request was cancelled
+func timedOut(ctx, stageCtx context.Context) bool {
+	return ctx.Err() == nil && stageCtx.Err() == context.DeadlineExceeded
+}
@@ -0,0 +1,189 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/llm"
+	"github.com/Guru2308/rag-code/internal/lru"
+)
+
+// Names of the query variants a transformed query is searched with
+const (
+	VariantQuery      = "query"
+	VariantHyDE       = "hyde"
+	variantParaphrase = "paraphrase"
+)
+
+const (
+	maxParaphrases        = 5
+	maxHypotheticalChars  = 2000
+	maxParaphraseChars    = 300
+	defaultTransformCache = 1024
+)
+
+// ChatModel is the model that writes hypothetical code and paraphrases
+type ChatModel interface {
+	Generate(ctx context.Context, messages []llm.ChatMessage) (string, error)
+}
+
+// TransformConfig configures the query transformer
+type TransformConfig struct {
+	Budget    time.Duration // time allowed per model call (default 5s)
+	CacheSize int           // cached transformations (default 1024)
+}
+
+// QueryTransformer rewrites natural-language questions into text that
+// embeds closer to code: a hypothetical code answer (HyDE) and paraphrases
+// for multi-query search. Results are cached by query, so paging through a
+// transformed search asks the model once.
+type QueryTransformer struct {
+	model ChatModel
+	cfg   TransformConfig
+	cache *lru.Cache[string, []string]
+}
+
+// NewQueryTransformer creates a query transformer
+func NewQueryTransformer(model ChatModel, cfg TransformConfig) *QueryTransformer {
+	if cfg.Budget <= 0 {
+		cfg.Budget = 5 * time.Second
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = defaultTransformCache
+	}
+	return &QueryTransformer{model: model, cfg: cfg, cache: lru.New[string, []string](cfg.CacheSize)}
+}
+
+const hydePrompt = `Write a short %s snippet that would answer the developer's question below, as it might appear in their codebase. Use plausible names for the functions, types and variables involved. Reply with the code only, without explanation.
+
+Question: %s`
+
+const paraphrasePrompt = `Rewrite the developer's question below as %d different search queries for a code search engine. Vary them: use likely function, type and package names, synonyms, and more specific or more general phrasings.
+
+Question: %s
+
+Reply with a JSON array of %d strings and nothing else.`
+
+// Hypothetical returns code the model sketches as an answer to query
+func (t *QueryTransformer) Hypothetical(ctx context.Context, query, language string) (string, error) {
+	key := "hyde\x00" + strings.ToLower(language) + "\x00" + normalizeQuery(query)
+	if v, ok := t.cache.Get(key); ok {
+		return v[0], nil
+	}
+
+	kind := "code"
+	if language != "" {
+		kind = language + " code"
+	}
+	reply, err := t.generate(ctx, fmt.Sprintf(hydePrompt, kind, query))
+	if err != nil {
+		return "", err
+	}
+	code, err := parseHypothetical(reply)
+	if err != nil {
+		return "", err
+	}
+	t.cache.Put(key, []string{code})
+	return code, nil
+}
+
+// Paraphrases returns up to n rewordings of query, n capped at 5
+func (t *QueryTransformer) Paraphrases(ctx context.Context, query string, n int) ([]string, error) {
+	n = min(n, maxParaphrases)
+	if n <= 0 {
+		return nil, nil
+	}
+	key := fmt.Sprintf("multi\x00%d\x00%s", n, normalizeQuery(query))
+	if v, ok := t.cache.Get(key); ok {
+		return v, nil
+	}
+
+	reply, err := t.generate(ctx, fmt.Sprintf(paraphrasePrompt, n, query, n))
+	if err != nil {
+		return nil, err
+	}
+	paraphrases, err := parseParaphrases(reply, query, n)
+	if err != nil {
+		return nil, err
+	}
+	t.cache.Put(key, paraphrases)
+	return paraphrases, nil
+}
+
+func (t *QueryTransformer) generate(ctx context.Context, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Budget)
+	defer cancel()
+	return t.model.Generate(ctx, []llm.ChatMessage{{Role: "user", Content: prompt}})
+}
+
+// parseHypothetical takes the code out of a Markdown fence if the model
+// added one
+func parseHypothetical(reply string) (string, error) {
+	code := strings.TrimSpace(reply)
+	if start := strings.Index(code, "```"); start >= 0 {
+		code = code[start+3:]
+		if nl := strings.Index(code, "\n"); nl >= 0 {
+			code = code[nl+1:] // drop the language tag
+		}
+		if end := strings.Index(code, "```"); end >= 0 {
+			code = code[:end]
+		}
+		code = strings.TrimSpace(code)
+	}
+	if code == "" {
+		return "", errors.ValidationError("empty hypothetical code")
+	}
+	if len(code) > maxHypotheticalChars {
+		// Cut at a rune boundary so the embedded text stays valid UTF-8
+		end := maxHypotheticalChars
+		for end > 0 && !utf8.RuneStart(code[end]) {
+			end--
+		}
+		code = code[:end]
+	}
+	return code, nil
+}
+
+// parseParaphrases reads a JSON array of strings, or failing that one
+// paraphrase per line, dropping blanks, repeats and the query itself
+func parseParaphrases(reply, query string, n int) ([]string, error) {
+	var candidates []string
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start || json.Unmarshal([]byte(reply[start:end+1]), &candidates) != nil {
+		candidates = nil
+		for _, line := range strings.Split(reply, "\n") {
+			line = strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.) ")
+			if !strings.HasSuffix(line, ":") { // "Here are the queries:"
+				candidates = append(candidates, line)
+			}
+		}
+	}
+
+	seen := map[string]bool{normalizeQuery(query): true}
+	var out []string
+	for _, c := range candidates {
+		c = strings.Trim(strings.TrimSpace(c), "\"'`")
+		key := normalizeQuery(c)
+		if key == "" || seen[key] || len(c) > maxParaphraseChars {
+			continue
+		}
+		seen[key] = true
+		out = append(out, c)
+		if len(out) == n {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.ValidationError("no paraphrases in model reply")
+	}
+	return out, nil
+}
+
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
@@ -0,0 +1,248 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/llm"
+)
+
+// promptModel answers HyDE and paraphrase prompts and counts its calls
+type promptModel struct {
+	mu          sync.Mutex
+	calls       int
+	code        string
+	paraphrases string
+	err         error
+}
+
+func (m *promptModel) Generate(_ context.Context, messages []llm.ChatMessage) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return "", m.err
+	}
+	if strings.Contains(messages[0].Content, "JSON array") {
+		return m.paraphrases, nil
+	}
+	return m.code, nil
+}
+
+// textEmbedder embeds a text as a one-hot vector naming it, so the store
+// can tell which variant is searching
+type textEmbedder struct{ texts []string }
+
+func (e *textEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	for i, t := range e.texts {
+		if t == text {
+			v := make([]float32, len(e.texts))
+			v[i] = 1
+			return v, nil
+		}
+	}
+	return nil, errors.New("unexpected text " + text)
+}
+
+func (e *textEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("not implemented")
+}
+
+// variantStore returns the results listed for the embedded text
+type variantStore struct {
+	results [][]string // chunk IDs by text index
+}
+
+func (s *variantStore) Search(_ context.Context, vector []float32, limit int) ([]*domain.SearchResult, error) {
+	for i, x := range vector {
+		if x == 1 {
+			var out []*domain.SearchResult
+			for rank, id := range s.results[i] {
+				out = append(out, &domain.SearchResult{Chunk: &domain.CodeChunk{ID: id, Content: id}, Score: 0.9 - float32(rank)*0.1})
+			}
+			return out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *variantStore) Store(context.Context, []*domain.CodeChunk) error { return nil }
+func (s *variantStore) Get(_ context.Context, id string) (*domain.CodeChunk, error) {
+	return &domain.CodeChunk{ID: id, Content: id}, nil
+}
+func (s *variantStore) Delete(context.Context, string) error                       { return nil }
+func (s *variantStore) DeleteStaleFiles(context.Context, []domain.StaleFile) error { return nil }
+
+func TestRetriever_QueryTransformations(t *testing.T) {
+	const (
+		question = "how are expired sessions cleaned up"
+		code     = "func (s *Store) PurgeExpired() error { ... }"
+	)
+	model := &promptModel{code: "```go\n" + code + "\n```", paraphrases: `["session expiry cleanup", "PurgeExpired sessions"]`}
+	embedder := &textEmbedder{texts: []string{question, code, "session expiry cleanup", "PurgeExpired sessions"}}
+	store := &variantStore{results: [][]string{
+		{"handler", "purge"},
+		{"purge", "store"},
+		{"purge"},
+		{"purge", "cron"},
+	}}
+	r := NewRetriever(embedder, store, nil, nil, NewQueryPreprocessor(), nil, nil, nil, DefaultFusionConfig())
+
+	// Without a transformer the flags are ignored
+	results, err := r.Retrieve(context.Background(), domain.SearchQuery{Query: question, HyDE: true, MultiQuery: 2, MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if ids := resultIDs(results); !reflect.DeepEqual(ids, []string{"handler", "purge"}) {
+		t.Errorf("untransformed results = %v", ids)
+	}
+
+	r.SetTransformer(NewQueryTransformer(model, TransformConfig{}))
+	ctx, timings := WithStageTimings(context.Background())
+	query := domain.SearchQuery{Query: question, HyDE: true, MultiQuery: 2, MaxResults: 10, Debug: true}
+	results, err = r.Retrieve(ctx, query)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	// purge is found by every variant, so RRF ranks it first; cron and store
+	// are second for one variant each and tie
+	if ids := resultIDs(results); !reflect.DeepEqual(ids, []string{"purge", "handler", "cron", "store"}) {
+		t.Errorf("transformed results = %v", ids)
+	}
+	if v := results[0].Debug.Variants; !reflect.DeepEqual(v, []string{"query", "hyde", "paraphrase 1", "paraphrase 2"}) {
+		t.Errorf("purge variants = %v", v)
+	}
+	if v := results[2].Debug.Variants; !reflect.DeepEqual(v, []string{"paraphrase 2"}) {
+		t.Errorf("cron variants = %v", v)
+	}
+	if v := results[3].Debug.Variants; !reflect.DeepEqual(v, []string{"hyde"}) {
+		t.Errorf("store variants = %v", v)
+	}
+	if _, ok := timings.Milliseconds()[StageTransform]; !ok {
+		t.Error("transform stage should be timed")
+	}
+
+	// Transformations are cached per query
+	if _, err := r.Retrieve(context.Background(), query); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if model.calls != 2 {
+		t.Errorf("model called %d times, want 2", model.calls)
+	}
+}
+
+func TestRetriever_QueryTransformations_Fallback(t *testing.T) {
+	model := &promptModel{err: errors.New("model unavailable")}
+	embedder := &textEmbedder{texts: []string{"token refresh"}}
+	store := &variantStore{results: [][]string{{"refresh"}}}
+	r := NewRetriever(embedder, store, nil, nil, NewQueryPreprocessor(), nil, nil, nil, DefaultFusionConfig())
+	r.SetTransformer(NewQueryTransformer(model, TransformConfig{}))
+
+	results, err := r.Retrieve(context.Background(), domain.SearchQuery{Query: "token refresh", HyDE: true, MultiQuery: 3})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if ids := resultIDs(results); !reflect.DeepEqual(ids, []string{"refresh"}) {
+		t.Errorf("results = %v, want the query's own results", ids)
+	}
+}
+
+// tokenKeyword returns the documents listed for any of the searched tokens
+type tokenKeyword struct{ docs map[string][]string }
+
+func (k *tokenKeyword) Search(context.Context, []string, int) ([]string, error) {
+	return nil, errors.New("use SearchScored")
+}
+
+func (k *tokenKeyword) SearchScored(_ context.Context, tokens []string, _ int) ([]ScoredDoc, error) {
+	var out []ScoredDoc
+	for _, tok := range tokens {
+		for _, id := range k.docs[tok] {
+			out = append(out, ScoredDoc{ID: id, Score: 1})
+		}
+	}
+	return out, nil
+}
+
+func (k *tokenKeyword) AddToInvertedIndex(context.Context, []*domain.CodeChunk) error { return nil }
+func (k *tokenKeyword) RemoveStaleFiles(context.Context, []domain.StaleFile) error    { return nil }
+
+func TestRetriever_QueryTransformations_VariantVectorFailure(t *testing.T) {
+	const question = "token refresh"
+	// The paraphrase cannot be embedded, so only its vector search fails
+	model := &promptModel{paraphrases: `["rotate credentials"]`}
+	embedder := &textEmbedder{texts: []string{question}}
+	store := &variantStore{results: [][]string{{"refresh"}}}
+	keyword := &tokenKeyword{docs: map[string][]string{"rotate": {"rotate"}}}
+	r := NewRetriever(embedder, store, keyword, &batchScorer{}, NewQueryPreprocessor(), nil, nil, nil, DefaultFusionConfig())
+	r.SetTransformer(NewQueryTransformer(model, TransformConfig{}))
+
+	results, err := r.Retrieve(context.Background(), domain.SearchQuery{Query: question, MultiQuery: 1, MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	ids := resultIDs(results)
+	if !slices.Contains(ids, "rotate") {
+		t.Errorf("results = %v, want the paraphrase's keyword match kept", ids)
+	}
+}
+
+func TestParseParaphrases(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		{"json", `Sure: ["a b", "c d", "e f"]`, []string{"a b", "c d"}},
+		{"lines", "Here are the queries:\n1. token refresh\n2) \"refresh JWT\"\n- Token Refresh", []string{"token refresh", "refresh JWT"}},
+		{"drops the query and repeats", `["Expire Tokens", "expire   tokens", "rotate keys"]`, []string{"rotate keys"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseParaphrases(tt.reply, "expire tokens", 2)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseParaphrases() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := parseParaphrases(`["expire tokens"]`, "expire tokens", 2); err == nil {
+		t.Error("a reply with only the query should fail")
+	}
+}
+
+func TestParseHypothetical(t *testing.T) {
+	got, err := parseHypothetical("Here you go:\n```python\ndef refresh(token):\n    pass\n```\nThis refreshes.")
+	if err != nil || got != "def refresh(token):\n    pass" {
+		t.Errorf("parseHypothetical() = %q, %v", got, err)
+	}
+	if _, err := parseHypothetical("  "); err == nil {
+		t.Error("empty reply should fail")
+	}
+}
+
+func TestParseHypothetical_TruncatesAtRuneBoundary(t *testing.T) {
+	// The cut falls inside the trailing "é"
+	code := strings.Repeat("x", maxHypotheticalChars-1) + "é"
+	got, err := parseHypothetical(code)
+	if err != nil {
+		t.Fatalf("parseHypothetical() error = %v", err)
+	}
+	if !utf8.ValidString(got) || len(got) != maxHypotheticalChars-1 {
+		t.Errorf("parseHypothetical() kept %d bytes, valid UTF-8 = %v", len(got), utf8.ValidString(got))
+	}
+}
+
+func resultIDs(results []*domain.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Chunk.ID
+	}
+	return ids
+}
@@ -0,0 +1,528 @@
+package retrieval
+
+import (
+	"context"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/indexing"
+)
+
+// Grep result limits
+const (
+	DefaultGrepResults = 100
+	MaxGrepResults     = 1000
+)
+
+// TrigramIndex maps every three-byte sequence of chunk content to the
+// chunks containing it, for exact and regular expression search in the
+// manner of codesearch and zoekt. A pattern is narrowed to the chunks that
+// hold every trigram a match needs, and those are checked line by line.
+// Trigrams are ASCII case-folded, so one index serves case-sensitive and
+// case-insensitive patterns. The index is kept in memory and rebuilt from
+// the vector store on startup.
+type TrigramIndex struct {
+	mu       sync.RWMutex
+	docs     []*trigramDoc              // by document number; nil once removed
+	ids      map[string]uint32          // canonical chunk ID -> document number
+	files    map[string]map[uint32]bool // file path -> document numbers
+	postings map[uint32][]uint32        // trigram -> ascending document numbers
+	removed  int
+}
+
+type trigramDoc struct {
+	id         string
+	filePath   string
+	startLine  int
+	generation int64
+	content    string
+}
+
+// NewTrigramIndex creates an empty index
+func NewTrigramIndex() *TrigramIndex {
+	return &TrigramIndex{
+		ids:      make(map[string]uint32),
+		files:    make(map[string]map[uint32]bool),
+		postings: make(map[uint32][]uint32),
+	}
+}
+
+// AddToInvertedIndex adds chunks to the index. A chunk already indexed
+// with the same content only has its file and generation refreshed. IDs are
+// kept in canonical form, so chunks loaded from Qdrant and chunks indexed
+// since are the same documents.
+func (t *TrigramIndex) AddToInvertedIndex(_ context.Context, chunks []*domain.CodeChunk) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range chunks {
+		id := domain.CanonicalChunkID(c.ID)
+		if n, ok := t.ids[id]; ok {
+			if doc := t.docs[n]; doc.content == c.Content {
+				t.unlinkFile(n)
+				doc.filePath, doc.startLine, doc.generation = c.FilePath, c.StartLine, c.Generation
+				t.linkFile(n)
+				continue
+			}
+			t.remove(n)
+		}
+		t.add(&trigramDoc{id: id, filePath: c.FilePath, startLine: c.StartLine, generation: c.Generation, content: c.Content})
+	}
+	t.compactIfSparse()
+	return nil
+}
+
+// RemoveStale removes the chunks of filePath that were not written by
+// generation. Pass 0 to remove all of the file's chunks.
+func (t *TrigramIndex) RemoveStale(ctx context.Context, filePath string, generation int64) error {
+	return t.RemoveStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath, Generation: generation}})
+}
+
+// RemoveStaleFiles removes the stale chunks of several files under one lock
+func (t *TrigramIndex) RemoveStaleFiles(_ context.Context, files []domain.StaleFile) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, f := range files {
+		for n := range t.files[f.FilePath] {
+			if f.Generation == 0 || t.docs[n].generation != f.Generation {
+				t.remove(n)
+			}
+		}
+	}
+	t.compactIfSparse()
+	return nil
+}
+
+// RemoveChunks removes chunks by ID
+func (t *TrigramIndex) RemoveChunks(_ context.Context, ids []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range ids {
+		if n, ok := t.ids[domain.CanonicalChunkID(id)]; ok {
+			t.remove(n)
+		}
+	}
+	t.compactIfSparse()
+	return nil
+}
+
+// Len returns the number of indexed chunks
+func (t *TrigramIndex) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.ids)
+}
+
+func (t *TrigramIndex) add(doc *trigramDoc) {
+	n := uint32(len(t.docs))
+	t.docs = append(t.docs, doc)
+	t.ids[doc.id] = n
+	t.linkFile(n)
+	for tri := range trigramsOf(foldASCII(doc.content)) {
+		t.postings[tri] = append(t.postings[tri], n)
+	}
+}
+
+// remove drops a document; its postings are left behind until the index
+// is compacted and are skipped when read
+func (t *TrigramIndex) remove(n uint32) {
+	t.unlinkFile(n)
+	delete(t.ids, t.docs[n].id)
+	t.docs[n] = nil
+	t.removed++
+}
+
+// linkFile records document n under its file
+func (t *TrigramIndex) linkFile(n uint32) {
+	path := t.docs[n].filePath
+	if t.files[path] == nil {
+		t.files[path] = make(map[uint32]bool)
+	}
+	t.files[path][n] = true
+}
+
+// unlinkFile drops document n from its file's documents
+func (t *TrigramIndex) unlinkFile(n uint32) {
+	path := t.docs[n].filePath
+	delete(t.files[path], n)
+	if len(t.files[path]) == 0 {
+		delete(t.files, path)
+	}
+}
+
+// compactIfSparse renumbers the documents and rebuilds the postings once
+// most documents have been removed
+func (t *TrigramIndex) compactIfSparse() {
+	if t.removed <= len(t.ids) {
+		return
+	}
+	docs := t.docs
+	t.docs, t.removed = nil, 0
+	t.ids = make(map[string]uint32, len(t.ids))
+	t.files = make(map[string]map[uint32]bool, len(t.files))
+	t.postings = make(map[uint32][]uint32, len(t.postings))
+	for _, doc := range docs {
+		if doc != nil {
+			t.add(doc)
+		}
+	}
+}
+
+// Grep returns the lines of indexed code matching req, in file and line
+// order. Lines shared by overlapping chunks are reported once.
+func (t *TrigramIndex) Grep(ctx context.Context, req domain.GrepRequest) (*domain.GrepResponse, error) {
+	if req.Pattern == "" {
+		return nil, errors.ValidationError("pattern is required")
+	}
+	expr := req.Pattern
+	if !req.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !req.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.ValidationError("invalid regular expression: " + err.Error())
+	}
+	parsed, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, errors.ValidationError("invalid regular expression: " + err.Error())
+	}
+	paths, err := indexing.NewPathFilter(req.Paths)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.MaxResults
+	if limit <= 0 {
+		limit = DefaultGrepResults
+	}
+	limit = min(limit, MaxGrepResults)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var docs []*trigramDoc
+	for _, n := range t.candidates(regexpQuery(parsed.Simplify())) {
+		if doc := t.docs[n]; doc != nil && paths.Match(doc.filePath) {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].filePath != docs[j].filePath {
+			return docs[i].filePath < docs[j].filePath
+		}
+		return docs[i].startLine < docs[j].startLine
+	})
+
+	resp := &domain.GrepResponse{Matches: []domain.GrepMatch{}, Candidates: len(docs)}
+	type fileLine struct {
+		path string
+		line int
+	}
+	seen := make(map[fileLine]bool)
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for i, line := range strings.Split(doc.content, "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			locs := re.FindAllStringIndex(line, -1)
+			if locs == nil {
+				continue
+			}
+			key := fileLine{doc.filePath, doc.startLine + i}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if len(resp.Matches) == limit {
+				resp.Truncated = true
+				return resp, nil
+			}
+			match := domain.GrepMatch{FilePath: doc.filePath, Line: doc.startLine + i, Text: line, ChunkID: doc.id}
+			for _, loc := range locs {
+				match.Ranges = append(match.Ranges, [2]int{loc[0], loc[1]})
+			}
+			resp.Matches = append(resp.Matches, match)
+		}
+	}
+	return resp, nil
+}
+
+// SearchExact ranks chunks by how many of terms they contain verbatim,
+// ignoring case, and then by how often. It returns at most limit chunks.
+func (t *TrigramIndex) SearchExact(ctx context.Context, terms []string, limit int) ([]ScoredDoc, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	matched := make(map[uint32]int)     // terms found in each document
+	occurrences := make(map[uint32]int) // total occurrences of those terms
+	for _, term := range terms {
+		folded := foldASCII(term)
+		for _, n := range t.candidates(literalQuery(term, false)) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			doc := t.docs[n]
+			if doc == nil {
+				continue
+			}
+			if count := strings.Count(foldASCII(doc.content), folded); count > 0 {
+				matched[n]++
+				occurrences[n] += count
+			}
+		}
+	}
+
+	ranked := make([]ScoredDoc, 0, len(matched))
+	for n, count := range matched {
+		// Whole terms dominate; occurrences only break ties between them
+		ranked = append(ranked, ScoredDoc{ID: t.docs[n].id, Score: float64(count) + 1 - 1/float64(1+occurrences[n])})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+// trigramQuery is a boolean query over trigrams: a document matches an
+// AND node if it holds all of its trigrams and matches all of its subqueries,
+// and an OR node if it matches any subquery. A nil query matches everything.
+type trigramQuery struct {
+	or   bool
+	tris []uint32
+	subs []*trigramQuery
+}
+
+// literalQuery requires the trigrams of s. Under case folding, trigrams
+// with non-ASCII bytes are left out, as the index only folds ASCII.
+func literalQuery(s string, fold bool) *trigramQuery {
+	var tris []uint32
+	for tri := range trigramsOf(foldASCII(s)) {
+		if fold && (tri&0x808080) != 0 {
+			continue
+		}
+		tris = append(tris, tri)
+	}
+	if len(tris) == 0 {
+		return nil
+	}
+	sort.Slice(tris, func(i, j int) bool { return tris[i] < tris[j] })
+	return &trigramQuery{tris: tris}
+}
+
+// regexpQuery derives the trigrams any match of re must contain. Parts of
+// the expression that can match without a fixed literal impose nothing.
+func regexpQuery(re *syntax.Regexp) *trigramQuery {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return literalQuery(string(re.Rune), re.Flags&syntax.FoldCase != 0)
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexpQuery(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return regexpQuery(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Adjacent literals form one run, so their trigrams span the joins
+		q := &trigramQuery{}
+		var run strings.Builder
+		fold := false
+		flush := func() {
+			if sub := literalQuery(run.String(), fold); sub != nil {
+				q.subs = append(q.subs, sub)
+			}
+			run.Reset()
+			fold = false
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run.WriteString(string(sub.Rune))
+				fold = fold || sub.Flags&syntax.FoldCase != 0
+				continue
+			}
+			flush()
+			if subQuery := regexpQuery(sub); subQuery != nil {
+				q.subs = append(q.subs, subQuery)
+			}
+		}
+		flush()
+		if len(q.subs) == 0 {
+			return nil
+		}
+		return q
+	case syntax.OpAlternate:
+		q := &trigramQuery{or: true}
+		for _, sub := range re.Sub {
+			subQuery := regexpQuery(sub)
+			if subQuery == nil {
+				return nil
+			}
+			q.subs = append(q.subs, subQuery)
+		}
+		return q
+	}
+	return nil
+}
+
+// candidates returns the ascending numbers of the documents that may match
+// q, including removed ones
+func (t *TrigramIndex) candidates(q *trigramQuery) []uint32 {
+	docs, all := t.eval(q)
+	if !all {
+		return docs
+	}
+	docs = make([]uint32, len(t.docs))
+	for n := range docs {
+		docs[n] = uint32(n)
+	}
+	return docs
+}
+
+// eval returns the documents matching q, or all set when q matches every
+// document
+func (t *TrigramIndex) eval(q *trigramQuery) (docs []uint32, all bool) {
+	if q == nil {
+		return nil, true
+	}
+	if q.or {
+		for _, sub := range q.subs {
+			subDocs, subAll := t.eval(sub)
+			if subAll {
+				return nil, true
+			}
+			docs = union(docs, subDocs)
+		}
+		return docs, false
+	}
+
+	all = true
+	intersect := func(list []uint32) {
+		if all {
+			docs, all = list, false
+		} else {
+			docs = intersection(docs, list)
+		}
+	}
+	for _, tri := range q.tris {
+		intersect(t.postings[tri])
+	}
+	for _, sub := range q.subs {
+		if subDocs, subAll := t.eval(sub); !subAll {
+			intersect(subDocs)
+		}
+	}
+	return docs, all
+}
+
+func intersection(a, b []uint32) []uint32 {
+	var out []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func union(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// trigramsOf returns the set of three-byte sequences in s
+func trigramsOf(s string) map[uint32]struct{} {
+	tris := make(map[uint32]struct{})
+	for i := 0; i+3 <= len(s); i++ {
+		tris[uint32(s[i])<<16|uint32(s[i+1])<<8|uint32(s[i+2])] = struct{}{}
+	}
+	return tris
+}
+
+// foldASCII lower-cases ASCII letters, leaving every other byte as is
+func foldASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// exactTerms returns the parts of a query worth finding verbatim: spans in
+// backquotes, and words that look like code because they contain
+// punctuation, an underscore or an inner capital, such as ctx.Done() or
+// parseConfig
+func exactTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	addTerm := func(term string) {
+		if len(term) >= 3 && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	parts := strings.Split(query, "`")
+	for i, part := range parts {
+		if i%2 == 1 && i < len(parts)-1 {
+			addTerm(strings.TrimSpace(part))
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			word = strings.Trim(word, `"'“”‘’,;:!?`)
+			word = strings.TrimRight(word, ".")
+			if looksLikeCode(word) {
+				addTerm(word)
+			}
+		}
+	}
+	return terms
+}
+
+func looksLikeCode(word string) bool {
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case strings.IndexByte("._()[]{}<>:=*&$#/\\", c) >= 0:
+			return true
+		case 'A' <= c && c <= 'Z' && i > 0 && 'a' <= word[i-1] && word[i-1] <= 'z':
+			return true
+		}
+	}
+	return false
+}
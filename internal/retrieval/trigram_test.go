@@ -0,0 +1,305 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp/syntax"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+var trigramTestChunks = []*domain.CodeChunk{
+	{ID: "w1", FilePath: "internal/watch/watcher.go", StartLine: 10, Generation: 1, Content: "func (w *Watcher) Run(ctx context.Context) {\n\tselect {\n\tcase <-ctx.Done():\n\t\treturn\n\t}\n}"},
+	{ID: "e1", FilePath: "internal/errors/errors.go", StartLine: 1, Generation: 1, Content: "const (\n\tErrValidationType = \"validation\"\n\tErrNotFoundType = \"not_found\"\n\terrLowerType = \"x\"\n)"},
+	{ID: "m1", FilePath: "cmd/server/main.go", StartLine: 20, Generation: 1, Content: "// CTX.DONE() in a comment\nfunc main() {\n\t<-ctx.Done()\n}"},
+	{ID: "v1", FilePath: "vendor/lib/lib.go", StartLine: 1, Generation: 1, Content: "func wait(ctx context.Context) { <-ctx.Done() }"},
+}
+
+func newTestTrigramIndex(t *testing.T) *TrigramIndex {
+	t.Helper()
+	idx := NewTrigramIndex()
+	if err := idx.AddToInvertedIndex(context.Background(), trigramTestChunks); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	return idx
+}
+
+func grepLines(resp *domain.GrepResponse) []string {
+	var lines []string
+	for _, m := range resp.Matches {
+		lines = append(lines, fmt.Sprintf("%s:%d", m.FilePath, m.Line))
+	}
+	return lines
+}
+
+func TestTrigramIndex_Grep(t *testing.T) {
+	idx := newTestTrigramIndex(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  domain.GrepRequest
+		want []string
+	}{
+		{
+			name: "literal ignores case",
+			req:  domain.GrepRequest{Pattern: "ctx.Done()"},
+			want: []string{"cmd/server/main.go:20", "cmd/server/main.go:22", "internal/watch/watcher.go:12", "vendor/lib/lib.go:1"},
+		},
+		{
+			name: "literal case-sensitive",
+			req:  domain.GrepRequest{Pattern: "ctx.Done()", CaseSensitive: true},
+			want: []string{"cmd/server/main.go:22", "internal/watch/watcher.go:12", "vendor/lib/lib.go:1"},
+		},
+		{
+			name: "regex",
+			req:  domain.GrepRequest{Pattern: `Err[A-Z]\w+Type`, Regex: true, CaseSensitive: true},
+			want: []string{"internal/errors/errors.go:2", "internal/errors/errors.go:3"},
+		},
+		{
+			name: "regex alternation",
+			req:  domain.GrepRequest{Pattern: `func (main|wait)\(`, Regex: true},
+			want: []string{"cmd/server/main.go:21", "vendor/lib/lib.go:1"},
+		},
+		{
+			name: "path filters",
+			req:  domain.GrepRequest{Pattern: "ctx.Done()", Paths: []string{"*.go", "!vendor/", "!cmd/"}},
+			want: []string{"internal/watch/watcher.go:12"},
+		},
+		{
+			name: "no match",
+			req:  domain.GrepRequest{Pattern: "ctx.Err()"},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := idx.Grep(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("Grep() error = %v", err)
+			}
+			if got := grepLines(resp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Grep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrigramIndex_GrepMatchDetails(t *testing.T) {
+	idx := newTestTrigramIndex(t)
+	resp, err := idx.Grep(context.Background(), domain.GrepRequest{Pattern: "ctx", CaseSensitive: true, Paths: []string{"internal/watch/"}})
+	if err != nil {
+		t.Fatalf("Grep() error = %v", err)
+	}
+	if len(resp.Matches) != 2 || resp.Candidates != 1 {
+		t.Fatalf("Grep() = %+v", resp)
+	}
+	first := resp.Matches[0]
+	if first.ChunkID != "w1" || first.Line != 10 || first.Text != "func (w *Watcher) Run(ctx context.Context) {" {
+		t.Errorf("first match = %+v", first)
+	}
+	if !reflect.DeepEqual(first.Ranges, [][2]int{{22, 25}}) {
+		t.Errorf("first match ranges = %v", first.Ranges)
+	}
+}
+
+func TestTrigramIndex_GrepOverlapAndLimit(t *testing.T) {
+	idx := NewTrigramIndex()
+	ctx := context.Background()
+	// Two chunks overlapping on lines 3 and 4
+	chunks := []*domain.CodeChunk{
+		{ID: "p1", FilePath: "a.go", StartLine: 1, Content: "x := 1\nuse(x)\nuse(x)\nuse(x)"},
+		{ID: "p2", FilePath: "a.go", StartLine: 3, Content: "use(x)\nuse(x)\nuse(x)"},
+	}
+	if err := idx.AddToInvertedIndex(ctx, chunks); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+
+	resp, err := idx.Grep(ctx, domain.GrepRequest{Pattern: "use(x)"})
+	if err != nil {
+		t.Fatalf("Grep() error = %v", err)
+	}
+	if got, want := grepLines(resp), []string{"a.go:2", "a.go:3", "a.go:4", "a.go:5"}; !reflect.DeepEqual(got, want) || resp.Truncated {
+		t.Errorf("Grep() = %v (truncated %v), want %v", got, resp.Truncated, want)
+	}
+
+	resp, err = idx.Grep(ctx, domain.GrepRequest{Pattern: "use(x)", MaxResults: 2})
+	if err != nil {
+		t.Fatalf("Grep() error = %v", err)
+	}
+	if len(resp.Matches) != 2 || !resp.Truncated {
+		t.Errorf("Grep() with limit = %d matches, truncated %v", len(resp.Matches), resp.Truncated)
+	}
+}
+
+func TestTrigramIndex_GrepInvalid(t *testing.T) {
+	idx := newTestTrigramIndex(t)
+	for _, req := range []domain.GrepRequest{
+		{Pattern: ""},
+		{Pattern: "Err[A-Z", Regex: true},
+		{Pattern: "ctx", Paths: []string{"[z-a]"}},
+	} {
+		if _, err := idx.Grep(context.Background(), req); !errors.Is(err, errors.ErrorTypeValidation) {
+			t.Errorf("Grep(%+v) error = %v, want a validation error", req, err)
+		}
+	}
+}
+
+func TestTrigramIndex_Remove(t *testing.T) {
+	idx := newTestTrigramIndex(t)
+	ctx := context.Background()
+
+	// w1 is rewritten in generation 2 and its file loses the other chunks
+	updated := *trigramTestChunks[0]
+	updated.Generation = 2
+	updated.Content = "func (w *Watcher) Run() {}"
+	if err := idx.AddToInvertedIndex(ctx, []*domain.CodeChunk{&updated}); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	if err := idx.RemoveStale(ctx, updated.FilePath, 2); err != nil {
+		t.Fatalf("RemoveStale() error = %v", err)
+	}
+	if err := idx.RemoveChunks(ctx, []string{"v1"}); err != nil {
+		t.Fatalf("RemoveChunks() error = %v", err)
+	}
+	if idx.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", idx.Len())
+	}
+
+	resp, err := idx.Grep(ctx, domain.GrepRequest{Pattern: "ctx.Done()", CaseSensitive: true})
+	if err != nil {
+		t.Fatalf("Grep() error = %v", err)
+	}
+	if got, want := grepLines(resp), []string{"cmd/server/main.go:22"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Grep() after removal = %v, want %v", got, want)
+	}
+
+	// Generation 0 removes the whole file. Once removed documents outnumber
+	// live ones the index is compacted, leaving slots for e1 and m1.
+	for _, path := range []string{"internal/watch/watcher.go", "internal/errors/errors.go"} {
+		if err := idx.RemoveStale(ctx, path, 0); err != nil {
+			t.Fatalf("RemoveStale() error = %v", err)
+		}
+	}
+	if idx.Len() != 1 || len(idx.docs) != 2 {
+		t.Errorf("Len() = %d with %d slots, want 1 with 2 slots", idx.Len(), len(idx.docs))
+	}
+	resp, err = idx.Grep(ctx, domain.GrepRequest{Pattern: "ctx.Done()", CaseSensitive: true})
+	if err != nil || len(resp.Matches) != 1 {
+		t.Errorf("Grep() after compaction = %+v, %v", resp, err)
+	}
+}
+
+func TestTrigramIndex_RemoveStaleMovedChunk(t *testing.T) {
+	idx := newTestTrigramIndex(t)
+	ctx := context.Background()
+
+	// The file is renamed: its chunk keeps its ID under the new path, and
+	// dropping the old path must not take it along
+	moved := *trigramTestChunks[3]
+	moved.FilePath, moved.Generation = "third_party/lib/lib.go", 2
+	if err := idx.AddToInvertedIndex(ctx, []*domain.CodeChunk{&moved}); err != nil {
+		t.Fatalf("AddToInvertedIndex() error = %v", err)
+	}
+	if err := idx.RemoveStale(ctx, "vendor/lib/lib.go", 0); err != nil || idx.Len() != 4 {
+		t.Fatalf("RemoveStale(old path) left %d documents, %v, want 4", idx.Len(), err)
+	}
+	if err := idx.RemoveStale(ctx, moved.FilePath, 0); err != nil || idx.Len() != 3 {
+		t.Fatalf("RemoveStale(new path) left %d documents, %v, want 3", idx.Len(), err)
+	}
+	if len(idx.files) != 3 {
+		t.Errorf("files = %v, want the three remaining paths", idx.files)
+	}
+}
+
+func TestTrigramIndex_CanonicalIDs(t *testing.T) {
+	idx := NewTrigramIndex()
+	ctx := context.Background()
+	// Loaded from Qdrant on startup, then indexed again under the chunker's ID
+	loaded := &domain.CodeChunk{ID: "dfe7bd5d-38ad-3a31-91dd-4317ece83bba", FilePath: "a.go", Generation: 1, Content: "<-ctx.Done()"}
+	indexed := &domain.CodeChunk{ID: "dfe7bd5d38ad3a3191dd4317ece83bba", FilePath: "a.go", Generation: 2, Content: "<-ctx.Done()"}
+	for _, c := range []*domain.CodeChunk{loaded, indexed} {
+		if err := idx.AddToInvertedIndex(ctx, []*domain.CodeChunk{c}); err != nil {
+			t.Fatalf("AddToInvertedIndex() error = %v", err)
+		}
+	}
+	if idx.Len() != 1 || len(idx.docs) != 1 {
+		t.Fatalf("Len() = %d with %d slots, want one document", idx.Len(), len(idx.docs))
+	}
+
+	resp, err := idx.Grep(ctx, domain.GrepRequest{Pattern: "ctx.Done()"})
+	if err != nil || len(resp.Matches) != 1 || resp.Matches[0].ChunkID != indexed.ID {
+		t.Errorf("Grep() = %+v, %v, want one match in %s", resp, err, indexed.ID)
+	}
+	if err := idx.RemoveChunks(ctx, []string{loaded.ID}); err != nil || idx.Len() != 0 {
+		t.Errorf("RemoveChunks() by hyphenated ID left %d documents, %v", idx.Len(), err)
+	}
+}
+
+func TestTrigramIndex_SearchExact(t *testing.T) {
+	idx := newTestTrigramIndex(t)
+	docs, err := idx.SearchExact(context.Background(), []string{"ctx.Done()", "context.Context"}, 10)
+	if err != nil {
+		t.Fatalf("SearchExact() error = %v", err)
+	}
+	var ids []string
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	// v1 and w1 hold both terms; m1 holds ctx.Done() twice, ignoring case
+	if want := []string{"v1", "w1", "m1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("SearchExact() = %v, want %v", ids, want)
+	}
+	if docs[2].Score <= 1 || docs[2].Score >= docs[1].Score {
+		t.Errorf("SearchExact() scores = %+v", docs)
+	}
+
+	docs, err = idx.SearchExact(context.Background(), []string{"ctx.Done()"}, 1)
+	if err != nil || len(docs) != 1 || docs[0].ID != "m1" {
+		t.Errorf("SearchExact() with limit = %+v, %v", docs, err)
+	}
+}
+
+func TestExactTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"where is ctx.Done() handled?", []string{"ctx.Done()"}},
+		{"how does parseConfig read the file", []string{"parseConfig"}},
+		{"find `Err[A-Z]` and MAX_SIZE, then MAX_SIZE", []string{"Err[A-Z]", "MAX_SIZE"}},
+		{"how does authentication work", nil},
+		{"the Go API uses a", nil},
+	}
+	for _, tt := range tests {
+		if got := exactTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("exactTerms(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRegexpQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		nil  bool
+	}{
+		{`ctx\.Done\(\)`, false},
+		{`Err[A-Z]\w+Type`, false},
+		{`(?i)(foo|bar)baz`, false},
+		{`(foo|.)`, true},
+		{`a.b`, true},
+		{`\w+`, true},
+	}
+	for _, tt := range tests {
+		re, err := syntax.Parse(tt.expr, syntax.Perl)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+		}
+		if q := regexpQuery(re.Simplify()); (q == nil) != tt.nil {
+			t.Errorf("regexpQuery(%q) = %+v, want nil %v", tt.expr, q, tt.nil)
+		}
+	}
+}
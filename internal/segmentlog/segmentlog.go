@@ -0,0 +1,201 @@
+// Package segmentlog persists an in-memory store as a log of gob-encoded
+// segment files in a directory. Every write is appended as a delta segment,
+// and once the deltas outgrow the base segment the whole store is written
+// as a new base, so the work of rewriting it is spread over the writes that
+// made it necessary. The local keyword index and the local vector store
+// both persist through it.
+package segmentlog
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+// Segment file suffixes. A base segment holds the whole store; each delta
+// segment holds one batch of changes made after it.
+const (
+	BaseExt  = ".base"
+	DeltaExt = ".delta"
+)
+
+// Log is a directory of segments of type S
+type Log[S any] struct {
+	dir        string
+	name       string // what the log persists, for error messages
+	seq        int    // number of the newest segment
+	baseBytes  int64  // size of the current base segment
+	deltaBytes int64  // total size of the delta segments after it
+	minCompact int64
+}
+
+// Open opens the log in dir, creating dir if needed, and replays it: replay
+// is called with the newest base segment and then each delta after it,
+// oldest first, with the segment's file name. Older segments, left behind
+// if a compaction was interrupted, are ignored.
+//
+// Deltas are compacted once they outgrow the base, or minCompact bytes if
+// the base is smaller.
+func Open[S any](dir, name string, minCompact int64, replay func(seg *S, file string) error) (*Log[S], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to create "+name+" directory")
+	}
+	l := &Log[S]{dir: dir, name: name, minCompact: minCompact}
+
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+	start := 0
+	for i, f := range files {
+		if f.base {
+			start = i
+		}
+	}
+	for _, f := range files[start:] {
+		seg, err := l.read(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := replay(seg, f.name); err != nil {
+			return nil, err
+		}
+		if f.base {
+			l.baseBytes = f.size
+		} else {
+			l.deltaBytes += f.size
+		}
+	}
+	if len(files) > 0 {
+		l.seq = files[len(files)-1].seq
+	}
+	return l, nil
+}
+
+// SetMinCompact sets the size the deltas may reach before they are
+// compacted, whatever the size of the base
+func (l *Log[S]) SetMinCompact(n int64) {
+	l.minCompact = n
+}
+
+// Append writes seg as the next delta segment. Callers apply seg to their
+// store only once it is written, so a failed write leaves the store as it
+// was. It reports whether the deltas have outgrown the base, in which case
+// the caller should Compact.
+func (l *Log[S]) Append(seg *S) (bool, error) {
+	size, err := l.write(seg, DeltaExt)
+	if err != nil {
+		return false, err
+	}
+	l.deltaBytes += size
+	return l.deltaBytes > max(l.baseBytes, l.minCompact), nil
+}
+
+// Compact writes base, the whole store, as a new base segment and deletes
+// the segments before it. It returns the size of the new base.
+func (l *Log[S]) Compact(base *S) (int64, error) {
+	size, err := l.write(base, BaseExt)
+	if err != nil {
+		return 0, err
+	}
+	l.baseBytes, l.deltaBytes = size, 0
+
+	files, err := l.files()
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if f.seq < l.seq {
+			if err := os.Remove(filepath.Join(l.dir, f.name)); err != nil && !os.IsNotExist(err) {
+				return 0, errors.Wrap(err, errors.ErrorTypeInternal, "failed to remove compacted "+l.name+" segment")
+			}
+		}
+	}
+	return size, nil
+}
+
+// write persists seg as the next segment and returns its size. The file is
+// written under a temporary name and renamed, so a crash never leaves a
+// partial segment behind.
+func (l *Log[S]) write(seg *S, ext string) (int64, error) {
+	l.seq++
+	path := filepath.Join(l.dir, fmt.Sprintf("%08d%s", l.seq, ext))
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeInternal, "failed to create "+l.name+" segment")
+	}
+	err = gob.NewEncoder(f).Encode(seg)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, errors.Wrap(err, errors.ErrorTypeInternal, "failed to write "+l.name+" segment")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeInternal, "failed to stat "+l.name+" segment")
+	}
+	return info.Size(), nil
+}
+
+func (l *Log[S]) read(name string) (*S, error) {
+	f, err := os.Open(filepath.Join(l.dir, name))
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to open "+l.name+" segment")
+	}
+	defer f.Close()
+	var seg S
+	if err := gob.NewDecoder(f).Decode(&seg); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to read "+l.name+" segment "+name)
+	}
+	return &seg, nil
+}
+
+type segmentFile struct {
+	name string
+	seq  int
+	base bool
+	size int64
+}
+
+// files lists the segment files in the log directory, oldest first
+func (l *Log[S]) files() ([]segmentFile, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to read "+l.name+" directory")
+	}
+	var files []segmentFile
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != BaseExt && ext != DeltaExt) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ext))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrorTypeInternal, "failed to stat "+l.name+" segment")
+		}
+		files = append(files, segmentFile{name: e.Name(), seq: seq, base: ext == BaseExt, size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+	return files, nil
+}
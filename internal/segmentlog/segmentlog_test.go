@@ -0,0 +1,84 @@
+package segmentlog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+type testSegment struct {
+	Values []int
+}
+
+// openTest opens the log in dir and returns it with the values it replayed
+func openTest(t *testing.T, dir string, minCompact int64) (*Log[testSegment], *[]int) {
+	t.Helper()
+	var values []int
+	l, err := Open(dir, "test", minCompact, func(seg *testSegment, _ string) error {
+		values = append(values, seg.Values...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return l, &values
+}
+
+func TestLog_AppendAndReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log")
+	l, _ := openTest(t, dir, 1<<20)
+	for i := 1; i <= 3; i++ {
+		if due, err := l.Append(&testSegment{Values: []int{i}}); err != nil || due {
+			t.Fatalf("Append() = %v, %v, want no compaction yet", due, err)
+		}
+	}
+
+	_, values := openTest(t, dir, 1<<20)
+	if !reflect.DeepEqual(*values, []int{1, 2, 3}) {
+		t.Errorf("replayed %v, want the deltas in order", *values)
+	}
+}
+
+func TestLog_Compact(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTest(t, dir, 0)
+	due, err := l.Append(&testSegment{Values: []int{1}})
+	if err != nil || !due {
+		t.Fatalf("Append() = %v, %v, want compaction due", due, err)
+	}
+	if _, err := l.Compact(&testSegment{Values: []int{10}}); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if _, err := l.Append(&testSegment{Values: []int{2}}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"00000002.base", "00000003.delta"}) {
+		t.Errorf("segments = %v, want the base and the delta after it", names)
+	}
+
+	// Segments older than the base, left by an interrupted compaction, are ignored
+	os.WriteFile(filepath.Join(dir, "00000001.delta"), []byte("stale"), 0o644)
+	_, values := openTest(t, dir, 0)
+	if !reflect.DeepEqual(*values, []int{10, 2}) {
+		t.Errorf("replayed %v, want the base and the delta after it", *values)
+	}
+}
+
+func TestLog_CorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "00000001.delta"), []byte("not a segment"), 0o644)
+	_, err := Open(dir, "test", 0, func(*testSegment, string) error { return nil })
+	if !errors.Is(err, errors.ErrorTypeInternal) || !strings.Contains(err.Error(), "00000001.delta") {
+		t.Errorf("Open() error = %v, want an internal error naming the segment", err)
+	}
+}
@@ -0,0 +1,200 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// hnsw is a hierarchical navigable small world graph for approximate
+// nearest neighbour search. Removed nodes are only marked, so they still
+// route searches; the store rebuilds the graph without them when it
+// compacts.
+type hnsw struct {
+	m              int // neighbours per node above layer 0; 2m on layer 0
+	efConstruction int
+	levelMult      float64
+	nodes          []*hnswNode
+	entry          int // -1 while empty
+	maxLevel       int
+	removed        int
+	rng            *rand.Rand
+}
+
+type hnswNode struct {
+	id      string
+	vec     *vector
+	removed bool
+	friends [][]int // neighbour node indexes by layer
+}
+
+func newHNSW(m, efConstruction int) *hnsw {
+	return &hnsw{
+		m:              m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(1)),
+	}
+}
+
+// insert adds a node for id and returns its index
+func (h *hnsw) insert(id string, vec *vector) int {
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	idx := len(h.nodes)
+	node := &hnswNode{id: id, vec: vec, friends: make([][]int, level+1)}
+	h.nodes = append(h.nodes, node)
+	if h.entry < 0 {
+		h.entry, h.maxLevel = idx, level
+		return idx
+	}
+
+	query := vec.floats()
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(query, ep, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(query, ep, h.efConstruction, l)
+		neighbours := candidates[:min(h.m, len(candidates))]
+		for _, c := range neighbours {
+			node.friends[l] = append(node.friends[l], c.node)
+			h.connect(c.node, idx, l)
+		}
+		ep = candidates[0].node
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = idx, level
+	}
+	return idx
+}
+
+// connect adds to as a neighbour of from on layer l, keeping from's most
+// similar neighbours when it has too many
+func (h *hnsw) connect(from, to, l int) {
+	node := h.nodes[from]
+	node.friends[l] = append(node.friends[l], to)
+	maxFriends := h.m
+	if l == 0 {
+		maxFriends = 2 * h.m
+	}
+	if len(node.friends[l]) <= maxFriends {
+		return
+	}
+	query := node.vec.floats()
+	sort.Slice(node.friends[l], func(i, j int) bool {
+		return h.nodes[node.friends[l][i]].vec.similarity(query) > h.nodes[node.friends[l][j]].vec.similarity(query)
+	})
+	node.friends[l] = node.friends[l][:maxFriends]
+}
+
+// remove marks the node at idx removed
+func (h *hnsw) remove(idx int) {
+	if !h.nodes[idx].removed {
+		h.nodes[idx].removed = true
+		h.removed++
+	}
+}
+
+// search returns up to ef nodes most similar to query, best first, leaving
+// out removed nodes
+func (h *hnsw) search(query []float32, ef int) []scored {
+	if h.entry < 0 {
+		return nil
+	}
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(query, ep, l)
+	}
+	found := h.searchLayer(query, ep, ef, 0)
+	out := found[:0]
+	for _, c := range found {
+		if !h.nodes[c.node].removed {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// greedy walks layer l from ep to the node most similar to query
+func (h *hnsw) greedy(query []float32, ep, l int) int {
+	best := h.nodes[ep].vec.similarity(query)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.nodes[ep].friends[l] {
+			if s := h.nodes[n].vec.similarity(query); s > best {
+				best, ep, changed = s, n, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer is a beam search of width ef over layer l, returning the
+// nodes found best first
+func (h *hnsw) searchLayer(query []float32, ep, ef, l int) []scored {
+	visited := map[int]bool{ep: true}
+	start := scored{node: ep, score: h.nodes[ep].vec.similarity(query)}
+	candidates := &maxHeap{start}
+	results := &minHeap{start}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(scored)
+		if results.Len() >= ef && c.score < (*results)[0].score {
+			break
+		}
+		for _, n := range h.nodes[c.node].friends[l] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			s := scored{node: n, score: h.nodes[n].vec.similarity(query)}
+			if results.Len() < ef || s.score > (*results)[0].score {
+				heap.Push(candidates, s)
+				heap.Push(results, s)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := make([]scored, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(scored)
+	}
+	return out
+}
+
+type scored struct {
+	node  int
+	score float32
+}
+
+// minHeap keeps the worst result on top; maxHeap the best candidate
+type minHeap []scored
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(scored)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type maxHeap []scored
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].score > h[j].score }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(scored)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
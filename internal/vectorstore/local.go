@@ -0,0 +1,495 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+	"github.com/Guru2308/rag-code/internal/logger"
+	"github.com/Guru2308/rag-code/internal/segmentlog"
+)
+
+// Store is what the app needs from a vector store. QdrantStore and
+// LocalStore both implement it.
+type Store interface {
+	Store(ctx context.Context, chunks []*domain.CodeChunk) error
+	Delete(ctx context.Context, filePath string) error
+	DeleteStale(ctx context.Context, filePath string, generation int64) error
+	DeleteStaleFiles(ctx context.Context, files []domain.StaleFile) error
+	Get(ctx context.Context, id string) (*domain.CodeChunk, error)
+	GetBatch(ctx context.Context, ids []string) ([]*domain.CodeChunk, error)
+	Search(ctx context.Context, queryVector []float32, limit int) ([]*domain.SearchResult, error)
+	ListChunks(ctx context.Context) ([]domain.ChunkRef, error)
+	RemoveChunks(ctx context.Context, ids []string) error
+	ScrollChunks(ctx context.Context, fn func(*domain.CodeChunk) error) error
+	InitCollection(ctx context.Context, vectorSize int) error
+}
+
+// defaultMinCompactBytes is the size delta segments may reach before they
+// are compacted into a new base, whatever the size of the base
+const defaultMinCompactBytes = 4 << 20
+
+// LocalConfig selects how a LocalStore indexes its vectors
+type LocalConfig struct {
+	// Index is "flat" for exact search over every vector or "hnsw" for an
+	// approximate graph index
+	Index string
+	// Quantization is "none" or "int8", which stores each vector in a
+	// quarter of the memory at a small cost in accuracy
+	Quantization string
+
+	// HNSW parameters: neighbours per node, and the beam widths used when
+	// building the graph and when searching it
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+// DefaultLocalConfig returns an exact, unquantized index
+func DefaultLocalConfig() LocalConfig {
+	return LocalConfig{
+		Index:          "flat",
+		Quantization:   "none",
+		M:              16,
+		EfConstruction: 200,
+		EfSearch:       64,
+	}
+}
+
+// LocalStore is an embedded vector store persisted to a segment log in a
+// directory, for running without Qdrant. Vectors are compared by cosine
+// similarity like the Qdrant collection, either exhaustively or through an
+// HNSW graph, and searches can be filtered by chunk payload.
+type LocalStore struct {
+	mu     sync.RWMutex
+	dir    string
+	cfg    LocalConfig
+	dims   int
+	points map[string]*localPoint
+	graph  *hnsw          // nil for a flat index
+	nodes  map[string]int // chunk ID -> graph node
+	log    *segmentlog.Log[vectorSegment]
+}
+
+type localPoint struct {
+	chunk *domain.CodeChunk // without its embedding
+	vec   *vector
+}
+
+// vectorSegment is the on-disk form of a batch of changes, or of the whole
+// store for a base segment. Count is the number of points after the segment
+// is applied and is checked on load.
+type vectorSegment struct {
+	Dims    int
+	Points  []storedPoint
+	Deleted []string
+	Count   int
+}
+
+// storedPoint holds a chunk and its normalized vector, as floats or as
+// int8 codes with their scale
+type storedPoint struct {
+	Chunk  domain.CodeChunk
+	Vector []float32
+	Codes  []int8
+	Scale  float32
+}
+
+// NewLocalStore opens the store persisted in dir, creating dir if needed
+func NewLocalStore(dir string, cfg LocalConfig) (*LocalStore, error) {
+	defaults := DefaultLocalConfig()
+	if cfg.Index == "" {
+		cfg.Index = defaults.Index
+	}
+	if cfg.Quantization == "" {
+		cfg.Quantization = defaults.Quantization
+	}
+	if cfg.M < 2 {
+		cfg.M = defaults.M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = defaults.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = defaults.EfSearch
+	}
+	if cfg.Index != "flat" && cfg.Index != "hnsw" {
+		return nil, errors.ValidationError("vector index must be flat or hnsw, got " + cfg.Index)
+	}
+	if cfg.Quantization != "none" && cfg.Quantization != "int8" {
+		return nil, errors.ValidationError("vector quantization must be none or int8, got " + cfg.Quantization)
+	}
+
+	s := &LocalStore{
+		dir:    dir,
+		cfg:    cfg,
+		points: make(map[string]*localPoint),
+	}
+	log, err := segmentlog.Open(dir, "vector store", defaultMinCompactBytes, s.replay)
+	if err != nil {
+		return nil, err
+	}
+	s.log = log
+	if len(s.points) > 0 {
+		logger.Info("Loaded local vector store", "dir", dir, "points", len(s.points))
+	}
+	// The graph is not persisted; it is built once the points are loaded
+	s.rebuildGraph()
+	return s, nil
+}
+
+// InitCollection fixes the vector size of an empty store, and checks it
+// against the size of a store that already holds vectors
+func (s *LocalStore) InitCollection(_ context.Context, vectorSize int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dims == 0 {
+		s.dims = vectorSize
+		logger.Info("Initialized local vector store", "dir", s.dir, "size", vectorSize, "index", s.cfg.Index, "quantization", s.cfg.Quantization)
+		return nil
+	}
+	if s.dims != vectorSize {
+		return errors.ValidationError(fmt.Sprintf("vector store in %s holds %d-dimensional vectors, not %d; remove it and reindex to change embedding model", s.dir, s.dims, vectorSize))
+	}
+	return nil
+}
+
+// Store adds chunks with their embeddings, replacing chunks with the same ID
+func (s *LocalStore) Store(_ context.Context, chunks []*domain.CodeChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dims := s.dims
+	seg := &vectorSegment{Points: make([]storedPoint, 0, len(chunks))}
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) == 0 {
+			return errors.ValidationError("chunk " + chunk.ID + " has no embedding")
+		}
+		if dims == 0 {
+			dims = len(chunk.Embedding)
+		}
+		if len(chunk.Embedding) != dims {
+			return errors.ValidationError(fmt.Sprintf("chunk %s has a %d-dimensional embedding, want %d", chunk.ID, len(chunk.Embedding), dims))
+		}
+		stored := storedPoint{Chunk: *chunk}
+		stored.Chunk.Embedding = nil
+		stored.Chunk.Content = toValidUTF8(chunk.Content)
+		vec := newVector(chunk.Embedding, s.quantize())
+		stored.Vector, stored.Codes, stored.Scale = vec.f, vec.q, vec.scale
+		seg.Points = append(seg.Points, stored)
+	}
+	seg.Dims = dims
+	return s.commit(seg)
+}
+
+// Delete removes every chunk of a file
+func (s *LocalStore) Delete(_ context.Context, filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeWhere(func(c *domain.CodeChunk) bool { return c.FilePath == filePath })
+}
+
+// DeleteStale removes a file's chunks written by any generation other than
+// generation
+func (s *LocalStore) DeleteStale(ctx context.Context, filePath string, generation int64) error {
+	return s.DeleteStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath, Generation: generation}})
+}
+
+// DeleteStaleFiles removes the stale chunks of several files in one segment
+func (s *LocalStore) DeleteStaleFiles(_ context.Context, files []domain.StaleFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	current := make(map[string]int64, len(files))
+	for _, f := range files {
+		current[f.FilePath] = f.Generation
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeWhere(func(c *domain.CodeChunk) bool {
+		generation, ok := current[c.FilePath]
+		return ok && c.Generation != generation
+	})
+}
+
+// RemoveChunks deletes chunks by ID
+func (s *LocalStore) RemoveChunks(_ context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted []string
+	for _, id := range ids {
+		if _, ok := s.points[id]; ok {
+			deleted = append(deleted, id)
+		}
+	}
+	return s.remove(deleted)
+}
+
+// removeWhere commits the removal of the chunks that match
+func (s *LocalStore) removeWhere(match func(*domain.CodeChunk) bool) error {
+	var deleted []string
+	for id, p := range s.points {
+		if match(p.chunk) {
+			deleted = append(deleted, id)
+		}
+	}
+	return s.remove(deleted)
+}
+
+func (s *LocalStore) remove(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+	return s.commit(&vectorSegment{Dims: s.dims, Deleted: slices.Compact(ids)})
+}
+
+// Get retrieves a single chunk by ID
+func (s *LocalStore) Get(_ context.Context, id string) (*domain.CodeChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.points[id]
+	if !ok {
+		return nil, errors.NotFoundError("chunk not found")
+	}
+	return copyChunk(p.chunk), nil
+}
+
+// GetBatch fetches several chunks. IDs that are not stored are left out of
+// the result.
+func (s *LocalStore) GetBatch(_ context.Context, ids []string) ([]*domain.CodeChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var chunks []*domain.CodeChunk
+	for _, id := range ids {
+		if p, ok := s.points[id]; ok {
+			chunks = append(chunks, copyChunk(p.chunk))
+		}
+	}
+	return chunks, nil
+}
+
+// ListChunks returns the ID, file, generation and file hash of every stored
+// chunk
+func (s *LocalStore) ListChunks(_ context.Context) ([]domain.ChunkRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	refs := make([]domain.ChunkRef, 0, len(s.points))
+	for id, p := range s.points {
+		refs = append(refs, domain.ChunkRef{ID: id, FilePath: p.chunk.FilePath, Generation: p.chunk.Generation, FileHash: p.chunk.FileHash})
+	}
+	return refs, nil
+}
+
+// ScrollChunks calls fn with every stored chunk in ID order, without its
+// embedding, stopping at the first error fn returns
+func (s *LocalStore) ScrollChunks(ctx context.Context, fn func(*domain.CodeChunk) error) error {
+	s.mu.RLock()
+	chunks := make([]*domain.CodeChunk, 0, len(s.points))
+	for _, p := range s.points {
+		chunks = append(chunks, copyChunk(p.chunk))
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ID < chunks[j].ID })
+	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search returns the limit chunks most similar to queryVector
+func (s *LocalStore) Search(ctx context.Context, queryVector []float32, limit int) ([]*domain.SearchResult, error) {
+	return s.SearchFiltered(ctx, queryVector, limit, nil)
+}
+
+// SearchFiltered returns the limit chunks most similar to queryVector among
+// those that match filter. The HNSW index searches a wider beam when
+// filtering and falls back to an exact scan if too few matches survive.
+func (s *LocalStore) SearchFiltered(_ context.Context, queryVector []float32, limit int, filter *domain.ChunkFilter) ([]*domain.SearchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.points) == 0 {
+		return []*domain.SearchResult{}, nil
+	}
+	if len(queryVector) != s.dims {
+		return nil, errors.ValidationError(fmt.Sprintf("query vector has %d dimensions, want %d", len(queryVector), s.dims))
+	}
+	query := normalize(queryVector)
+
+	if s.graph != nil {
+		ef := max(s.cfg.EfSearch, limit)
+		if filter != nil {
+			ef *= 4
+		}
+		var results []*domain.SearchResult
+		for _, c := range s.graph.search(query, ef) {
+			p := s.points[s.graph.nodes[c.node].id]
+			if filter.Matches(p.chunk) {
+				results = append(results, &domain.SearchResult{Chunk: copyChunk(p.chunk), Score: c.score})
+			}
+		}
+		if len(results) >= limit || (filter == nil && len(results) == len(s.points)) {
+			return topResults(results, limit), nil
+		}
+	}
+
+	results := make([]*domain.SearchResult, 0, len(s.points))
+	for _, p := range s.points {
+		if filter.Matches(p.chunk) {
+			results = append(results, &domain.SearchResult{Chunk: p.chunk, Score: p.vec.similarity(query)})
+		}
+	}
+	results = topResults(results, limit)
+	for _, res := range results {
+		res.Chunk = copyChunk(res.Chunk)
+	}
+	return results, nil
+}
+
+// topResults sorts results by score, ties by ID, and keeps the first limit
+func topResults(results []*domain.SearchResult, limit int) []*domain.SearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (s *LocalStore) quantize() bool {
+	return s.cfg.Quantization == "int8"
+}
+
+// copyChunk returns a copy of a stored chunk that callers may modify
+func copyChunk(c *domain.CodeChunk) *domain.CodeChunk {
+	out := *c
+	if c.Metadata != nil {
+		out.Metadata = make(map[string]string, len(c.Metadata))
+		for k, v := range c.Metadata {
+			out.Metadata[k] = v
+		}
+	}
+	out.Dependencies = append([]string(nil), c.Dependencies...)
+	return &out
+}
+
+// commit writes seg as a delta segment and then applies it, so a failed
+// write leaves the store as it was. Once the deltas outgrow the base they
+// are compacted.
+func (s *LocalStore) commit(seg *vectorSegment) error {
+	seg.Count = len(s.points) - len(seg.Deleted)
+	added := make(map[string]bool, len(seg.Points))
+	for _, p := range seg.Points {
+		if _, ok := s.points[p.Chunk.ID]; !ok && !added[p.Chunk.ID] {
+			seg.Count++
+		}
+		added[p.Chunk.ID] = true
+	}
+
+	due, err := s.log.Append(seg)
+	if err != nil {
+		return err
+	}
+	s.apply(seg)
+	if due {
+		return s.compact()
+	}
+	return nil
+}
+
+// apply adds seg's changes to the in-memory store and graph
+func (s *LocalStore) apply(seg *vectorSegment) {
+	if seg.Dims != 0 {
+		s.dims = seg.Dims
+	}
+	for _, id := range seg.Deleted {
+		delete(s.points, id)
+		if node, ok := s.nodes[id]; ok && s.graph != nil {
+			s.graph.remove(node)
+			delete(s.nodes, id)
+		}
+	}
+	for i := range seg.Points {
+		stored := &seg.Points[i]
+		chunk := stored.Chunk
+		vec := &vector{f: stored.Vector, q: stored.Codes, scale: stored.Scale}
+		if (vec.q != nil) != s.quantize() {
+			// The store was written with other quantization settings
+			vec = newVector(vec.floats(), s.quantize())
+		}
+		s.points[chunk.ID] = &localPoint{chunk: &chunk, vec: vec}
+		if s.graph != nil {
+			if node, ok := s.nodes[chunk.ID]; ok {
+				s.graph.remove(node)
+			}
+			s.nodes[chunk.ID] = s.graph.insert(chunk.ID, vec)
+		}
+	}
+}
+
+// replay applies a segment read back from the log, checking it against its
+// point count
+func (s *LocalStore) replay(seg *vectorSegment, file string) error {
+	s.apply(seg)
+	if seg.Count != len(s.points) {
+		return errors.New(errors.ErrorTypeInternal, "vector store segment "+file+" does not match its point count")
+	}
+	return nil
+}
+
+// rebuildGraph builds the HNSW graph from scratch over the live points, in
+// ID order so the same store always builds the same graph
+func (s *LocalStore) rebuildGraph() {
+	if s.cfg.Index != "hnsw" {
+		return
+	}
+	ids := make([]string, 0, len(s.points))
+	for id := range s.points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	s.graph = newHNSW(s.cfg.M, s.cfg.EfConstruction)
+	s.nodes = make(map[string]int, len(ids))
+	for _, id := range ids {
+		s.nodes[id] = s.graph.insert(id, s.points[id].vec)
+	}
+}
+
+// compact writes the whole store as a new base segment, replacing the
+// segments before it, and rebuilds the graph without removed nodes
+func (s *LocalStore) compact() error {
+	base := &vectorSegment{Dims: s.dims, Points: make([]storedPoint, 0, len(s.points)), Count: len(s.points)}
+	for _, p := range s.points {
+		base.Points = append(base.Points, storedPoint{Chunk: *p.chunk, Vector: p.vec.f, Codes: p.vec.q, Scale: p.vec.scale})
+	}
+
+	size, err := s.log.Compact(base)
+	if err != nil {
+		return err
+	}
+	if s.graph != nil && s.graph.removed > 0 {
+		s.rebuildGraph()
+	}
+	logger.Debug("Compacted vector store", "dir", s.dir, "points", len(s.points), "bytes", size)
+	return nil
+}
@@ -0,0 +1,257 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Guru2308/rag-code/internal/domain"
+	"github.com/Guru2308/rag-code/internal/errors"
+)
+
+func localTestChunks() []*domain.CodeChunk {
+	return []*domain.CodeChunk{
+		{ID: "a1", FilePath: "auth/token.go", Language: "go", ChunkType: domain.ChunkTypeFunction, Generation: 1, Content: "func Refresh()", Embedding: []float32{1, 0, 0}, Metadata: map[string]string{"name": "Refresh"}},
+		{ID: "a2", FilePath: "auth/token.go", Language: "go", ChunkType: domain.ChunkTypeFunction, Generation: 1, Content: "func Validate()", Embedding: []float32{0.9, 0.1, 0}},
+		{ID: "p1", FilePath: "web/session.py", Language: "python", ChunkType: domain.ChunkTypeClass, Generation: 1, Content: "class Session:", Embedding: []float32{0.8, 0, 0.2}},
+		{ID: "c1", FilePath: "cache/cache.go", Language: "go", ChunkType: domain.ChunkTypeClass, Generation: 1, Content: "type Cache struct{}", Embedding: []float32{0, 0, 1}},
+	}
+}
+
+func newTestLocalStore(t *testing.T, dir string, cfg LocalConfig) *LocalStore {
+	t.Helper()
+	s, err := NewLocalStore(dir, cfg)
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	return s
+}
+
+func searchIDs(results []*domain.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, res := range results {
+		ids[i] = res.Chunk.ID
+	}
+	return ids
+}
+
+func TestLocalStore_StoreAndSearch(t *testing.T) {
+	ctx := context.Background()
+	for _, cfg := range []LocalConfig{
+		{Index: "flat"},
+		{Index: "hnsw"},
+		{Index: "flat", Quantization: "int8"},
+		{Index: "hnsw", Quantization: "int8"},
+	} {
+		t.Run(cfg.Index+"/"+cfg.Quantization, func(t *testing.T) {
+			s := newTestLocalStore(t, t.TempDir(), cfg)
+			if err := s.InitCollection(ctx, 3); err != nil {
+				t.Fatalf("InitCollection() error = %v", err)
+			}
+			if err := s.Store(ctx, localTestChunks()); err != nil {
+				t.Fatalf("Store() error = %v", err)
+			}
+
+			results, err := s.Search(ctx, []float32{2, 0, 0}, 3)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if ids := searchIDs(results); !reflect.DeepEqual(ids, []string{"a1", "a2", "p1"}) {
+				t.Errorf("Search() = %v, want a1, a2, p1", ids)
+			}
+			if score := results[0].Score; score < 0.99 || score > 1.01 {
+				t.Errorf("top score = %v, want the cosine similarity 1", score)
+			}
+			if results[0].Chunk.Embedding != nil || results[0].Chunk.Metadata["name"] != "Refresh" {
+				t.Errorf("result chunk = %+v, want the payload without the embedding", results[0].Chunk)
+			}
+
+			filtered, err := s.SearchFiltered(ctx, []float32{1, 0, 0}, 2, &domain.ChunkFilter{Language: "Go", ChunkType: domain.ChunkTypeClass})
+			if err != nil {
+				t.Fatalf("SearchFiltered() error = %v", err)
+			}
+			if ids := searchIDs(filtered); !reflect.DeepEqual(ids, []string{"c1"}) {
+				t.Errorf("SearchFiltered() = %v, want c1", ids)
+			}
+			filtered, _ = s.SearchFiltered(ctx, []float32{1, 0, 0}, 5, &domain.ChunkFilter{PathPrefix: "web/"})
+			if ids := searchIDs(filtered); !reflect.DeepEqual(ids, []string{"p1"}) {
+				t.Errorf("SearchFiltered() by path = %v, want p1", ids)
+			}
+		})
+	}
+}
+
+func TestLocalStore_CRUD(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocalStore(t, t.TempDir(), LocalConfig{Index: "hnsw"})
+	if err := s.Store(ctx, localTestChunks()); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	chunk, err := s.Get(ctx, "a1")
+	if err != nil || chunk.Content != "func Refresh()" {
+		t.Fatalf("Get(a1) = %+v, %v", chunk, err)
+	}
+	chunk.Metadata["name"] = "changed"
+	if again, _ := s.Get(ctx, "a1"); again.Metadata["name"] != "Refresh" {
+		t.Error("modifying a returned chunk changed the store")
+	}
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, errors.ErrorTypeNotFound) {
+		t.Errorf("Get(missing) error = %v, want not found", err)
+	}
+	batch, _ := s.GetBatch(ctx, []string{"p1", "missing", "a2"})
+	if len(batch) != 2 || batch[0].ID != "p1" || batch[1].ID != "a2" {
+		t.Errorf("GetBatch() returned %d chunks, want p1 and a2", len(batch))
+	}
+
+	// auth/token.go is reindexed as generation 2 with a single chunk
+	if err := s.Store(ctx, []*domain.CodeChunk{{ID: "a3", FilePath: "auth/token.go", Language: "go", Generation: 2, Embedding: []float32{1, 0, 0}}}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := s.DeleteStale(ctx, "auth/token.go", 2); err != nil {
+		t.Fatalf("DeleteStale() error = %v", err)
+	}
+	if err := s.RemoveChunks(ctx, []string{"c1", "c1", "missing"}); err != nil {
+		t.Fatalf("RemoveChunks() error = %v", err)
+	}
+	refs, _ := s.ListChunks(ctx)
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ID < refs[j].ID })
+	want := []domain.ChunkRef{{ID: "a3", FilePath: "auth/token.go", Generation: 2}, {ID: "p1", FilePath: "web/session.py", Generation: 1}}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("ListChunks() = %v, want %v", refs, want)
+	}
+	results, _ := s.Search(ctx, []float32{1, 0, 0}, 10)
+	if ids := searchIDs(results); !reflect.DeepEqual(ids, []string{"a3", "p1"}) {
+		t.Errorf("Search() after deletes = %v, want a3, p1", ids)
+	}
+
+	if err := s.Delete(ctx, "web/session.py"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	var scrolled []string
+	s.ScrollChunks(ctx, func(c *domain.CodeChunk) error {
+		scrolled = append(scrolled, c.ID)
+		return nil
+	})
+	if !reflect.DeepEqual(scrolled, []string{"a3"}) {
+		t.Errorf("ScrollChunks() = %v, want a3", scrolled)
+	}
+}
+
+func TestLocalStore_Dimensions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocalStore(t, t.TempDir(), LocalConfig{})
+	if err := s.InitCollection(ctx, 3); err != nil {
+		t.Fatalf("InitCollection() error = %v", err)
+	}
+	if err := s.Store(ctx, []*domain.CodeChunk{{ID: "x", Embedding: []float32{1, 0}}}); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("Store() with the wrong size error = %v, want a validation error", err)
+	}
+	if err := s.Store(ctx, []*domain.CodeChunk{{ID: "x"}}); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("Store() without an embedding error = %v, want a validation error", err)
+	}
+	if err := s.Store(ctx, localTestChunks()); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := s.InitCollection(ctx, 384); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("InitCollection() with another size error = %v, want a validation error", err)
+	}
+	if _, err := s.Search(ctx, []float32{1, 0}, 1); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("Search() with the wrong size error = %v, want a validation error", err)
+	}
+	if _, err := NewLocalStore(t.TempDir(), LocalConfig{Index: "ivf"}); !errors.Is(err, errors.ErrorTypeValidation) {
+		t.Errorf("NewLocalStore() with an unknown index error = %v, want a validation error", err)
+	}
+}
+
+func TestLocalStore_Persistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := newTestLocalStore(t, dir, LocalConfig{Index: "hnsw"})
+	s.log.SetMinCompact(0)
+	for _, c := range localTestChunks() {
+		if err := s.Store(ctx, []*domain.CodeChunk{c}); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	if err := s.RemoveChunks(ctx, []string{"a2"}); err != nil {
+		t.Fatalf("RemoveChunks() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 3 {
+		t.Errorf("%d segments left after compaction, want a base and few deltas", len(entries))
+	}
+
+	// Reopening with quantization converts the stored vectors
+	for _, cfg := range []LocalConfig{{Index: "hnsw"}, {Index: "flat", Quantization: "int8"}} {
+		reopened := newTestLocalStore(t, dir, cfg)
+		if reopened.dims != 3 || len(reopened.points) != 3 {
+			t.Fatalf("reopened store has %d points of %d dimensions, want 3 of 3", len(reopened.points), reopened.dims)
+		}
+		results, _ := reopened.Search(ctx, []float32{1, 0, 0}, 3)
+		if ids := searchIDs(results); !reflect.DeepEqual(ids, []string{"a1", "p1", "c1"}) {
+			t.Errorf("%s/%s: Search() after reopening = %v, want a1, p1, c1", cfg.Index, cfg.Quantization, ids)
+		}
+		if chunk, _ := reopened.Get(ctx, "p1"); chunk.Language != "python" || chunk.ChunkType != domain.ChunkTypeClass {
+			t.Errorf("reopened chunk = %+v, want its payload", chunk)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "99999999.delta"), []byte("not a segment"), 0o644)
+	if _, err := NewLocalStore(dir, LocalConfig{}); !errors.Is(err, errors.ErrorTypeInternal) {
+		t.Errorf("NewLocalStore() with a corrupt segment error = %v, want an internal error", err)
+	}
+}
+
+func TestLocalStore_HNSWRecall(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(7))
+	randomVector := func() []float32 {
+		v := make([]float32, 32)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		return v
+	}
+	chunks := make([]*domain.CodeChunk, 2000)
+	for i := range chunks {
+		chunks[i] = &domain.CodeChunk{ID: fmt.Sprintf("c%04d", i), Embedding: randomVector()}
+	}
+
+	flat := newTestLocalStore(t, t.TempDir(), LocalConfig{Index: "flat"})
+	graph := newTestLocalStore(t, t.TempDir(), LocalConfig{Index: "hnsw"})
+	quantized := newTestLocalStore(t, t.TempDir(), LocalConfig{Index: "hnsw", Quantization: "int8"})
+	for _, s := range []*LocalStore{flat, graph, quantized} {
+		if err := s.Store(ctx, chunks); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	const queries, k = 50, 10
+	hits := map[*LocalStore]int{}
+	for q := 0; q < queries; q++ {
+		query := randomVector()
+		exact, _ := flat.Search(ctx, query, k)
+		want := make(map[string]bool, k)
+		for _, res := range exact {
+			want[res.Chunk.ID] = true
+		}
+		for _, s := range []*LocalStore{graph, quantized} {
+			got, _ := s.Search(ctx, query, k)
+			for _, res := range got {
+				if want[res.Chunk.ID] {
+					hits[s]++
+				}
+			}
+		}
+	}
+	for name, s := range map[string]*LocalStore{"hnsw": graph, "hnsw+int8": quantized} {
+		if recall := float64(hits[s]) / (queries * k); recall < 0.9 {
+			t.Errorf("%s recall@%d = %.2f, want at least 0.9", name, k, recall)
+		}
+	}
+}
@@ -24,6 +24,7 @@ type QdrantClient interface {
 	Delete(ctx context.Context, in *qdrant.DeletePoints) (*qdrant.UpdateResult, error)
 	Get(ctx context.Context, in *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error)
 	Query(ctx context.Context, in *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error)
+	ScrollAndOffset(ctx context.Context, in *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error)
 	CollectionExists(ctx context.Context, collectionName string) (bool, error)
 	CreateCollection(ctx context.Context, in *qdrant.CreateCollection) error
 }
@@ -84,6 +85,8 @@ func (s *QdrantStore) Store(ctx context.Context, chunks []*domain.CodeChunk) err
 			"start_line": float64(chunk.StartLine),
 			"end_line":   float64(chunk.EndLine),
 			"content":    toValidUTF8(chunk.Content),
+			"generation": chunk.Generation,
+			"file_hash":  chunk.FileHash,
 		}
 
 		// Store dependencies
@@ -139,6 +142,123 @@ func (s *QdrantStore) Delete(ctx context.Context, filePath string) error {
 	return nil
 }
 
+// DeleteStale removes a file's chunks written by any generation other than
+// generation. Called after the new generation is upserted, so readers never
+// see the file without chunks. Points stored before generations existed have
+// no generation and are removed too.
+func (s *QdrantStore) DeleteStale(ctx context.Context, filePath string, generation int64) error {
+	return s.DeleteStaleFiles(ctx, []domain.StaleFile{{FilePath: filePath, Generation: generation}})
+}
+
+// DeleteStaleFiles removes the stale chunks of several files in one request
+func (s *QdrantStore) DeleteStaleFiles(ctx context.Context, files []domain.StaleFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	filter := staleFilter(files[0])
+	if len(files) > 1 {
+		conditions := make([]*qdrant.Condition, len(files))
+		for i, f := range files {
+			conditions[i] = qdrant.NewFilterAsCondition(staleFilter(f))
+		}
+		filter = &qdrant.Filter{Should: conditions}
+	}
+	_, err := s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.collection,
+		Points:         qdrant.NewPointsSelectorFilter(filter),
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to delete stale points from Qdrant")
+	}
+
+	logger.Debug("Deleted stale file chunks from Qdrant", "files", len(files))
+	return nil
+}
+
+// staleFilter matches the chunks of f.FilePath not written by f.Generation
+func staleFilter(f domain.StaleFile) *qdrant.Filter {
+	return &qdrant.Filter{
+		Must:    []*qdrant.Condition{qdrant.NewMatch("file_path", f.FilePath)},
+		MustNot: []*qdrant.Condition{qdrant.NewMatchInt("generation", f.Generation)},
+	}
+}
+
+// scrollPageSize is the number of points fetched per page when scrolling
+const scrollPageSize = 1000
+
+// ListChunks returns the ID, file, generation and file hash of every stored
+// chunk
+func (s *QdrantStore) ListChunks(ctx context.Context) ([]domain.ChunkRef, error) {
+	var refs []domain.ChunkRef
+	err := s.scroll(ctx, qdrant.NewWithPayloadInclude("file_path", "generation", "file_hash"), func(point *qdrant.RetrievedPoint) error {
+		refs = append(refs, domain.ChunkRef{
+			ID:         chunkID(point.Id),
+			FilePath:   point.Payload["file_path"].GetStringValue(),
+			Generation: point.Payload["generation"].GetIntegerValue(),
+			FileHash:   point.Payload["file_hash"].GetStringValue(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// ScrollChunks calls fn with every stored chunk, without its embedding,
+// stopping at the first error fn returns
+func (s *QdrantStore) ScrollChunks(ctx context.Context, fn func(*domain.CodeChunk) error) error {
+	return s.scroll(ctx, qdrant.NewWithPayload(true), func(point *qdrant.RetrievedPoint) error {
+		return fn(s.mapPointToChunk(point))
+	})
+}
+
+// scroll pages through every point in the collection
+func (s *QdrantStore) scroll(ctx context.Context, payload *qdrant.WithPayloadSelector, fn func(*qdrant.RetrievedPoint) error) error {
+	var offset *qdrant.PointId
+	for {
+		points, next, err := s.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: s.collection,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(scrollPageSize)),
+			WithPayload:    payload,
+		})
+		if err != nil {
+			return errors.Wrap(err, errors.ErrorTypeExternal, "failed to scroll Qdrant points")
+		}
+		for _, point := range points {
+			if err := fn(point); err != nil {
+				return err
+			}
+		}
+		if next == nil || len(points) == 0 {
+			return nil
+		}
+		offset = next
+	}
+}
+
+// RemoveChunks deletes chunks by ID
+func (s *QdrantStore) RemoveChunks(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pointIDs := make([]*qdrant.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = qdrant.NewID(id)
+	}
+	_, err := s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.collection,
+		Points:         qdrant.NewPointsSelector(pointIDs...),
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExternal, "failed to delete points from Qdrant")
+	}
+
+	logger.Info("Removed chunks from Qdrant", "count", len(ids))
+	return nil
+}
+
 // Get retrieves a single chunk by ID
 func (s *QdrantStore) Get(ctx context.Context, id string) (*domain.CodeChunk, error) {
 	resp, err := s.client.Get(ctx, &qdrant.GetPoints{
@@ -158,6 +278,32 @@ func (s *QdrantStore) Get(ctx context.Context, id string) (*domain.CodeChunk, er
 	return s.mapPointToChunk(point), nil
 }
 
+// GetBatch fetches several chunks in one request. IDs that are not stored
+// are left out of the result.
+func (s *QdrantStore) GetBatch(ctx context.Context, ids []string) ([]*domain.CodeChunk, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	pointIDs := make([]*qdrant.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = qdrant.NewID(id)
+	}
+	resp, err := s.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: s.collection,
+		Ids:            pointIDs,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExternal, "failed to get points from Qdrant")
+	}
+
+	chunks := make([]*domain.CodeChunk, len(resp))
+	for i, point := range resp {
+		chunks[i] = s.mapPointToChunk(point)
+	}
+	return chunks, nil
+}
+
 // Search performs a vector search in Qdrant
 func (s *QdrantStore) Search(ctx context.Context, queryVector []float32, limit int) ([]*domain.SearchResult, error) {
 	resp, err := s.client.Query(ctx, &qdrant.QueryPoints{
@@ -182,14 +328,21 @@ func (s *QdrantStore) Search(ctx context.Context, queryVector []float32, limit i
 	return results, nil
 }
 
+// chunkID returns the chunk ID of a point. Qdrant hands back the hex chunk
+// IDs it was given as hyphenated UUIDs; they are returned in the chunker's
+// form so they match the keyword index and the graph.
+func chunkID(id *qdrant.PointId) string {
+	return domain.CanonicalChunkID(id.GetUuid())
+}
+
 // mapPointToChunk converts a Qdrant RetrievedPoint to a CodeChunk
 func (s *QdrantStore) mapPointToChunk(point *qdrant.RetrievedPoint) *domain.CodeChunk {
-	return s.mapPayloadToChunk(point.Id.GetUuid(), point.Payload)
+	return s.mapPayloadToChunk(chunkID(point.Id), point.Payload)
 }
 
 // mapScoredPointToChunk converts a Qdrant ScoredPoint to a CodeChunk
 func (s *QdrantStore) mapScoredPointToChunk(point *qdrant.ScoredPoint) *domain.CodeChunk {
-	return s.mapPayloadToChunk(point.Id.GetUuid(), point.Payload)
+	return s.mapPayloadToChunk(chunkID(point.Id), point.Payload)
 }
 
 // mapPayloadToChunk helper to convert payload map to CodeChunk
@@ -202,6 +355,9 @@ func (s *QdrantStore) mapPayloadToChunk(id string, payload map[string]*qdrant.Va
 		ChunkType: domain.ChunkType(payload["chunk_type"].GetStringValue()),
 		StartLine: int(payload["start_line"].GetDoubleValue()),
 		EndLine:   int(payload["end_line"].GetDoubleValue()),
+		// Zero for points stored before generations existed
+		Generation: payload["generation"].GetIntegerValue(),
+		FileHash:   payload["file_hash"].GetStringValue(),
 	}
 
 	// Retrieve dependencies
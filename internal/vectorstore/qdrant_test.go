@@ -2,6 +2,7 @@ package vectorstore
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/Guru2308/rag-code/internal/domain"
@@ -14,6 +15,17 @@ func init() {
 	logger.Init(logger.Config{Level: logger.LevelDebug})
 }
 
+// Chunk IDs as the chunker generates them, and the hyphenated UUIDs Qdrant
+// returns for them
+const (
+	chunkA = "dfe7bd5d38ad3a3191dd4317ece83bba"
+	pointA = "dfe7bd5d-38ad-3a31-91dd-4317ece83bba"
+	chunkB = "8d1b564ddaf67b0cb21b01ad6a4eaea1"
+	pointB = "8d1b564d-daf6-7b0c-b21b-01ad6a4eaea1"
+	chunkC = "3b904b2af5e8f907f1d44ed5460ad69a"
+	pointC = "3b904b2a-f5e8-f907-f1d4-4ed5460ad69a"
+)
+
 func TestQdrantStore_Store(t *testing.T) {
 	mockClient := &mocks.MockQdrantClient{
 		UpsertFunc: func(ctx context.Context, in *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
@@ -31,7 +43,7 @@ func TestQdrantStore_Store(t *testing.T) {
 
 	chunks := []*domain.CodeChunk{
 		{
-			ID:        "uuid1",
+			ID:        chunkA,
 			FilePath:  "test.go",
 			Content:   "test content",
 			Embedding: []float32{0.1, 0.2, 0.3},
@@ -49,7 +61,7 @@ func TestQdrantStore_Get(t *testing.T) {
 		GetFunc: func(ctx context.Context, in *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
 			return []*qdrant.RetrievedPoint{
 				{
-					Id: qdrant.NewID("uuid1"),
+					Id: qdrant.NewID(pointA),
 					Payload: map[string]*qdrant.Value{
 						"file_path":  qdrant.NewValueString("test.go"),
 						"content":    qdrant.NewValueString("content"),
@@ -68,22 +80,52 @@ func TestQdrantStore_Get(t *testing.T) {
 		collection: "test",
 	}
 
-	chunk, err := store.Get(context.Background(), "uuid1")
+	chunk, err := store.Get(context.Background(), chunkA)
 	if err != nil {
 		t.Fatalf("Get failed: %v", err)
 	}
 
-	if chunk.ID != "uuid1" || chunk.FilePath != "test.go" || chunk.StartLine != 10 {
+	if chunk.ID != chunkA || chunk.FilePath != "test.go" || chunk.StartLine != 10 {
 		t.Errorf("Mismatched chunk data: %+v", chunk)
 	}
 }
 
+func TestQdrantStore_GetBatch(t *testing.T) {
+	calls := 0
+	mockClient := &mocks.MockQdrantClient{
+		GetFunc: func(ctx context.Context, in *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+			calls++
+			if len(in.Ids) != 3 {
+				t.Errorf("Expected 3 IDs in one request, got %d", len(in.Ids))
+			}
+			// chunkB is not stored
+			return []*qdrant.RetrievedPoint{
+				{Id: qdrant.NewID(pointC), Payload: map[string]*qdrant.Value{"file_path": qdrant.NewValueString("c.go")}},
+				{Id: qdrant.NewID(pointA), Payload: map[string]*qdrant.Value{"file_path": qdrant.NewValueString("a.go")}},
+			}, nil
+		},
+	}
+	store := &QdrantStore{client: mockClient, collection: "test"}
+
+	chunks, err := store.GetBatch(context.Background(), []string{chunkA, chunkB, chunkC})
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if calls != 1 || len(chunks) != 2 || chunks[0].ID != chunkC || chunks[1].ID != chunkA || chunks[1].FilePath != "a.go" {
+		t.Errorf("Unexpected chunks after %d calls: %+v", calls, chunks)
+	}
+
+	if chunks, err := store.GetBatch(context.Background(), nil); err != nil || chunks != nil || calls != 1 {
+		t.Errorf("Expected no request for no IDs, got %v, %v", chunks, err)
+	}
+}
+
 func TestQdrantStore_Search(t *testing.T) {
 	mockClient := &mocks.MockQdrantClient{
 		QueryFunc: func(ctx context.Context, in *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
 			return []*qdrant.ScoredPoint{
 				{
-					Id:    qdrant.NewID("uuid1"),
+					Id:    qdrant.NewID(pointA),
 					Score: 0.95,
 					Payload: map[string]*qdrant.Value{
 						"file_path": qdrant.NewValueString("test.go"),
@@ -107,8 +149,8 @@ func TestQdrantStore_Search(t *testing.T) {
 	if len(results) != 1 {
 		t.Errorf("Expected 1 result, got %d", len(results))
 	}
-	if results[0].Score != 0.95 {
-		t.Errorf("Expected score 0.95, got %f", results[0].Score)
+	if results[0].Score != 0.95 || results[0].Chunk.ID != chunkA {
+		t.Errorf("Expected %s with score 0.95, got %s with %f", chunkA, results[0].Chunk.ID, results[0].Score)
 	}
 }
 
@@ -130,6 +172,151 @@ func TestQdrantStore_Delete(t *testing.T) {
 	}
 }
 
+func TestQdrantStore_DeleteStale(t *testing.T) {
+	var filter *qdrant.Filter
+	mockClient := &mocks.MockQdrantClient{
+		DeleteFunc: func(ctx context.Context, in *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+			filter = in.Points.GetFilter()
+			return &qdrant.UpdateResult{}, nil
+		},
+	}
+
+	store := &QdrantStore{
+		client:     mockClient,
+		collection: "test",
+	}
+
+	if err := store.DeleteStale(context.Background(), "test.go", 42); err != nil {
+		t.Fatalf("DeleteStale failed: %v", err)
+	}
+	if filter == nil || len(filter.Must) != 1 || len(filter.MustNot) != 1 {
+		t.Fatalf("expected file_path must and generation must_not conditions, got %v", filter)
+	}
+	if got := filter.MustNot[0].GetField().GetMatch().GetInteger(); got != 42 {
+		t.Errorf("must_not generation = %d, want 42", got)
+	}
+}
+
+func TestQdrantStore_DeleteStaleFiles(t *testing.T) {
+	calls := 0
+	var filter *qdrant.Filter
+	mockClient := &mocks.MockQdrantClient{
+		DeleteFunc: func(ctx context.Context, in *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+			calls++
+			filter = in.Points.GetFilter()
+			return &qdrant.UpdateResult{}, nil
+		},
+	}
+	store := &QdrantStore{client: mockClient, collection: "test"}
+
+	files := []domain.StaleFile{{FilePath: "a.go", Generation: 2}, {FilePath: "b.go", Generation: 3}}
+	if err := store.DeleteStaleFiles(context.Background(), files); err != nil {
+		t.Fatalf("DeleteStaleFiles failed: %v", err)
+	}
+	if calls != 1 || filter == nil || len(filter.Should) != 2 {
+		t.Fatalf("expected one request matching either file, got %d calls with %v", calls, filter)
+	}
+	second := filter.Should[1].GetFilter()
+	if second.Must[0].GetField().GetMatch().GetKeyword() != "b.go" || second.MustNot[0].GetField().GetMatch().GetInteger() != 3 {
+		t.Errorf("second condition = %v, want b.go outside generation 3", second)
+	}
+
+	if err := store.DeleteStaleFiles(context.Background(), nil); err != nil || calls != 1 {
+		t.Errorf("DeleteStaleFiles(nil) = %v after %d calls, want no request", err, calls)
+	}
+}
+
+func TestQdrantStore_ListChunks(t *testing.T) {
+	pages := [][]*qdrant.RetrievedPoint{
+		{{
+			Id:      qdrant.NewID(pointA),
+			Payload: qdrant.NewValueMap(map[string]any{"file_path": "a.go", "generation": int64(3)}),
+		}},
+		{{
+			Id:      qdrant.NewID(pointB),
+			Payload: qdrant.NewValueMap(map[string]any{"file_path": "b.go", "generation": int64(4), "file_hash": "b1"}),
+		}},
+	}
+	calls := 0
+	mockClient := &mocks.MockQdrantClient{
+		ScrollAndOffsetFunc: func(ctx context.Context, in *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+			page := pages[calls]
+			calls++
+			if calls < len(pages) {
+				return page, qdrant.NewID("next"), nil
+			}
+			return page, nil, nil
+		},
+	}
+
+	store := &QdrantStore{client: mockClient, collection: "test"}
+	refs, err := store.ListChunks(context.Background())
+	if err != nil {
+		t.Fatalf("ListChunks failed: %v", err)
+	}
+	if calls != 2 || len(refs) != 2 {
+		t.Fatalf("expected 2 pages and 2 refs, got %d pages and %v", calls, refs)
+	}
+	if refs[0].ID != chunkA || refs[1].ID != chunkB || refs[1].FilePath != "b.go" || refs[1].Generation != 4 || refs[1].FileHash != "b1" {
+		t.Errorf("unexpected ref %+v", refs[1])
+	}
+}
+
+func TestQdrantStore_ScrollChunks(t *testing.T) {
+	mockClient := &mocks.MockQdrantClient{
+		ScrollAndOffsetFunc: func(ctx context.Context, in *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+			if !in.WithPayload.GetEnable() {
+				t.Error("expected the full payload to be requested")
+			}
+			return []*qdrant.RetrievedPoint{
+				{Id: qdrant.NewID(pointA), Payload: qdrant.NewValueMap(map[string]any{"file_path": "a.go", "language": "go", "chunk_type": "function"})},
+				{Id: qdrant.NewID(pointB), Payload: qdrant.NewValueMap(map[string]any{"file_path": "b.py", "language": "python"})},
+			}, nil, nil
+		},
+	}
+
+	store := &QdrantStore{client: mockClient, collection: "test"}
+	var chunks []*domain.CodeChunk
+	err := store.ScrollChunks(context.Background(), func(c *domain.CodeChunk) error {
+		chunks = append(chunks, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScrollChunks failed: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].ID != chunkA || chunks[0].Language != "go" || chunks[0].ChunkType != domain.ChunkTypeFunction || chunks[1].FilePath != "b.py" {
+		t.Errorf("unexpected chunks %+v", chunks)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = store.ScrollChunks(context.Background(), func(c *domain.CodeChunk) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected scrolling to stop at the callback error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestQdrantStore_RemoveChunks(t *testing.T) {
+	var deleted int
+	mockClient := &mocks.MockQdrantClient{
+		DeleteFunc: func(ctx context.Context, in *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+			deleted = len(in.Points.GetPoints().GetIds())
+			return &qdrant.UpdateResult{}, nil
+		},
+	}
+
+	store := &QdrantStore{client: mockClient, collection: "test"}
+	if err := store.RemoveChunks(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatalf("RemoveChunks failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d points, want 2", deleted)
+	}
+}
+
 func TestQdrantStore_InitCollection(t *testing.T) {
 	mockClient := &mocks.MockQdrantClient{
 		CollectionExistsFunc: func(ctx context.Context, collectionName string) (bool, error) {
@@ -0,0 +1,75 @@
+package vectorstore
+
+import "math"
+
+// vector is a unit-length embedding, held either as float32 or quantized to
+// int8 with a per-vector scale. Similarity is the dot product, which for
+// unit vectors is the cosine similarity Qdrant collections are created with.
+type vector struct {
+	f     []float32
+	q     []int8
+	scale float32 // q[i] * scale approximates the normalized component
+}
+
+// newVector normalizes v and, if quantize is set, stores it as int8
+func newVector(v []float32, quantize bool) *vector {
+	norm := 0.0
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		norm = 1
+	}
+	f := make([]float32, len(v))
+	maxAbs := float32(0)
+	for i, x := range v {
+		f[i] = float32(float64(x) / norm)
+		maxAbs = max(maxAbs, float32(math.Abs(float64(f[i]))))
+	}
+	if !quantize {
+		return &vector{f: f}
+	}
+
+	scale := maxAbs / 127
+	if scale == 0 {
+		scale = 1
+	}
+	q := make([]int8, len(f))
+	for i, x := range f {
+		q[i] = int8(math.Round(float64(x / scale)))
+	}
+	return &vector{q: q, scale: scale}
+}
+
+// floats returns the normalized components, dequantizing if needed
+func (v *vector) floats() []float32 {
+	if v.f != nil {
+		return v.f
+	}
+	f := make([]float32, len(v.q))
+	for i, x := range v.q {
+		f[i] = float32(x) * v.scale
+	}
+	return f
+}
+
+// similarity is the dot product of v with a normalized query
+func (v *vector) similarity(query []float32) float32 {
+	var sum float32
+	if v.f != nil {
+		for i, x := range v.f {
+			sum += x * query[i]
+		}
+		return sum
+	}
+	for i, x := range v.q {
+		sum += float32(x) * query[i]
+	}
+	return sum * v.scale
+}
+
+// normalize returns q scaled to unit length
+func normalize(q []float32) []float32 {
+	return newVector(q, false).f
+}
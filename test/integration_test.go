@@ -30,6 +30,7 @@ import (
 )
 
 // Integration tests require: docker-compose up (Qdrant + Redis) and Ollama running.
+// The local variant only needs Ollama.
 // Run with: go test -tags=integration ./test/...
 
 func init() {
@@ -63,26 +64,63 @@ func TestIntegration_IndexAndQuery(t *testing.T) {
 		t.Skipf("Redis unavailable: %v", err)
 	}
 
+	runIndexAndQuery(t, cfg, qStore, retrieval.NewRedisIndex(redisClient, "rag:integration:"))
+}
+
+// TestIntegration_IndexAndQuery_Local runs the same flow on the embedded
+// vector store and keyword index, so only Ollama is needed
+func TestIntegration_IndexAndQuery_Local(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Skipf("Config load failed (missing .env?): %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	embedder := embeddings.NewOllamaEmbedder(cfg.OllamaURL, cfg.EmbeddingModel)
+	if _, err := embedder.Embed(ctx, "ping"); err != nil {
+		t.Skipf("Ollama unavailable: %v", err)
+	}
+
+	dir := t.TempDir()
+	store, err := vectorstore.NewLocalStore(filepath.Join(dir, "vectors"), vectorstore.LocalConfig{Index: "hnsw"})
+	if err != nil {
+		t.Fatalf("Local vector store: %v", err)
+	}
+	if err := store.InitCollection(ctx, 384); err != nil {
+		t.Fatalf("Local vector store init: %v", err)
+	}
+	keyword, err := retrieval.NewLocalIndex(filepath.Join(dir, "keywords"))
+	if err != nil {
+		t.Fatalf("Local keyword index: %v", err)
+	}
+	runIndexAndQuery(t, cfg, store, keyword)
+}
+
+// runIndexAndQuery indexes this codebase through the API into store and
+// keyword, then queries it
+func runIndexAndQuery(t *testing.T, cfg *config.Config, store vectorstore.Store, keyword retrieval.KeywordIndex) {
+	t.Helper()
+
 	// Build pipeline
 	embedder := embeddings.NewOllamaEmbedder(cfg.OllamaURL, cfg.EmbeddingModel)
 	llmClient := llm.NewOllamaLLM(cfg.OllamaURL, cfg.LLMModel)
-	redisIndex := retrieval.NewRedisIndex(redisClient, "rag:integration:")
-	bm25Scorer := retrieval.NewBM25Scorer(cfg.BM25K1, cfg.BM25B, redisIndex)
+	bm25Scorer := retrieval.NewBM25Scorer(cfg.BM25K1, cfg.BM25B, keyword)
 	preprocessor := retrieval.NewQueryPreprocessor()
 	depGraph := graph.NewGraph()
-	expander := retrieval.NewContextExpander(depGraph, qStore)
+	expander := retrieval.NewContextExpander(depGraph, store)
 	reRanker := reranker.NewHeuristicReranker()
 	hierFilter := hierarchy.NewHierarchicalFilter(3)
 	fusionConfig := retrieval.DefaultFusionConfig()
 
 	retriever := retrieval.NewRetriever(
-		embedder, qStore, redisIndex, bm25Scorer,
+		embedder, store, keyword, bm25Scorer,
 		preprocessor, expander, reRanker, hierFilter, fusionConfig,
 	)
 
 	parser := indexing.NewMultiParser()
 	chunker := indexing.NewSemanticChunker(cfg.MaxChunkSize, cfg.ChunkOverlap)
-	indexer := indexing.NewIndexer(parser, chunker, embedder, qStore, retriever, depGraph, 2)
+	indexer := indexing.NewIndexer(parser, chunker, embedder, store, retriever, depGraph, 2)
 
 	prompter, err := prompt.NewTemplateGenerator("", prompt.WithMaxTokens(4096))
 	if err != nil {